@@ -84,7 +84,18 @@ func (mw *traceMiddleware) startTraceMiddleware(stack *middleware.Stack) error {
 		if !math.IsNaN(mw.cfg.analyticsRate) {
 			opts = append(opts, tracer.Tag(ext.EventSampleRate, mw.cfg.analyticsRate))
 		}
+		propagateSQS := mw.cfg.sqsPropagation && serviceID == "SQS"
+		propagateSNS := mw.cfg.snsPropagation && serviceID == "SNS"
+		if (propagateSQS && isSQSSend(operation)) || (propagateSNS && isSNSPublish(operation)) {
+			opts = append(opts, tracer.Tag(ext.SpanKind, ext.SpanKindProducer))
+		}
 		span, spanctx := tracer.StartSpanFromContext(ctx, fmt.Sprintf("%s.request", serviceID), opts...)
+		if propagateSQS {
+			in.Parameters = injectSQS(span.Context(), in.Parameters)
+		}
+		if propagateSNS {
+			in.Parameters = injectSNS(span.Context(), in.Parameters)
+		}
 
 		// Handle initialize and continue through the middleware chain.
 		out, metadata, err = next.HandleInitialize(spanctx, in)
@@ -9,6 +9,7 @@ import (
 	"context"
 	"log"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	awscfg "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 
@@ -26,3 +27,27 @@ func Example() {
 	sqsClient := sqs.NewFromConfig(awsCfg)
 	sqsClient.ListQueues(context.Background(), &sqs.ListQueuesInput{})
 }
+
+func ExampleStartSQSConsumerSpan() {
+	awsCfg, err := awscfg.LoadDefaultConfig(context.Background())
+	if err != nil {
+		log.Fatalf(err.Error())
+	}
+
+	// propagate the trace context through the messages sent and received
+	awstrace.AppendMiddleware(&awsCfg, awstrace.WithSQSPropagation())
+
+	sqsClient := sqs.NewFromConfig(awsCfg)
+	out, err := sqsClient.ReceiveMessage(context.Background(), &sqs.ReceiveMessageInput{
+		QueueUrl: aws.String("https://sqs.eu-west-1.amazonaws.com/123456789012/orders"),
+	})
+	if err != nil {
+		log.Fatalf(err.Error())
+	}
+	for _, msg := range out.Messages {
+		span, ctx := awstrace.StartSQSConsumerSpan(context.Background(), msg)
+		// process the message using ctx
+		_ = ctx
+		span.Finish()
+	}
+}
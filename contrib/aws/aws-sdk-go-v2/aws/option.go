@@ -12,8 +12,10 @@ import (
 )
 
 type config struct {
-	serviceName   string
-	analyticsRate float64
+	serviceName    string
+	analyticsRate  float64
+	sqsPropagation bool
+	snsPropagation bool
 }
 
 // Option represents an option that can be passed to Dial.
@@ -58,3 +60,25 @@ func WithAnalyticsRate(rate float64) Option {
 		}
 	}
 }
+
+// WithSQSPropagation enables propagating the trace context through SQS messages. The
+// context of the span of SendMessage and SendMessageBatch calls, whose kind is set to
+// producer, is injected in the "_datadog" attribute of the messages sent, unless they
+// already have the maximum of 10 attributes. ReceiveMessage calls request that attribute,
+// such that consumers can continue the trace using StartSQSConsumerSpan.
+func WithSQSPropagation() Option {
+	return func(cfg *config) {
+		cfg.sqsPropagation = true
+	}
+}
+
+// WithSNSPropagation enables propagating the trace context through SNS messages. The
+// context of the span of Publish calls, whose kind is set to producer, is injected in the
+// "_datadog" attribute of the message published, unless it already has the maximum of 10
+// attributes. SQS consumers of the topic can continue the trace using StartSQSConsumerSpan,
+// whether the messages are delivered raw or wrapped in the SNS notification envelope.
+func WithSNSPropagation() Option {
+	return func(cfg *config) {
+		cfg.snsPropagation = true
+	}
+}
@@ -0,0 +1,59 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package aws
+
+import (
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+)
+
+// snsMaxAttributes is the maximum number of attributes of an SNS message which can be
+// delivered to SQS subscribers.
+const snsMaxAttributes = sqsMaxAttributes
+
+// isSNSPublish reports whether the given SNS operation publishes messages.
+func isSNSPublish(operation string) bool {
+	return operation == "Publish"
+}
+
+// injectSNS returns a copy of the parameters of an SNS operation in which the message
+// published carries the given span context in its attributes. The given parameters are
+// returned unchanged for other operations.
+func injectSNS(sctx ddtrace.SpanContext, params interface{}) interface{} {
+	in, ok := params.(*sns.PublishInput)
+	if !ok {
+		return params
+	}
+	cp := *in
+	cp.MessageAttributes = injectSNSAttributes(sctx, in.MessageAttributes)
+	return &cp
+}
+
+// injectSNSAttributes returns a copy of the given message attributes holding the span
+// context. The attributes are returned unchanged if they are already at the maximum.
+func injectSNSAttributes(sctx ddtrace.SpanContext, attrs map[string]snstypes.MessageAttributeValue) map[string]snstypes.MessageAttributeValue {
+	if len(attrs) >= snsMaxAttributes {
+		log.Debug("contrib/aws/aws-sdk-go-v2/aws: message has %d attributes, not injecting the trace context", len(attrs))
+		return attrs
+	}
+	v, ok := encodeTraceContext(sctx)
+	if !ok {
+		return attrs
+	}
+	cp := make(map[string]snstypes.MessageAttributeValue, len(attrs)+1)
+	for k, v := range attrs {
+		cp[k] = v
+	}
+	cp[sqsAttributeName] = snstypes.MessageAttributeValue{
+		DataType:    aws.String("String"),
+		StringValue: aws.String(v),
+	}
+	return cp
+}
@@ -0,0 +1,166 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSNSTestClient returns an SNS client sending its requests to a server which
+// records their form values into the returned channel.
+func newSNSTestClient(t *testing.T, opts ...Option) (*sns.Client, <-chan url.Values) {
+	forms := make(chan url.Values, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		forms <- r.PostForm
+		w.Header().Set("X-Amz-RequestId", "test_req")
+		w.WriteHeader(200)
+	}))
+	t.Cleanup(server.Close)
+	awsCfg := aws.Config{
+		Region:      "eu-west-1",
+		Credentials: aws.AnonymousCredentials{},
+		EndpointResolver: aws.EndpointResolverFunc(func(service, region string) (aws.Endpoint, error) {
+			return aws.Endpoint{
+				PartitionID:   "aws",
+				URL:           server.URL,
+				SigningRegion: "eu-west-1",
+			}, nil
+		}),
+	}
+	AppendMiddleware(&awsCfg, opts...)
+	return sns.NewFromConfig(awsCfg), forms
+}
+
+// publishedAttributes returns the message attributes found in the form of a Publish
+// request, as they are delivered to SQS subscribers.
+func publishedAttributes(form url.Values, prefix string) map[string]types.MessageAttributeValue {
+	attrs := make(map[string]types.MessageAttributeValue)
+	for i := 1; form.Get(prefix+"MessageAttributes.entry."+strconv.Itoa(i)+".Name") != ""; i++ {
+		p := prefix + "MessageAttributes.entry." + strconv.Itoa(i)
+		attrs[form.Get(p+".Name")] = types.MessageAttributeValue{
+			DataType:    aws.String(form.Get(p + ".Value.DataType")),
+			StringValue: aws.String(form.Get(p + ".Value.StringValue")),
+		}
+	}
+	return attrs
+}
+
+func TestSNSPropagation(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	client, forms := newSNSTestClient(t, WithSNSPropagation())
+	in := &sns.PublishInput{
+		TopicArn: aws.String("arn:aws:sns:eu-west-1:123:orders"),
+		Message:  aws.String("hello"),
+		MessageAttributes: map[string]snstypes.MessageAttributeValue{
+			"tenant": {DataType: aws.String("String"), StringValue: aws.String("acme")},
+		},
+	}
+	client.Publish(context.Background(), in)
+	assert.Len(t, in.MessageAttributes, 1, "the input of the caller is not modified")
+
+	spans := mt.FinishedSpans()
+	require.Len(t, spans, 1)
+	producer := spans[0]
+	assert.Equal(t, "SNS.Publish", producer.Tag(ext.ResourceName))
+	assert.Equal(t, ext.SpanKindProducer, producer.Tag(ext.SpanKind))
+
+	attrs := publishedAttributes(<-forms, "")
+	require.Len(t, attrs, 2)
+	assert.Equal(t, "acme", *attrs["tenant"].StringValue)
+	assert.Equal(t, "String", *attrs[sqsAttributeName].DataType)
+
+	// SQS subscribers continue the trace from the message attributes
+	sctx, err := ExtractSQSMessage(types.Message{MessageAttributes: attrs})
+	require.NoError(t, err)
+	assert.Equal(t, producer.TraceID(), sctx.TraceID())
+	assert.Equal(t, producer.SpanID(), sctx.SpanID())
+}
+
+func TestSNSPropagationMaxAttributes(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	client, forms := newSNSTestClient(t, WithSNSPropagation())
+	full := make(map[string]snstypes.MessageAttributeValue)
+	for i := 0; i < snsMaxAttributes; i++ {
+		full["attr"+strconv.Itoa(i)] = snstypes.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String("v")}
+	}
+	client.Publish(context.Background(), &sns.PublishInput{
+		TopicArn:          aws.String("arn:aws:sns:eu-west-1:123:orders"),
+		Message:           aws.String("hello"),
+		MessageAttributes: full,
+	})
+	// messages already having the maximum number of attributes are left untouched
+	attrs := publishedAttributes(<-forms, "")
+	assert.Len(t, attrs, snsMaxAttributes)
+	assert.NotContains(t, attrs, sqsAttributeName)
+	assert.Equal(t, ext.SpanKindProducer, mt.FinishedSpans()[0].Tag(ext.SpanKind))
+}
+
+func TestSNSPropagationEnvelope(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	client, forms := newSNSTestClient(t, WithSNSPropagation())
+	client.Publish(context.Background(), &sns.PublishInput{
+		TopicArn: aws.String("arn:aws:sns:eu-west-1:123:orders"),
+		Message:  aws.String("hello"),
+	})
+	producer := mt.FinishedSpans()[0]
+	attr := publishedAttributes(<-forms, "")[sqsAttributeName]
+
+	// without raw message delivery, SQS subscribers receive the attributes in the body
+	body, err := json.Marshal(map[string]interface{}{
+		"Type":     "Notification",
+		"TopicArn": "arn:aws:sns:eu-west-1:123:orders",
+		"Message":  "hello",
+		"MessageAttributes": map[string]interface{}{
+			sqsAttributeName: map[string]string{"Type": "String", "Value": *attr.StringValue},
+		},
+	})
+	require.NoError(t, err)
+	sctx, err := ExtractSQSMessage(types.Message{Body: aws.String(string(body))})
+	require.NoError(t, err)
+	assert.Equal(t, producer.TraceID(), sctx.TraceID())
+	assert.Equal(t, producer.SpanID(), sctx.SpanID())
+
+	// other bodies carry no span context
+	_, err = ExtractSQSMessage(types.Message{Body: aws.String(`{"_datadog": "hello"}`)})
+	assert.Equal(t, tracer.ErrSpanContextNotFound, err)
+}
+
+func TestSNSPropagationDisabled(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	client, forms := newSNSTestClient(t, WithSQSPropagation())
+	client.Publish(context.Background(), &sns.PublishInput{
+		TopicArn: aws.String("arn:aws:sns:eu-west-1:123:orders"),
+		Message:  aws.String("hello"),
+	})
+	assert.Empty(t, publishedAttributes(<-forms, ""))
+	assert.Equal(t, ext.SpanKindClient, mt.FinishedSpans()[0].Tag(ext.SpanKind))
+}
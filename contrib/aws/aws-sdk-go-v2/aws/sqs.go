@@ -0,0 +1,178 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+const (
+	// sqsAttributeName is the name of the message attribute holding the trace context.
+	sqsAttributeName = "_datadog"
+	// sqsMaxAttributes is the maximum number of attributes of an SQS message.
+	sqsMaxAttributes = 10
+
+	tagSQSMessageID = "aws.sqs.message_id"
+)
+
+// isSQSSend reports whether the given SQS operation sends messages.
+func isSQSSend(operation string) bool {
+	return operation == "SendMessage" || operation == "SendMessageBatch"
+}
+
+// injectSQS returns a copy of the parameters of an SQS operation in which the messages
+// sent carry the given span context in their attributes, and in which received messages
+// are requested along with the attribute holding it. The given parameters are returned
+// unchanged for other operations.
+func injectSQS(sctx ddtrace.SpanContext, params interface{}) interface{} {
+	switch in := params.(type) {
+	case *sqs.SendMessageInput:
+		cp := *in
+		cp.MessageAttributes = injectSQSAttributes(sctx, in.MessageAttributes)
+		return &cp
+	case *sqs.SendMessageBatchInput:
+		cp := *in
+		cp.Entries = make([]types.SendMessageBatchRequestEntry, len(in.Entries))
+		for i, e := range in.Entries {
+			e.MessageAttributes = injectSQSAttributes(sctx, e.MessageAttributes)
+			cp.Entries[i] = e
+		}
+		return &cp
+	case *sqs.ReceiveMessageInput:
+		for _, name := range in.MessageAttributeNames {
+			if name == sqsAttributeName || name == "All" || name == ".*" {
+				return in
+			}
+		}
+		cp := *in
+		cp.MessageAttributeNames = append(append([]string(nil), in.MessageAttributeNames...), sqsAttributeName)
+		return &cp
+	}
+	return params
+}
+
+// injectSQSAttributes returns a copy of the given message attributes holding the span
+// context. The attributes are returned unchanged if they are already at the maximum.
+func injectSQSAttributes(sctx ddtrace.SpanContext, attrs map[string]types.MessageAttributeValue) map[string]types.MessageAttributeValue {
+	if len(attrs) >= sqsMaxAttributes {
+		log.Debug("contrib/aws/aws-sdk-go-v2/aws: message has %d attributes, not injecting the trace context", len(attrs))
+		return attrs
+	}
+	v, ok := encodeTraceContext(sctx)
+	if !ok {
+		return attrs
+	}
+	cp := make(map[string]types.MessageAttributeValue, len(attrs)+1)
+	for k, v := range attrs {
+		cp[k] = v
+	}
+	cp[sqsAttributeName] = types.MessageAttributeValue{
+		DataType:    aws.String("String"),
+		StringValue: aws.String(v),
+	}
+	return cp
+}
+
+// encodeTraceContext returns the given span context encoded as the JSON value of a message
+// attribute. It reports false if the context could not be encoded.
+func encodeTraceContext(sctx ddtrace.SpanContext) (string, bool) {
+	carrier := tracer.TextMapCarrier{}
+	if err := tracer.Inject(sctx, carrier); err != nil {
+		log.Debug("contrib/aws/aws-sdk-go-v2/aws: failed to inject the trace context: %v", err)
+		return "", false
+	}
+	b, err := json.Marshal(carrier)
+	if err != nil {
+		log.Debug("contrib/aws/aws-sdk-go-v2/aws: failed to encode the trace context: %v", err)
+		return "", false
+	}
+	return string(b), true
+}
+
+// snsNotification is the envelope of the SNS messages delivered to SQS queues without raw
+// message delivery, holding the attributes of the message published in its body.
+type snsNotification struct {
+	Type              string `json:"Type"`
+	MessageAttributes map[string]struct {
+		Type  string `json:"Type"`
+		Value string `json:"Value"`
+	} `json:"MessageAttributes"`
+}
+
+// ExtractSQSMessage returns the span context propagated in the attributes of the given
+// message, as sent by a client configured using WithSQSPropagation, or published to SNS by
+// a client configured using WithSNSPropagation. It returns tracer.ErrSpanContextNotFound if
+// the message carries no span context.
+func ExtractSQSMessage(msg types.Message) (ddtrace.SpanContext, error) {
+	var value string
+	if attr, ok := msg.MessageAttributes[sqsAttributeName]; ok && attr.StringValue != nil {
+		value = *attr.StringValue
+	} else if v, ok := snsAttribute(msg); ok {
+		value = v
+	} else {
+		return nil, tracer.ErrSpanContextNotFound
+	}
+	var carrier tracer.TextMapCarrier
+	if err := json.Unmarshal([]byte(value), &carrier); err != nil {
+		return nil, tracer.ErrSpanContextCorrupted
+	}
+	return tracer.Extract(carrier)
+}
+
+// snsAttribute returns the value of the attribute holding the trace context of the SNS
+// message wrapped in the body of msg, if any.
+func snsAttribute(msg types.Message) (string, bool) {
+	if msg.Body == nil || !strings.Contains(*msg.Body, sqsAttributeName) {
+		return "", false
+	}
+	var n snsNotification
+	if err := json.Unmarshal([]byte(*msg.Body), &n); err != nil || n.Type != "Notification" {
+		return "", false
+	}
+	attr, ok := n.MessageAttributes[sqsAttributeName]
+	if !ok || attr.Type != "String" {
+		return "", false
+	}
+	return attr.Value, true
+}
+
+// StartSQSConsumerSpan starts a span for the processing of the given message, received
+// from SQS. The span continues the trace of the span which sent the message, if it was
+// propagated in its attributes (see WithSQSPropagation), and is returned along with a
+// context holding it. The message must be received with the "_datadog" message attribute,
+// which is requested automatically by clients configured using WithSQSPropagation.
+func StartSQSConsumerSpan(ctx context.Context, msg types.Message, opts ...ddtrace.StartSpanOption) (ddtrace.Span, context.Context) {
+	spanOpts := []ddtrace.StartSpanOption{
+		tracer.SpanType(ext.SpanTypeMessageConsumer),
+		tracer.ServiceName("aws.SQS"),
+		tracer.ResourceName("SQS.ProcessMessage"),
+		tracer.Tag(tagAWSService, "SQS"),
+		tracer.Tag(ext.Component, "aws/aws-sdk-go-v2/aws"),
+		tracer.Tag(ext.SpanKind, ext.SpanKindConsumer),
+	}
+	if msg.MessageId != nil {
+		spanOpts = append(spanOpts, tracer.Tag(tagSQSMessageID, *msg.MessageId))
+	}
+	sctx, err := ExtractSQSMessage(msg)
+	if err != nil {
+		return tracer.StartSpanFromContext(ctx, "SQS.process", append(spanOpts, opts...)...)
+	}
+	// the span continues the trace of the producer rather than the one of the span of ctx,
+	// which StartSpanFromContext would use as its parent.
+	span := tracer.StartSpan("SQS.process", append(append(spanOpts, tracer.ChildOf(sctx)), opts...)...)
+	return span, tracer.ContextWithSpan(ctx, span)
+}
@@ -0,0 +1,218 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package aws
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSQSTestClient returns an SQS client sending its requests to a server which
+// records their form values into the returned channel.
+func newSQSTestClient(t *testing.T, opts ...Option) (*sqs.Client, <-chan url.Values) {
+	forms := make(chan url.Values, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		forms <- r.PostForm
+		w.Header().Set("X-Amz-RequestId", "test_req")
+		w.WriteHeader(200)
+		w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(server.Close)
+	awsCfg := aws.Config{
+		Region:      "eu-west-1",
+		Credentials: aws.AnonymousCredentials{},
+		EndpointResolver: aws.EndpointResolverFunc(func(service, region string) (aws.Endpoint, error) {
+			return aws.Endpoint{
+				PartitionID:   "aws",
+				URL:           server.URL,
+				SigningRegion: "eu-west-1",
+			}, nil
+		}),
+	}
+	AppendMiddleware(&awsCfg, opts...)
+	return sqs.NewFromConfig(awsCfg), forms
+}
+
+// sentAttributes returns the message attributes found in the form of a SendMessage request.
+func sentAttributes(form url.Values, prefix string) map[string]types.MessageAttributeValue {
+	attrs := make(map[string]types.MessageAttributeValue)
+	for i := 1; form.Get(prefix+"MessageAttribute."+strconv.Itoa(i)+".Name") != ""; i++ {
+		p := prefix + "MessageAttribute." + strconv.Itoa(i)
+		attrs[form.Get(p+".Name")] = types.MessageAttributeValue{
+			DataType:    aws.String(form.Get(p + ".Value.DataType")),
+			StringValue: aws.String(form.Get(p + ".Value.StringValue")),
+		}
+	}
+	return attrs
+}
+
+func TestSQSPropagation(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	client, forms := newSQSTestClient(t, WithSQSPropagation())
+	in := &sqs.SendMessageInput{
+		QueueUrl:    aws.String("https://sqs.eu-west-1.amazonaws.com/123/orders"),
+		MessageBody: aws.String("hello"),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"tenant": {DataType: aws.String("String"), StringValue: aws.String("acme")},
+		},
+	}
+	client.SendMessage(context.Background(), in)
+	assert.Len(t, in.MessageAttributes, 1, "the input of the caller is not modified")
+
+	spans := mt.FinishedSpans()
+	require.Len(t, spans, 1)
+	producer := spans[0]
+	assert.Equal(t, ext.SpanKindProducer, producer.Tag(ext.SpanKind))
+
+	attrs := sentAttributes(<-forms, "")
+	require.Len(t, attrs, 2)
+	assert.Equal(t, "acme", *attrs["tenant"].StringValue)
+	assert.Equal(t, "String", *attrs[sqsAttributeName].DataType)
+
+	// the consumer continues the trace from the message attributes
+	mt.Reset()
+	msg := types.Message{MessageId: aws.String("msg-1"), MessageAttributes: attrs}
+	sctx, err := ExtractSQSMessage(msg)
+	require.NoError(t, err)
+	assert.Equal(t, producer.TraceID(), sctx.TraceID())
+	assert.Equal(t, producer.SpanID(), sctx.SpanID())
+
+	span, ctx := StartSQSConsumerSpan(context.Background(), msg)
+	child, _ := tracer.StartSpanFromContext(ctx, "process.order")
+	child.Finish()
+	span.Finish()
+	spans = mt.FinishedSpans()
+	require.Len(t, spans, 2)
+	consumer := spans[1]
+	assert.Equal(t, "SQS.process", consumer.OperationName())
+	assert.Equal(t, ext.SpanKindConsumer, consumer.Tag(ext.SpanKind))
+	assert.Equal(t, ext.SpanTypeMessageConsumer, consumer.Tag(ext.SpanType))
+	assert.Equal(t, "msg-1", consumer.Tag(tagSQSMessageID))
+	assert.Equal(t, producer.TraceID(), consumer.TraceID())
+	assert.Equal(t, producer.SpanID(), consumer.ParentID())
+	assert.Equal(t, consumer.SpanID(), spans[0].ParentID())
+}
+
+func TestStartSQSConsumerSpanActiveSpan(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	producer := tracer.StartSpan("producer")
+	producer.Finish()
+	v, ok := encodeTraceContext(producer.Context())
+	require.True(t, ok)
+	msg := types.Message{
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			sqsAttributeName: {DataType: aws.String("String"), StringValue: aws.String(v)},
+		},
+	}
+
+	// the consumer runs within a span, e.g. the one of a polling loop
+	poll, ctx := tracer.StartSpanFromContext(context.Background(), "poll")
+	span, ctx := StartSQSConsumerSpan(ctx, msg)
+	current, ok := tracer.SpanFromContext(ctx)
+	require.True(t, ok)
+	assert.Equal(t, span, current)
+	span.Finish()
+	poll.Finish()
+
+	consumer := mt.FinishedSpans()[1]
+	assert.Equal(t, "SQS.process", consumer.OperationName())
+	assert.Equal(t, producer.Context().TraceID(), consumer.TraceID())
+	assert.Equal(t, producer.Context().SpanID(), consumer.ParentID())
+
+	// without a span context in the message, the span of ctx is its parent
+	span, _ = StartSQSConsumerSpan(ctx, types.Message{})
+	span.Finish()
+	assert.Equal(t, consumer.SpanID(), mt.FinishedSpans()[3].ParentID())
+}
+
+func TestSQSPropagationBatch(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	client, forms := newSQSTestClient(t, WithSQSPropagation())
+	full := make(map[string]types.MessageAttributeValue)
+	for i := 0; i < sqsMaxAttributes; i++ {
+		full["attr"+strconv.Itoa(i)] = types.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String("v")}
+	}
+	client.SendMessageBatch(context.Background(), &sqs.SendMessageBatchInput{
+		QueueUrl: aws.String("https://sqs.eu-west-1.amazonaws.com/123/orders"),
+		Entries: []types.SendMessageBatchRequestEntry{
+			{Id: aws.String("1"), MessageBody: aws.String("one")},
+			{Id: aws.String("2"), MessageBody: aws.String("two"), MessageAttributes: full},
+		},
+	})
+	form := <-forms
+	first := sentAttributes(form, "SendMessageBatchRequestEntry.1.")
+	require.Contains(t, first, sqsAttributeName)
+	sctx, err := ExtractSQSMessage(types.Message{MessageAttributes: first})
+	require.NoError(t, err)
+	assert.Equal(t, mt.FinishedSpans()[0].SpanID(), sctx.SpanID())
+	// messages already having the maximum number of attributes are left untouched
+	second := sentAttributes(form, "SendMessageBatchRequestEntry.2.")
+	assert.Len(t, second, sqsMaxAttributes)
+	assert.NotContains(t, second, sqsAttributeName)
+}
+
+func TestSQSPropagationReceive(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	client, forms := newSQSTestClient(t, WithSQSPropagation())
+	client.ReceiveMessage(context.Background(), &sqs.ReceiveMessageInput{
+		QueueUrl:              aws.String("https://sqs.eu-west-1.amazonaws.com/123/orders"),
+		MessageAttributeNames: []string{"tenant"},
+	})
+	form := <-forms
+	assert.Equal(t, "tenant", form.Get("MessageAttributeName.1"))
+	assert.Equal(t, sqsAttributeName, form.Get("MessageAttributeName.2"))
+	assert.Equal(t, ext.SpanKindClient, mt.FinishedSpans()[0].Tag(ext.SpanKind))
+
+	client.ReceiveMessage(context.Background(), &sqs.ReceiveMessageInput{
+		QueueUrl:              aws.String("https://sqs.eu-west-1.amazonaws.com/123/orders"),
+		MessageAttributeNames: []string{"All"},
+	})
+	form = <-forms
+	assert.Equal(t, "All", form.Get("MessageAttributeName.1"))
+	assert.Empty(t, form.Get("MessageAttributeName.2"))
+}
+
+func TestSQSPropagationDisabled(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	client, forms := newSQSTestClient(t)
+	client.SendMessage(context.Background(), &sqs.SendMessageInput{
+		QueueUrl:    aws.String("https://sqs.eu-west-1.amazonaws.com/123/orders"),
+		MessageBody: aws.String("hello"),
+	})
+	assert.Empty(t, sentAttributes(<-forms, ""))
+	assert.Equal(t, ext.SpanKindClient, mt.FinishedSpans()[0].Tag(ext.SpanKind))
+
+	_, err := ExtractSQSMessage(types.Message{})
+	assert.Equal(t, tracer.ErrSpanContextNotFound, err)
+	span, _ := StartSQSConsumerSpan(context.Background(), types.Message{})
+	span.Finish()
+	assert.Zero(t, mt.FinishedSpans()[1].ParentID())
+}
@@ -0,0 +1,72 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+// keyArgTypes is the tag holding the comma-separated Go types of the query arguments.
+const keyArgTypes = "sql.args.types"
+
+// argTypes returns the comma-separated list of the Go types of args, in order. The
+// values are never looked at: nil arguments are reported as "nil" and driver.Valuer
+// implementations by their own type, without calling their Value method.
+func argTypes(args []driver.NamedValue) string {
+	var b strings.Builder
+	for i, arg := range args {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		if arg.Value == nil {
+			b.WriteString("nil")
+			continue
+		}
+		fmt.Fprintf(&b, "%T", arg.Value)
+	}
+	return b.String()
+}
+
+// recordArgType records the type of the argument value, as given to database/sql and
+// before its conversion to a driver value, for argTypesTag to report it. It is called
+// with the arguments of a call in order, as they are checked by CheckNamedValue.
+func (tp *traceParams) recordArgType(value *driver.NamedValue) {
+	if !tp.cfg.argTypeTags {
+		return
+	}
+	i := value.Ordinal - 1
+	if i < 0 || i > len(tp.argTypes) {
+		return
+	}
+	name := "nil"
+	if value.Value != nil {
+		name = reflect.TypeOf(value.Value).String()
+	}
+	// the argument replaces the one removed at the same position, if any
+	tp.argTypes = append(tp.argTypes[:i], name)
+}
+
+// argTypesTag returns a span option tagging the span with the types of args when
+// WithArgTypeTags is enabled, or a no-op option otherwise. The types recorded by
+// recordArgType are reported when they match args, the types of the driver values
+// otherwise.
+func (tp *traceParams) argTypesTag(args []driver.NamedValue) ddtrace.StartSpanOption {
+	if !tp.cfg.argTypeTags || len(args) == 0 {
+		return func(*ddtrace.StartSpanConfig) {}
+	}
+	types := argTypes(args)
+	if len(tp.argTypes) == len(args) {
+		types = strings.Join(tp.argTypes, ",")
+	}
+	tp.argTypes = tp.argTypes[:0]
+	return tracer.Tag(keyArgTypes, types)
+}
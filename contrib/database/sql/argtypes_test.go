@@ -0,0 +1,75 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/database/sql/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+)
+
+func TestArgTypes(t *testing.T) {
+	for _, tt := range []struct {
+		args []driver.NamedValue
+		want string
+	}{
+		{nil, ""},
+		{[]driver.NamedValue{{Value: int64(1)}}, "int64"},
+		{[]driver.NamedValue{{Value: int64(1)}, {Value: "a"}, {Value: time.Time{}}}, "int64,string,time.Time"},
+		{[]driver.NamedValue{{Value: nil}, {Value: []byte("a")}}, "nil,[]uint8"},
+		// the value of a driver.Valuer is never read
+		{[]driver.NamedValue{{Value: panicValuer{}}}, "sql.panicValuer"},
+	} {
+		assert.Equal(t, tt.want, argTypes(tt.args))
+	}
+}
+
+func TestWithArgTypeTags(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	for name, tt := range map[string]struct {
+		opts []Option
+		want interface{}
+	}{
+		"disabled": {want: nil},
+		// the types are the ones of the arguments, before their conversion to driver values
+		"enabled": {opts: []Option{WithArgTypeTags()}, want: "int,string,time.Time,nil,sql.NullString"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			Register("test", &internal.MockDriver{}, tt.opts...)
+			defer unregister("test")
+			db, err := Open("test", "dn")
+			require.NoError(t, err)
+			defer db.Close()
+
+			mt.Reset()
+			args := []interface{}{1, "secret", time.Now(), nil, sql.NullString{String: "secret", Valid: true}}
+			rows, err := db.QueryContext(context.Background(), "SELECT * FROM t WHERE a = ? AND b = ? AND c = ? AND d = ? AND e = ?", args...)
+			require.NoError(t, err)
+			rows.Close()
+			_, err = db.ExecContext(context.Background(), "UPDATE t SET a = ? WHERE b = ? AND c = ? AND d = ? AND e = ?", args...)
+			require.NoError(t, err)
+
+			spans := append(spansOfType(mt.FinishedSpans(), queryTypeQuery), spansOfType(mt.FinishedSpans(), queryTypeExec)...)
+			require.Len(t, spans, 2)
+			for _, s := range spans {
+				assert.Equal(t, tt.want, s.Tag(keyArgTypes))
+				for k, v := range s.Tags() {
+					assert.NotContains(t, v, "secret", k)
+				}
+			}
+		})
+	}
+}
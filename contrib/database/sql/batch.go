@@ -0,0 +1,107 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+const keyBatchSize = "sql.batch.size"
+
+// splitStatements splits query into the statements it is made of. Statements are
+// separated by semicolons which are not part of a quoted string, a quoted identifier
+// or a comment. Empty statements are discarded.
+func splitStatements(query string) []string {
+	var (
+		stmts []string
+		start int
+	)
+	add := func(stmt string) {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; c {
+		case '\'', '"', '`':
+			// skip to the closing quote; doubled quotes are escapes and
+			// are handled by re-entering this case on the next iteration.
+			for i++; i < len(query) && query[i] != c; i++ {
+				if query[i] == '\\' && c != '`' {
+					i++
+				}
+			}
+		case '-':
+			if i+1 < len(query) && query[i+1] == '-' {
+				for i += 2; i < len(query) && query[i] != '\n'; i++ {
+				}
+			}
+		case '/':
+			if i+1 < len(query) && query[i+1] == '*' {
+				end := strings.Index(query[i+2:], "*/")
+				if end < 0 {
+					i = len(query)
+				} else {
+					i += end + 3
+				}
+			}
+		case ';':
+			add(query[start:i])
+			start = i + 1
+		}
+	}
+	if start < len(query) {
+		add(query[start:])
+	}
+	return stmts
+}
+
+// batchStatements returns the statements query is made of when batch statement
+// spans are enabled.
+func (tp *traceParams) batchStatements(query string) []string {
+	if !tp.cfg.batchSpans {
+		return nil
+	}
+	return splitStatements(query)
+}
+
+// tryTraceBatch creates a "sql.batch" span for the given multi-statement query, along
+// with a child span for each of its statements. Like tryTrace, it is a no-op when err
+// is driver.ErrSkip.
+func (tp *traceParams) tryTraceBatch(ctx context.Context, query string, stmts []string, startTime time.Time, err error, spanOpts ...tracer.StartSpanOption) {
+	if err == driver.ErrSkip {
+		return
+	}
+	if _, exists := tracer.SpanFromContext(ctx); tp.cfg.childSpansOnly && !exists {
+		return
+	}
+	if tp.belowThreshold(startTime, err, spanOpts) {
+		return
+	}
+	service := tp.cfg.shardServiceName(ctx, query, tp.cfg.serviceName)
+	opts := tp.spanOptions(service, startTime, append(spanOpts,
+		tracer.ResourceName(tp.resourceName(query)),
+		tracer.Tag(keyBatchSize, len(stmts)),
+	)...)
+	span, ctx := tracer.StartSpanFromContext(ctx, "sql.batch", opts...)
+	tp.setEnvVersion(span)
+	tp.setContextTags(ctx, span)
+	tp.setConnTags(span, query)
+	for _, stmt := range stmts {
+		// the driver reports a single result for the whole batch, so the error
+		// is only set on the parent span.
+		tp.tryTrace(ctx, queryTypeExec, stmt, startTime, nil)
+	}
+	if err != nil {
+		tp.setError(span, err)
+	}
+	span.Finish()
+}
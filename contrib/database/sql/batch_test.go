@@ -0,0 +1,121 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"context"
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/database/sql/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitStatements(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{
+			name:  "single",
+			query: "SELECT 1",
+			want:  []string{"SELECT 1"},
+		},
+		{
+			name:  "trailing-semicolon",
+			query: "SELECT 1;",
+			want:  []string{"SELECT 1"},
+		},
+		{
+			name:  "two",
+			query: "INSERT INTO t VALUES (1); UPDATE t SET a = 2",
+			want:  []string{"INSERT INTO t VALUES (1)", "UPDATE t SET a = 2"},
+		},
+		{
+			name:  "quoted",
+			query: `INSERT INTO t VALUES ('a;b', "c;d", 'it''s;', 'e\';f'); SELECT ` + "`x;y`" + ` FROM t`,
+			want:  []string{`INSERT INTO t VALUES ('a;b', "c;d", 'it''s;', 'e\';f')`, "SELECT `x;y` FROM t"},
+		},
+		{
+			name:  "comments",
+			query: "SELECT 1 -- one; two\n; /* three; four */ SELECT 2",
+			want:  []string{"SELECT 1 -- one; two", "/* three; four */ SELECT 2"},
+		},
+		{
+			name:  "unterminated",
+			query: "SELECT 'a; SELECT 2",
+			want:  []string{"SELECT 'a; SELECT 2"},
+		},
+		{
+			name:  "empty",
+			query: " ; ;",
+			want:  nil,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, splitStatements(tt.query))
+		})
+	}
+}
+
+func TestBatchStatementSpans(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	const query = "INSERT INTO t VALUES ('a;b'); UPDATE t SET a = 'c'"
+
+	t.Run("enabled", func(t *testing.T) {
+		Register("test", &internal.MockDriver{}, WithBatchStatementSpans())
+		defer unregister("test")
+		db, err := Open("test", "dn")
+		require.NoError(t, err)
+		defer db.Close()
+
+		mt.Reset()
+		_, err = db.ExecContext(context.Background(), query)
+		require.NoError(t, err)
+
+		var batch mocktracer.Span
+		for _, s := range mt.FinishedSpans() {
+			if s.OperationName() == "sql.batch" {
+				batch = s
+			}
+		}
+		require.NotNil(t, batch)
+		assert.Equal(t, query, batch.Tag(ext.ResourceName))
+		assert.Equal(t, 2, batch.Tag(keyBatchSize))
+		assert.Equal(t, "test.db", batch.Tag(ext.ServiceName))
+
+		spans := spansOfType(mt.FinishedSpans(), queryTypeExec)
+		require.Len(t, spans, 2)
+		assert.Equal(t, "INSERT INTO t VALUES ('a;b')", spans[0].Tag(ext.ResourceName))
+		assert.Equal(t, "UPDATE t SET a = 'c'", spans[1].Tag(ext.ResourceName))
+		for _, s := range spans {
+			assert.Equal(t, batch.SpanID(), s.ParentID())
+			assert.Equal(t, "test.query", s.OperationName())
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		Register("test", &internal.MockDriver{})
+		defer unregister("test")
+		db, err := Open("test", "dn")
+		require.NoError(t, err)
+		defer db.Close()
+
+		mt.Reset()
+		_, err = db.ExecContext(context.Background(), query)
+		require.NoError(t, err)
+
+		spans := spansOfType(mt.FinishedSpans(), queryTypeExec)
+		require.Len(t, spans, 1)
+		assert.Equal(t, query, spans[0].Tag(ext.ResourceName))
+	})
+}
@@ -0,0 +1,79 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"container/list"
+	"sync"
+)
+
+// defaultObfuscationCacheSize is the number of obfuscated queries cached per database
+// when not set using WithObfuscationCacheSize.
+const defaultObfuscationCacheSize = 1000
+
+// queryCache is a concurrency-safe LRU cache mapping raw queries to their obfuscated
+// version. It holds at most size entries, evicting the least recently used one.
+type queryCache struct {
+	mu     sync.Mutex
+	size   int
+	ll     *list.List               // most recently used entries first
+	items  map[string]*list.Element // raw query to its entry in ll
+	hits   uint64                   // number of lookups which found their query
+	misses uint64                   // number of lookups which did not find their query
+}
+
+type queryCacheEntry struct {
+	query      string
+	obfuscated string
+}
+
+// newQueryCache returns a cache holding at most size obfuscated queries.
+func newQueryCache(size int) *queryCache {
+	return &queryCache{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// get returns the obfuscated version of query, if it is cached.
+func (c *queryCache) get(query string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[query]
+	if !ok {
+		c.misses++
+		return "", false
+	}
+	c.hits++
+	c.ll.MoveToFront(e)
+	return e.Value.(*queryCacheEntry).obfuscated, true
+}
+
+// add caches the obfuscated version of query, evicting the least recently used
+// entry if the cache is full.
+func (c *queryCache) add(query, obfuscated string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[query]; ok {
+		e.Value.(*queryCacheEntry).obfuscated = obfuscated
+		c.ll.MoveToFront(e)
+		return
+	}
+	c.items[query] = c.ll.PushFront(&queryCacheEntry{query: query, obfuscated: obfuscated})
+	if c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*queryCacheEntry).query)
+	}
+}
+
+// len returns the number of cached queries.
+func (c *queryCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
@@ -0,0 +1,132 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/database/sql/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+)
+
+func TestQueryCache(t *testing.T) {
+	c := newQueryCache(2)
+	_, ok := c.get("SELECT 1")
+	assert.False(t, ok)
+
+	c.add("SELECT 1", "SELECT ?")
+	c.add("SELECT 2", "SELECT ?")
+	oq, ok := c.get("SELECT 1")
+	assert.True(t, ok)
+	assert.Equal(t, "SELECT ?", oq)
+
+	// "SELECT 2" is the least recently used query
+	c.add("SELECT 3", "SELECT ?")
+	assert.Equal(t, 2, c.len())
+	_, ok = c.get("SELECT 2")
+	assert.False(t, ok)
+	_, ok = c.get("SELECT 1")
+	assert.True(t, ok)
+	_, ok = c.get("SELECT 3")
+	assert.True(t, ok)
+
+	assert.Equal(t, uint64(3), c.hits)
+	assert.Equal(t, uint64(2), c.misses)
+}
+
+func TestObfuscateQuery(t *testing.T) {
+	c := newQueryCache(10)
+	for i := 0; i < 3; i++ {
+		oq, err := obfuscateQuery("SELECT * FROM users WHERE id = 42", c)
+		require.NoError(t, err)
+		assert.Equal(t, "SELECT * FROM users WHERE id = ?", oq)
+	}
+	assert.Equal(t, uint64(2), c.hits)
+	assert.Equal(t, uint64(1), c.misses)
+
+	// queries failing obfuscation are not cached
+	_, err := obfuscateQuery("SELECT 'unterminated", c)
+	assert.Error(t, err)
+	assert.Equal(t, 1, c.len())
+}
+
+func TestWithObfuscationCacheSize(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	for name, tt := range map[string]struct {
+		opts []Option
+		size int // expected size of the cache, 0 when disabled
+	}{
+		"default":  {opts: []Option{WithQuerySignature()}, size: defaultObfuscationCacheSize},
+		"size":     {opts: []Option{WithQuerySignature(), WithObfuscationCacheSize(10)}, size: 10},
+		"disabled": {opts: []Option{WithQuerySignature(), WithObfuscationCacheSize(0)}},
+		"unused":   {},
+		"resource": {opts: []Option{WithMaxResourceNameLength(10)}, size: defaultObfuscationCacheSize},
+		"slowlog":  {opts: []Option{WithSlowQueryLog(0, new(recordLogger))}, size: defaultObfuscationCacheSize},
+		"otel":     {opts: []Option{WithOTelSemanticConventions()}, size: defaultObfuscationCacheSize},
+	} {
+		t.Run(name, func(t *testing.T) {
+			Register("test", &internal.MockDriver{}, tt.opts...)
+			defer unregister("test")
+			db, err := Open("test", "dn")
+			require.NoError(t, err)
+			defer db.Close()
+
+			for i := 0; i < 3; i++ {
+				rows, err := db.QueryContext(context.Background(), "SELECT name FROM users WHERE id = 1")
+				require.NoError(t, err)
+				rows.Close()
+			}
+
+			conn, err := db.Conn(context.Background())
+			require.NoError(t, err)
+			defer conn.Close()
+			var cache *queryCache
+			err = conn.Raw(func(dc interface{}) error {
+				cache = dc.(*TracedConn).cfg.queryCache
+				return nil
+			})
+			require.NoError(t, err)
+			if tt.size == 0 {
+				assert.Nil(t, cache)
+				return
+			}
+			require.NotNil(t, cache)
+			assert.Equal(t, tt.size, cache.size)
+			assert.Equal(t, uint64(2), cache.hits)
+			assert.Equal(t, uint64(1), cache.misses)
+		})
+	}
+}
+
+func BenchmarkQuerySignature(b *testing.B) {
+	queries := make([]string, 10)
+	for i := range queries {
+		queries[i] = fmt.Sprintf("SELECT u.name, o.total FROM users u JOIN orders_%d o ON o.user_id = u.id WHERE u.id = %d AND o.status IN ('paid', 'shipped')", i, i)
+	}
+	for name, cache := range map[string]*queryCache{
+		"cache":    newQueryCache(defaultObfuscationCacheSize),
+		"no-cache": nil,
+	} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				querySignature(queries[i%len(queries)], cache)
+			}
+			if cache != nil {
+				b.ReportMetric(float64(cache.misses)/float64(cache.hits+cache.misses), "obfuscations/op")
+			} else {
+				b.ReportMetric(1, "obfuscations/op")
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"sync"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/clock"
+)
+
+// keyErrorCount holds the number of identical errors coalesced into a span, as enabled
+// using WithErrorCoalescing.
+const keyErrorCount = "sql.error.count"
+
+// errorCoalescer collapses the identical errors returned by the same query shape within a
+// window into the span of the first of them, as enabled using WithErrorCoalescing.
+type errorCoalescer struct {
+	window time.Duration
+
+	mu   sync.Mutex      // guards errs
+	errs map[string]*int // number of occurrences of the errors being coalesced, by key
+}
+
+func newErrorCoalescer(window time.Duration) *errorCoalescer {
+	return &errorCoalescer{
+		window: window,
+		errs:   make(map[string]*int),
+	}
+}
+
+// claim counts an occurrence of the error of key, reporting whether it is the first one of
+// its window, whose span must be passed to hold.
+func (c *errorCoalescer) claim(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n, ok := c.errs[key]; ok {
+		*n++
+		return false
+	}
+	n := 1
+	c.errs[key] = &n
+	return true
+}
+
+// hold keeps span, the span of the first occurrence of the error of key, open until the end
+// of its window, when it is tagged with the number of occurrences and finished at finishTime.
+func (c *errorCoalescer) hold(key string, span ddtrace.Span, finishTime time.Time) {
+	clock.AfterFunc(c.window, func() {
+		c.mu.Lock()
+		n := *c.errs[key]
+		delete(c.errs, key)
+		c.mu.Unlock()
+		span.SetTag(keyErrorCount, n)
+		span.Finish(tracer.FinishTime(finishTime))
+	})
+}
+
+// errorKey returns the key identifying the error err returned by query, of type qtype,
+// among the ones coalesced. Queries only differing by their literals share the same key.
+func (tp *traceParams) errorKey(qtype queryType, query string, err error) string {
+	if oq, oerr := obfuscateQuery(query, tp.cfg.queryCache); oerr == nil {
+		query = oq
+	}
+	return string(qtype) + "\x00" + query + "\x00" + err.Error()
+}
@@ -0,0 +1,97 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/database/sql/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+func TestWithErrorCoalescing(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	const window = 50 * time.Millisecond
+	Register("test", &slowDriver{MockDriver: &internal.MockDriver{}}, WithErrorCoalescing(window))
+	defer unregister("test")
+	db, err := Open("test", "dn")
+	require.NoError(t, err)
+	defer db.Close()
+
+	mt.Reset()
+	start := time.Now()
+	for i := 0; i < 20; i++ {
+		_, err := db.ExecContext(context.Background(), fmt.Sprintf("UPDATE orders SET status = 'fail' WHERE id = %d", i))
+		require.Error(t, err)
+	}
+	_, err = db.ExecContext(context.Background(), "DELETE FROM fail")
+	require.Error(t, err)
+	_, err = db.ExecContext(context.Background(), "SELECT 1")
+	require.NoError(t, err)
+	firstEnd := time.Now()
+
+	// the error spans are held until the end of the window
+	spans := spansOfType(mt.FinishedSpans(), string(queryTypeExec))
+	require.Len(t, spans, 1)
+	assert.Equal(t, "SELECT 1", spans[0].Tag(ext.ResourceName))
+	assert.Nil(t, spans[0].Tag(keyErrorCount))
+
+	require.Eventually(t, func() bool {
+		return len(spansOfType(mt.FinishedSpans(), string(queryTypeExec))) == 3
+	}, time.Second, window/5)
+	counts := make(map[interface{}]interface{})
+	for _, s := range spansOfType(mt.FinishedSpans(), string(queryTypeExec))[1:] {
+		assert.NotNil(t, s.Tag(ext.Error))
+		assert.False(t, s.FinishTime().After(firstEnd), "the span finishes with its call")
+		assert.False(t, s.StartTime().Before(start))
+		counts[s.Tag(ext.ResourceName)] = s.Tag(keyErrorCount)
+	}
+	assert.Equal(t, map[interface{}]interface{}{
+		"UPDATE orders SET status = 'fail' WHERE id = 0": 20,
+		"DELETE FROM fail": 1,
+	}, counts)
+
+	// a new window starts once the previous one is over
+	mt.Reset()
+	_, err = db.ExecContext(context.Background(), "DELETE FROM fail")
+	require.Error(t, err)
+	require.Eventually(t, func() bool {
+		return len(spansOfType(mt.FinishedSpans(), string(queryTypeExec))) == 1
+	}, time.Second, window/5)
+	assert.Equal(t, 1, spansOfType(mt.FinishedSpans(), string(queryTypeExec))[0].Tag(keyErrorCount))
+}
+
+func TestWithErrorCoalescingDBM(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	d := &internal.MockDriver{}
+	Register("test", d, WithErrorCoalescing(time.Millisecond), WithDBMPropagation(tracer.DBMPropagationModeFull))
+	defer unregister("test")
+	db, err := Open("test", "dn")
+	require.NoError(t, err)
+	defer db.Close()
+
+	cfg, ok := ConfigFromDB(db)
+	require.True(t, ok)
+	assert.Equal(t, tracer.DBMPropagationModeService, cfg.DBMPropagationMode)
+
+	_, err = db.ExecContext(context.Background(), "SELECT 1")
+	require.NoError(t, err)
+	require.Len(t, d.Executed, 1)
+	assert.Contains(t, d.Executed[0], "dddbs=")
+	assert.NotContains(t, d.Executed[0], "traceparent")
+}
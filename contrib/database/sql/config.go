@@ -0,0 +1,94 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"database/sql"
+	"sync"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+// Config is a read-only snapshot of the effective configuration of a database opened
+// using Open or OpenDB, once the options passed to them are merged with those passed to
+// Register. It is meant for packages wrapping this one to assert their configuration.
+type Config struct {
+	// DriverName is the name the driver was registered with.
+	DriverName string
+	// ServiceName is the service of the spans.
+	ServiceName string
+	// AnalyticsRate is the analytics rate of the spans; it is NaN when not set.
+	AnalyticsRate float64
+	// DBMPropagationMode is the mode of propagation of the trace context to the database.
+	DBMPropagationMode tracer.DBMPropagationMode
+	// ChildSpansOnly reports whether spans are only created when they have a parent.
+	ChildSpansOnly bool
+	// Tags holds the additional tags set on the spans.
+	Tags map[string]interface{}
+}
+
+// tracedDBs holds a registry of the databases opened using Open or OpenDB, mapped to their
+// connector. Databases are removed from it once closed.
+var tracedDBs = &dbRegistry{dbs: make(map[*sql.DB]*tracedConnector)}
+
+type dbRegistry struct {
+	mu  sync.RWMutex
+	dbs map[*sql.DB]*tracedConnector
+}
+
+// add adds db, opened using tc, to the registry.
+func (r *dbRegistry) add(db *sql.DB, tc *tracedConnector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dbs[db] = tc
+}
+
+// remove removes the database opened using tc from the registry.
+func (r *dbRegistry) remove(tc *tracedConnector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for db, c := range r.dbs {
+		if c == tc {
+			delete(r.dbs, db)
+		}
+	}
+}
+
+// connector returns the connector db was opened with, if it is in the registry.
+func (r *dbRegistry) connector(db *sql.DB) (*tracedConnector, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tc, ok := r.dbs[db]
+	return tc, ok
+}
+
+// ConfigFromDB returns the configuration db was opened with, reporting whether db was
+// opened using Open or OpenDB and is not closed yet.
+func ConfigFromDB(db *sql.DB) (Config, bool) {
+	tc, ok := tracedDBs.connector(db)
+	if !ok {
+		return Config{}, false
+	}
+	return tc.snapshot(), true
+}
+
+// snapshot returns the Config of the databases opened using t.
+func (t *tracedConnector) snapshot() Config {
+	c := Config{
+		DriverName:         t.driverName,
+		ServiceName:        t.cfg.serviceName,
+		AnalyticsRate:      t.cfg.analyticsRate,
+		DBMPropagationMode: t.cfg.dbmPropagationMode,
+		ChildSpansOnly:     t.cfg.childSpansOnly,
+	}
+	if len(t.cfg.tags) > 0 {
+		c.Tags = make(map[string]interface{}, len(t.cfg.tags))
+		for k, v := range t.cfg.tags {
+			c.Tags[k] = v
+		}
+	}
+	return c
+}
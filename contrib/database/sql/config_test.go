@@ -0,0 +1,88 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/database/sql/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+func TestConfigFromDB(t *testing.T) {
+	Register("postgres", &internal.MockDriver{}, WithServiceName("registered-db"), WithAnalyticsRate(0.2))
+	defer unregister("postgres")
+
+	t.Run("options", func(t *testing.T) {
+		db, err := Open("postgres", "postgres://bob@db.internal:5432/orders",
+			WithServiceName("orders-db"),
+			WithAnalyticsRate(0.5),
+			WithDBMPropagation(tracer.DBMPropagationModeService),
+			WithCustomTag("team", "payments"),
+		)
+		require.NoError(t, err)
+		defer db.Close()
+
+		cfg, ok := ConfigFromDB(db)
+		require.True(t, ok)
+		assert.Equal(t, Config{
+			DriverName:         "postgres",
+			ServiceName:        "orders-db",
+			AnalyticsRate:      0.5,
+			DBMPropagationMode: tracer.DBMPropagationModeService,
+			Tags:               map[string]interface{}{"team": "payments"},
+		}, cfg)
+
+		// the snapshot is a copy
+		cfg.Tags["team"] = "billing"
+		cfg, _ = ConfigFromDB(db)
+		assert.Equal(t, "payments", cfg.Tags["team"])
+	})
+
+	t.Run("registered", func(t *testing.T) {
+		db, err := Open("postgres", "postgres://bob@db.internal:5432/orders")
+		require.NoError(t, err)
+		defer db.Close()
+
+		cfg, ok := ConfigFromDB(db)
+		require.True(t, ok)
+		assert.Equal(t, "registered-db", cfg.ServiceName)
+		assert.Equal(t, 0.2, cfg.AnalyticsRate)
+		assert.Nil(t, cfg.Tags)
+	})
+
+	t.Run("untraced", func(t *testing.T) {
+		db := sql.OpenDB(&dsnConnector{driver: &internal.MockDriver{}})
+		defer db.Close()
+
+		cfg, ok := ConfigFromDB(db)
+		assert.False(t, ok)
+		assert.Equal(t, Config{}, cfg)
+	})
+
+	t.Run("no-conn", func(t *testing.T) {
+		db, err := Open("postgres", "postgres://bob@db.internal:5432/orders")
+		require.NoError(t, err)
+		db.SetMaxOpenConns(1)
+		conn, err := db.Conn(context.Background())
+		require.NoError(t, err)
+
+		// the configuration is available while the pool is exhausted
+		cfg, ok := ConfigFromDB(db)
+		assert.True(t, ok)
+		assert.Equal(t, "registered-db", cfg.ServiceName)
+
+		conn.Close()
+		db.Close()
+		_, ok = ConfigFromDB(db)
+		assert.False(t, ok, "closed databases are removed from the registry")
+	})
+}
@@ -7,9 +7,13 @@ package sql // import "gopkg.in/DataDog/dd-trace-go.v1/contrib/database/sql"
 
 import (
 	"context"
+	"database/sql"
 	"database/sql/driver"
 	"fmt"
 	"math"
+	"reflect"
+	"sort"
+	"strings"
 	"time"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
@@ -32,10 +36,22 @@ const (
 	queryTypeClose              = "Close"
 	queryTypeCommit             = "Commit"
 	queryTypeRollback           = "Rollback"
+	queryTypeReset              = "ResetSession"
+	queryTypeCopy               = "Copy"
 )
 
 const (
 	keyDBMTraceInjected = "_dd.dbm_trace_injected"
+	keyArgPanic         = "sql.arg_panic"
+	// keyArgCheckError and keyArgCheckErrorIndex hold the error returned by the driver when
+	// checking an argument, and the position of the argument, starting at 1.
+	keyArgCheckError      = "sql.arg_check_error"
+	keyArgCheckErrorIndex = "sql.arg_check_error_index"
+	// keyDBMHost holds the hostname of the database, as set using WithDBMHostname.
+	keyDBMHost = "_dd.db.host"
+	// keyTxIsolation and keyTxReadOnly hold the options a transaction was started with.
+	keyTxIsolation = "sql.tx.isolation"
+	keyTxReadOnly  = "sql.tx.readonly"
 )
 
 // TracedConn holds a traced connection with tracing parameters.
@@ -61,22 +77,32 @@ func (tc *TracedConn) WrappedConn() driver.Conn {
 // an error will be returned.
 func (tc *TracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (tx driver.Tx, err error) {
 	start := time.Now()
+	txTags := []ddtrace.StartSpanOption{
+		tracer.Tag(keyTxIsolation, isolationLevelName(opts.Isolation)),
+		tracer.Tag(keyTxReadOnly, opts.ReadOnly),
+	}
 	if connBeginTx, ok := tc.Conn.(driver.ConnBeginTx); ok {
 		tx, err = connBeginTx.BeginTx(ctx, opts)
-		tc.tryTrace(ctx, queryTypeBegin, "", start, err)
+		tc.tryTrace(ctx, queryTypeBegin, "", start, err, txTags...)
 		if err != nil {
 			return nil, err
 		}
 		return &tracedTx{tx, tc.traceParams, ctx}, nil
 	}
 	tx, err = tc.Conn.Begin()
-	tc.tryTrace(ctx, queryTypeBegin, "", start, err)
+	tc.tryTrace(ctx, queryTypeBegin, "", start, err, txTags...)
 	if err != nil {
 		return nil, err
 	}
 	return &tracedTx{tx, tc.traceParams, ctx}, nil
 }
 
+// isolationLevelName returns the name of the isolation level l in lower case, with words
+// separated by underscores, e.g. "read_committed".
+func isolationLevelName(l driver.IsolationLevel) string {
+	return strings.ReplaceAll(strings.ToLower(sql.IsolationLevel(l).String()), " ", "_")
+}
+
 // PrepareContext creates a prepared statement for later queries or executions.
 // Multiple queries or executions may be run concurrently from the
 // returned statement.
@@ -99,24 +125,32 @@ func (tc *TracedConn) PrepareContext(ctx context.Context, query string) (stmt dr
 		if err != nil {
 			return nil, err
 		}
-		return &tracedStmt{Stmt: stmt, traceParams: tc.traceParams, ctx: ctx, query: query}, nil
+		return tc.newTracedStmt(ctx, stmt, query), nil
 	}
 	stmt, err = tc.Prepare(cquery)
 	tc.tryTrace(ctx, queryTypePrepare, query, start, err, append(withDBMTraceInjectedTag(mode), tracer.WithSpanID(spanID))...)
 	if err != nil {
 		return nil, err
 	}
-	return &tracedStmt{Stmt: stmt, traceParams: tc.traceParams, ctx: ctx, query: query}, nil
+	return tc.newTracedStmt(ctx, stmt, query), nil
 }
 
 // ExecContext executes a query without returning any rows.
 // The args are for any placeholder parameters in the query.
 func (tc *TracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (r driver.Result, err error) {
 	start := time.Now()
+	if opts, err := argError(args); err != nil {
+		tc.tryTrace(ctx, queryTypeExec, query, start, err, opts...)
+		return nil, err
+	}
 	if execContext, ok := tc.Conn.(driver.ExecerContext); ok {
 		cquery, spanID := tc.injectComments(ctx, query, tc.cfg.dbmPropagationMode)
 		r, err := execContext.ExecContext(ctx, cquery, args)
-		tc.tryTrace(ctx, queryTypeExec, query, start, err, append(withDBMTraceInjectedTag(tc.cfg.dbmPropagationMode), tracer.WithSpanID(spanID))...)
+		if stmts := tc.batchStatements(query); len(stmts) > 1 {
+			tc.tryTraceBatch(ctx, query, stmts, start, err, append(withDBMTraceInjectedTag(tc.cfg.dbmPropagationMode), tracer.WithSpanID(spanID), tc.argTypesTag(args))...)
+			return r, err
+		}
+		tc.tryTrace(ctx, queryTypeExec, query, start, err, append(withDBMTraceInjectedTag(tc.cfg.dbmPropagationMode), tracer.WithSpanID(spanID), tc.argTypesTag(args))...)
 		return r, err
 	}
 	if execer, ok := tc.Conn.(driver.Execer); ok {
@@ -131,7 +165,11 @@ func (tc *TracedConn) ExecContext(ctx context.Context, query string, args []driv
 		}
 		cquery, spanID := tc.injectComments(ctx, query, tc.cfg.dbmPropagationMode)
 		r, err = execer.Exec(cquery, dargs)
-		tc.tryTrace(ctx, queryTypeExec, query, start, err, append(withDBMTraceInjectedTag(tc.cfg.dbmPropagationMode), tracer.WithSpanID(spanID))...)
+		if stmts := tc.batchStatements(query); len(stmts) > 1 {
+			tc.tryTraceBatch(ctx, query, stmts, start, err, append(withDBMTraceInjectedTag(tc.cfg.dbmPropagationMode), tracer.WithSpanID(spanID), tc.argTypesTag(args))...)
+			return r, err
+		}
+		tc.tryTrace(ctx, queryTypeExec, query, start, err, append(withDBMTraceInjectedTag(tc.cfg.dbmPropagationMode), tracer.WithSpanID(spanID), tc.argTypesTag(args))...)
 		return r, err
 	}
 	return nil, driver.ErrSkip
@@ -151,10 +189,14 @@ func (tc *TracedConn) Ping(ctx context.Context) (err error) {
 // The args are for any placeholder parameters in the query.
 func (tc *TracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (rows driver.Rows, err error) {
 	start := time.Now()
+	if opts, err := argError(args); err != nil {
+		tc.tryTrace(ctx, queryTypeQuery, query, start, err, opts...)
+		return nil, err
+	}
 	if queryerContext, ok := tc.Conn.(driver.QueryerContext); ok {
 		cquery, spanID := tc.injectComments(ctx, query, tc.cfg.dbmPropagationMode)
 		rows, err := queryerContext.QueryContext(ctx, cquery, args)
-		tc.tryTrace(ctx, queryTypeQuery, query, start, err, append(withDBMTraceInjectedTag(tc.cfg.dbmPropagationMode), tracer.WithSpanID(spanID))...)
+		tc.tryTrace(ctx, queryTypeQuery, query, start, err, append(withDBMTraceInjectedTag(tc.cfg.dbmPropagationMode), tracer.WithSpanID(spanID), tc.argTypesTag(args))...)
 		return rows, err
 	}
 	if queryer, ok := tc.Conn.(driver.Queryer); ok {
@@ -169,7 +211,7 @@ func (tc *TracedConn) QueryContext(ctx context.Context, query string, args []dri
 		}
 		cquery, spanID := tc.injectComments(ctx, query, tc.cfg.dbmPropagationMode)
 		rows, err = queryer.Query(cquery, dargs)
-		tc.tryTrace(ctx, queryTypeQuery, query, start, err, append(withDBMTraceInjectedTag(tc.cfg.dbmPropagationMode), tracer.WithSpanID(spanID))...)
+		tc.tryTrace(ctx, queryTypeQuery, query, start, err, append(withDBMTraceInjectedTag(tc.cfg.dbmPropagationMode), tracer.WithSpanID(spanID), tc.argTypesTag(args))...)
 		return rows, err
 	}
 	return nil, driver.ErrSkip
@@ -178,22 +220,121 @@ func (tc *TracedConn) QueryContext(ctx context.Context, query string, args []dri
 // CheckNamedValue is called before passing arguments to the driver
 // and is called in place of any ColumnConverter. CheckNamedValue must do type
 // validation and conversion as appropriate for the driver.
+//
+// The value is first checked by the checker of the wrapped connection, if any.
+// When it is not, or when that checker returns driver.ErrSkip, driver.ErrSkip is
+// returned so that database/sql applies its own conversion.
+//
+// A panic raised while converting the value (e.g. by a driver.Valuer) is recovered
+// and the value is replaced so that the error is reported by the traced call instead.
+// The same goes for errors returned by the driver's checker when WithArgCheckErrors is used.
 func (tc *TracedConn) CheckNamedValue(value *driver.NamedValue) error {
-	if checker, ok := tc.Conn.(driver.NamedValueChecker); ok {
-		return checker.CheckNamedValue(value)
+	checker, _ := tc.Conn.(driver.NamedValueChecker)
+	return tc.checkNamedValue(checker, value)
+}
+
+// checkNamedValue implements CheckNamedValue for connections and statements, checking
+// value using checker, when not nil.
+func (tp *traceParams) checkNamedValue(checker driver.NamedValueChecker, value *driver.NamedValue) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			value.Value = argPanic{newArgPanicError(value.Ordinal, r)}
+			err = nil
+		}
+	}()
+	tp.recordArgType(value)
+	if checker != nil {
+		err = checker.CheckNamedValue(value)
+		if err != nil && err != driver.ErrSkip && err != driver.ErrRemoveArgument && tp.cfg.argCheckErrors {
+			value.Value = argCheckFailure{ordinal: value.Ordinal, err: err, wrapped: newArgCheckError(value, err)}
+			return nil
+		}
+		if err != driver.ErrSkip {
+			return err
+		}
+	}
+	if v, ok := value.Value.(driver.Valuer); ok {
+		// database/sql would call it after driver.ErrSkip, out of reach of the recovery above
+		value.Value, err = valuerValue(v)
+		if err != nil {
+			return err
+		}
 	}
 	return driver.ErrSkip
 }
 
+var valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+
+// valuerValue returns the value of v, like database/sql: nil pointers to types
+// implementing driver.Valuer with a value receiver have a nil value.
+func valuerValue(v driver.Valuer) (driver.Value, error) {
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() && rv.Type().Elem().Implements(valuerType) {
+		return nil, nil
+	}
+	return v.Value()
+}
+
+// argPanic replaces an argument whose conversion panicked.
+type argPanic struct{ err error }
+
+// newArgPanicError returns an error describing the panic r raised while converting
+// the argument at the given ordinal position.
+func newArgPanicError(ordinal int, r interface{}) error {
+	if err, ok := r.(error); ok {
+		return fmt.Errorf("contrib/database/sql: panic converting argument %d: %w", ordinal, err)
+	}
+	return fmt.Errorf("contrib/database/sql: panic converting argument %d: %v", ordinal, r)
+}
+
+// argCheckFailure replaces an argument rejected by the driver's checker.
+type argCheckFailure struct {
+	ordinal int
+	err     error // returned by the checker
+	wrapped error // returned to the caller
+}
+
+// newArgCheckError returns err, returned by the driver when checking value, wrapped like
+// database/sql does when the error is returned to it.
+func newArgCheckError(value *driver.NamedValue, err error) error {
+	if value.Name != "" {
+		return fmt.Errorf("sql: converting argument with name %q type: %w", value.Name, err)
+	}
+	return fmt.Errorf("sql: converting argument $%d type: %w", value.Ordinal, err)
+}
+
+// argError returns the error of the first argument in args whose conversion panicked
+// or which was rejected by the driver, if any, along with the tags describing it.
+func argError(args []driver.NamedValue) ([]ddtrace.StartSpanOption, error) {
+	for _, arg := range args {
+		switch v := arg.Value.(type) {
+		case argPanic:
+			return []ddtrace.StartSpanOption{tracer.Tag(keyArgPanic, true)}, v.err
+		case argCheckFailure:
+			return []ddtrace.StartSpanOption{
+				tracer.Tag(keyArgCheckError, v.err.Error()),
+				tracer.Tag(keyArgCheckErrorIndex, v.ordinal),
+			}, v.wrapped
+		}
+	}
+	return nil, nil
+}
+
 var _ driver.SessionResetter = (*TracedConn)(nil)
 
 // ResetSession implements driver.SessionResetter
-func (tc *TracedConn) ResetSession(ctx context.Context) error {
-	if resetter, ok := tc.Conn.(driver.SessionResetter); ok {
+func (tc *TracedConn) ResetSession(ctx context.Context) (err error) {
+	resetter, ok := tc.Conn.(driver.SessionResetter)
+	if !ok {
+		// If driver doesn't implement driver.SessionResetter there's nothing to do
+		return nil
+	}
+	if !tc.cfg.sessionResetSpans {
 		return resetter.ResetSession(ctx)
 	}
-	// If driver doesn't implement driver.SessionResetter there's nothing to do
-	return nil
+	start := time.Now()
+	err = resetter.ResetSession(ctx)
+	tc.tryTrace(ctx, queryTypeReset, "", start, err)
+	return err
 }
 
 // traceParams stores all information related to tracing the driver.Conn
@@ -201,6 +342,11 @@ type traceParams struct {
 	cfg        *config
 	driverName string
 	meta       map[string]string
+	// argTypes are the types of the arguments of the ongoing call, as recorded by
+	// recordArgType. Calls on a connection are never concurrent.
+	argTypes []string
+	// warmup is the warmup of the database the connection belongs to, if enabled.
+	warmup *warmup
 }
 
 type contextKey int
@@ -226,6 +372,12 @@ func (tc *TracedConn) injectComments(ctx context.Context, query string, mode tra
 		spanCtx = span.Context()
 	}
 	carrier := tracer.SQLCommentCarrier{Query: query, Mode: mode, DBServiceName: tc.cfg.serviceName}
+	if len(tc.cfg.sqlCommentTags) > 0 {
+		carrier.Tags = make(map[string]string, len(tc.cfg.sqlCommentTags))
+		for k, fn := range tc.cfg.sqlCommentTags {
+			carrier.Tags[k] = fn(ctx)
+		}
+	}
 	if err := carrier.Inject(spanCtx); err != nil {
 		// this should never happen
 		log.Warn("contrib/database/sql: failed to inject query comments: %v", err)
@@ -241,6 +393,46 @@ func withDBMTraceInjectedTag(mode tracer.DBMPropagationMode) []tracer.StartSpanO
 }
 
 // tryTrace will create a span using the given arguments, but will act as a no-op when err is driver.ErrSkip.
+// spanOptions returns the options shared by the spans of the calls started at startTime
+// on behalf of service, followed by opts.
+func (tp *traceParams) spanOptions(service string, startTime time.Time, opts ...ddtrace.StartSpanOption) []ddtrace.StartSpanOption {
+	opts = append(opts,
+		tracer.ServiceName(service),
+		tracer.SpanType(tp.cfg.spanTypeOrDefault()),
+		tracer.StartTime(startTime),
+		tracer.Tag(ext.Component, "database/sql"),
+		tracer.Tag(ext.SpanKind, ext.SpanKindClient),
+		// set a default value for this tag which will be overwritten later if set in the metadata.
+		tracer.Tag(ext.DBSystem, ext.DBSystemOtherSQL),
+	)
+	for key, tag := range tp.cfg.tags {
+		opts = append(opts, tracer.Tag(key, tag))
+	}
+	if !math.IsNaN(tp.cfg.analyticsRate) {
+		opts = append(opts, tracer.Tag(ext.EventSampleRate, tp.cfg.analyticsRate))
+	}
+	return opts
+}
+
+// setConnTags sets the tags describing the connection and the database on span,
+// which traces query.
+func (tp *traceParams) setConnTags(span ddtrace.Span, query string) {
+	for k, v := range tp.meta {
+		span.SetTag(k, v)
+	}
+	if tp.cfg.dbSystem != "" {
+		span.SetTag(ext.DBSystem, tp.cfg.dbSystem)
+	}
+	if tp.cfg.driverVersion != "" {
+		span.SetTag(keyDriverVersion, tp.cfg.driverVersion)
+	}
+	if tp.cfg.dbmHostname != "" {
+		span.SetTag(ext.DBInstance, tp.cfg.dbmHostname)
+		span.SetTag(keyDBMHost, tp.cfg.dbmHostname)
+	}
+	tp.setOTelTags(span, query)
+}
+
 func (tp *traceParams) tryTrace(ctx context.Context, qtype queryType, query string, startTime time.Time, err error, spanOpts ...ddtrace.StartSpanOption) {
 	if err == driver.ErrSkip {
 		// Not a user error: driver is telling sql package that an
@@ -249,44 +441,170 @@ func (tp *traceParams) tryTrace(ctx context.Context, qtype queryType, query stri
 		// See: https://github.com/DataDog/dd-trace-go/issues/270
 		return
 	}
+	if tp.warmup != nil {
+		tp.warmup.query(qtype)
+	}
+	var span ddtrace.Span
+	if tp.cfg.slowQueryLogger != nil && query != "" && (qtype == queryTypeQuery || qtype == queryTypeExec) {
+		// logged even when the call is not traced
+		logged := query
+		defer func() { tp.logSlowQuery(ctx, span, qtype, logged, startTime) }()
+	}
 	if _, exists := tracer.SpanFromContext(ctx); tp.cfg.childSpansOnly && !exists {
 		return
 	}
+	if tp.belowThreshold(startTime, err, spanOpts) {
+		return
+	}
+	var errKey string
+	if c := tp.cfg.errorCoalescer; c != nil && err != nil && tp.isError(err) {
+		errKey = tp.errorKey(qtype, query, err)
+		if !c.claim(errKey) {
+			// counted on the span of the first occurrence
+			return
+		}
+	}
 	name := fmt.Sprintf("%s.query", tp.driverName)
-	opts := append(spanOpts,
-		tracer.ServiceName(tp.cfg.serviceName),
-		tracer.SpanType(ext.SpanTypeSQL),
-		tracer.StartTime(startTime),
-		tracer.Tag(ext.Component, "database/sql"),
-		tracer.Tag(ext.SpanKind, ext.SpanKindClient),
-		// set a default value for this tag which will be overwritten later if set in the metadata.
-		tracer.Tag(ext.DBSystem, ext.DBSystemOtherSQL),
+	service := tp.cfg.shardServiceName(ctx, query, tp.cfg.serviceNameFor(query))
+	span, _ = tracer.StartSpanFromContext(ctx, name, tp.spanOptions(service, startTime, spanOpts...)...)
+	var (
+		operation    string
+		hasOperation bool
 	)
-	if tp.cfg.tags != nil {
-		for key, tag := range tp.cfg.tags {
-			opts = append(opts, tracer.Tag(key, tag))
-		}
+	if tp.cfg.operationPrefix != "" {
+		operation, hasOperation = operationFromComment(query, tp.cfg.operationPrefix)
 	}
-	if !math.IsNaN(tp.cfg.analyticsRate) {
-		opts = append(opts, tracer.Tag(ext.EventSampleRate, tp.cfg.analyticsRate))
+	var commentTags map[string]string
+	if tp.cfg.sqlCommentExtraction {
+		if tags, rest, ok := extractSQLComment(query); ok {
+			// the comment is left out of the resource, which it would make unique
+			commentTags, query = tags, rest
+		}
 	}
-	span, _ := tracer.StartSpanFromContext(ctx, name, opts...)
 	resource := string(qtype)
-	if query != "" {
-		resource = query
+	switch {
+	case hasOperation:
+		// the operation groups the queries of the same kind, whatever their text
+		resource = operation
+		span.SetTag(keyOperation, operation)
+	case query != "":
+		resource = tp.resourceName(query)
 	}
 	span.SetTag("sql.query_type", string(qtype))
 	span.SetTag(ext.ResourceName, resource)
-	for k, v := range tp.meta {
-		span.SetTag(k, v)
+	tp.setEnvVersion(span)
+	if tp.cfg.querySignature && query != "" {
+		if sig, ok := querySignature(query, tp.cfg.queryCache); ok {
+			span.SetTag(keyQuerySignature, sig)
+		}
+	}
+	if tp.cfg.featureTags && query != "" {
+		if features := detectFeatures(query); features != "" {
+			span.SetTag(keyFeatures, features)
+		}
+	}
+	for k, v := range commentTags {
+		span.SetTag(keySQLCommentPrefix+k, v)
 	}
+	tp.setConnTags(span, query)
 	if meta, ok := ctx.Value(spanTagsKey).(map[string]string); ok {
 		for k, v := range meta {
 			span.SetTag(k, v)
 		}
 	}
-	if err != nil && (tp.cfg.errCheck == nil || tp.cfg.errCheck(err)) {
-		span.SetTag(ext.Error, err)
+	switch qtype {
+	case queryTypeQuery, queryTypeExec, queryTypeConnect:
+		tp.setContextTags(ctx, span)
+	}
+	if err != nil {
+		tp.setError(span, err)
+	}
+	if errKey != "" {
+		tp.cfg.errorCoalescer.hold(errKey, span, time.Now())
+		return
 	}
 	span.Finish()
 }
+
+// maxContextTags is the maximum number of tags set on a span from the ones returned
+// by the function set using WithContextTags.
+const maxContextTags = 32
+
+// setContextTags sets the tags returned by the function set using WithContextTags for
+// ctx on span. Only the first maxContextTags tags, in the order of their keys, are set.
+func (tp *traceParams) setContextTags(ctx context.Context, span ddtrace.Span) {
+	if tp.cfg.contextTags == nil {
+		return
+	}
+	tags := tp.cfg.contextTags(ctx)
+	if len(tags) <= maxContextTags {
+		for k, v := range tags {
+			span.SetTag(k, v)
+		}
+		return
+	}
+	log.Debug("contrib/database/sql: context tags function returned %d tags, keeping the first %d", len(tags), maxContextTags)
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys[:maxContextTags] {
+		span.SetTag(k, tags[k])
+	}
+}
+
+// setEnvVersion overrides the global env and version tags of span with the ones
+// configured for the database, if any. It is called once the span is started, as
+// the global ones are set when starting it.
+func (tp *traceParams) setEnvVersion(span ddtrace.Span) {
+	if tp.cfg.env != "" {
+		span.SetTag(ext.Environment, tp.cfg.env)
+	}
+	if tp.cfg.version != "" {
+		span.SetTag(ext.Version, tp.cfg.version)
+	}
+}
+
+// setError tags span with the given error, unless it should not be considered an error
+// according to the configuration: constraint violations are only tagged as such when
+// WithConstraintViolationNonError is used, and the error check is consulted otherwise.
+func (tp *traceParams) setError(span ddtrace.Span, err error) {
+	if tp.cfg.constraintViolationNonError && isConstraintViolation(err) {
+		span.SetTag(keyConstraintViolation, true)
+		return
+	}
+	if !tp.isError(err) {
+		return
+	}
+	span.SetTag(ext.Error, err)
+	if key, code, ok := errorCode(err); ok {
+		span.SetTag(key, code)
+	}
+}
+
+// isError reports whether err is tagged as an error on spans, according to the configuration.
+func (tp *traceParams) isError(err error) bool {
+	if tp.cfg.constraintViolationNonError && isConstraintViolation(err) {
+		return false
+	}
+	return tp.cfg.errCheck == nil || tp.cfg.errCheck(err)
+}
+
+// belowThreshold reports whether a call started at startTime, which returned err,
+// completed fast enough to not be traced according to the configured slow query threshold.
+// Calls whose span ID was injected into the query for DBM, as tagged by opts, are always
+// traced, since the database links the query to that span.
+func (tp *traceParams) belowThreshold(startTime time.Time, err error, opts []ddtrace.StartSpanOption) bool {
+	if tp.cfg.slowQueryThreshold <= 0 || err != nil {
+		return false
+	}
+	if time.Since(startTime) >= tp.cfg.slowQueryThreshold {
+		return false
+	}
+	cfg := ddtrace.StartSpanConfig{Tags: make(map[string]interface{})}
+	for _, fn := range opts {
+		fn(&cfg)
+	}
+	return cfg.Tags[keyDBMTraceInjected] != true
+}
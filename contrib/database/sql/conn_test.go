@@ -7,13 +7,19 @@ package sql
 
 import (
 	"context"
+	"database/sql"
 	"database/sql/driver"
+	"errors"
+	"fmt"
 	"log"
 	"strings"
 	"testing"
+	"time"
 
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/database/sql/internal"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 
 	"github.com/go-sql-driver/mysql"
 	"github.com/lib/pq"
@@ -311,3 +317,649 @@ func TestWithCustomTag(t *testing.T) {
 		})
 	}
 }
+
+func TestPrepareContext(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	d := &internal.MockDriver{}
+	Register("test", d)
+	defer unregister("test")
+
+	db, err := Open("test", "dn")
+	require.NoError(t, err)
+	defer db.Close()
+
+	mt.Reset()
+	stmt, err := db.PrepareContext(context.Background(), "SELECT 1 FROM DUAL")
+	require.NoError(t, err)
+	_, err = stmt.ExecContext(context.Background())
+	require.NoError(t, err)
+
+	spans := spansOfType(mt.FinishedSpans(), queryTypePrepare)
+	require.Len(t, spans, 1)
+	prepareSpan := spans[0]
+	assert.Equal(t, "test.query", prepareSpan.OperationName())
+	assert.Equal(t, "SELECT 1 FROM DUAL", prepareSpan.Tag(ext.ResourceName))
+	assert.Nil(t, prepareSpan.Tag(ext.Error))
+
+	spans = spansOfType(mt.FinishedSpans(), queryTypeExec)
+	require.Len(t, spans, 1)
+	assert.Equal(t, "SELECT 1 FROM DUAL", spans[0].Tag(ext.ResourceName))
+}
+
+type panicValuer struct{}
+
+func (panicValuer) Value() (driver.Value, error) { panic("boom") }
+
+func TestValuerPanic(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	d := &internal.MockDriver{}
+	Register("test", d)
+	defer unregister("test")
+
+	db, err := Open("test", "dn")
+	require.NoError(t, err)
+	defer db.Close()
+
+	t.Run("exec", func(t *testing.T) {
+		mt.Reset()
+		_, err := db.ExecContext(context.Background(), "INSERT INTO t VALUES (?)", panicValuer{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "panic converting argument 1: boom")
+		assert.Empty(t, d.Executed)
+
+		spans := spansOfType(mt.FinishedSpans(), queryTypeExec)
+		require.Len(t, spans, 1)
+		assert.Equal(t, "INSERT INTO t VALUES (?)", spans[0].Tag(ext.ResourceName))
+		assert.Equal(t, true, spans[0].Tag(keyArgPanic))
+		assert.Equal(t, err, spans[0].Tag(ext.Error))
+	})
+
+	t.Run("query", func(t *testing.T) {
+		mt.Reset()
+		_, err := db.QueryContext(context.Background(), "SELECT * FROM t WHERE id = ?", panicValuer{})
+		require.Error(t, err)
+
+		spans := spansOfType(mt.FinishedSpans(), queryTypeQuery)
+		require.Len(t, spans, 1)
+		assert.Equal(t, true, spans[0].Tag(keyArgPanic))
+		assert.Equal(t, err, spans[0].Tag(ext.Error))
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		mt.Reset()
+		_, err := db.ExecContext(context.Background(), "INSERT INTO t VALUES (?)", sql.NullString{String: "a", Valid: true})
+		require.NoError(t, err)
+
+		spans := spansOfType(mt.FinishedSpans(), queryTypeExec)
+		require.Len(t, spans, 1)
+		assert.Nil(t, spans[0].Tag(keyArgPanic))
+		assert.Nil(t, spans[0].Tag(ext.Error))
+	})
+}
+
+// checkerDriver wraps internal.MockDriver with connections implementing driver.NamedValueChecker,
+// rejecting float arguments.
+type checkerDriver struct {
+	*internal.MockDriver
+}
+
+func (d *checkerDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.MockDriver.Open(name)
+	return &checkerConn{c.(queryerExecerConn)}, err
+}
+
+type queryerExecerConn interface {
+	driver.Conn
+	driver.QueryerContext
+	driver.ExecerContext
+}
+
+type checkerConn struct {
+	queryerExecerConn
+}
+
+var errFloatArg = errors.New("float arguments are not supported")
+
+func (c *checkerConn) CheckNamedValue(v *driver.NamedValue) error {
+	if _, ok := v.Value.(float64); ok {
+		return errFloatArg
+	}
+	return driver.ErrSkip
+}
+
+func TestWithArgCheckErrors(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	for name, enabled := range map[string]bool{"enabled": true, "disabled": false} {
+		t.Run(name, func(t *testing.T) {
+			var opts []Option
+			if enabled {
+				opts = append(opts, WithArgCheckErrors())
+			}
+			d := &checkerDriver{MockDriver: &internal.MockDriver{}}
+			Register("test", d, opts...)
+			defer unregister("test")
+			db, err := Open("test", "dn")
+			require.NoError(t, err)
+			defer db.Close()
+
+			mt.Reset()
+			_, err = db.ExecContext(context.Background(), "INSERT INTO t VALUES (?, ?)", "a", 1.5)
+			// the error is the same whether the option is enabled or not
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, errFloatArg))
+			assert.Equal(t, "sql: converting argument $2 type: float arguments are not supported", err.Error())
+			assert.Empty(t, d.Executed)
+
+			spans := spansOfType(mt.FinishedSpans(), queryTypeExec)
+			if !enabled {
+				assert.Empty(t, spans)
+				return
+			}
+			require.Len(t, spans, 1)
+			assert.Equal(t, "float arguments are not supported", spans[0].Tag(keyArgCheckError))
+			assert.Equal(t, 2, spans[0].Tag(keyArgCheckErrorIndex))
+			assert.NotNil(t, spans[0].Tag(ext.Error))
+
+			mt.Reset()
+			rows, err := db.QueryContext(context.Background(), "SELECT * FROM t WHERE id = ?", 1)
+			require.NoError(t, err)
+			rows.Close()
+			spans = spansOfType(mt.FinishedSpans(), queryTypeQuery)
+			require.Len(t, spans, 1)
+			assert.Nil(t, spans[0].Tag(keyArgCheckError))
+		})
+	}
+}
+
+// converterStmt is a driver.Stmt with a column converter and a named value checker,
+// rejecting string arguments.
+type converterStmt struct {
+	driver.Stmt
+}
+
+func (converterStmt) ColumnConverter(int) driver.ValueConverter { return driver.Bool }
+
+func (converterStmt) CheckNamedValue(v *driver.NamedValue) error {
+	if _, ok := v.Value.(string); ok {
+		return errors.New("string arguments are not supported")
+	}
+	return driver.ErrSkip
+}
+
+func TestCheckNamedValue(t *testing.T) {
+	conn, err := (&internal.MockDriver{}).Open("dn")
+	require.NoError(t, err)
+	tp := &traceParams{cfg: new(config)}
+	defaults(tp.cfg)
+
+	t.Run("conn", func(t *testing.T) {
+		tc := &TracedConn{conn, tp}
+		v := &driver.NamedValue{Ordinal: 1, Value: 1}
+		// database/sql converts the values the driver does not check
+		assert.Equal(t, driver.ErrSkip, tc.CheckNamedValue(v))
+		assert.Equal(t, 1, v.Value)
+
+		v = &driver.NamedValue{Ordinal: 1, Value: sql.NullString{String: "a", Valid: true}}
+		assert.Equal(t, driver.ErrSkip, tc.CheckNamedValue(v))
+		assert.Equal(t, "a", v.Value)
+
+		v = &driver.NamedValue{Ordinal: 1, Value: (*sql.NullString)(nil)}
+		assert.Equal(t, driver.ErrSkip, tc.CheckNamedValue(v))
+		assert.Nil(t, v.Value)
+	})
+
+	t.Run("checker", func(t *testing.T) {
+		tc := &TracedConn{&checkerConn{conn.(queryerExecerConn)}, tp}
+		assert.EqualError(t, tc.CheckNamedValue(&driver.NamedValue{Ordinal: 1, Value: 1.5}), "float arguments are not supported")
+		v := &driver.NamedValue{Ordinal: 1, Value: sql.NullString{String: "a", Valid: true}}
+		assert.Equal(t, driver.ErrSkip, tc.CheckNamedValue(v))
+		assert.Equal(t, "a", v.Value)
+	})
+
+	t.Run("stmt", func(t *testing.T) {
+		tc := &TracedConn{&checkerConn{conn.(queryerExecerConn)}, tp}
+		s := tc.newTracedStmt(context.Background(), converterStmt{}, "SELECT ?")
+		// the checker of the statement takes precedence over the one of the connection
+		assert.EqualError(t, s.CheckNamedValue(&driver.NamedValue{Ordinal: 1, Value: "a"}), "string arguments are not supported")
+		assert.Equal(t, driver.ErrSkip, s.CheckNamedValue(&driver.NamedValue{Ordinal: 1, Value: 1.5}))
+		assert.Equal(t, driver.Bool, s.ColumnConverter(0))
+
+		s = tc.newTracedStmt(context.Background(), nil, "SELECT ?")
+		assert.EqualError(t, s.CheckNamedValue(&driver.NamedValue{Ordinal: 1, Value: 1.5}), "float arguments are not supported")
+		assert.Equal(t, driver.DefaultParameterConverter, s.ColumnConverter(0))
+	})
+}
+
+// resetterDriver wraps internal.MockDriver with connections implementing driver.SessionResetter.
+type resetterDriver struct {
+	*internal.MockDriver
+	err    error
+	resets int
+}
+
+func (d *resetterDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.MockDriver.Open(name)
+	return &resetterConn{Conn: c, driver: d}, err
+}
+
+type resetterConn struct {
+	driver.Conn
+	driver *resetterDriver
+}
+
+func (c *resetterConn) ResetSession(_ context.Context) error {
+	c.driver.resets++
+	return c.driver.err
+}
+
+func TestResetSession(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	for name, tc := range map[string]struct {
+		opts  []Option
+		err   error
+		spans int
+	}{
+		"disabled": {spans: 0},
+		"enabled":  {opts: []Option{WithSessionResetSpans()}, spans: 1},
+		"error":    {opts: []Option{WithSessionResetSpans()}, err: errors.New("reset failed"), spans: 1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			d := &resetterDriver{MockDriver: &internal.MockDriver{}, err: tc.err}
+			Register("test", d, tc.opts...)
+			defer unregister("test")
+			db, err := Open("test", "dn")
+			require.NoError(t, err)
+			defer db.Close()
+			db.SetMaxOpenConns(1)
+
+			mt.Reset()
+			// the second call reuses the pooled connection, which resets its session;
+			// database/sql only reports driver.ErrBadConn from ResetSession to callers
+			require.NoError(t, db.PingContext(context.Background()))
+			require.NoError(t, db.PingContext(context.Background()))
+			assert.Equal(t, 1, d.resets)
+
+			spans := spansOfType(mt.FinishedSpans(), queryTypeReset)
+			require.Len(t, spans, tc.spans)
+			for _, s := range spans {
+				assert.Equal(t, "test.query", s.OperationName())
+				assert.Equal(t, queryTypeReset, s.Tag(ext.ResourceName))
+				assert.Equal(t, tc.err, s.Tag(ext.Error))
+			}
+		})
+	}
+
+	t.Run("not-implemented", func(t *testing.T) {
+		tc := &TracedConn{traceParams: &traceParams{cfg: &config{sessionResetSpans: true}}}
+		assert.NoError(t, tc.ResetSession(context.Background()))
+	})
+}
+
+// slowDriver wraps internal.MockDriver with connections sleeping for the given
+// delay before executing queries mentioning "pg_sleep", or failing those mentioning "fail".
+type slowDriver struct {
+	*internal.MockDriver
+	delay time.Duration
+}
+
+func (d *slowDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.MockDriver.Open(name)
+	return &slowConn{Conn: c, delay: d.delay}, err
+}
+
+type slowConn struct {
+	driver.Conn
+	delay time.Duration
+}
+
+func (c *slowConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if strings.Contains(query, "pg_sleep") {
+		time.Sleep(c.delay)
+	}
+	if strings.Contains(query, "fail") {
+		return nil, errors.New("query failed")
+	}
+	return c.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+}
+
+func TestSlowQueryThreshold(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	// all the successful calls of the test are faster than the threshold
+	const threshold = time.Minute
+
+	t.Run("calls", func(t *testing.T) {
+		d := &slowDriver{MockDriver: &internal.MockDriver{}}
+		Register("test", d, WithSlowQueryThreshold(threshold))
+		defer unregister("test")
+		db, err := Open("test", "dn")
+		require.NoError(t, err)
+		defer db.Close()
+
+		mt.Reset()
+		parent, ctx := tracer.StartSpanFromContext(context.Background(), "parent")
+		_, err = db.ExecContext(ctx, "SELECT 1")
+		require.NoError(t, err)
+		_, err = db.ExecContext(ctx, "SELECT fail")
+		require.Error(t, err)
+		parent.Finish()
+
+		assert.Equal(t, []string{"SELECT 1"}, d.Executed)
+		spans := spansOfType(mt.FinishedSpans(), queryTypeExec)
+		require.Len(t, spans, 1)
+		assert.Equal(t, "SELECT fail", spans[0].Tag(ext.ResourceName))
+		assert.NotNil(t, spans[0].Tag(ext.Error))
+		// the spans kept are still part of the calling trace
+		assert.Equal(t, parent.Context().SpanID(), spans[0].ParentID())
+		assert.Equal(t, parent.Context().TraceID(), spans[0].TraceID())
+	})
+
+	t.Run("slow", func(t *testing.T) {
+		tp := &traceParams{cfg: new(config), driverName: "test"}
+		defaults(tp.cfg)
+		WithSlowQueryThreshold(threshold)(tp.cfg)
+
+		mt.Reset()
+		now := time.Now()
+		tp.tryTrace(context.Background(), queryTypeExec, "SELECT 1", now, nil)
+		tp.tryTrace(context.Background(), queryTypeExec, "SELECT pg_sleep(120)", now.Add(-2*threshold), nil)
+
+		spans := spansOfType(mt.FinishedSpans(), queryTypeExec)
+		require.Len(t, spans, 1)
+		assert.Equal(t, "SELECT pg_sleep(120)", spans[0].Tag(ext.ResourceName))
+		assert.True(t, spans[0].FinishTime().Sub(spans[0].StartTime()) >= threshold)
+	})
+
+	t.Run("dbm", func(t *testing.T) {
+		d := &slowDriver{MockDriver: &internal.MockDriver{}}
+		Register("test", d, WithSlowQueryThreshold(threshold), WithDBMPropagation(tracer.DBMPropagationModeFull))
+		defer unregister("test")
+		db, err := Open("test", "dn")
+		require.NoError(t, err)
+		defer db.Close()
+
+		mt.Reset()
+		_, err = db.ExecContext(context.Background(), "SELECT 1")
+		require.NoError(t, err)
+
+		// the span whose ID was injected into the query is kept
+		spans := spansOfType(mt.FinishedSpans(), queryTypeExec)
+		require.Len(t, spans, 1)
+		require.Len(t, d.Executed, 1)
+		assert.Contains(t, d.Executed[0], fmt.Sprintf("%016x", spans[0].SpanID()))
+	})
+}
+
+func TestWithEnvVersion(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	Register("test", &internal.MockDriver{}, WithVersion("1.2.3"))
+	defer unregister("test")
+
+	exec := func(opts ...Option) mocktracer.Span {
+		db, err := Open("test", "dn", opts...)
+		require.NoError(t, err)
+		defer db.Close()
+
+		mt.Reset()
+		_, err = db.ExecContext(context.Background(), "SELECT 1")
+		require.NoError(t, err)
+		spans := spansOfType(mt.FinishedSpans(), queryTypeExec)
+		require.Len(t, spans, 1)
+		return spans[0]
+	}
+
+	billing := exec(WithEnv("billing-prod"))
+	assert.Equal(t, "billing-prod", billing.Tag(ext.Environment))
+	assert.Equal(t, "1.2.3", billing.Tag(ext.Version))
+
+	search := exec(WithEnv("search-prod"), WithVersion("2.0.0"))
+	assert.Equal(t, "search-prod", search.Tag(ext.Environment))
+	assert.Equal(t, "2.0.0", search.Tag(ext.Version))
+
+	// the global values are kept when not set
+	other := exec()
+	assert.Nil(t, other.Tag(ext.Environment))
+	assert.Equal(t, "1.2.3", other.Tag(ext.Version))
+}
+
+type tenantKey struct{}
+
+func TestWithContextTags(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	Register("test", &internal.MockDriver{}, WithContextTags(func(ctx context.Context) map[string]interface{} {
+		tenant, ok := ctx.Value(tenantKey{}).(string)
+		if !ok {
+			return nil
+		}
+		return map[string]interface{}{"tenant": tenant, "flags.new_billing": true}
+	}))
+	defer unregister("test")
+	db, err := Open("test", "dn")
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+	rows, err := db.QueryContext(ctx, "SELECT 1")
+	require.NoError(t, err)
+	rows.Close()
+	_, err = db.ExecContext(context.Background(), "SELECT 1")
+	require.NoError(t, err)
+
+	spans := spansOfType(mt.FinishedSpans(), queryTypeQuery)
+	require.Len(t, spans, 1)
+	assert.Equal(t, "acme", spans[0].Tag("tenant"))
+	assert.Equal(t, true, spans[0].Tag("flags.new_billing"))
+	spans = spansOfType(mt.FinishedSpans(), queryTypeExec)
+	require.Len(t, spans, 1)
+	assert.Nil(t, spans[0].Tag("tenant"))
+
+	t.Run("cap", func(t *testing.T) {
+		mt.Reset()
+		tags := make(map[string]interface{})
+		for i := 0; i < 100; i++ {
+			tags[fmt.Sprintf("tag.%03d", i)] = i
+		}
+		tp := &traceParams{cfg: &config{contextTags: func(context.Context) map[string]interface{} { return tags }}}
+		span := tracer.StartSpan("test")
+		tp.setContextTags(context.Background(), span)
+		span.Finish()
+
+		got := mt.FinishedSpans()[0].Tags()
+		assert.Equal(t, 0, got["tag.000"])
+		assert.Equal(t, maxContextTags-1, got[fmt.Sprintf("tag.%03d", maxContextTags-1)])
+		assert.NotContains(t, got, fmt.Sprintf("tag.%03d", maxContextTags))
+	})
+}
+
+func TestWithSpanTypeDBSystem(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	for name, tt := range map[string]struct {
+		opts     []Option
+		spanType string
+		dbSystem string
+	}{
+		"default":  {spanType: ext.SpanTypeSQL, dbSystem: ext.DBSystemOtherSQL},
+		"override": {opts: []Option{WithSpanType("dynamodb"), WithDBSystem("dynamodb")}, spanType: "dynamodb", dbSystem: "dynamodb"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			Register("test", &internal.MockDriver{}, tt.opts...)
+			defer unregister("test")
+			db, err := Open("test", "dn")
+			require.NoError(t, err)
+			defer db.Close()
+
+			mt.Reset()
+			rows, err := db.QueryContext(context.Background(), "SELECT * FROM orders WHERE id = ?", 1)
+			require.NoError(t, err)
+			rows.Close()
+
+			spans := spansOfType(mt.FinishedSpans(), queryTypeQuery)
+			require.Len(t, spans, 1)
+			assert.Equal(t, tt.spanType, spans[0].Tag(ext.SpanType))
+			assert.Equal(t, tt.dbSystem, spans[0].Tag(ext.DBSystem))
+		})
+	}
+
+	t.Run("dsn", func(t *testing.T) {
+		// the override takes precedence over the system inferred from the driver
+		tp := &traceParams{
+			driverName: "postgres",
+			cfg:        &config{dbSystem: "cockroachdb"},
+			meta:       map[string]string{ext.DBSystem: ext.DBSystemPostgreSQL},
+		}
+		mt.Reset()
+		tp.tryTrace(context.Background(), queryTypeExec, "SELECT 1", time.Now(), nil)
+		spans := mt.FinishedSpans()
+		require.Len(t, spans, 1)
+		assert.Equal(t, "cockroachdb", spans[0].Tag(ext.DBSystem))
+		assert.Equal(t, ext.SpanTypeSQL, spans[0].Tag(ext.SpanType))
+	})
+}
+
+func TestWithReadWriteServiceSplit(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	Register("test", &internal.MockDriver{}, WithServiceName("orders-db"), WithReadWriteServiceSplit("orders-db-read", "orders-db-write"))
+	defer unregister("test")
+	db, err := Open("test", "dn")
+	require.NoError(t, err)
+	defer db.Close()
+
+	mt.Reset()
+	rows, err := db.QueryContext(context.Background(), "SELECT * FROM orders WHERE id = ?", 1)
+	require.NoError(t, err)
+	rows.Close()
+	_, err = db.ExecContext(context.Background(), "INSERT INTO orders (id) VALUES (?)", 1)
+	require.NoError(t, err)
+
+	spans := spansOfType(mt.FinishedSpans(), queryTypeQuery)
+	require.Len(t, spans, 1)
+	assert.Equal(t, "orders-db-read", spans[0].Tag(ext.ServiceName))
+	spans = spansOfType(mt.FinishedSpans(), queryTypeExec)
+	require.Len(t, spans, 1)
+	assert.Equal(t, "orders-db-write", spans[0].Tag(ext.ServiceName))
+	spans = spansOfType(mt.FinishedSpans(), string(queryTypeConnect))
+	require.NotEmpty(t, spans)
+	assert.Equal(t, "orders-db", spans[0].Tag(ext.ServiceName))
+}
+
+func TestWithPostgresPlanTags(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	for name, tt := range map[string]struct {
+		driverName string
+		dsn        string
+		opts       []Option
+		want       interface{}
+	}{
+		"postgres": {driverName: "postgres", dsn: "postgres://bob@127.0.0.1:5432/db", opts: []Option{WithPostgresPlanTags()}, want: true},
+		"pgx":      {driverName: "pgx", dsn: "postgres://bob@127.0.0.1:5432/db", opts: []Option{WithPostgresPlanTags()}, want: true},
+		"mysql":    {driverName: "mysql", dsn: "bob:secret@tcp(127.0.0.1:3306)/db", opts: []Option{WithPostgresPlanTags()}},
+		"disabled": {driverName: "postgres", dsn: "postgres://bob@127.0.0.1:5432/db"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			Register(tt.driverName, &internal.MockDriver{}, tt.opts...)
+			defer unregister(tt.driverName)
+			db, err := Open(tt.driverName, tt.dsn)
+			require.NoError(t, err)
+			defer db.Close()
+
+			mt.Reset()
+			stmt, err := db.Prepare("INSERT INTO t VALUES (1)")
+			require.NoError(t, err)
+			defer stmt.Close()
+			_, err = stmt.Exec()
+			require.NoError(t, err)
+			_, err = db.Exec("INSERT INTO t VALUES (1)")
+			require.NoError(t, err)
+
+			spans := spansOfType(mt.FinishedSpans(), queryTypeExec)
+			require.Len(t, spans, 2)
+			assert.Equal(t, tt.want, spans[0].Tag(keyPGPrepared))
+			assert.Nil(t, spans[1].Tag(keyPGPrepared), "statements which are not prepared are not tagged")
+		})
+	}
+}
+
+func TestTxOptionsTags(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	Register("test", &internal.MockDriver{})
+	defer unregister("test")
+	db, err := Open("test", "dn")
+	require.NoError(t, err)
+	defer db.Close()
+
+	for _, tt := range []struct {
+		opts      *sql.TxOptions
+		isolation string
+		readOnly  bool
+	}{
+		{opts: nil, isolation: "default"},
+		{opts: &sql.TxOptions{Isolation: sql.LevelSerializable}, isolation: "serializable"},
+		{opts: &sql.TxOptions{Isolation: sql.LevelReadCommitted, ReadOnly: true}, isolation: "read_committed", readOnly: true},
+		{opts: &sql.TxOptions{Isolation: sql.LevelRepeatableRead}, isolation: "repeatable_read"},
+		{opts: &sql.TxOptions{ReadOnly: true}, isolation: "default", readOnly: true},
+	} {
+		t.Run(tt.isolation, func(t *testing.T) {
+			mt.Reset()
+			tx, err := db.BeginTx(context.Background(), tt.opts)
+			require.NoError(t, err)
+			require.NoError(t, tx.Rollback())
+
+			spans := spansOfType(mt.FinishedSpans(), queryTypeBegin)
+			require.Len(t, spans, 1)
+			assert.Equal(t, tt.isolation, spans[0].Tag(keyTxIsolation))
+			assert.Equal(t, tt.readOnly, spans[0].Tag(keyTxReadOnly))
+		})
+	}
+}
+
+func TestWithDBMHostname(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	for name, tt := range map[string]struct {
+		opts []Option
+		want interface{}
+	}{
+		"override": {opts: []Option{WithDBMHostname("orders-db-1.internal")}, want: "orders-db-1.internal"},
+		"default":  {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			Register("postgres", &internal.MockDriver{}, tt.opts...)
+			defer unregister("postgres")
+			db, err := Open("postgres", "postgres://bob@pgbouncer.internal:6432/orders")
+			require.NoError(t, err)
+			defer db.Close()
+
+			mt.Reset()
+			_, err = db.Exec("INSERT INTO orders VALUES (1)")
+			require.NoError(t, err)
+
+			spans := spansOfType(mt.FinishedSpans(), queryTypeExec)
+			require.Len(t, spans, 1)
+			assert.Equal(t, tt.want, spans[0].Tag(keyDBMHost))
+			assert.Equal(t, tt.want, spans[0].Tag(ext.DBInstance))
+			assert.Equal(t, "pgbouncer.internal", spans[0].Tag(ext.TargetHost))
+		})
+	}
+}
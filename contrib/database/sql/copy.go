@@ -0,0 +1,76 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+// keyRowsCopied holds the number of rows sent by a bulk copy operation, as traced when
+// enabled using WithCopyAggregation.
+const keyRowsCopied = "sql.rows_copied"
+
+// isCopyFromStdin reports whether query is a bulk copy statement reading its rows from the
+// client, such as the ones prepared using pq.CopyIn. The rows of such statements are sent by
+// executing them once per row, and the copy is completed by executing them without arguments.
+func isCopyFromStdin(query string) bool {
+	q := strings.ToUpper(strings.TrimSpace(query))
+	return strings.HasPrefix(q, "COPY ") && strings.Contains(q, "FROM STDIN")
+}
+
+// copyState holds the state of the bulk copy operation of a statement, traced as a whole.
+type copyState struct {
+	// start is the time the first row was sent at.
+	start time.Time
+	// rows is the number of rows sent so far.
+	rows int
+}
+
+// execCopy executes the bulk copy statement s with args, which are either a row to copy, or
+// none when completing the copy. Rows are not traced individually: the whole operation is
+// traced once completed, or as soon as a row fails to be sent.
+func (s *tracedStmt) execCopy(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	if s.copy.rows == 0 {
+		s.copy.start = start
+	}
+	res, err := s.execDriver(ctx, args)
+	if len(args) > 0 && err == nil {
+		s.copy.rows++
+		return res, nil
+	}
+	s.finishCopy(ctx, err)
+	return res, err
+}
+
+// finishCopy traces the bulk copy operation of the statement, which ended with err, and
+// resets its state.
+func (s *tracedStmt) finishCopy(ctx context.Context, err error) {
+	s.tryTrace(ctx, queryTypeCopy, s.query, s.copy.start, err, tracer.Tag(keyRowsCopied, s.copy.rows))
+	*s.copy = copyState{}
+}
+
+// execDriver executes the statement with args using the driver, without tracing it.
+func (s *tracedStmt) execDriver(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if stmtExecContext, ok := s.Stmt.(driver.StmtExecContext); ok {
+		return stmtExecContext.ExecContext(ctx, args)
+	}
+	dargs, err := namedValueToValue(args)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+	return s.Exec(dargs)
+}
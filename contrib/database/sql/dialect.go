@@ -0,0 +1,65 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import "strings"
+
+const keyFeatures = "sql.features"
+
+// dialectFeatures lists vendor-specific syntax which can be detected in queries, along
+// with the marker identifying it. Detection is a cheap heuristic: it does not take string
+// literals or comments into account.
+var dialectFeatures = []struct {
+	name    string
+	marker  string
+	keyword bool // the marker is matched as a whole word, ignoring case
+}{
+	{name: "pg_cast", marker: "::"},
+	{name: "backtick_identifier", marker: "`"},
+	{name: "returning", marker: "RETURNING", keyword: true},
+	{name: "window_function", marker: "OVER", keyword: true},
+	{name: "on_conflict", marker: "ON CONFLICT", keyword: true},
+	{name: "on_duplicate_key", marker: "ON DUPLICATE KEY", keyword: true},
+}
+
+// detectFeatures returns a comma-separated list of the dialect features used by query,
+// or an empty string if none were found.
+func detectFeatures(query string) string {
+	var features []string
+	for _, f := range dialectFeatures {
+		var found bool
+		if f.keyword {
+			found = containsKeyword(query, f.marker)
+		} else {
+			found = strings.Contains(query, f.marker)
+		}
+		if found {
+			features = append(features, f.name)
+		}
+	}
+	return strings.Join(features, ",")
+}
+
+// containsKeyword reports whether query contains kw as a whole word, ignoring case.
+func containsKeyword(query, kw string) bool {
+	for i := 0; i+len(kw) <= len(query); i++ {
+		if !strings.EqualFold(query[i:i+len(kw)], kw) {
+			continue
+		}
+		if i > 0 && isWordChar(query[i-1]) {
+			continue
+		}
+		if end := i + len(kw); end < len(query) && isWordChar(query[end]) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
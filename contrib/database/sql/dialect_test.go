@@ -0,0 +1,68 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"context"
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/database/sql/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectFeatures(t *testing.T) {
+	for _, tt := range []struct {
+		query string
+		want  string
+	}{
+		{query: "SELECT 1", want: ""},
+		{query: "SELECT id::text FROM users", want: "pg_cast"},
+		{query: "SELECT `id` FROM `users`", want: "backtick_identifier"},
+		{query: "INSERT INTO users (name) VALUES ($1) returning id", want: "returning"},
+		{query: "SELECT rank() OVER (PARTITION BY dept) FROM emp", want: "window_function"},
+		{query: "SELECT overdue, returning_user FROM loans", want: ""},
+		{query: "INSERT INTO t VALUES (1) ON CONFLICT DO NOTHING RETURNING id", want: "returning,on_conflict"},
+		{query: "INSERT INTO `t` VALUES (1) on duplicate key update a = 1", want: "backtick_identifier,on_duplicate_key"},
+	} {
+		t.Run(tt.query, func(t *testing.T) {
+			assert.Equal(t, tt.want, detectFeatures(tt.query))
+		})
+	}
+}
+
+func TestDialectFeatureTags(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	const query = "SELECT id::text FROM users"
+	for name, tc := range map[string]struct {
+		opts []Option
+		want interface{}
+	}{
+		"enabled":  {opts: []Option{WithDialectFeatureTags()}, want: "pg_cast"},
+		"disabled": {want: nil},
+	} {
+		t.Run(name, func(t *testing.T) {
+			Register("test", &internal.MockDriver{}, tc.opts...)
+			defer unregister("test")
+			db, err := Open("test", "dn")
+			require.NoError(t, err)
+			defer db.Close()
+
+			mt.Reset()
+			rows, err := db.QueryContext(context.Background(), query)
+			require.NoError(t, err)
+			rows.Close()
+
+			spans := spansOfType(mt.FinishedSpans(), queryTypeQuery)
+			require.Len(t, spans, 1)
+			assert.Equal(t, tc.want, spans[0].Tag(keyFeatures))
+		})
+	}
+}
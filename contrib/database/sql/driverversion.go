@@ -0,0 +1,60 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"runtime/debug"
+	"strings"
+)
+
+const (
+	// keyDriverVersion holds the version of the module of the driver.
+	keyDriverVersion = "sql.driver.version"
+	// unknownDriverVersion is the driver version used when it cannot be determined.
+	unknownDriverVersion = "unknown"
+)
+
+// driverVersion returns the version of the module providing the driver d, as found in
+// the build information of the binary, or "unknown" if it cannot be determined, such as
+// when the driver is part of the main module.
+func driverVersion(d driver.Driver) string {
+	t := reflect.TypeOf(d)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return unknownDriverVersion
+	}
+	return moduleVersion(info, t.PkgPath())
+}
+
+// moduleVersion returns the version of the dependency providing the package pkg, according
+// to info, or "unknown" if there is none.
+func moduleVersion(info *debug.BuildInfo, pkg string) string {
+	var found *debug.Module
+	for _, m := range info.Deps {
+		if pkg != m.Path && !strings.HasPrefix(pkg, m.Path+"/") {
+			continue
+		}
+		if found == nil || len(m.Path) > len(found.Path) {
+			// the longest path is the one of the module, nested modules being more specific
+			found = m
+		}
+	}
+	if found == nil {
+		return unknownDriverVersion
+	}
+	if found.Replace != nil && found.Replace.Version != "" {
+		return found.Replace.Version
+	}
+	if found.Version == "" || found.Version == "(devel)" {
+		return unknownDriverVersion
+	}
+	return found.Version
+}
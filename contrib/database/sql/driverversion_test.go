@@ -0,0 +1,79 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"database/sql/driver"
+	"runtime/debug"
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/database/sql/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModuleVersion(t *testing.T) {
+	info := &debug.BuildInfo{
+		Deps: []*debug.Module{
+			{Path: "github.com/jackc/pgx", Version: "v3.6.2"},
+			{Path: "github.com/jackc/pgx/v5", Version: "v5.4.3"},
+			{Path: "github.com/lib/pq", Version: "v1.10.2", Replace: &debug.Module{Path: "../pq", Version: ""}},
+			{Path: "modernc.org/sqlite", Version: "v1.20.0", Replace: &debug.Module{Path: "example.com/sqlite", Version: "v1.20.1"}},
+		},
+	}
+	for pkg, want := range map[string]string{
+		"github.com/jackc/pgx/v5/stdlib": "v5.4.3",
+		"github.com/jackc/pgx/stdlib":    "v3.6.2",
+		"github.com/lib/pq":              "v1.10.2",
+		"modernc.org/sqlite":             "v1.20.1",
+		"github.com/lib/pqx":             unknownDriverVersion,
+		"example.com/driver":             unknownDriverVersion,
+	} {
+		assert.Equal(t, want, moduleVersion(info, pkg), pkg)
+	}
+}
+
+func TestDriverVersionTag(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	for name, tt := range map[string]struct {
+		driver driver.Driver
+		opts   []Option
+		want   string
+	}{
+		"main-module": {driver: &internal.MockDriver{}, want: unknownDriverVersion},
+		"option":      {driver: &internal.MockDriver{}, opts: []Option{WithDriverVersion("v1.2.3")}, want: "v1.2.3"},
+		"build-info":  {driver: &pq.Driver{}, want: driverVersion(&pq.Driver{})},
+	} {
+		t.Run(name, func(t *testing.T) {
+			Register("test", tt.driver, tt.opts...)
+			defer unregister("test")
+			db, err := Open("test", "dn")
+			require.NoError(t, err)
+			defer db.Close()
+
+			mt.Reset()
+			if _, ok := tt.driver.(*internal.MockDriver); ok {
+				rows, err := db.Query("SELECT 1")
+				require.NoError(t, err)
+				rows.Close()
+			} else {
+				db.Ping() // fails to connect, but is traced
+			}
+
+			spans := mt.FinishedSpans()
+			require.NotEmpty(t, spans)
+			for _, s := range spans {
+				assert.Equal(t, tt.want, s.Tag(keyDriverVersion))
+			}
+		})
+	}
+	assert.NotEqual(t, unknownDriverVersion, driverVersion(&pq.Driver{}), "the version of pq is found in the build info")
+}
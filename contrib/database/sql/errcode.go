@@ -0,0 +1,84 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+)
+
+const (
+	keyErrorCode           = "sql.error_code"
+	keySQLState            = "db.sqlstate"
+	keyConstraintViolation = "sql.constraint_violation"
+)
+
+// mysqlErrorType is the name of the type of errors returned by github.com/go-sql-driver/mysql.
+// The type is matched by name to avoid importing the driver, which would register it.
+const mysqlErrorType = "github.com/go-sql-driver/mysql.MySQLError"
+
+// errorCode returns the tag key and value of the vendor-specific code carried by err, if it
+// was returned by one of the supported drivers:
+//   - github.com/lib/pq and github.com/jackc/pgx: SQLSTATE, as "db.sqlstate"
+//   - github.com/denisenkom/go-mssqldb: error number, as "sql.error_code"
+//   - github.com/go-sql-driver/mysql: error number, as "sql.error_code"
+func errorCode(err error) (key, value string, ok bool) {
+	// *pgconn.PgError (pgx)
+	var sqlState interface{ SQLState() string }
+	if errors.As(err, &sqlState) {
+		if code := sqlState.SQLState(); code != "" {
+			return keySQLState, code, true
+		}
+	}
+	// *pq.Error
+	var pqErr interface {
+		Get(k byte) string
+		Fatal() bool
+	}
+	if errors.As(err, &pqErr) {
+		if code := pqErr.Get('C'); code != "" {
+			return keySQLState, code, true
+		}
+	}
+	// mssql.Error
+	var mssqlErr interface{ SQLErrorNumber() int32 }
+	if errors.As(err, &mssqlErr) {
+		return keyErrorCode, strconv.Itoa(int(mssqlErr.SQLErrorNumber())), true
+	}
+	// *mysql.MySQLError
+	for ; err != nil; err = errors.Unwrap(err) {
+		v := reflect.ValueOf(err)
+		if v.Kind() != reflect.Ptr || v.IsNil() {
+			continue
+		}
+		t := v.Elem().Type()
+		if t.PkgPath()+"."+t.Name() != mysqlErrorType {
+			continue
+		}
+		if n := v.Elem().FieldByName("Number"); n.IsValid() && n.Kind() == reflect.Uint16 {
+			return keyErrorCode, strconv.FormatUint(n.Uint(), 10), true
+		}
+	}
+	return "", "", false
+}
+
+// isConstraintViolation reports whether err is a duplicate key or unique constraint violation
+// returned by one of the supported drivers: SQLSTATE 23505 for Postgres, error number 1062
+// for MySQL and error numbers 2627 or 2601 for SQL Server.
+func isConstraintViolation(err error) bool {
+	key, code, ok := errorCode(err)
+	if !ok {
+		return false
+	}
+	switch key {
+	case keySQLState:
+		return code == "23505"
+	case keyErrorCode:
+		return code == "1062" || code == "2627" || code == "2601"
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/database/sql/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+)
+
+func TestErrorCode(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		err   error
+		key   string
+		value string
+	}{
+		{
+			name:  "mysql",
+			err:   &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"},
+			key:   keyErrorCode,
+			value: "1062",
+		},
+		{
+			name:  "postgres",
+			err:   &pq.Error{Code: "23505", Message: "duplicate key value"},
+			key:   keySQLState,
+			value: "23505",
+		},
+		{
+			name:  "mssql",
+			err:   mssql.Error{Number: 2627, Message: "Violation of PRIMARY KEY constraint"},
+			key:   keyErrorCode,
+			value: "2627",
+		},
+		{
+			name:  "wrapped",
+			err:   fmt.Errorf("insert failed: %w", &mysql.MySQLError{Number: 1205}),
+			key:   keyErrorCode,
+			value: "1205",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			key, value, ok := errorCode(tt.err)
+			assert.True(t, ok)
+			assert.Equal(t, tt.key, key)
+			assert.Equal(t, tt.value, value)
+		})
+	}
+
+	t.Run("unknown", func(t *testing.T) {
+		_, _, ok := errorCode(errors.New("some error"))
+		assert.False(t, ok)
+		_, _, ok = errorCode((*mysql.MySQLError)(nil))
+		assert.False(t, ok)
+	})
+}
+
+func TestIsConstraintViolation(t *testing.T) {
+	for name, err := range map[string]error{
+		"mysql":    &mysql.MySQLError{Number: 1062, Message: "Duplicate entry '1' for key 'PRIMARY'"},
+		"postgres": &pq.Error{Code: "23505", Message: "duplicate key value violates unique constraint"},
+		"mssql":    mssql.Error{Number: 2627, Message: "Violation of PRIMARY KEY constraint"},
+		"mssql2":   mssql.Error{Number: 2601, Message: "Cannot insert duplicate key row"},
+		"wrapped":  fmt.Errorf("upsert failed: %w", &pq.Error{Code: "23505"}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.True(t, isConstraintViolation(err))
+		})
+	}
+	for name, err := range map[string]error{
+		"mysql":    &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"},
+		"postgres": &pq.Error{Code: "23503", Message: "foreign key violation"},
+		"mssql":    mssql.Error{Number: 1205, Message: "deadlock"},
+		"unknown":  errors.New("duplicate key"),
+	} {
+		t.Run("not/"+name, func(t *testing.T) {
+			assert.False(t, isConstraintViolation(err))
+		})
+	}
+}
+
+// errConn wraps a connection of internal.MockDriver, failing the queries found in errs.
+type errConn struct {
+	driver.Conn
+	errs map[string]error
+}
+
+func (c *errConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err, ok := c.errs[query]; ok {
+		return nil, err
+	}
+	return c.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+}
+
+type errDriver struct {
+	*internal.MockDriver
+	errs map[string]error
+}
+
+func (d *errDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.MockDriver.Open(name)
+	return &errConn{Conn: c, errs: d.errs}, err
+}
+
+func TestConstraintViolationNonError(t *testing.T) {
+	errs := map[string]error{
+		"INSERT mysql":    &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"},
+		"INSERT postgres": &pq.Error{Code: "23505", Message: "duplicate key value"},
+		"INSERT mssql":    mssql.Error{Number: 2627, Message: "Violation of PRIMARY KEY constraint"},
+		"INSERT deadlock": &mysql.MySQLError{Number: 1213, Message: "Deadlock found"},
+		"INSERT checked":  errors.New("checked"),
+	}
+	queries := []string{"INSERT mysql", "INSERT postgres", "INSERT mssql", "INSERT deadlock", "INSERT checked"}
+
+	run := func(t *testing.T, opts ...Option) []mocktracer.Span {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		Register("test", &errDriver{MockDriver: &internal.MockDriver{}, errs: errs}, opts...)
+		defer unregister("test")
+		db, err := Open("test", "dn")
+		require.NoError(t, err)
+		defer db.Close()
+
+		mt.Reset()
+		for _, q := range queries {
+			_, err := db.ExecContext(context.Background(), q)
+			require.Error(t, err)
+		}
+		spans := spansOfType(mt.FinishedSpans(), queryTypeExec)
+		require.Len(t, spans, len(queries))
+		return spans
+	}
+
+	t.Run("enabled", func(t *testing.T) {
+		spans := run(t, WithConstraintViolationNonError(), WithErrorCheck(func(err error) bool {
+			return err.Error() != "checked"
+		}))
+		for _, s := range spans[:3] {
+			assert.Nil(t, s.Tag(ext.Error), s.Tag(ext.ResourceName))
+			assert.Equal(t, true, s.Tag(keyConstraintViolation), s.Tag(ext.ResourceName))
+		}
+		// other errors are still reported, according to the error check
+		assert.NotNil(t, spans[3].Tag(ext.Error))
+		assert.Equal(t, "1213", spans[3].Tag(keyErrorCode))
+		assert.Nil(t, spans[3].Tag(keyConstraintViolation))
+		assert.Nil(t, spans[4].Tag(ext.Error))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		spans := run(t)
+		for _, s := range spans {
+			assert.NotNil(t, s.Tag(ext.Error), s.Tag(ext.ResourceName))
+			assert.Nil(t, s.Tag(keyConstraintViolation))
+		}
+	})
+}
@@ -34,6 +34,9 @@ func ParseDSN(driverName, dsn string) (meta map[string]string, err error) {
 			return
 		}
 		meta[ext.DBSystem] = ext.DBSystemMicrosoftSQLServer
+	case "sqlite", "sqlite3":
+		meta = parseSQLiteDSN(dsn)
+		meta[ext.DBSystem] = ext.DBSystemSQLite
 	default:
 		// not supported
 	}
@@ -61,22 +64,56 @@ func reduceKeys(meta map[string]string) map[string]string {
 	return m
 }
 
+// parseSQLiteDSN parses a sqlite-type dsn, which is the path of the database file, optionally
+// prefixed with "file:" and followed by query parameters, into a map. SQLite databases have no host.
+func parseSQLiteDSN(dsn string) map[string]string {
+	path := strings.TrimPrefix(dsn, "file:")
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	if path == "" {
+		return map[string]string{}
+	}
+	return map[string]string{"dbname": path}
+}
+
 // parseMySQLDSN parses a mysql-type dsn into a map.
 func parseMySQLDSN(dsn string) (m map[string]string, err error) {
 	var cfg *mySQLConfig
 	if cfg, err = mySQLConfigFromDSN(dsn); err == nil {
-		host, port, _ := net.SplitHostPort(cfg.Addr)
+		host, port := mySQLHostPort(cfg)
 		m = map[string]string{
 			"user":   cfg.User,
 			"host":   host,
-			"port":   port,
 			"dbname": cfg.DBName,
 		}
+		if port != "" {
+			m["port"] = port
+		}
 		return m, nil
 	}
 	return nil, err
 }
 
+// mySQLHostPort returns the host and port to be used as tags for the given
+// config. Unix socket addresses are reported as the host with no port, and
+// for comma-separated host lists only the first host is taken into account.
+func mySQLHostPort(cfg *mySQLConfig) (host, port string) {
+	if cfg.Net == "unix" {
+		return cfg.Addr, ""
+	}
+	addr := cfg.Addr
+	if i := strings.IndexByte(addr, ','); i >= 0 {
+		addr = addr[:i]
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		// no port specified
+		return addr, ""
+	}
+	return host, port
+}
+
 // parsePostgresDSN parses a postgres-type dsn into a map.
 func parsePostgresDSN(dsn string) (map[string]string, error) {
 	var err error
@@ -31,6 +31,22 @@ func TestParseDSN(t *testing.T) {
 				ext.DBSystem:   "postgresql",
 			},
 		},
+		{
+			driverName: "sqlite",
+			dsn:        "file:/var/lib/orders.db?cache=shared&mode=rwc",
+			expected: map[string]string{
+				ext.DBName:   "/var/lib/orders.db",
+				ext.DBSystem: "sqlite",
+			},
+		},
+		{
+			driverName: "sqlite3",
+			dsn:        "./test.db",
+			expected: map[string]string{
+				ext.DBName:   "./test.db",
+				ext.DBSystem: "sqlite",
+			},
+		},
 		{
 			driverName: "mysql",
 			dsn:        "bob:secret@tcp(1.2.3.4:5432)/mydb",
@@ -42,6 +58,16 @@ func TestParseDSN(t *testing.T) {
 				ext.DBSystem:   "mysql",
 			},
 		},
+		{
+			driverName: "mysql",
+			dsn:        "bob:secret@unix(/var/run/mysqld/mysqld.sock)/mydb",
+			expected: map[string]string{
+				ext.DBName:     "mydb",
+				ext.DBUser:     "bob",
+				ext.TargetHost: "/var/run/mysqld/mysqld.sock",
+				ext.DBSystem:   "mysql",
+			},
+		},
 		{
 			driverName: "postgres",
 			dsn:        "connect_timeout=0 binary_parameters=no password=zMWmQz26GORmgVVKEbEl dbname=dogdatastaging application_name=trace-api port=5433 sslmode=disable host=master-db-master-active.postgres.service.consul user=dog",
@@ -84,16 +110,75 @@ func TestParseDSN(t *testing.T) {
 }
 
 func TestParseMySQLDSN(t *testing.T) {
-	assert := assert.New(t)
-	expected := map[string]string{
-		"dbname": "mydb",
-		"user":   "bob",
-		"host":   "1.2.3.4",
-		"port":   "5432",
+	for _, tt := range []struct {
+		name     string
+		dsn      string
+		expected map[string]string
+	}{
+		{
+			name: "tcp",
+			dsn:  "bob:secret@tcp(1.2.3.4:5432)/mydb",
+			expected: map[string]string{
+				"dbname": "mydb",
+				"user":   "bob",
+				"host":   "1.2.3.4",
+				"port":   "5432",
+			},
+		},
+		{
+			name: "tcp_no_port",
+			dsn:  "bob:secret@tcp(db.example.com)/mydb",
+			expected: map[string]string{
+				"dbname": "mydb",
+				"user":   "bob",
+				"host":   "db.example.com",
+			},
+		},
+		{
+			name: "default_addr",
+			dsn:  "bob@/mydb",
+			expected: map[string]string{
+				"dbname": "mydb",
+				"user":   "bob",
+				"host":   "127.0.0.1",
+				"port":   "3306",
+			},
+		},
+		{
+			name: "unix",
+			dsn:  "bob:secret@unix(/var/run/mysqld/mysqld.sock)/mydb",
+			expected: map[string]string{
+				"dbname": "mydb",
+				"user":   "bob",
+				"host":   "/var/run/mysqld/mysqld.sock",
+			},
+		},
+		{
+			name: "unix_default_addr",
+			dsn:  "bob@unix/mydb",
+			expected: map[string]string{
+				"dbname": "mydb",
+				"user":   "bob",
+				"host":   "/tmp/mysql.sock",
+			},
+		},
+		{
+			name: "multi_host",
+			dsn:  "bob:secret@tcp(10.0.0.1:3306,10.0.0.2:3307)/mydb",
+			expected: map[string]string{
+				"dbname": "mydb",
+				"user":   "bob",
+				"host":   "10.0.0.1",
+				"port":   "3306",
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := parseMySQLDSN(tt.dsn)
+			assert.Equal(t, nil, err)
+			assert.Equal(t, tt.expected, m)
+		})
 	}
-	m, err := parseMySQLDSN("bob:secret@tcp(1.2.3.4:5432)/mydb")
-	assert.Equal(nil, err)
-	assert.Equal(expected, m)
 }
 
 func TestParsePostgresDSN(t *testing.T) {
@@ -0,0 +1,60 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import "strings"
+
+// keyOperation holds the operation named in the comments of queries, as enabled using
+// WithOperationFromComment.
+const keyOperation = "sql.operation"
+
+// operationFromComment returns the operation named by the first comment of query whose text
+// starts with prefix, such as "GetUserByID" for the comment "-- op: GetUserByID" and the prefix
+// "op:". Both line and block comments are considered, but not the text of quoted strings and
+// identifiers. It returns false if no comment names an operation.
+func operationFromComment(query, prefix string) (string, bool) {
+	var quote byte // the quote of the string or identifier being read, if any
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			if op, ok := parseOperation(query[i+2:i+end], prefix); ok {
+				return op, true
+			}
+			i += end
+		case strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				end = len(query) - i - 2
+			}
+			if op, ok := parseOperation(query[i+2:i+2+end], prefix); ok {
+				return op, true
+			}
+			i += end + 3
+		}
+	}
+	return "", false
+}
+
+// parseOperation returns the operation named by the given comment text, if it starts with prefix.
+func parseOperation(comment, prefix string) (string, bool) {
+	comment = strings.TrimSpace(comment)
+	if !strings.HasPrefix(comment, prefix) {
+		return "", false
+	}
+	op := strings.TrimSpace(comment[len(prefix):])
+	return op, op != ""
+}
@@ -0,0 +1,73 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/database/sql/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+)
+
+func TestOperationFromComment(t *testing.T) {
+	for _, tt := range []struct {
+		query string
+		op    string
+	}{
+		{query: "SELECT * FROM users WHERE id = $1 -- op: GetUserByID", op: "GetUserByID"},
+		{query: "-- op: GetUserByID\nSELECT * FROM users WHERE id = $1", op: "GetUserByID"},
+		{query: "/* op: GetUserByID */ SELECT * FROM users WHERE id = $1", op: "GetUserByID"},
+		{query: "SELECT 1 /* unterminated op: Ping", op: ""},
+		{query: "SELECT 1 /* op: Ping", op: "Ping"},
+		// the first comment naming an operation is used
+		{query: "/* generated */ SELECT 1 -- op: First\n-- op: Second", op: "First"},
+		// comments without an operation are ignored
+		{query: "SELECT 1 -- op:\n-- fetch", op: ""},
+		{query: "SELECT 1", op: ""},
+		// quoted text is not a comment
+		{query: "SELECT '-- op: Fake', \"/* op: Fake */\" FROM t -- op: Real", op: "Real"},
+		{query: "SELECT 'it''s -- op: Fake' FROM t", op: ""},
+	} {
+		op, ok := operationFromComment(tt.query, "op:")
+		assert.Equal(t, tt.op, op, tt.query)
+		assert.Equal(t, tt.op != "", ok, tt.query)
+	}
+}
+
+func TestWithOperationFromComment(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	Register("postgres", &internal.MockDriver{}, WithOperationFromComment("op:"))
+	defer unregister("postgres")
+	db, err := Open("postgres", "postgres://bob@db.internal:5432/orders")
+	require.NoError(t, err)
+	defer db.Close()
+
+	for query, op := range map[string]string{
+		"SELECT * FROM users WHERE id = 1 -- op: GetUserByID": "GetUserByID",
+		"SELECT * FROM users WHERE id = 2":                    "",
+	} {
+		mt.Reset()
+		rows, err := db.Query(query)
+		require.NoError(t, err)
+		rows.Close()
+
+		spans := spansOfType(mt.FinishedSpans(), queryTypeQuery)
+		require.Len(t, spans, 1)
+		if op == "" {
+			assert.Equal(t, query, spans[0].Tag(ext.ResourceName))
+			assert.Nil(t, spans[0].Tag(keyOperation))
+			continue
+		}
+		assert.Equal(t, op, spans[0].Tag(ext.ResourceName))
+		assert.Equal(t, op, spans[0].Tag(keyOperation))
+	}
+}
@@ -6,9 +6,13 @@
 package sql
 
 import (
+	"context"
 	"math"
 	"os"
+	"time"
 
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal"
 )
@@ -21,6 +25,90 @@ type config struct {
 	errCheck           func(err error) bool
 	tags               map[string]interface{}
 	dbmPropagationMode tracer.DBMPropagationMode
+	batchSpans         bool
+	featureTags        bool
+	sessionResetSpans  bool
+	slowQueryThreshold time.Duration
+	argTypeTags        bool
+	querySignature     bool
+	env                string
+	version            string
+	// constraintViolationNonError reports whether constraint violations are not errors.
+	constraintViolationNonError bool
+	// obfuscationCacheSize is the number of obfuscated queries cached; it is negative
+	// when the cache is disabled and zero when not set.
+	obfuscationCacheSize int
+	// queryCache caches the obfuscated queries, if enabled.
+	queryCache *queryCache
+	// contextTags returns the tags to set on spans from the context of their call.
+	contextTags func(ctx context.Context) map[string]interface{}
+	// spanType overrides the type of the spans, if set.
+	spanType string
+	// dbSystem overrides the db.system tag of the spans, if set.
+	dbSystem string
+	// readServiceName and writeServiceName override the service of the spans of
+	// reading and writing statements respectively, if set.
+	readServiceName  string
+	writeServiceName string
+	// argCheckErrors reports whether arguments rejected by the driver are traced.
+	argCheckErrors bool
+	// slowQueryLogger, when set, logs the queries taking at least slowQueryLogThreshold.
+	slowQueryLogger       ddtrace.Logger
+	slowQueryLogThreshold time.Duration
+	// postgresPlanTags reports whether the spans of prepared Postgres statements are tagged.
+	postgresPlanTags bool
+	// connectRetryWindow, when positive, is the time within which consecutive failed
+	// connections are counted as retries.
+	connectRetryWindow time.Duration
+	// dbmHostname, when set, is the hostname of the database reported for DBM correlation.
+	dbmHostname string
+	// driverVersion is the version of the driver, reported on all spans.
+	driverVersion string
+	// sqlCommentExtraction reports whether the sqlcommenter comments of queries are tagged.
+	sqlCommentExtraction bool
+	// otelSemanticConventions reports whether the tags of the OpenTelemetry semantic
+	// conventions are set along with the Datadog ones.
+	otelSemanticConventions bool
+	// copyAggregation reports whether bulk copy operations are traced as a whole.
+	copyAggregation bool
+	// maxResourceNameLength, when positive, is the number of runes resources are truncated at.
+	maxResourceNameLength int
+	// sqlCommentTags returns the values of the additional tags injected in SQL comments,
+	// by key, from the context of the call.
+	sqlCommentTags map[string]func(ctx context.Context) string
+	// shardResolver returns the shard queried by the query of a span, suffixing its service,
+	// or the service replacing it when shardReplace is set.
+	shardResolver func(ctx context.Context, query string) string
+	shardReplace  bool
+	// operationPrefix, when set, prefixes the comments of queries naming their operation.
+	operationPrefix string
+	// warmupSpanName, when set, names the span grouping the connections opened when the
+	// pool warms up, up to warmupConns connections.
+	warmupSpanName string
+	warmupConns    int
+	// errorCoalescingWindow, when positive, is the window within which identical errors
+	// are coalesced into a single span.
+	errorCoalescingWindow time.Duration
+	// errorCoalescer coalesces the identical errors, if enabled.
+	errorCoalescer *errorCoalescer
+}
+
+// spanTypeOrDefault returns the type of the spans, which defaults to ext.SpanTypeSQL.
+func (cfg *config) spanTypeOrDefault() string {
+	if cfg.spanType != "" {
+		return cfg.spanType
+	}
+	return ext.SpanTypeSQL
+}
+
+// obfuscatesQueries reports whether any of the enabled features obfuscates the queries,
+// in which case the obfuscated queries are cached.
+func (cfg *config) obfuscatesQueries() bool {
+	return cfg.querySignature ||
+		cfg.maxResourceNameLength > 0 ||
+		cfg.slowQueryLogger != nil ||
+		cfg.otelSemanticConventions ||
+		cfg.errorCoalescer != nil
 }
 
 // Option represents an option that can be passed to Register, Open or OpenDB.
@@ -134,3 +222,355 @@ func WithDBMPropagation(mode tracer.DBMPropagationMode) Option {
 		cfg.dbmPropagationMode = mode
 	}
 }
+
+// WithBatchStatementSpans enables splitting queries made of multiple statements
+// separated by semicolons, as supported by some drivers in a single Exec call. When
+// such a query is executed, a parent "sql.batch" span is created along with one
+// child span per statement, each having the statement as its resource.
+func WithBatchStatementSpans() Option {
+	return func(cfg *config) {
+		cfg.batchSpans = true
+	}
+}
+
+// WithDialectFeatureTags enables tagging spans with the vendor-specific SQL features
+// detected in their query, such as Postgres casts, MySQL backtick identifiers, RETURNING
+// clauses or window functions. The features are listed in the "sql.features" tag.
+func WithDialectFeatureTags() Option {
+	return func(cfg *config) {
+		cfg.featureTags = true
+	}
+}
+
+// WithSessionResetSpans enables tracing of the session resets done by drivers implementing
+// driver.SessionResetter before a pooled connection is reused. This can help debugging
+// stale connection issues. It is disabled by default.
+func WithSessionResetSpans() Option {
+	return func(cfg *config) {
+		cfg.sessionResetSpans = true
+	}
+}
+
+// WithSlowQueryThreshold sets a duration under which successful calls are not traced.
+// The calls are still timed, but only those taking at least d, or failing, result in a
+// span. This can help reducing the volume of spans for high throughput services, while
+// keeping the slow queries. The spans of the calling code are not affected. Calls whose
+// span ID is injected into the query for DBM, as done by WithDBMPropagation in full mode,
+// are always traced. A threshold of zero, the default, traces all calls.
+func WithSlowQueryThreshold(d time.Duration) Option {
+	return func(cfg *config) {
+		cfg.slowQueryThreshold = d
+	}
+}
+
+// WithArgTypeTags enables tagging spans with the Go types of the query arguments, in
+// order and separated by commas (e.g. "int64,string,time.Time"), in the "sql.args.types"
+// tag. This can help diagnosing implicit casts. The argument values are never recorded.
+// The types are the ones passed to the driver, after the conversions done by database/sql,
+// e.g. a driver.Valuer is reported by the type of its value unless the driver accepts it.
+func WithArgTypeTags() Option {
+	return func(cfg *config) {
+		cfg.argTypeTags = true
+	}
+}
+
+// WithQuerySignature enables tagging spans with a signature of their query, in the
+// "sql.query_signature" tag. The signature is a hash of the obfuscated query, such that
+// queries differing only in their literal values share the same signature, which allows
+// grouping identical query shapes.
+func WithQuerySignature() Option {
+	return func(cfg *config) {
+		cfg.querySignature = true
+	}
+}
+
+// WithConstraintViolationNonError stops marking spans as errors when their call failed
+// with a duplicate key or unique constraint violation, which upsert-heavy workloads hit
+// on purpose. Such spans are tagged with "sql.constraint_violation" set to true instead.
+// The violations are detected for the github.com/go-sql-driver/mysql (1062), github.com/lib/pq
+// and github.com/jackc/pgx (23505) and github.com/denisenkom/go-mssqldb (2627 and 2601) drivers.
+// Other errors are still passed to the function set using WithErrorCheck, if any.
+func WithConstraintViolationNonError() Option {
+	return func(cfg *config) {
+		cfg.constraintViolationNonError = true
+	}
+}
+
+// WithEnv sets the env tag of the spans of the database, overriding the one set globally
+// on the tracer. This allows attributing databases to their owners when a process talks to
+// several of them. The global env is used when not set.
+func WithEnv(env string) Option {
+	return func(cfg *config) {
+		cfg.env = env
+	}
+}
+
+// WithVersion sets the version tag of the spans of the database, overriding the one set
+// globally on the tracer. The global version is used when not set.
+func WithVersion(version string) Option {
+	return func(cfg *config) {
+		cfg.version = version
+	}
+}
+
+// WithObfuscationCacheSize sets the number of queries whose obfuscated version is cached,
+// in order to avoid obfuscating identical queries over and over, such as when computing
+// their signature as enabled by WithQuerySignature, or when reporting them as enabled by
+// WithMaxResourceNameLength, WithSlowQueryLog, WithOTelSemanticConventions or
+// WithErrorCoalescing. The least recently used queries are evicted once the cache is full.
+// It defaults to 1000 queries per database. A size of zero or less disables the cache.
+func WithObfuscationCacheSize(n int) Option {
+	return func(cfg *config) {
+		if n <= 0 {
+			n = -1
+		}
+		cfg.obfuscationCacheSize = n
+	}
+}
+
+// WithContextTags specifies a function fn returning tags to set on the spans of queries,
+// executions and connections, computed from the context of the call, e.g. to tag them
+// with request-scoped values such as a tenant. At most 32 of the returned tags are set,
+// in the order of their keys. The fn is called whenever such a span is created.
+func WithContextTags(fn func(ctx context.Context) map[string]interface{}) Option {
+	return func(cfg *config) {
+		cfg.contextTags = fn
+	}
+}
+
+// WithSpanType sets the type of the spans, which defaults to "sql". This allows drivers
+// of databases which are not relational, such as SQL adapters of NoSQL databases, to be
+// presented accordingly, e.g. using "dynamodb".
+func WithSpanType(typ string) Option {
+	return func(cfg *config) {
+		cfg.spanType = typ
+	}
+}
+
+// WithDBSystem sets the "db.system" tag of the spans, overriding the one inferred from
+// the driver, e.g. to "dynamodb" for a DynamoDB SQL adapter. It defaults to
+// "other_sql" for drivers which are not recognized.
+func WithDBSystem(system string) Option {
+	return func(cfg *config) {
+		cfg.dbSystem = system
+	}
+}
+
+// WithReadWriteServiceSplit sets the service of the spans of statements which read data to
+// readSvc, and of the ones which write data to writeSvc, overriding the service of the
+// database. SELECT statements are reads and any other statements are writes, except for
+// statements starting with common table expressions, which are writes only if they contain
+// an INSERT, UPDATE, DELETE or similar statement. SELECT statements locking the rows they
+// read, using FOR UPDATE, FOR SHARE or similar clauses, are writes as well. An empty name
+// keeps the database's service for the corresponding statements, as do spans without a
+// statement, such as transactions.
+func WithReadWriteServiceSplit(readSvc, writeSvc string) Option {
+	return func(cfg *config) {
+		cfg.readServiceName = readSvc
+		cfg.writeServiceName = writeSvc
+	}
+}
+
+// WithArgCheckErrors enables tracing the calls whose arguments are rejected by the driver's
+// driver.NamedValueChecker, which otherwise fail before reaching the driver and are not
+// traced. Their spans are tagged with the error in "sql.arg_check_error" and with the
+// position of the rejected argument, starting at 1, in "sql.arg_check_error_index". The
+// argument values are never recorded.
+func WithArgCheckErrors() Option {
+	return func(cfg *config) {
+		cfg.argCheckErrors = true
+	}
+}
+
+// WithSlowQueryLog enables logging the queries and executions taking at least the given
+// threshold using logger, e.g. to alert on slow queries from Datadog Logs. Each query is
+// logged as a JSON object holding the duration in milliseconds, the obfuscated query and
+// the "dd.trace_id" and "dd.span_id" attributes correlating it with its trace. Queries are
+// logged even when not traced, e.g. because of WithChildSpansOnly, in which case they are
+// correlated with the span found in their context, if any.
+func WithSlowQueryLog(threshold time.Duration, logger ddtrace.Logger) Option {
+	return func(cfg *config) {
+		cfg.slowQueryLogThreshold = threshold
+		cfg.slowQueryLogger = logger
+	}
+}
+
+// WithPostgresPlanTags enables tagging the spans of the executions of prepared statements
+// with "sql.pg.prepared" set to true when the database is Postgres, as detected from the
+// name of the driver ("postgres" or "pgx"). Such statements are executed using the extended
+// protocol, allowing the server to cache their plan. This has no effect on other databases.
+func WithPostgresPlanTags() Option {
+	return func(cfg *config) {
+		cfg.postgresPlanTags = true
+	}
+}
+
+// WithConnectRetries enables tagging Connect spans with the number of connections which failed
+// right before them, as an approximation of the retries done transparently by connection pools
+// and database/sql itself, in the "sql.connect.retries" tag. Failed connections are counted as
+// long as each follows the previous one within window, and the count is reset once a connection
+// succeeds. Spans of connections which do not follow any failure are not tagged.
+func WithConnectRetries(window time.Duration) Option {
+	return func(cfg *config) {
+		cfg.connectRetryWindow = window
+	}
+}
+
+// WithDBMHostname sets the hostname of the database server, as reported by Database Monitoring,
+// in the "db.instance" and "_dd.db.host" tags of the spans, which are used to correlate them with
+// the database. This is needed when the host found in the DSN is not the database itself, such as
+// when connecting through a proxy like pgbouncer. The "out.host" tag keeps the host of the DSN.
+func WithDBMHostname(name string) Option {
+	return func(cfg *config) {
+		cfg.dbmHostname = name
+	}
+}
+
+// WithDriverVersion sets the version of the driver reported in the "sql.driver.version" tag
+// of the spans. By default, it is the version of the module of the driver, as found in the
+// build information of the binary, or "unknown" when it cannot be determined, such as for
+// drivers which are vendored or part of the main module.
+func WithDriverVersion(version string) Option {
+	return func(cfg *config) {
+		cfg.driverVersion = version
+	}
+}
+
+// WithSQLCommentExtraction enables parsing the sqlcommenter comment leading queries, such as the
+// ones injected by upstream services or ORMs, and setting the key/value pairs it holds as tags
+// of the spans under the "sql.comment." prefix. The comment is then left out of the resource of
+// the spans, since it usually holds values which change with each query. Comments which do not
+// follow the sqlcommenter format are ignored and kept in the resource.
+// See https://google.github.io/sqlcommenter/spec/ for more details.
+func WithSQLCommentExtraction() Option {
+	return func(cfg *config) {
+		cfg.sqlCommentExtraction = true
+	}
+}
+
+// WithOTelSemanticConventions enables setting the tags of the OpenTelemetry semantic conventions
+// for databases on the spans, along with the Datadog ones, which are kept for compatibility. The
+// obfuscated query is set in "db.statement", and the host and port of the DSN, found in "out.host" and
+// "out.port", are also set in "server.address" and "server.port". The "db.system", "db.name" and
+// "db.user" tags are the same in both conventions, and are set regardless of this option.
+func WithOTelSemanticConventions() Option {
+	return func(cfg *config) {
+		cfg.otelSemanticConventions = true
+	}
+}
+
+// WithCopyAggregation enables tracing bulk copy operations, such as the Postgres COPY FROM STDIN
+// statements prepared using pq.CopyIn, as a single span with "sql.query_type" set to "Copy",
+// instead of one span per row sent. The number of rows sent is set in the "sql.rows_copied"
+// tag. The span starts when the first row is sent, and ends when the copy is completed by
+// executing the statement without arguments, or by closing it.
+func WithCopyAggregation() Option {
+	return func(cfg *config) {
+		cfg.copyAggregation = true
+	}
+}
+
+// WithMaxResourceNameLength sets the maximum length of the resource names of spans, in runes.
+// Queries longer than n runes are obfuscated and truncated to their first n runes, followed by
+// "...", before being set as the resource, preventing generated queries of many kilobytes from
+// creating huge resources. The query is truncated as is if it can not be obfuscated. A value of
+// zero or less, the default, leaves resources untouched.
+func WithMaxResourceNameLength(n int) Option {
+	return func(cfg *config) {
+		cfg.maxResourceNameLength = n
+	}
+}
+
+// WithSQLCommentTag injects an additional tag in the SQL comments of traced queries, under the
+// given key, whose value is returned by fn from the context of the call, e.g. to correlate slow
+// queries to the IDs of application requests:
+//
+//	sqltrace.WithSQLCommentTag("request_id", func(ctx context.Context) string {
+//		id, _ := ctx.Value(requestIDKey{}).(string)
+//		return id
+//	})
+//
+// Keys and values are escaped following the sqlcommenter specification. The tag is injected
+// regardless of the DBM propagation mode, but not when fn returns an empty string or the key is
+// used by the tags injected for DBM. Unlike the other options, it can be used multiple times.
+func WithSQLCommentTag(key string, fn func(ctx context.Context) string) Option {
+	return func(cfg *config) {
+		if cfg.sqlCommentTags == nil {
+			cfg.sqlCommentTags = make(map[string]func(ctx context.Context) string)
+		}
+		cfg.sqlCommentTags[key] = fn
+	}
+}
+
+// WithShardResolver sets a function returning the shard queried by a query, from the context
+// of the call and the query itself, e.g. as computed by an ORM. The service of the span of the
+// query is suffixed with the shard, such that the spans of the queries to the "orders" service
+// on shard "3" use the "orders-3" service. When the read and write services are split using
+// WithReadWriteServiceSplit, they are suffixed instead. An empty shard keeps the service as is.
+func WithShardResolver(resolver func(ctx context.Context, query string) string) Option {
+	return func(cfg *config) {
+		cfg.shardResolver = resolver
+		cfg.shardReplace = false
+	}
+}
+
+// WithShardServiceResolver sets a function returning the service of the shard queried by a
+// query, from the context of the call and the query itself. Unlike WithShardResolver, the
+// returned service replaces the service of the span of the query, including the read and write
+// services set using WithReadWriteServiceSplit, rather than suffixing it. An empty service keeps
+// the service as is. It overrides WithShardResolver, and the other way around.
+func WithShardServiceResolver(resolver func(ctx context.Context, query string) string) Option {
+	return func(cfg *config) {
+		cfg.shardResolver = resolver
+		cfg.shardReplace = true
+	}
+}
+
+// WithOperationFromComment enables naming the spans of queries after the operation named in
+// their comments, such as the ones injected by ORMs, e.g. "-- op: GetUserByID" with the prefix
+// "op:". The first line or block comment whose text starts with prefix names the operation,
+// which is set as both the resource and the "sql.operation" tag of the span, grouping queries
+// by operation rather than by text. Queries without such a comment are not affected.
+func WithOperationFromComment(prefix string) Option {
+	return func(cfg *config) {
+		cfg.operationPrefix = prefix
+	}
+}
+
+// WithWarmupSpanName enables grouping the connections opened when the pool of the database warms
+// up under a single span with the given name, started by Open or OpenDB. The Connect spans without
+// a parent are its children until the pool holds as many connections as it keeps idle, which is 2
+// by default, or the number set using WithWarmupConns. The span is finished then, or once the
+// first query is made, a connection fails, the database is closed or 10 seconds have passed,
+// whichever comes first.
+func WithWarmupSpanName(name string) Option {
+	return func(cfg *config) {
+		cfg.warmupSpanName = name
+	}
+}
+
+// WithWarmupConns sets the number of connections grouped under the span enabled using
+// WithWarmupSpanName. It should match the value passed to the SetMaxIdleConns method of the
+// database. It has no effect otherwise.
+func WithWarmupConns(n int) Option {
+	return func(cfg *config) {
+		if n > 0 {
+			cfg.warmupConns = n
+		}
+	}
+}
+
+// WithErrorCoalescing enables collapsing the identical errors returned by queries of the same
+// shape, i.e. only differing by their literals, within the given window, such as the ones of a
+// failing query retried in a tight loop. The first error is traced by a span, which is kept open
+// until the end of the window, when it is finished with the number of errors in the
+// "sql.error.count" tag. The subsequent errors within the window are counted on it and are not
+// traced by spans of their own. Spans still open when the program exits are lost. Since the
+// queries are run before knowing whether they are coalesced, DBMPropagationModeFull falls back
+// to DBMPropagationModeService when this option is enabled, such that queries never reference
+// spans which are not sent.
+func WithErrorCoalescing(window time.Duration) Option {
+	return func(cfg *config) {
+		cfg.errorCoalescingWindow = window
+	}
+}
@@ -135,6 +135,33 @@ func TestDBMPropagation(t *testing.T) {
 			},
 			executed: []*regexp.Regexp{regexp.MustCompile("/\\*dddbs='test.db',dde='test-env',ddps='test-service',ddpv='1.0.0',traceparent='00-00000000000000000000000000000001-[\\da-f]{16}-01'\\*/ SELECT 1 from DUAL")},
 		},
+		{
+			name: "exec-comment-tag",
+			opts: []RegisterOption{WithDBMPropagation(tracer.DBMPropagationModeService), WithSQLCommentTag("request_id", requestID)},
+			callDB: func(ctx context.Context, db *sql.DB) error {
+				_, err := db.ExecContext(context.WithValue(ctx, requestIDKey{}, "req-42'"), "SELECT 1 from DUAL")
+				return err
+			},
+			executed: []*regexp.Regexp{regexp.MustCompile("/\\*dddbs='test.db',dde='test-env',ddps='test-service',ddpv='1.0.0',request_id='req-42%27'\\*/ SELECT 1 from DUAL")},
+		},
+		{
+			name: "exec-comment-tag-disabled",
+			opts: []RegisterOption{WithDBMPropagation(tracer.DBMPropagationModeDisabled), WithSQLCommentTag("request_id", requestID)},
+			callDB: func(ctx context.Context, db *sql.DB) error {
+				_, err := db.ExecContext(context.WithValue(ctx, requestIDKey{}, "req-42"), "SELECT 1 from DUAL")
+				return err
+			},
+			executed: []*regexp.Regexp{regexp.MustCompile("^/\\*request_id='req-42'\\*/ SELECT 1 from DUAL$")},
+		},
+		{
+			name: "exec-comment-tag-empty",
+			opts: []RegisterOption{WithDBMPropagation(tracer.DBMPropagationModeDisabled), WithSQLCommentTag("request_id", requestID)},
+			callDB: func(ctx context.Context, db *sql.DB) error {
+				_, err := db.ExecContext(ctx, "SELECT 1 from DUAL")
+				return err
+			},
+			executed: []*regexp.Regexp{regexp.MustCompile("^SELECT 1 from DUAL$")},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -169,6 +196,14 @@ func TestDBMPropagation(t *testing.T) {
 	}
 }
 
+type requestIDKey struct{}
+
+// requestID returns the request ID found in ctx, if any.
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
 func TestDBMTraceContextTagging(t *testing.T) {
 	testCases := []struct {
 		name                    string
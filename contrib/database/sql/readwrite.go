@@ -0,0 +1,133 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import "strings"
+
+// writeKeywords lists the keywords which make a statement starting with a common
+// table expression (WITH) a write, such as "WITH d AS (DELETE ... RETURNING id) SELECT ...".
+var writeKeywords = []string{"INSERT", "UPDATE", "DELETE", "MERGE", "UPSERT", "REPLACE"}
+
+// lockingClauses lists the clauses of SELECT statements which lock the rows they read,
+// such that they must run where writes do.
+var lockingClauses = [][]string{
+	{"FOR", "UPDATE"},
+	{"FOR", "NO", "KEY", "UPDATE"},
+	{"FOR", "SHARE"},
+	{"FOR", "KEY", "SHARE"},
+	{"LOCK", "IN", "SHARE", "MODE"},
+}
+
+// isReadQuery reports whether query only reads data, based on its command verb:
+// SELECT statements are reads, unless they lock the rows they read, while any other
+// statement is a write. Statements starting with common table expressions are reads
+// unless they contain a writing keyword or a locking clause anywhere. Like
+// detectFeatures, this is a cheap heuristic which does not take string literals
+// into account.
+func isReadQuery(query string) bool {
+	switch strings.ToUpper(firstKeyword(query)) {
+	case "SELECT":
+		return !isLocking(query)
+	case "WITH":
+		for _, kw := range writeKeywords {
+			if containsKeyword(query, kw) {
+				return false
+			}
+		}
+		return !isLocking(query)
+	default:
+		return false
+	}
+}
+
+// isLocking reports whether query contains one of the lockingClauses.
+func isLocking(query string) bool {
+	for _, clause := range lockingClauses {
+		if containsWords(query, clause) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsWords reports whether query contains the given words as whole words, in the
+// same order and only separated by whitespace, ignoring case.
+func containsWords(query string, words []string) bool {
+	for i := 0; i < len(query); i++ {
+		if i > 0 && isWordChar(query[i-1]) {
+			continue
+		}
+		if hasWordsPrefix(query[i:], words) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasWordsPrefix reports whether s starts with the given words, separated by whitespace.
+func hasWordsPrefix(s string, words []string) bool {
+	for i, w := range words {
+		if i > 0 {
+			t := strings.TrimLeft(s, " \t\r\n")
+			if len(t) == len(s) {
+				return false
+			}
+			s = t
+		}
+		if len(s) < len(w) || !strings.EqualFold(s[:len(w)], w) {
+			return false
+		}
+		s = s[len(w):]
+		if len(s) > 0 && isWordChar(s[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+// firstKeyword returns the first word of query, skipping leading spaces, comments
+// and opening parentheses.
+func firstKeyword(query string) string {
+	for {
+		query = strings.TrimLeft(query, " \t\r\n(")
+		switch {
+		case strings.HasPrefix(query, "--"):
+			i := strings.IndexByte(query, '\n')
+			if i < 0 {
+				return ""
+			}
+			query = query[i+1:]
+		case strings.HasPrefix(query, "/*"):
+			i := strings.Index(query, "*/")
+			if i < 0 {
+				return ""
+			}
+			query = query[i+2:]
+		default:
+			i := 0
+			for i < len(query) && isWordChar(query[i]) {
+				i++
+			}
+			return query[:i]
+		}
+	}
+}
+
+// serviceNameFor returns the service of the span of query, which is the read or write
+// service set using WithReadWriteServiceSplit, if any, and the database's service otherwise.
+func (cfg *config) serviceNameFor(query string) string {
+	if query == "" || cfg.readServiceName == "" && cfg.writeServiceName == "" {
+		return cfg.serviceName
+	}
+	svc := cfg.writeServiceName
+	if isReadQuery(query) {
+		svc = cfg.readServiceName
+	}
+	if svc == "" {
+		return cfg.serviceName
+	}
+	return svc
+}
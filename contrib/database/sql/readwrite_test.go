@@ -0,0 +1,54 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsReadQuery(t *testing.T) {
+	for query, want := range map[string]bool{
+		"SELECT * FROM users":                                true,
+		"  select name from users":                           true,
+		"(SELECT 1) UNION (SELECT 2)":                        true,
+		"/* app:web */ SELECT 1":                             true,
+		"-- comment\nSELECT 1":                               true,
+		"WITH t AS (SELECT 1) SELECT * FROM t":               true,
+		"WITH last_update AS (SELECT 1) SELECT 2":            true,
+		"INSERT INTO users (name) VALUES (?)":                false,
+		"UPDATE users SET name = ?":                          false,
+		"DELETE FROM users":                                  false,
+		"CREATE TABLE users (id INT)":                        false,
+		"WITH d AS (DELETE FROM t RETURNING *) SELECT 1":     false,
+		"with t as (select 1) insert into u select * from t": false,
+		"SELECT * FROM jobs WHERE id = ? FOR UPDATE":         false,
+		"select * from jobs for update skip locked":          false,
+		"SELECT * FROM jobs FOR NO KEY UPDATE":               false,
+		"SELECT * FROM jobs FOR\n  SHARE":                    false,
+		"SELECT * FROM jobs FOR KEY SHARE NOWAIT":            false,
+		"SELECT * FROM jobs LOCK IN SHARE MODE":              false,
+		"WITH j AS (SELECT 1) SELECT * FROM j FOR UPDATE":    false,
+		"SELECT for_update, share FROM jobs":                 true,
+		"SELECT * FROM jobs WHERE state = 'FOR'":             true,
+		"":                                                   false,
+		"/* unterminated":                                    false,
+	} {
+		assert.Equal(t, want, isReadQuery(query), query)
+	}
+}
+
+func TestServiceNameFor(t *testing.T) {
+	cfg := &config{serviceName: "db", readServiceName: "db-read", writeServiceName: "db-write"}
+	assert.Equal(t, "db-read", cfg.serviceNameFor("SELECT 1"))
+	assert.Equal(t, "db-write", cfg.serviceNameFor("INSERT INTO t VALUES (1)"))
+	assert.Equal(t, "db", cfg.serviceNameFor(""))
+
+	cfg = &config{serviceName: "db", readServiceName: "db-read"}
+	assert.Equal(t, "db-read", cfg.serviceNameFor("SELECT 1"))
+	assert.Equal(t, "db", cfg.serviceNameFor("INSERT INTO t VALUES (1)"))
+}
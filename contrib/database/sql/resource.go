@@ -0,0 +1,43 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"unicode/utf8"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+)
+
+// truncatedSuffix ends the resources truncated as configured using WithMaxResourceNameLength.
+const truncatedSuffix = "..."
+
+// resourceName returns the resource of the spans of query. Queries longer than the maximum
+// length set using WithMaxResourceNameLength are obfuscated, and truncated to that many runes.
+func (tp *traceParams) resourceName(query string) string {
+	max := tp.cfg.maxResourceNameLength
+	if max <= 0 || utf8.RuneCountInString(query) <= max {
+		return query
+	}
+	if oq, err := obfuscateQuery(query, tp.cfg.queryCache); err == nil {
+		query = oq
+	} else {
+		log.Debug("contrib/database/sql: unable to obfuscate query before truncating it: %v", err)
+	}
+	return truncateRunes(query, max)
+}
+
+// truncateRunes returns s truncated to its first n runes, followed by truncatedSuffix, or s
+// itself if it is not longer than n runes.
+func truncateRunes(s string, n int) string {
+	i := 0
+	for pos := range s {
+		if i == n {
+			return s[:pos] + truncatedSuffix
+		}
+		i++
+	}
+	return s
+}
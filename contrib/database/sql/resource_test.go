@@ -0,0 +1,75 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/database/sql/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+)
+
+func TestTruncateRunes(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{in: "SELECT 1", n: 8, want: "SELECT 1"},
+		{in: "SELECT 1", n: 20, want: "SELECT 1"},
+		{in: "SELECT 1", n: 6, want: "SELECT..."},
+		{in: "SELECT 'héllo'", n: 10, want: "SELECT 'hé..."},
+		{in: "日本語のテーブル", n: 3, want: "日本語..."},
+	} {
+		got := truncateRunes(tt.in, tt.n)
+		assert.Equal(t, tt.want, got)
+		assert.True(t, utf8.ValidString(got))
+	}
+}
+
+func TestWithMaxResourceNameLength(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	const max = 40
+	Register("postgres", &internal.MockDriver{}, WithMaxResourceNameLength(max))
+	defer unregister("postgres")
+	db, err := Open("postgres", "postgres://bob@db.internal:5432/orders")
+	require.NoError(t, err)
+	defer db.Close()
+
+	t.Run("oversized", func(t *testing.T) {
+		mt.Reset()
+		query := "SELECT " + strings.Repeat("prénom, ", 1000) + "nom FROM 注文 WHERE id = 'é'"
+		rows, err := db.Query(query)
+		require.NoError(t, err)
+		rows.Close()
+
+		spans := spansOfType(mt.FinishedSpans(), queryTypeQuery)
+		require.Len(t, spans, 1)
+		resource := spans[0].Tag(ext.ResourceName).(string)
+		assert.Equal(t, "SELECT prénom, prénom, prénom, prénom, p...", resource)
+		assert.True(t, utf8.ValidString(resource))
+	})
+
+	t.Run("short", func(t *testing.T) {
+		mt.Reset()
+		const query = "SELECT * FROM orders WHERE id = 1"
+		rows, err := db.Query(query)
+		require.NoError(t, err)
+		rows.Close()
+
+		spans := spansOfType(mt.FinishedSpans(), queryTypeQuery)
+		require.Len(t, spans, 1)
+		assert.Equal(t, query, spans[0].Tag(ext.ResourceName))
+	})
+}
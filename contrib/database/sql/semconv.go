@@ -0,0 +1,48 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"strconv"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+)
+
+// Tags of the OpenTelemetry semantic conventions which have no equivalent in ext, set
+// when enabled using WithOTelSemanticConventions.
+const (
+	keyOTelServerAddress = "server.address"
+	keyOTelServerPort    = "server.port"
+)
+
+// setOTelTags sets the tags of the OpenTelemetry database semantic conventions which differ
+// from the ones already set on span, if enabled using WithOTelSemanticConventions. The
+// db.system, db.name and db.user tags share their keys with the ones set by default.
+func (tp *traceParams) setOTelTags(span ddtrace.Span, query string) {
+	if !tp.cfg.otelSemanticConventions {
+		return
+	}
+	if query != "" {
+		// the raw query may hold sensitive literals, which are never reported
+		if oq, err := obfuscateQuery(query, tp.cfg.queryCache); err == nil {
+			span.SetTag(ext.DBStatement, oq)
+		} else {
+			log.Debug("contrib/database/sql: unable to obfuscate query for %s: %v", ext.DBStatement, err)
+		}
+	}
+	if host, ok := tp.meta[ext.TargetHost]; ok {
+		span.SetTag(keyOTelServerAddress, host)
+	}
+	if port, ok := tp.meta[ext.TargetPort]; ok {
+		if n, err := strconv.Atoi(port); err == nil {
+			span.SetTag(keyOTelServerPort, n)
+		} else {
+			span.SetTag(keyOTelServerPort, port)
+		}
+	}
+}
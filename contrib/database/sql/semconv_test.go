@@ -0,0 +1,62 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/database/sql/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+)
+
+func TestWithOTelSemanticConventions(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	for name, enabled := range map[string]bool{"enabled": true, "disabled": false} {
+		t.Run(name, func(t *testing.T) {
+			var opts []Option
+			if enabled {
+				opts = append(opts, WithOTelSemanticConventions())
+			}
+			Register("postgres", &internal.MockDriver{}, opts...)
+			defer unregister("postgres")
+			db, err := Open("postgres", "postgres://bob@db.internal:5433/orders")
+			require.NoError(t, err)
+			defer db.Close()
+
+			mt.Reset()
+			const query = "SELECT * FROM orders WHERE id = 42"
+			rows, err := db.Query(query)
+			require.NoError(t, err)
+			rows.Close()
+
+			spans := spansOfType(mt.FinishedSpans(), queryTypeQuery)
+			require.Len(t, spans, 1)
+			span := spans[0]
+			// the Datadog tags are always set
+			assert.Equal(t, ext.DBSystemPostgreSQL, span.Tag(ext.DBSystem))
+			assert.Equal(t, "orders", span.Tag(ext.DBName))
+			assert.Equal(t, "bob", span.Tag(ext.DBUser))
+			assert.Equal(t, "db.internal", span.Tag(ext.TargetHost))
+			assert.Equal(t, "5433", span.Tag(ext.TargetPort))
+			assert.Equal(t, query, span.Tag(ext.ResourceName))
+			if !enabled {
+				assert.Nil(t, span.Tag(ext.DBStatement))
+				assert.Nil(t, span.Tag(keyOTelServerAddress))
+				assert.Nil(t, span.Tag(keyOTelServerPort))
+				return
+			}
+			assert.Equal(t, "SELECT * FROM orders WHERE id = ?", span.Tag(ext.DBStatement))
+			assert.Equal(t, "db.internal", span.Tag(keyOTelServerAddress))
+			assert.Equal(t, 5433, span.Tag(keyOTelServerPort))
+		})
+	}
+}
@@ -0,0 +1,26 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import "context"
+
+// shardServiceName returns the given service of the span of query, suffixed with the shard
+// returned by the resolver set using WithShardResolver, or replaced with the service returned
+// by the resolver set using WithShardServiceResolver, if any.
+func (cfg *config) shardServiceName(ctx context.Context, query, svc string) string {
+	if cfg.shardResolver == nil {
+		return svc
+	}
+	shard := cfg.shardResolver(ctx, query)
+	switch {
+	case shard == "":
+		return svc
+	case cfg.shardReplace:
+		return shard
+	default:
+		return svc + "-" + shard
+	}
+}
@@ -0,0 +1,98 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/database/sql/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+)
+
+type shardKey struct{}
+
+func TestWithShardResolver(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	var resolved []string
+	resolver := func(ctx context.Context, query string) string {
+		resolved = append(resolved, query)
+		shard, _ := ctx.Value(shardKey{}).(string)
+		return shard
+	}
+	Register("postgres", &internal.MockDriver{}, WithServiceName("orders-db"), WithShardResolver(resolver))
+	defer unregister("postgres")
+	db, err := Open("postgres", "postgres://bob@db.internal:5432/orders")
+	require.NoError(t, err)
+	defer db.Close()
+
+	for _, tt := range []struct {
+		shard   string
+		query   string
+		service string
+	}{
+		{shard: "shard-1", query: "SELECT * FROM orders WHERE id = 1", service: "orders-db-shard-1"},
+		{shard: "shard-2", query: "SELECT * FROM orders WHERE id = 2", service: "orders-db-shard-2"},
+		{shard: "", query: "SELECT * FROM orders WHERE id = 3", service: "orders-db"},
+	} {
+		t.Run(tt.service, func(t *testing.T) {
+			mt.Reset()
+			resolved = nil
+			ctx := context.WithValue(context.Background(), shardKey{}, tt.shard)
+			rows, err := db.QueryContext(ctx, tt.query)
+			require.NoError(t, err)
+			rows.Close()
+
+			spans := spansOfType(mt.FinishedSpans(), queryTypeQuery)
+			require.Len(t, spans, 1)
+			assert.Equal(t, tt.service, spans[0].Tag(ext.ServiceName))
+			assert.Contains(t, strings.Join(resolved, "\n"), tt.query)
+		})
+	}
+}
+
+func TestWithShardServiceResolver(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	resolver := func(ctx context.Context, query string) string {
+		shard, _ := ctx.Value(shardKey{}).(string)
+		return shard
+	}
+	Register("postgres", &internal.MockDriver{}, WithServiceName("orders-db"), WithShardServiceResolver(resolver))
+	defer unregister("postgres")
+	db, err := Open("postgres", "postgres://bob@db.internal:5432/orders")
+	require.NoError(t, err)
+	defer db.Close()
+
+	for _, tt := range []struct {
+		shard   string
+		service string
+	}{
+		{shard: "orders-eu", service: "orders-eu"},
+		{shard: "orders-us", service: "orders-us"},
+		{shard: "", service: "orders-db"},
+	} {
+		t.Run(tt.service, func(t *testing.T) {
+			mt.Reset()
+			ctx := context.WithValue(context.Background(), shardKey{}, tt.shard)
+			rows, err := db.QueryContext(ctx, "SELECT * FROM orders WHERE id = 1")
+			require.NoError(t, err)
+			rows.Close()
+
+			spans := spansOfType(mt.FinishedSpans(), queryTypeQuery)
+			require.Len(t, spans, 1)
+			assert.Equal(t, tt.service, spans[0].Tag(ext.ServiceName))
+		})
+	}
+}
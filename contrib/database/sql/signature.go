@@ -0,0 +1,53 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"hash/fnv"
+	"strconv"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+
+	"github.com/DataDog/datadog-agent/pkg/obfuscate"
+)
+
+// keyQuerySignature is the tag holding the signature of the query.
+const keyQuerySignature = "sql.query_signature"
+
+// obfuscator obfuscates the queries before computing their signature.
+var obfuscator = obfuscate.NewObfuscator(obfuscate.Config{})
+
+// obfuscateQuery returns the obfuscated version of query, looking it up in the
+// given cache first, if not nil, to avoid parsing the same query over and over.
+func obfuscateQuery(query string, cache *queryCache) (string, error) {
+	if cache != nil {
+		if oq, ok := cache.get(query); ok {
+			return oq, nil
+		}
+	}
+	oq, err := obfuscator.ObfuscateSQLString(query)
+	if err != nil {
+		return "", err
+	}
+	if cache != nil {
+		cache.add(query, oq.Query)
+	}
+	return oq.Query, nil
+}
+
+// querySignature returns the hex encoded FNV-1a hash of the obfuscated query, which
+// is the same for queries differing only in their literals. It returns false if the
+// query can not be obfuscated. The obfuscated queries are cached in cache, if not nil.
+func querySignature(query string, cache *queryCache) (string, bool) {
+	oq, err := obfuscateQuery(query, cache)
+	if err != nil {
+		log.Debug("contrib/database/sql: unable to obfuscate query for signature: %v", err)
+		return "", false
+	}
+	h := fnv.New64a()
+	h.Write([]byte(oq))
+	return strconv.FormatUint(h.Sum64(), 16), true
+}
@@ -0,0 +1,71 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/database/sql/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+)
+
+func TestQuerySignature(t *testing.T) {
+	sig := func(q string) string {
+		s, ok := querySignature(q, nil)
+		require.True(t, ok, q)
+		return s
+	}
+	assert.Equal(t, sig("SELECT * FROM users WHERE id = 1"), sig("SELECT * FROM users WHERE id = 42"))
+	assert.Equal(t, sig("SELECT * FROM users WHERE name = 'alice'"), sig("SELECT * FROM users WHERE name = 'bob'"))
+	assert.Equal(t, sig("SELECT * FROM users WHERE id IN (1, 2)"), sig("SELECT * FROM users WHERE id IN (3, 4, 5)"))
+	assert.NotEqual(t, sig("SELECT * FROM users WHERE id = 1"), sig("SELECT * FROM orders WHERE id = 1"))
+	assert.NotEqual(t, sig("SELECT * FROM users WHERE id = 1"), sig("SELECT * FROM users WHERE id > 1"))
+}
+
+func TestWithQuerySignature(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	for name, tt := range map[string]struct {
+		opts []Option
+		want bool
+	}{
+		"disabled": {},
+		"enabled":  {opts: []Option{WithQuerySignature()}, want: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			Register("test", &internal.MockDriver{}, tt.opts...)
+			defer unregister("test")
+			db, err := Open("test", "dn")
+			require.NoError(t, err)
+			defer db.Close()
+
+			mt.Reset()
+			for _, q := range []string{
+				"SELECT name FROM users WHERE id = 1 AND status = 'active'",
+				"SELECT name FROM users WHERE id = 2 AND status = 'deleted'",
+			} {
+				rows, err := db.QueryContext(context.Background(), q)
+				require.NoError(t, err)
+				rows.Close()
+			}
+
+			spans := spansOfType(mt.FinishedSpans(), queryTypeQuery)
+			require.Len(t, spans, 2)
+			if !tt.want {
+				assert.Nil(t, spans[0].Tag(keyQuerySignature))
+				assert.Nil(t, spans[1].Tag(keyQuerySignature))
+				return
+			}
+			assert.NotEmpty(t, spans[0].Tag(keyQuerySignature))
+			assert.Equal(t, spans[0].Tag(keyQuerySignature), spans[1].Tag(keyQuerySignature))
+		})
+	}
+}
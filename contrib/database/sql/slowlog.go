@@ -0,0 +1,64 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+)
+
+// slowQueryLogEntry is the JSON encoded log line emitted for slow queries, as enabled
+// using WithSlowQueryLog. The dd.* attributes correlate it with the trace of the query.
+type slowQueryLogEntry struct {
+	Message   string  `json:"message"`
+	Duration  float64 `json:"duration_ms"`
+	QueryType string  `json:"sql.query_type"`
+	Query     string  `json:"db.statement,omitempty"`
+	Service   string  `json:"dd.service,omitempty"`
+	TraceID   string  `json:"dd.trace_id,omitempty"`
+	SpanID    string  `json:"dd.span_id,omitempty"`
+}
+
+// logSlowQuery logs the given query, started at startTime, using the logger set with
+// WithSlowQueryLog, if it took at least the configured threshold. The log line holds the
+// IDs of span, or of the span found in ctx if span is nil, such as when the query was not
+// traced. The query is only logged obfuscated, and left out if it can not be obfuscated.
+func (tp *traceParams) logSlowQuery(ctx context.Context, span ddtrace.Span, qtype queryType, query string, startTime time.Time) {
+	d := time.Since(startTime)
+	if d < tp.cfg.slowQueryLogThreshold {
+		return
+	}
+	e := slowQueryLogEntry{
+		Message:   "slow query",
+		Duration:  float64(d) / float64(time.Millisecond),
+		QueryType: string(qtype),
+		Service:   tp.cfg.serviceNameFor(query),
+	}
+	if oq, err := obfuscateQuery(query, tp.cfg.queryCache); err == nil {
+		e.Query = oq
+	}
+	if span == nil {
+		if s, ok := tracer.SpanFromContext(ctx); ok {
+			span = s
+		}
+	}
+	if span != nil {
+		e.TraceID = strconv.FormatUint(span.Context().TraceID(), 10)
+		e.SpanID = strconv.FormatUint(span.Context().SpanID(), 10)
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		log.Debug("contrib/database/sql: failed to encode slow query log: %v", err)
+		return
+	}
+	tp.cfg.slowQueryLogger.Log(string(b))
+}
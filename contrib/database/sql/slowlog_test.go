@@ -0,0 +1,85 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/database/sql/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+// recordLogger records the messages it logs.
+type recordLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *recordLogger) Log(msg string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, msg)
+}
+
+func (l *recordLogger) Lines() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.lines...)
+}
+
+func TestWithSlowQueryLog(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	l := new(recordLogger)
+	Register("test", &slowDriver{MockDriver: &internal.MockDriver{}, delay: 20 * time.Millisecond},
+		WithServiceName("orders-db"), WithSlowQueryLog(10*time.Millisecond, l))
+	defer unregister("test")
+	db, err := Open("test", "dn")
+	require.NoError(t, err)
+	defer db.Close()
+
+	root, ctx := tracer.StartSpanFromContext(context.Background(), "http.request")
+	_, err = db.ExecContext(ctx, "SELECT pg_sleep(0.02) FROM orders WHERE id = 42")
+	require.NoError(t, err)
+	root.Finish()
+
+	spans := spansOfType(mt.FinishedSpans(), queryTypeExec)
+	require.Len(t, spans, 1)
+	lines := l.Lines()
+	require.Len(t, lines, 1)
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "slow query", entry["message"])
+	assert.Equal(t, "SELECT pg_sleep ( ? ) FROM orders WHERE id = ?", entry["db.statement"])
+	assert.Equal(t, "Exec", entry["sql.query_type"])
+	assert.Equal(t, "orders-db", entry["dd.service"])
+	assert.Equal(t, strconv.FormatUint(spans[0].TraceID(), 10), entry["dd.trace_id"])
+	assert.Equal(t, strconv.FormatUint(spans[0].SpanID(), 10), entry["dd.span_id"])
+	assert.GreaterOrEqual(t, entry["duration_ms"], 20.0)
+
+	t.Run("fast", func(t *testing.T) {
+		l := new(recordLogger)
+		Register("fast", &internal.MockDriver{}, WithSlowQueryLog(time.Hour, l))
+		defer unregister("fast")
+		db, err := Open("fast", "dn")
+		require.NoError(t, err)
+		defer db.Close()
+
+		_, err = db.ExecContext(context.Background(), "INSERT INTO orders (id) VALUES (1)")
+		require.NoError(t, err)
+		assert.Empty(t, l.Lines())
+	})
+}
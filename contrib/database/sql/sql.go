@@ -20,12 +20,15 @@ import (
 	"database/sql"
 	"database/sql/driver"
 	"errors"
+	"io"
 	"math"
 	"reflect"
 	"sync"
 	"time"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/contrib/database/sql/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 )
@@ -123,10 +126,31 @@ func Register(driverName string, driver driver.Driver, opts ...RegisterOption) {
 	if cfg.serviceName == "" {
 		cfg.serviceName = driverName + ".db"
 	}
+	if cfg.dbSystem == "" && isSQLiteDriver(driver) {
+		cfg.dbSystem = ext.DBSystemSQLite
+	}
+	if cfg.driverVersion == "" {
+		cfg.driverVersion = driverVersion(driver)
+	}
 	log.Debug("contrib/database/sql: Registering driver: %s %#v", driverName, cfg)
 	registeredDrivers.add(driverName, driver, cfg)
 }
 
+// sqliteDriverPackages are the import paths of the packages of known SQLite drivers.
+var sqliteDriverPackages = map[string]bool{
+	"github.com/mattn/go-sqlite3": true,
+	"modernc.org/sqlite":          true,
+}
+
+// isSQLiteDriver reports whether d is a known SQLite driver, based on its package.
+func isSQLiteDriver(d driver.Driver) bool {
+	t := reflect.TypeOf(d)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return sqliteDriverPackages[t.PkgPath()]
+}
+
 // unregister is used to make tests idempotent.
 func unregister(name string) {
 	if registeredDrivers.isRegistered(name) {
@@ -142,21 +166,79 @@ type tracedConnector struct {
 	connector  driver.Connector
 	driverName string
 	cfg        *config
+	retries    connectRetries
+	// warmup groups the connections opened when the pool warms up, if enabled.
+	warmup *warmup
+}
+
+// keyConnectRetries holds the number of failed connections preceding a connection, when
+// enabled using WithConnectRetries.
+const keyConnectRetries = "sql.connect.retries"
+
+// connectRetries counts the consecutive failed connections of a connector.
+type connectRetries struct {
+	mu       sync.Mutex
+	failures int       // number of consecutive failed connections
+	last     time.Time // time of the last failed connection
+}
+
+// attempt returns the number of failed connections preceding a connection started at
+// start, failures older than window not being counted.
+func (r *connectRetries) attempt(start time.Time, window time.Duration) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failures > 0 && start.Sub(r.last) > window {
+		r.failures = 0
+	}
+	return r.failures
+}
+
+// done records the outcome of a connection which ended at end.
+func (r *connectRetries) done(end time.Time, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err == nil {
+		r.failures = 0
+		return
+	}
+	r.failures++
+	r.last = end
 }
 
 func (t *tracedConnector) Connect(ctx context.Context) (driver.Conn, error) {
 	tp := &traceParams{
 		driverName: t.driverName,
 		cfg:        t.cfg,
+		warmup:     t.warmup,
+	}
+	dsnDriverName := t.driverName
+	if t.cfg.dbSystem == ext.DBSystemSQLite {
+		// SQLite drivers may be registered under any name
+		dsnDriverName = "sqlite"
 	}
 	if dc, ok := t.connector.(*dsnConnector); ok {
-		tp.meta, _ = internal.ParseDSN(t.driverName, dc.dsn)
+		tp.meta, _ = internal.ParseDSN(dsnDriverName, dc.dsn)
 	} else if t.cfg.dsn != "" {
-		tp.meta, _ = internal.ParseDSN(t.driverName, t.cfg.dsn)
+		tp.meta, _ = internal.ParseDSN(dsnDriverName, t.cfg.dsn)
 	}
 	start := time.Now()
+	var opts []ddtrace.StartSpanOption
+	if window := t.cfg.connectRetryWindow; window > 0 {
+		if n := t.retries.attempt(start, window); n > 0 {
+			opts = append(opts, tracer.Tag(keyConnectRetries, n))
+		}
+	}
+	if t.warmup != nil {
+		ctx = t.warmup.context(ctx)
+	}
 	conn, err := t.connector.Connect(ctx)
-	tp.tryTrace(ctx, queryTypeConnect, "", start, err)
+	if t.cfg.connectRetryWindow > 0 {
+		t.retries.done(time.Now(), err)
+	}
+	tp.tryTrace(ctx, queryTypeConnect, "", start, err, opts...)
+	if t.warmup != nil {
+		t.warmup.done(err)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -167,6 +249,19 @@ func (t *tracedConnector) Driver() driver.Driver {
 	return t.connector.Driver()
 }
 
+// Close implements io.Closer, which database/sql calls when closing the database. It
+// closes the wrapped connector, if it implements io.Closer as well.
+func (t *tracedConnector) Close() error {
+	tracedDBs.remove(t)
+	if t.warmup != nil {
+		t.warmup.finish(nil)
+	}
+	if c, ok := t.connector.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
 // from Go stdlib implementation of sql.Open
 type dsnConnector struct {
 	dsn    string
@@ -207,13 +302,104 @@ func OpenDB(c driver.Connector, opts ...Option) *sql.DB {
 	if cfg.dbmPropagationMode == tracer.DBMPropagationModeUndefined {
 		cfg.dbmPropagationMode = rc.dbmPropagationMode
 	}
+	if cfg.slowQueryThreshold == 0 {
+		cfg.slowQueryThreshold = rc.slowQueryThreshold
+	}
+	if cfg.slowQueryLogger == nil {
+		cfg.slowQueryLogger = rc.slowQueryLogger
+		cfg.slowQueryLogThreshold = rc.slowQueryLogThreshold
+	}
+	if cfg.env == "" {
+		cfg.env = rc.env
+	}
+	if cfg.version == "" {
+		cfg.version = rc.version
+	}
+	if cfg.contextTags == nil {
+		cfg.contextTags = rc.contextTags
+	}
+	if cfg.spanType == "" {
+		cfg.spanType = rc.spanType
+	}
+	if cfg.dbSystem == "" {
+		cfg.dbSystem = rc.dbSystem
+	}
+	if cfg.readServiceName == "" && cfg.writeServiceName == "" {
+		cfg.readServiceName = rc.readServiceName
+		cfg.writeServiceName = rc.writeServiceName
+	}
 	cfg.childSpansOnly = rc.childSpansOnly
+	cfg.batchSpans = cfg.batchSpans || rc.batchSpans
+	cfg.featureTags = cfg.featureTags || rc.featureTags
+	cfg.sessionResetSpans = cfg.sessionResetSpans || rc.sessionResetSpans
+	cfg.argTypeTags = cfg.argTypeTags || rc.argTypeTags
+	cfg.argCheckErrors = cfg.argCheckErrors || rc.argCheckErrors
+	cfg.postgresPlanTags = cfg.postgresPlanTags || rc.postgresPlanTags
+	cfg.querySignature = cfg.querySignature || rc.querySignature
+	cfg.constraintViolationNonError = cfg.constraintViolationNonError || rc.constraintViolationNonError
+	cfg.sqlCommentExtraction = cfg.sqlCommentExtraction || rc.sqlCommentExtraction
+	cfg.otelSemanticConventions = cfg.otelSemanticConventions || rc.otelSemanticConventions
+	cfg.copyAggregation = cfg.copyAggregation || rc.copyAggregation
+	if cfg.driverVersion == "" {
+		cfg.driverVersion = rc.driverVersion
+	}
+	if cfg.dbmHostname == "" {
+		cfg.dbmHostname = rc.dbmHostname
+	}
+	if cfg.connectRetryWindow == 0 {
+		cfg.connectRetryWindow = rc.connectRetryWindow
+	}
+	if cfg.obfuscationCacheSize == 0 {
+		cfg.obfuscationCacheSize = rc.obfuscationCacheSize
+	}
+	if cfg.obfuscationCacheSize == 0 {
+		cfg.obfuscationCacheSize = defaultObfuscationCacheSize
+	}
+	if cfg.operationPrefix == "" {
+		cfg.operationPrefix = rc.operationPrefix
+	}
+	if cfg.shardResolver == nil {
+		cfg.shardResolver = rc.shardResolver
+		cfg.shardReplace = rc.shardReplace
+	}
+	if cfg.sqlCommentTags == nil {
+		cfg.sqlCommentTags = rc.sqlCommentTags
+	}
+	if cfg.maxResourceNameLength == 0 {
+		cfg.maxResourceNameLength = rc.maxResourceNameLength
+	}
+	if cfg.warmupSpanName == "" {
+		cfg.warmupSpanName = rc.warmupSpanName
+	}
+	if cfg.warmupConns == 0 {
+		cfg.warmupConns = rc.warmupConns
+	}
+	if cfg.errorCoalescingWindow == 0 {
+		cfg.errorCoalescingWindow = rc.errorCoalescingWindow
+	}
+	if cfg.errorCoalescingWindow > 0 {
+		cfg.errorCoalescer = newErrorCoalescer(cfg.errorCoalescingWindow)
+		if cfg.dbmPropagationMode == tracer.DBMPropagationModeFull {
+			// coalesced calls have no span of their own, so the span IDs injected in their
+			// queries would reference spans which are never sent
+			log.Warn("contrib/database/sql: error coalescing is enabled, using DBM propagation mode %q instead of %q", tracer.DBMPropagationModeService, tracer.DBMPropagationModeFull)
+			cfg.dbmPropagationMode = tracer.DBMPropagationModeService
+		}
+	}
+	if cfg.obfuscatesQueries() && cfg.obfuscationCacheSize > 0 {
+		cfg.queryCache = newQueryCache(cfg.obfuscationCacheSize)
+	}
 	tc := &tracedConnector{
 		connector:  c,
 		driverName: name,
 		cfg:        cfg,
 	}
-	return sql.OpenDB(tc)
+	if cfg.warmupSpanName != "" {
+		tc.warmup = startWarmup(cfg)
+	}
+	db := sql.OpenDB(tc)
+	tracedDBs.add(db, tc)
+	return db
 }
 
 // Open returns connection to a DB using the traced version of the given driver. In order for Open
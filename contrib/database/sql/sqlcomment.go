@@ -0,0 +1,66 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"net/url"
+	"strings"
+)
+
+// keySQLCommentPrefix prefixes the tags holding the key/value pairs extracted from the
+// sqlcommenter comment of queries, as enabled using WithSQLCommentExtraction.
+const keySQLCommentPrefix = "sql.comment."
+
+// extractSQLComment parses the sqlcommenter comment leading query, if any, and returns the
+// key/value pairs it holds along with the query without the comment. It returns false if
+// the query does not start with a comment, or if the comment does not follow the format of
+// the sqlcommenter specification, in which case the query should be used as is.
+// See https://google.github.io/sqlcommenter/spec/ for more details.
+func extractSQLComment(query string) (tags map[string]string, rest string, ok bool) {
+	q := strings.TrimLeft(query, " \t\r\n")
+	if !strings.HasPrefix(q, "/*") {
+		return nil, query, false
+	}
+	end := strings.Index(q, "*/")
+	if end < 0 {
+		return nil, query, false
+	}
+	body := strings.TrimSpace(q[2:end])
+	if body == "" {
+		return nil, query, false
+	}
+	tags = make(map[string]string)
+	for _, pair := range strings.Split(body, ",") {
+		k, v, ok := parseSQLCommentPair(strings.TrimSpace(pair))
+		if !ok {
+			return nil, query, false
+		}
+		tags[k] = v
+	}
+	return tags, strings.TrimLeft(q[end+2:], " \t\r\n"), true
+}
+
+// parseSQLCommentPair parses a key='value' pair of a sqlcommenter comment, whose key and
+// value are URL encoded, and whose value has its single quotes escaped.
+func parseSQLCommentPair(pair string) (key, value string, ok bool) {
+	i := strings.IndexByte(pair, '=')
+	if i <= 0 {
+		return "", "", false
+	}
+	quoted := pair[i+1:]
+	if len(quoted) < 2 || quoted[0] != '\'' || quoted[len(quoted)-1] != '\'' {
+		return "", "", false
+	}
+	key, err := url.PathUnescape(pair[:i])
+	if err != nil {
+		return "", "", false
+	}
+	value, err = url.PathUnescape(strings.ReplaceAll(quoted[1:len(quoted)-1], `\'`, "'"))
+	if err != nil {
+		return "", "", false
+	}
+	return key, value, true
+}
@@ -0,0 +1,100 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/database/sql/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+)
+
+func TestExtractSQLComment(t *testing.T) {
+	for _, tt := range []struct {
+		query string
+		tags  map[string]string
+		rest  string
+	}{
+		{
+			query: "/*controller='index',framework='spring',route='%2Fusers%2F%3Aid'*/ SELECT * FROM users",
+			tags:  map[string]string{"controller": "index", "framework": "spring", "route": "/users/:id"},
+			rest:  "SELECT * FROM users",
+		},
+		{
+			query: "  /* dddbs='orders-db', name='it\\'s' */SELECT 1",
+			tags:  map[string]string{"dddbs": "orders-db", "name": "it's"},
+			rest:  "SELECT 1",
+		},
+		{query: "SELECT 1 /*controller='index'*/"},
+		{query: "/* no pairs */ SELECT 1"},
+		{query: "/*controller=index*/ SELECT 1"},
+		{query: "/*controller='index' SELECT 1"},
+		{query: "/*='index'*/ SELECT 1"},
+		{query: "/*controller='%zz'*/ SELECT 1"},
+		{query: "/**/ SELECT 1"},
+		{query: ""},
+	} {
+		tags, rest, ok := extractSQLComment(tt.query)
+		if tt.tags == nil {
+			assert.False(t, ok, tt.query)
+			assert.Nil(t, tags, tt.query)
+			assert.Equal(t, tt.query, rest)
+			continue
+		}
+		assert.True(t, ok, tt.query)
+		assert.Equal(t, tt.tags, tags)
+		assert.Equal(t, tt.rest, rest)
+	}
+}
+
+func TestWithSQLCommentExtraction(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	const query = "/*controller='checkout',traceparent='00-0000000000000000000000000000002a-000000000000002b-01'*/ SELECT * FROM orders"
+	for name, tt := range map[string]struct {
+		opts     []Option
+		resource string
+		tags     map[string]string
+	}{
+		"disabled": {resource: query},
+		"enabled": {
+			opts:     []Option{WithSQLCommentExtraction()},
+			resource: "SELECT * FROM orders",
+			tags: map[string]string{
+				"sql.comment.controller":  "checkout",
+				"sql.comment.traceparent": "00-0000000000000000000000000000002a-000000000000002b-01",
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			Register("test", &internal.MockDriver{}, tt.opts...)
+			defer unregister("test")
+			db, err := Open("test", "dn")
+			require.NoError(t, err)
+			defer db.Close()
+
+			mt.Reset()
+			rows, err := db.QueryContext(context.Background(), query)
+			require.NoError(t, err)
+			rows.Close()
+
+			spans := spansOfType(mt.FinishedSpans(), string(queryTypeQuery))
+			require.Len(t, spans, 1)
+			assert.Equal(t, tt.resource, spans[0].Tag("resource.name"))
+			for k, v := range tt.tags {
+				assert.Equal(t, v, spans[0].Tag(k))
+			}
+			if tt.tags == nil {
+				assert.Nil(t, spans[0].Tag("sql.comment.controller"))
+			}
+		})
+	}
+}
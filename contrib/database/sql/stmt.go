@@ -10,8 +10,16 @@ import (
 	"database/sql/driver"
 	"errors"
 	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 )
 
+// keyPGPrepared is set on the spans of prepared Postgres statements when enabled
+// using WithPostgresPlanTags.
+const keyPGPrepared = "sql.pg.prepared"
+
 var _ driver.Stmt = (*tracedStmt)(nil)
 
 // tracedStmt is traced version of sql.Stmt
@@ -20,12 +28,49 @@ type tracedStmt struct {
 	*traceParams
 	ctx   context.Context
 	query string
+	// conn is the wrapped connection the statement was prepared on.
+	conn driver.Conn
+	// copy is set when the statement is a bulk copy traced as a whole, as enabled
+	// using WithCopyAggregation.
+	copy *copyState
+}
+
+// newTracedStmt returns the traced version of stmt, prepared for query.
+func (tc *TracedConn) newTracedStmt(ctx context.Context, stmt driver.Stmt, query string) *tracedStmt {
+	s := &tracedStmt{Stmt: stmt, traceParams: tc.traceParams, ctx: ctx, query: query, conn: tc.Conn}
+	if tc.cfg.copyAggregation && isCopyFromStdin(query) {
+		s.copy = new(copyState)
+	}
+	return s
+}
+
+// CheckNamedValue implements driver.NamedValueChecker like TracedConn.CheckNamedValue,
+// using the checker of the wrapped statement, if any, or of its connection.
+func (s *tracedStmt) CheckNamedValue(value *driver.NamedValue) error {
+	checker, ok := s.Stmt.(driver.NamedValueChecker)
+	if !ok {
+		checker, _ = s.conn.(driver.NamedValueChecker)
+	}
+	return s.checkNamedValue(checker, value)
+}
+
+// ColumnConverter implements driver.ColumnConverter, returning the converter of the
+// wrapped statement, if any, or the default one, which database/sql uses otherwise.
+func (s *tracedStmt) ColumnConverter(idx int) driver.ValueConverter {
+	if cc, ok := s.Stmt.(driver.ColumnConverter); ok {
+		return cc.ColumnConverter(idx)
+	}
+	return driver.DefaultParameterConverter
 }
 
 // Close sends a span before closing a statement
 func (s *tracedStmt) Close() (err error) {
 	start := time.Now()
 	err = s.Stmt.Close()
+	if s.copy != nil && s.copy.rows > 0 {
+		// drivers complete pending copies when closing their statement
+		s.finishCopy(s.ctx, err)
+	}
 	s.tryTrace(s.ctx, queryTypeClose, "", start, err)
 	return err
 }
@@ -33,9 +78,16 @@ func (s *tracedStmt) Close() (err error) {
 // ExecContext is needed to implement the driver.StmtExecContext interface
 func (s *tracedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (res driver.Result, err error) {
 	start := time.Now()
+	if opts, err := argError(args); err != nil {
+		s.tryTrace(ctx, queryTypeExec, s.query, start, err, opts...)
+		return nil, err
+	}
+	if s.copy != nil {
+		return s.execCopy(ctx, args)
+	}
 	if stmtExecContext, ok := s.Stmt.(driver.StmtExecContext); ok {
 		res, err := stmtExecContext.ExecContext(ctx, args)
-		s.tryTrace(ctx, queryTypeExec, s.query, start, err)
+		s.tryTrace(ctx, queryTypeExec, s.query, start, err, s.argTypesTag(args), s.pgPreparedTag())
 		return res, err
 	}
 	dargs, err := namedValueToValue(args)
@@ -48,16 +100,20 @@ func (s *tracedStmt) ExecContext(ctx context.Context, args []driver.NamedValue)
 	default:
 	}
 	res, err = s.Exec(dargs)
-	s.tryTrace(ctx, queryTypeExec, s.query, start, err)
+	s.tryTrace(ctx, queryTypeExec, s.query, start, err, s.argTypesTag(args), s.pgPreparedTag())
 	return res, err
 }
 
 // QueryContext is needed to implement the driver.StmtQueryContext interface
 func (s *tracedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (rows driver.Rows, err error) {
 	start := time.Now()
+	if opts, err := argError(args); err != nil {
+		s.tryTrace(ctx, queryTypeQuery, s.query, start, err, opts...)
+		return nil, err
+	}
 	if stmtQueryContext, ok := s.Stmt.(driver.StmtQueryContext); ok {
 		rows, err := stmtQueryContext.QueryContext(ctx, args)
-		s.tryTrace(ctx, queryTypeQuery, s.query, start, err)
+		s.tryTrace(ctx, queryTypeQuery, s.query, start, err, s.argTypesTag(args), s.pgPreparedTag())
 		return rows, err
 	}
 	dargs, err := namedValueToValue(args)
@@ -70,7 +126,7 @@ func (s *tracedStmt) QueryContext(ctx context.Context, args []driver.NamedValue)
 	default:
 	}
 	rows, err = s.Query(dargs)
-	s.tryTrace(ctx, queryTypeQuery, s.query, start, err)
+	s.tryTrace(ctx, queryTypeQuery, s.query, start, err, s.argTypesTag(args), s.pgPreparedTag())
 	return rows, err
 }
 
@@ -85,3 +141,14 @@ func namedValueToValue(named []driver.NamedValue) ([]driver.Value, error) {
 	}
 	return dargs, nil
 }
+
+// pgPreparedTag returns a span option tagging the span of an execution of the statement
+// as prepared, when WithPostgresPlanTags is enabled and the database is Postgres, or a
+// no-op option otherwise. Prepared statements are executed by Postgres drivers using the
+// extended protocol, which allows the server to cache their plan.
+func (s *tracedStmt) pgPreparedTag() ddtrace.StartSpanOption {
+	if !s.cfg.postgresPlanTags || s.meta[ext.DBSystem] != ext.DBSystemPostgreSQL {
+		return func(*ddtrace.StartSpanConfig) {}
+	}
+	return tracer.Tag(keyPGPrepared, true)
+}
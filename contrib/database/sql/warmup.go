@@ -0,0 +1,100 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/clock"
+)
+
+// defaultWarmupConns is the number of connections kept idle by database/sql by default.
+const defaultWarmupConns = 2
+
+// warmupTimeout is the time after which the warmup is over, even if the pool holds fewer
+// connections than expected; replaced in tests.
+var warmupTimeout = 10 * time.Second
+
+// warmup groups the connections opened when the pool of a database warms up under a
+// single span, as enabled using WithWarmupSpanName.
+type warmup struct {
+	mu    sync.Mutex
+	span  ddtrace.Span // nil once finished
+	conns int          // number of connections left to open
+	timer clock.Timer  // finishes the warmup after warmupTimeout
+}
+
+// startWarmup starts the warmup span of a database opened with cfg.
+func startWarmup(cfg *config) *warmup {
+	w := &warmup{conns: cfg.warmupConns}
+	if w.conns <= 0 {
+		w.conns = defaultWarmupConns
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.span = tracer.StartSpan(cfg.warmupSpanName,
+		tracer.ServiceName(cfg.serviceName),
+		tracer.SpanType(cfg.spanTypeOrDefault()),
+		tracer.Tag(ext.Component, "database/sql"),
+	)
+	w.timer = clock.AfterFunc(warmupTimeout, func() { w.finish(nil) })
+	return w
+}
+
+// query records a call of type qtype, finishing the warmup on the first query, since the
+// pool is in use from then on.
+func (w *warmup) query(qtype queryType) {
+	switch qtype {
+	case queryTypeConnect, queryTypePing, queryTypeReset, queryTypeClose:
+		return
+	}
+	w.finish(nil)
+}
+
+// context returns ctx with the warmup span as its parent span, unless ctx already has a
+// span or the warmup is over.
+func (w *warmup) context(ctx context.Context) context.Context {
+	if _, ok := tracer.SpanFromContext(ctx); ok {
+		return ctx
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.span == nil {
+		return ctx
+	}
+	return tracer.ContextWithSpan(ctx, w.span)
+}
+
+// done records a connection which ended with err, finishing the warmup once enough
+// connections are opened, or when err is not nil.
+func (w *warmup) done(err error) {
+	w.mu.Lock()
+	if err == nil {
+		w.conns--
+	}
+	over := err != nil || w.conns <= 0
+	w.mu.Unlock()
+	if over {
+		w.finish(err)
+	}
+}
+
+// finish finishes the warmup span with err, if not finished yet.
+func (w *warmup) finish(err error) {
+	w.mu.Lock()
+	span := w.span
+	w.span = nil
+	w.timer.Stop()
+	w.mu.Unlock()
+	if span != nil {
+		span.Finish(tracer.WithError(err))
+	}
+}
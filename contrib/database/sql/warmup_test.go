@@ -0,0 +1,134 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/contrib/database/sql/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+)
+
+func TestWithWarmupSpanName(t *testing.T) {
+	Register("postgres", &internal.MockDriver{}, WithServiceName("orders-db"))
+	defer unregister("postgres")
+
+	// connect opens n connections at once and returns them to the pool.
+	connect := func(t *testing.T, db *sql.DB, n int) {
+		var conns []*sql.Conn
+		for i := 0; i < n; i++ {
+			conn, err := db.Conn(context.Background())
+			require.NoError(t, err)
+			conns = append(conns, conn)
+		}
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}
+
+	t.Run("warmup", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+		db, err := Open("postgres", "postgres://bob@db.internal:5432/orders",
+			WithWarmupSpanName("db.warmup"), WithWarmupConns(3))
+		require.NoError(t, err)
+		defer db.Close()
+		db.SetMaxIdleConns(3)
+
+		connect(t, db, 3)
+		spans := mt.FinishedSpans()
+		require.Len(t, spans, 4)
+		warmup := spans[3]
+		assert.Equal(t, "db.warmup", warmup.OperationName())
+		assert.Equal(t, "orders-db", warmup.Tag(ext.ServiceName))
+		for _, s := range spansOfType(spans, string(queryTypeConnect)) {
+			assert.Equal(t, warmup.SpanID(), s.ParentID())
+			assert.Equal(t, warmup.TraceID(), s.TraceID())
+		}
+
+		// later connections are not part of the warmup
+		mt.Reset()
+		connect(t, db, 4)
+		spans = spansOfType(mt.FinishedSpans(), string(queryTypeConnect))
+		require.Len(t, spans, 1)
+		assert.Zero(t, spans[0].ParentID())
+	})
+
+	t.Run("one-conn", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+		db, err := Open("postgres", "postgres://bob@db.internal:5432/orders",
+			WithWarmupSpanName("db.warmup"), WithWarmupConns(1))
+		require.NoError(t, err)
+		defer db.Close()
+		db.SetMaxIdleConns(1)
+
+		connect(t, db, 1)
+		spans := mt.FinishedSpans()
+		require.Len(t, spans, 2)
+		assert.Equal(t, "db.warmup", spans[1].OperationName())
+		assert.Equal(t, spans[1].SpanID(), spans[0].ParentID())
+
+		mt.Reset()
+		connect(t, db, 2)
+		spans = spansOfType(mt.FinishedSpans(), string(queryTypeConnect))
+		require.Len(t, spans, 1)
+		assert.Zero(t, spans[0].ParentID())
+	})
+
+	t.Run("query", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+		db, err := Open("postgres", "postgres://bob@db.internal:5432/orders", WithWarmupSpanName("db.warmup"))
+		require.NoError(t, err)
+		defer db.Close()
+
+		rows, err := db.Query("SELECT 1")
+		require.NoError(t, err)
+		rows.Close()
+		spans := mt.FinishedSpans()
+		require.Len(t, spans, 3)
+		assert.Equal(t, "db.warmup", spans[1].OperationName())
+		assert.Equal(t, spans[1].SpanID(), spans[0].ParentID(), "the connection is part of the warmup")
+		assert.Zero(t, spans[2].ParentID(), "the query is not")
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		defer func(old time.Duration) { warmupTimeout = old }(warmupTimeout)
+		warmupTimeout = 10 * time.Millisecond
+		mt := mocktracer.Start()
+		defer mt.Stop()
+		db, err := Open("postgres", "postgres://bob@db.internal:5432/orders", WithWarmupSpanName("db.warmup"))
+		require.NoError(t, err)
+		defer db.Close()
+
+		connect(t, db, 1)
+		assert.Eventually(t, func() bool { return len(mt.FinishedSpans()) == 2 }, time.Second, time.Millisecond)
+		assert.Equal(t, "db.warmup", mt.FinishedSpans()[1].OperationName())
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+		db, err := Open("postgres", "postgres://bob@db.internal:5432/orders", WithWarmupSpanName("db.warmup"))
+		require.NoError(t, err)
+
+		connect(t, db, 1)
+		assert.Len(t, mt.FinishedSpans(), 1)
+		db.Close()
+		spans := mt.FinishedSpans()
+		require.Len(t, spans, 2)
+		assert.Equal(t, "db.warmup", spans[1].OperationName())
+		assert.Equal(t, spans[1].SpanID(), spans[0].ParentID())
+	})
+}
@@ -7,15 +7,19 @@ package grpc
 
 import (
 	"net"
+	"strings"
+	"sync"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/contrib/google.golang.org/internal/grpcutil"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/namingschema"
 
 	context "golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/grpclog"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
@@ -26,6 +30,8 @@ type clientStream struct {
 	ctx    context.Context
 	cfg    *config
 	method string
+	// messages numbers the messages of the stream, if enabled using WithMessageSpans.
+	messages messageCounter
 }
 
 func (cs *clientStream) Context() context.Context {
@@ -33,38 +39,50 @@ func (cs *clientStream) Context() context.Context {
 }
 
 func (cs *clientStream) RecvMsg(m interface{}) (err error) {
-	if _, ok := cs.cfg.untracedMethods[cs.method]; cs.cfg.traceStreamMessages && !ok {
+	if cs.cfg.traceStreamMessages && !cs.cfg.untraced(cs.method) {
 		span, _ := startSpanFromContext(
 			cs.Context(),
 			cs.method,
 			"grpc.message",
 			cs.cfg.clientServiceName(),
-			cs.cfg.startSpanOptions()...,
+			cs.cfg.clientSpanOptions()...,
 		)
 		span.SetTag(ext.Component, "google.golang.org/grpc")
+		cs.cfg.setResourceName(span, cs.method, cs.method)
 		if p, ok := peer.FromContext(cs.Context()); ok {
 			setSpanTargetFromPeer(span, *p)
 		}
-		defer func() { finishWithError(span, err, cs.cfg) }()
+		defer func() {
+			if cs.cfg.messageSpans && err == nil {
+				cs.messages.setMessageTags(span, messageReceived, m)
+			}
+			finishWithError(span, err, cs.cfg)
+		}()
 	}
 	err = cs.ClientStream.RecvMsg(m)
 	return err
 }
 
 func (cs *clientStream) SendMsg(m interface{}) (err error) {
-	if _, ok := cs.cfg.untracedMethods[cs.method]; cs.cfg.traceStreamMessages && !ok {
+	if cs.cfg.traceStreamMessages && !cs.cfg.untraced(cs.method) {
 		span, _ := startSpanFromContext(
 			cs.Context(),
 			cs.method,
 			"grpc.message",
 			cs.cfg.clientServiceName(),
-			cs.cfg.startSpanOptions()...,
+			cs.cfg.clientSpanOptions()...,
 		)
 		span.SetTag(ext.Component, "google.golang.org/grpc")
+		cs.cfg.setResourceName(span, cs.method, cs.method)
 		if p, ok := peer.FromContext(cs.Context()); ok {
 			setSpanTargetFromPeer(span, *p)
 		}
-		defer func() { finishWithError(span, err, cs.cfg) }()
+		defer func() {
+			if cs.cfg.messageSpans && err == nil {
+				cs.messages.setMessageTags(span, messageSent, m)
+			}
+			finishWithError(span, err, cs.cfg)
+		}()
 	}
 	err = cs.ClientStream.SendMsg(m)
 	return err
@@ -92,12 +110,12 @@ func StreamClientInterceptor(opts ...Option) grpc.StreamClientInterceptor {
 			}
 		}
 		var stream grpc.ClientStream
-		if _, ok := cfg.untracedMethods[method]; cfg.traceStreamCalls && !ok {
+		if cfg.traceStreamCalls && !cfg.untraced(method) {
 			var (
 				span tracer.Span
 				err  error
 			)
-			span, ctx, err = doClientRequest(ctx, cfg, method, methodKind, opts,
+			span, ctx, err = doClientRequest(ctx, cfg, cc, method, methodKind, opts,
 				func(ctx context.Context, opts []grpc.CallOption) error {
 					var err error
 					stream, err = streamer(ctx, desc, cc, method, opts...)
@@ -112,6 +130,7 @@ func StreamClientInterceptor(opts ...Option) grpc.StreamClientInterceptor {
 			// we need to set it via FromContext
 			if p, ok := peer.FromContext(stream.Context()); ok {
 				setSpanTargetFromPeer(span, *p)
+				setProtocolTag(span, cfg, p)
 			}
 
 			go func() {
@@ -150,14 +169,31 @@ func UnaryClientInterceptor(opts ...Option) grpc.UnaryClientInterceptor {
 		fn(cfg)
 	}
 	log.Debug("contrib/google.golang.org/grpc: Configuring UnaryClientInterceptor: %#v", cfg)
+	var warnOnce sync.Once
 	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
-		if _, ok := cfg.untracedMethods[method]; ok {
+		if cfg.untraced(method) {
 			return invoker(ctx, method, req, reply, cc, opts...)
 		}
-		span, _, err := doClientRequest(ctx, cfg, method, methodKindUnary, opts,
+		var rc *retryCollector
+		if cfg.retryCollapse {
+			if rc, ok := ctx.Value(retryCollectorKey{}).(*retryCollector); ok {
+				// this is an attempt made by a retry interceptor chained after
+				// this interceptor; it is reported once the whole call completes.
+				return rc.invoke(ctx, cfg, method, req, reply, cc, invoker, opts...)
+			}
+			rc = new(retryCollector)
+			ctx = context.WithValue(ctx, retryCollectorKey{}, rc)
+		}
+		span, _, err := doClientRequest(ctx, cfg, cc, method, methodKindUnary, opts,
 			func(ctx context.Context, opts []grpc.CallOption) error {
 				return invoker(ctx, method, req, reply, cc, opts...)
 			})
+		if rc != nil && !rc.collapse(cfg, span) && err == nil {
+			// the call succeeded without going through this interceptor again
+			warnOnce.Do(func() {
+				log.Warn("contrib/google.golang.org/grpc: WithRetryCollapse has no effect: the UnaryClientInterceptor must be chained both before and after the retry interceptor")
+			})
+		}
 		finishWithError(span, err, cfg)
 		return err
 	}
@@ -166,7 +202,7 @@ func UnaryClientInterceptor(opts ...Option) grpc.UnaryClientInterceptor {
 // doClientRequest starts a new span and invokes the handler with the new context
 // and options. The span should be finished by the caller.
 func doClientRequest(
-	ctx context.Context, cfg *config, method string, methodKind string, opts []grpc.CallOption,
+	ctx context.Context, cfg *config, cc *grpc.ClientConn, method string, methodKind string, opts []grpc.CallOption,
 	handler func(ctx context.Context, opts []grpc.CallOption) error,
 ) (ddtrace.Span, context.Context, error) {
 	// inject the trace id into the metadata
@@ -175,13 +211,18 @@ func doClientRequest(
 		method,
 		"grpc.client",
 		cfg.clientServiceName(),
-		cfg.startSpanOptions(
+		cfg.clientSpanOptions(
 			tracer.Tag(ext.Component, "google.golang.org/grpc"),
 			tracer.Tag(ext.SpanKind, ext.SpanKindClient))...,
 	)
+	cfg.setResourceName(span, method, method)
 	if methodKind != "" {
 		span.SetTag(tagMethodKind, methodKind)
 	}
+	setPeerService(span, cfg, connTarget(cc))
+	withDeadlineTag(ctx, span, tagTimeout)
+	withDeadlineSourceTag(ctx, cfg, span)
+	withMethodConfigTags(cc, cfg, method, span)
 
 	// fill in the peer so we can add it to the tags
 	var p peer.Peer
@@ -191,22 +232,138 @@ func doClientRequest(
 	err := handler(handlerCtx, opts)
 
 	setSpanTargetFromPeer(span, p)
+	setProtocolTag(span, cfg, &p)
 
 	return span, ctx, err
 }
 
 // setSpanTargetFromPeer sets the target tags in a span based on the gRPC peer.
 func setSpanTargetFromPeer(span ddtrace.Span, p peer.Peer) {
-	// if the peer was set, set the tags
-	if p.Addr != nil {
-		host, port, err := net.SplitHostPort(p.Addr.String())
-		if err == nil {
-			if host != "" {
-				span.SetTag(ext.TargetHost, host)
-			}
-			span.SetTag(ext.TargetPort, port)
+	setSpanTargetFromAddr(span, p.Addr)
+}
+
+// setSpanTargetFromAddr sets the target tags in a span based on the address of the
+// peer, which is the backend chosen by the load balancer. The host and port are not
+// set for unix socket addresses, which are only reported as the peer address.
+func setSpanTargetFromAddr(span ddtrace.Span, addr net.Addr) {
+	if addr == nil {
+		return
+	}
+	span.SetTag(tagTransport, transportOf(addr))
+	if a := addr.String(); a != "" {
+		span.SetTag(tagPeerAddress, a)
+	}
+	if addr.Network() == "unix" {
+		return
+	}
+	host, port, err := net.SplitHostPort(addr.String())
+	if err == nil {
+		if host != "" {
+			span.SetTag(ext.TargetHost, host)
+		}
+		span.SetTag(ext.TargetPort, port)
+	}
+}
+
+// transportOf returns the transport used to reach the peer at addr, based on its network.
+// Peers without an address, or with the address of an in-memory connection, such as the
+// ones of bufconn listeners or net.Pipe, are reached in-process.
+func transportOf(addr net.Addr) string {
+	if addr == nil {
+		return transportInProc
+	}
+	switch network := addr.Network(); {
+	case strings.HasPrefix(network, "tcp"):
+		return transportTCP
+	case strings.HasPrefix(network, "unix"):
+		return transportUDS
+	case network == "", network == "bufconn", network == "pipe":
+		return transportInProc
+	default:
+		return network
+	}
+}
+
+// protocolOf returns the protocol used over the connection to the peer: the protocol negotiated
+// using ALPN for TLS connections, "h2" for other secure connections, and "h2c" for plaintext ones.
+func protocolOf(p *peer.Peer) string {
+	switch info := p.AuthInfo.(type) {
+	case nil:
+		return protocolH2C
+	case credentials.TLSInfo:
+		if proto := info.State.NegotiatedProtocol; proto != "" {
+			return proto
 		}
 	}
+	return protocolH2
+}
+
+// setProtocolTag tags the span with the protocol used over the connection to the peer, if
+// enabled using WithProtocolTag. Peers without an address, such as the ones of calls which
+// failed before a connection was picked, are ignored.
+func setProtocolTag(span ddtrace.Span, cfg *config, p *peer.Peer) {
+	if !cfg.protocolTag || p.Addr == nil {
+		return
+	}
+	span.SetTag(tagProtocol, protocolOf(p))
+}
+
+// connTarget returns the target the given connection was dialed with.
+func connTarget(cc *grpc.ClientConn) string {
+	if cc == nil {
+		return ""
+	}
+	return cc.Target()
+}
+
+// withMethodConfigTags tags the span with the configuration applied to the method by the
+// service config of the connection, if enabled using WithMethodConfigTags.
+func withMethodConfigTags(cc *grpc.ClientConn, cfg *config, method string, span ddtrace.Span) {
+	if !cfg.methodConfigTags || cc == nil {
+		return
+	}
+	mc := cc.GetMethodConfig(method)
+	if mc.Timeout != nil {
+		span.SetTag(tagMethodConfigTimeout, mc.Timeout.Milliseconds())
+	}
+}
+
+// setPeerService sets the peer.service tag of a client span to the name set using
+// WithPeerService or, when using the v1 naming schema, to the host of the target dialed
+// by the client.
+func setPeerService(span ddtrace.Span, cfg *config, target string) {
+	if cfg.peerService != "" {
+		span.SetTag(ext.PeerService, cfg.peerService)
+		return
+	}
+	if cfg.namingSchema != namingschema.VersionV1 {
+		return
+	}
+	if svc := peerServiceFromTarget(target); svc != "" {
+		span.SetTag(ext.PeerService, svc)
+	}
+}
+
+// peerServiceFromTarget returns the host of the given dial target, which can be an
+// address such as "localhost:50051" or a URI such as "dns:///users.internal:443". It
+// returns the socket path of unix targets.
+func peerServiceFromTarget(target string) string {
+	if strings.HasPrefix(target, "unix:") {
+		// "unix:path" or "unix:///absolute/path"
+		return strings.TrimPrefix(strings.TrimPrefix(target, "unix:"), "//")
+	}
+	endpoint := target
+	if i := strings.Index(target, "://"); i >= 0 {
+		// skip the scheme and authority, e.g. "dns://8.8.8.8/users.internal:443"
+		endpoint = target[i+len("://"):]
+		if j := strings.Index(endpoint, "/"); j >= 0 {
+			endpoint = endpoint[j+1:]
+		}
+	}
+	if host, _, err := net.SplitHostPort(endpoint); err == nil {
+		return host
+	}
+	return endpoint
 }
 
 // injectSpanIntoContext injects the span associated with a context as gRPC metadata
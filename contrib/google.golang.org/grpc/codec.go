@@ -0,0 +1,139 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package grpc
+
+import (
+	"reflect"
+	"sync"
+	"time"
+
+	context "golang.org/x/net/context"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/stats"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+)
+
+// maxPendingTimings is the maximum number of codec timings waiting to be reported by a
+// stats handler. Timings are discarded past this limit, which is only reached when the
+// codec is used without stats handlers configured with WithCodecSpans.
+const maxPendingTimings = 4096
+
+// codecTimings holds the durations measured by codecs, per message, until the stats
+// handler of the RPC sending or receiving the message reports them.
+type codecTimings struct {
+	mu sync.Mutex // guards m
+	m  map[interface{}]time.Duration
+}
+
+var (
+	marshalTimings   codecTimings
+	unmarshalTimings codecTimings
+)
+
+// add records that d was spent (un)marshaling msg.
+func (ct *codecTimings) add(msg interface{}, d time.Duration) {
+	if msg == nil || reflect.TypeOf(msg).Kind() != reflect.Ptr {
+		// only pointers identify a message reliably
+		return
+	}
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	if ct.m == nil || len(ct.m) >= maxPendingTimings {
+		ct.m = make(map[interface{}]time.Duration)
+	}
+	ct.m[msg] += d
+}
+
+// take removes and returns the duration recorded for msg, if any.
+func (ct *codecTimings) take(msg interface{}) (time.Duration, bool) {
+	if msg == nil || reflect.TypeOf(msg).Kind() != reflect.Ptr {
+		return 0, false
+	}
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	d, ok := ct.m[msg]
+	delete(ct.m, msg)
+	return d, ok
+}
+
+// codec is an encoding.Codec measuring the time spent in the Marshal and Unmarshal
+// methods of the codec it wraps.
+type codec struct {
+	encoding.Codec
+}
+
+// NewCodec returns a codec wrapping c which measures the time spent serializing and
+// deserializing messages, for the stats handlers configured with WithCodecSpans to report
+// it. It has the name of c, so registering it with encoding.RegisterCodec replaces c for
+// servers, while clients can use it with the grpc.ForceCodec call option.
+func NewCodec(c encoding.Codec) encoding.Codec {
+	return codec{c}
+}
+
+// Marshal implements encoding.Codec.
+func (c codec) Marshal(v interface{}) ([]byte, error) {
+	start := time.Now()
+	data, err := c.Codec.Marshal(v)
+	marshalTimings.add(v, time.Since(start))
+	return data, err
+}
+
+// Unmarshal implements encoding.Codec.
+func (c codec) Unmarshal(data []byte, v interface{}) error {
+	start := time.Now()
+	err := c.Codec.Unmarshal(data, v)
+	unmarshalTimings.add(v, time.Since(start))
+	return err
+}
+
+// codecTimerKey is the context key holding the codecTimer of an RPC.
+type codecTimerKey struct{}
+
+// codecTimer accumulates the time spent serializing and deserializing the messages of
+// an RPC, as measured by the codec returned by NewCodec.
+type codecTimer struct {
+	mu          sync.Mutex // guards below fields
+	serialize   time.Duration
+	deserialize time.Duration
+}
+
+// withCodecTimer returns a copy of ctx holding a new codecTimer.
+func withCodecTimer(ctx context.Context) context.Context {
+	return context.WithValue(ctx, codecTimerKey{}, new(codecTimer))
+}
+
+// codecTimerFromContext returns the codecTimer found in ctx, if any.
+func codecTimerFromContext(ctx context.Context) (*codecTimer, bool) {
+	ct, ok := ctx.Value(codecTimerKey{}).(*codecTimer)
+	return ct, ok
+}
+
+// handle accounts for the RPC event rs.
+func (ct *codecTimer) handle(rs stats.RPCStats) {
+	switch rs := rs.(type) {
+	case *stats.InPayload:
+		if d, ok := unmarshalTimings.take(rs.Payload); ok {
+			ct.mu.Lock()
+			ct.deserialize += d
+			ct.mu.Unlock()
+		}
+	case *stats.OutPayload:
+		if d, ok := marshalTimings.take(rs.Payload); ok {
+			ct.mu.Lock()
+			ct.serialize += d
+			ct.mu.Unlock()
+		}
+	}
+}
+
+// setTags sets the accumulated durations on span, in nanoseconds.
+func (ct *codecTimer) setTags(span ddtrace.Span) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	span.SetTag(tagSerializeDuration, ct.serialize.Nanoseconds())
+	span.SetTag(tagDeserializeDuration, ct.deserialize.Nanoseconds())
+}
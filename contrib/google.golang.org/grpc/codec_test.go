@@ -0,0 +1,141 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package grpc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	context "golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/encoding/proto"
+	"google.golang.org/grpc/stats"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+// slowCodec is a proto codec taking at least delay to marshal and unmarshal messages.
+type slowCodec struct {
+	delay time.Duration
+}
+
+func (c slowCodec) Marshal(v interface{}) ([]byte, error) {
+	time.Sleep(c.delay)
+	return encoding.GetCodec(proto.Name).Marshal(v)
+}
+
+func (c slowCodec) Unmarshal(data []byte, v interface{}) error {
+	time.Sleep(c.delay)
+	return encoding.GetCodec(proto.Name).Unmarshal(data, v)
+}
+
+func (slowCodec) Name() string { return "slowproto" }
+
+func TestCodecTimer(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	ct, ok := codecTimerFromContext(withCodecTimer(context.Background()))
+	require.True(t, ok)
+	c := NewCodec(slowCodec{delay: 3 * time.Millisecond})
+	assert.Equal(t, "slowproto", c.Name())
+	sent := &FixtureRequest{Name: "pass"}
+	data, err := c.Marshal(sent)
+	require.NoError(t, err)
+	received := new(FixtureRequest)
+	require.NoError(t, c.Unmarshal(data, received))
+	assert.Equal(t, "pass", received.Name)
+
+	ct.handle(&stats.OutPayload{Client: true, Payload: sent})
+	ct.handle(&stats.InPayload{Client: true, Payload: received})
+	// timings are reported once, and messages not decoded by the codec are ignored
+	ct.handle(&stats.InPayload{Client: true, Payload: received})
+	ct.handle(&stats.InPayload{Client: true, Payload: new(FixtureRequest)})
+
+	span := tracer.StartSpan("grpc.client")
+	ct.setTags(span)
+	span.Finish()
+
+	s := mt.FinishedSpans()[0]
+	for _, tag := range []string{tagSerializeDuration, tagDeserializeDuration} {
+		d := s.Tag(tag).(int64)
+		assert.True(t, d >= int64(3*time.Millisecond) && d < int64(time.Second), tag, d)
+	}
+
+	_, ok = codecTimerFromContext(context.Background())
+	assert.False(t, ok)
+}
+
+func TestCodecSpans(t *testing.T) {
+	// finishedSpan waits for the span with the given operation name to be finished.
+	finishedSpan := func(t *testing.T, mt mocktracer.Tracer, name string) mocktracer.Span {
+		for i := 0; i < 100; i++ {
+			for _, s := range mt.FinishedSpans() {
+				if s.OperationName() == name {
+					return s
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("no %s span finished", name)
+		return nil
+	}
+	// each side marshals a message and unmarshals another one
+	const delay = 2 * time.Millisecond
+	encoding.RegisterCodec(NewCodec(slowCodec{delay: delay}))
+	assertTimings := func(t *testing.T, s mocktracer.Span) {
+		for _, tag := range []string{tagSerializeDuration, tagDeserializeDuration} {
+			d, ok := s.Tag(tag).(int64)
+			assert.True(t, ok, tag)
+			assert.True(t, d >= int64(delay), tag, d)
+		}
+	}
+
+	t.Run("client", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		rig, err := newClientStatsHandlerTestServer(NewClientStatsHandler(WithCodecSpans()))
+		require.NoError(t, err)
+		defer rig.Close()
+
+		_, err = rig.client.Ping(context.Background(), &FixtureRequest{Name: "pass"}, grpc.CallContentSubtype("slowproto"))
+		require.NoError(t, err)
+		assertTimings(t, finishedSpan(t, mt, "grpc.client"))
+	})
+
+	t.Run("server", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		rig, err := newServerStatsHandlerTestServer(NewServerStatsHandler(WithCodecSpans()))
+		require.NoError(t, err)
+		defer rig.Close()
+
+		_, err = rig.client.Ping(context.Background(), &FixtureRequest{Name: "pass"}, grpc.CallContentSubtype("slowproto"))
+		require.NoError(t, err)
+		assertTimings(t, finishedSpan(t, mt, "grpc.server"))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		rig, err := newClientStatsHandlerTestServer(NewClientStatsHandler())
+		require.NoError(t, err)
+		defer rig.Close()
+
+		_, err = rig.client.Ping(context.Background(), &FixtureRequest{Name: "pass"})
+		require.NoError(t, err)
+		s := finishedSpan(t, mt, "grpc.client")
+		assert.Nil(t, s.Tag(tagSerializeDuration))
+		assert.Nil(t, s.Tag(tagDeserializeDuration))
+	})
+}
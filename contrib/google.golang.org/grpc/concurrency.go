@@ -0,0 +1,138 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package grpc
+
+import (
+	"sync"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
+
+	context "golang.org/x/net/context"
+)
+
+// metricInFlight is the gauge reporting the number of RPCs being handled by a server,
+// per method, as enabled using WithConcurrencyMetrics.
+const metricInFlight = "grpc.server.in_flight"
+
+// defaultConcurrencyInterval is the default interval at which concurrency metrics are reported.
+const defaultConcurrencyInterval = 10 * time.Second
+
+// inFlight counts the RPCs in flight for a method.
+type inFlight struct {
+	mu      sync.Mutex // guards below fields
+	n       int64      // RPCs currently in flight
+	peak    int64      // highest value of n since the last report
+	service string
+}
+
+// concurrencyMetrics counts the RPCs in flight for each method and reports, at each interval,
+// the highest number reached during it as a gauge. Reports are made by a goroutine running
+// while RPCs are in flight, and until their end is reported.
+type concurrencyMetrics struct {
+	client   globalconfig.StatsdClient // when nil, the client of the tracer is used
+	interval time.Duration             // between reports; defaultConcurrencyInterval when zero
+	methods  sync.Map                  // full method name to the *inFlight of its RPCs
+
+	mu        sync.Mutex // guards below fields
+	active    int64      // RPCs in flight for all methods
+	reporting bool       // whether the reporting goroutine is running
+}
+
+// add adds delta to the number of RPCs in flight for method.
+func (m *concurrencyMetrics) add(method, service string, delta int64) {
+	v, _ := m.methods.LoadOrStore(method, &inFlight{service: service})
+	f := v.(*inFlight)
+	f.mu.Lock()
+	f.n += delta
+	if f.n > f.peak {
+		f.peak = f.n
+	}
+	f.mu.Unlock()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.active += delta
+	if m.active > 0 && !m.reporting {
+		m.reporting = true
+		go m.report()
+	}
+}
+
+// report reports the metrics at each interval, until no RPC was in flight during one.
+func (m *concurrencyMetrics) report() {
+	interval := m.interval
+	if interval == 0 {
+		interval = defaultConcurrencyInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		idle := m.flush()
+		m.mu.Lock()
+		if idle && m.active == 0 {
+			m.reporting = false
+			m.mu.Unlock()
+			return
+		}
+		m.mu.Unlock()
+	}
+}
+
+// flush reports the peak number of RPCs in flight for each method since the last flush,
+// and reports whether all of them were zero.
+func (m *concurrencyMetrics) flush() (idle bool) {
+	client := m.client
+	if client == nil {
+		client = globalconfig.Statsd()
+	}
+	idle = true
+	m.methods.Range(func(k, v interface{}) bool {
+		f := v.(*inFlight)
+		f.mu.Lock()
+		peak := f.peak
+		f.peak = f.n
+		f.mu.Unlock()
+		if peak > 0 {
+			idle = false
+		}
+		if client != nil {
+			tags := []string{"grpc.method:" + k.(string), "service:" + f.service}
+			client.Gauge(metricInFlight, float64(peak), tags, 1)
+		}
+		return true
+	})
+	return idle
+}
+
+// trackInFlight counts an RPC to method as being in flight, if enabled using
+// WithConcurrencyMetrics. The returned function must be called once the RPC is done.
+func (cfg *config) trackInFlight(method string) (done func()) {
+	m := cfg.concurrency
+	if m == nil {
+		return func() {}
+	}
+	service := cfg.serverServiceName()
+	m.add(method, service, 1)
+	var once sync.Once
+	return func() {
+		once.Do(func() { m.add(method, service, -1) })
+	}
+}
+
+type inFlightKey struct{}
+
+// withInFlight returns a copy of ctx holding the function ending the tracking of its RPC.
+func withInFlight(ctx context.Context, done func()) context.Context {
+	return context.WithValue(ctx, inFlightKey{}, done)
+}
+
+// endInFlight ends the tracking of the RPC of ctx, if any.
+func endInFlight(ctx context.Context) {
+	if done, ok := ctx.Value(inFlightKey{}).(func()); ok {
+		done()
+	}
+}
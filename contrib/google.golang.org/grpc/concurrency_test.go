@@ -0,0 +1,170 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package grpc
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	context "golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// gaugeRecorder is a gaugeClient recording the values of the gauges it receives.
+type gaugeRecorder struct {
+	mu     sync.Mutex
+	values []float64
+	tags   [][]string
+}
+
+func (r *gaugeRecorder) Gauge(name string, value float64, tags []string, _ float64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if name == metricInFlight {
+		r.values = append(r.values, value)
+		r.tags = append(r.tags, tags)
+	}
+	return nil
+}
+
+func (r *gaugeRecorder) recorded() []float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]float64(nil), r.values...)
+}
+
+// withConcurrencyMetrics enables concurrency metrics, counted by m.
+func withConcurrencyMetrics(m *concurrencyMetrics) Option {
+	return func(cfg *config) {
+		cfg.concurrency = m
+	}
+}
+
+func TestConcurrencyMetrics(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/grpc.Fixture/Ping"}
+	// block calls an interceptor in a goroutine, with a handler blocking until release is
+	// closed, and returns a channel closed once it returns.
+	block := func(interceptor grpc.UnaryServerInterceptor, release chan struct{}) (done chan struct{}) {
+		started, done := make(chan struct{}), make(chan struct{})
+		go func() {
+			defer close(done)
+			interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				close(started)
+				<-release
+				return nil, nil
+			})
+		}()
+		<-started
+		return done
+	}
+
+	t.Run("slow", func(t *testing.T) {
+		rec := new(gaugeRecorder)
+		m := &concurrencyMetrics{client: rec}
+		interceptor := UnaryServerInterceptor(WithServiceName("grpc"), withConcurrencyMetrics(m))
+		release := make(chan struct{})
+		done := block(interceptor, release)
+		m.flush()
+		assert.Equal(t, []float64{1}, rec.recorded())
+		close(release)
+		<-done
+		// the RPC was in flight during the interval
+		m.flush()
+		m.flush()
+		assert.Equal(t, []float64{1, 1, 0}, rec.recorded())
+		assert.ElementsMatch(t, []string{"grpc.method:/grpc.Fixture/Ping", "service:grpc"}, rec.tags[0])
+	})
+
+	t.Run("peak", func(t *testing.T) {
+		rec := new(gaugeRecorder)
+		m := &concurrencyMetrics{client: rec}
+		interceptor := UnaryServerInterceptor(withConcurrencyMetrics(m))
+		release := make(chan struct{})
+		done1, done2 := block(interceptor, release), block(interceptor, release)
+		close(release)
+		<-done1
+		<-done2
+		assert.False(t, m.flush())
+		assert.True(t, m.flush())
+		assert.Equal(t, []float64{2, 0}, rec.recorded())
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		rec := new(gaugeRecorder)
+		m := &concurrencyMetrics{client: rec}
+		interceptor := UnaryServerInterceptor(withConcurrencyMetrics(m))
+		assert.Panics(t, func() {
+			interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				panic("boom")
+			})
+		})
+		m.flush()
+		m.flush()
+		assert.Equal(t, []float64{1, 0}, rec.recorded())
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		rec := new(gaugeRecorder)
+		m := &concurrencyMetrics{client: rec}
+		rig, err := newServerStatsHandlerTestServer(NewServerStatsHandler(withConcurrencyMetrics(m)))
+		require.NoError(t, err)
+		defer rig.Close()
+
+		_, err = rig.client.Ping(context.Background(), &FixtureRequest{Name: "pass"})
+		require.NoError(t, err)
+		waitForSpans(mt, 1, 0)
+		m.flush()
+		m.flush()
+		assert.Equal(t, []float64{1, 0}, rec.recorded())
+	})
+
+	t.Run("report", func(t *testing.T) {
+		rec := new(gaugeRecorder)
+		m := &concurrencyMetrics{client: rec, interval: time.Millisecond}
+		interceptor := UnaryServerInterceptor(withConcurrencyMetrics(m))
+		release := make(chan struct{})
+		done := block(interceptor, release)
+		close(release)
+		<-done
+		// the goroutine stops once the end of the RPC is reported
+		assert.Eventually(t, func() bool {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			return !m.reporting
+		}, time.Second, time.Millisecond)
+		values := rec.recorded()
+		require.NotEmpty(t, values)
+		assert.Contains(t, values, float64(1))
+		assert.Equal(t, float64(0), values[len(values)-1])
+	})
+
+	t.Run("tracer", func(t *testing.T) {
+		rec := new(gaugeRecorder)
+		globalconfig.SetStatsd(rec)
+		defer globalconfig.SetStatsd(nil)
+		m := new(concurrencyMetrics)
+		m.add(info.FullMethod, "grpc", 1)
+		m.flush()
+		assert.Equal(t, []float64{1}, rec.recorded())
+		m.add(info.FullMethod, "grpc", -1)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		cfg := new(config)
+		defaults(cfg)
+		assert.Nil(t, cfg.concurrency)
+		cfg.trackInFlight(info.FullMethod)()
+	})
+}
@@ -0,0 +1,100 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package grpc
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	context "golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+)
+
+// relayServer handles pings by sending them to a downstream server, with the given timeout,
+// if any.
+type relayServer struct {
+	fixtureServer
+	downstream FixtureClient
+	timeout    time.Duration
+}
+
+func (s *relayServer) Ping(ctx context.Context, in *FixtureRequest) (*FixtureReply, error) {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	return s.downstream.Ping(ctx, in)
+}
+
+func TestWithDeadlineSourceTag(t *testing.T) {
+	downstream, err := newRig(false)
+	require.NoError(t, err)
+	defer downstream.Close()
+
+	// the relay server and its client to the downstream server are traced
+	conn, err := grpc.Dial(downstream.listener.Addr().String(), grpc.WithInsecure(),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(WithDeadlineSourceTag())))
+	require.NoError(t, err)
+	defer conn.Close()
+	relay := &relayServer{downstream: NewFixtureClient(conn)}
+	server := grpc.NewServer(grpc.UnaryInterceptor(UnaryServerInterceptor(WithDeadlineSourceTag())))
+	RegisterFixtureServer(server, relay)
+	li, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go server.Serve(li)
+	defer server.Stop()
+	relayConn, err := grpc.Dial(li.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer relayConn.Close()
+	client := NewFixtureClient(relayConn)
+
+	mt := mocktracer.Start()
+	defer mt.Stop()
+	for _, tt := range []struct {
+		name     string
+		inbound  time.Duration // deadline of the incoming RPC, if any
+		outbound time.Duration // deadline set by the relay, if any
+		source   interface{}
+	}{
+		{name: "inherited", inbound: 5 * time.Second, source: deadlineSourceInherited},
+		{name: "local", outbound: 5 * time.Second, source: deadlineSourceLocal},
+		{name: "shortened", inbound: 5 * time.Second, outbound: time.Second, source: deadlineSourceLocal},
+		{name: "extended", inbound: time.Second, outbound: 5 * time.Second, source: deadlineSourceInherited},
+		{name: "none", source: nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			mt.Reset()
+			relay.timeout = tt.outbound
+			ctx := context.Background()
+			if tt.inbound > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.inbound)
+				defer cancel()
+			}
+			_, err := client.Ping(ctx, &FixtureRequest{Name: "pass"})
+			require.NoError(t, err)
+
+			waitForSpans(mt, 3, time.Second)
+			var found bool
+			for _, s := range mt.FinishedSpans() {
+				switch s.OperationName() {
+				case "grpc.client":
+					found = true
+					assert.Equal(t, tt.source, s.Tag(tagDeadlineSource))
+				case "grpc.server":
+					assert.Nil(t, s.Tag(tagDeadlineSource))
+				}
+			}
+			assert.True(t, found)
+		})
+	}
+}
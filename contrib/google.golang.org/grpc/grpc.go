@@ -11,6 +11,8 @@ package grpc // import "gopkg.in/DataDog/dd-trace-go.v1/contrib/google.golang.or
 import (
 	"errors"
 	"io"
+	"math"
+	"time"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/contrib/google.golang.org/internal/grpcutil"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
@@ -44,6 +46,27 @@ func (cfg *config) startSpanOptions(opts ...tracer.StartSpanOption) []tracer.Sta
 	return ret
 }
 
+// clientSpanOptions is like startSpanOptions, for the spans started on the client side,
+// which use the analytics rate set using WithClientAnalyticsRate, if any.
+func (cfg *config) clientSpanOptions(opts ...tracer.StartSpanOption) []tracer.StartSpanOption {
+	return withAnalyticsRate(cfg.startSpanOptions(opts...), cfg.clientAnalyticsRate)
+}
+
+// serverSpanOptions is like startSpanOptions, for the spans started on the server side,
+// which use the analytics rate set using WithServerAnalyticsRate, if any.
+func (cfg *config) serverSpanOptions(opts ...tracer.StartSpanOption) []tracer.StartSpanOption {
+	return withAnalyticsRate(cfg.startSpanOptions(opts...), cfg.serverAnalyticsRate)
+}
+
+// withAnalyticsRate returns opts followed by the option setting the given analytics rate,
+// unless it is NaN. opts is left unchanged.
+func withAnalyticsRate(opts []tracer.StartSpanOption, rate float64) []tracer.StartSpanOption {
+	if math.IsNaN(rate) {
+		return opts
+	}
+	return append(opts[:len(opts):len(opts)], tracer.AnalyticsRate(rate))
+}
+
 func startSpanFromContext(
 	ctx context.Context, method, operation, service string, opts ...tracer.StartSpanOption,
 ) (ddtrace.Span, context.Context) {
@@ -56,10 +79,58 @@ func startSpanFromContext(
 	md, _ := metadata.FromIncomingContext(ctx) // nil is ok
 	if sctx, err := tracer.Extract(grpcutil.MDCarrier(md)); err == nil {
 		opts = append(opts, tracer.ChildOf(sctx))
+	} else if !errors.Is(err, tracer.ErrSpanContextNotFound) {
+		// the metadata carried a malformed span context; flag it, unless the
+		// span has a local parent and does not start from the metadata anyway.
+		if _, ok := tracer.SpanFromContext(ctx); !ok {
+			opts = append(opts, tracer.Tag(tagPropagationError, err.Error()))
+		}
 	}
 	return tracer.StartSpanFromContext(ctx, operation, opts...)
 }
 
+// withDeadlineTag sets the time remaining until the deadline of ctx, if any, as the
+// given tag on span, in milliseconds. It is negative once the deadline is exceeded.
+func withDeadlineTag(ctx context.Context, span ddtrace.Span, tag string) {
+	if deadline, ok := ctx.Deadline(); ok {
+		span.SetTag(tag, time.Until(deadline).Milliseconds())
+	}
+}
+
+type inboundDeadlineKey struct{}
+
+// withInboundDeadline returns a copy of ctx, the context of an incoming RPC, recording its
+// deadline, if any, for withDeadlineSourceTag, if enabled using WithDeadlineSourceTag.
+func withInboundDeadline(ctx context.Context, cfg *config) context.Context {
+	if !cfg.deadlineSourceTag {
+		return ctx
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		return context.WithValue(ctx, inboundDeadlineKey{}, deadline)
+	}
+	return ctx
+}
+
+// withDeadlineSourceTag tags span, the span of an outgoing call, with the source of the
+// deadline of ctx, if any and if enabled using WithDeadlineSourceTag: "inherited" when it
+// is the deadline of the incoming RPC ctx derives from, as recorded by withInboundDeadline,
+// and "local" otherwise.
+func withDeadlineSourceTag(ctx context.Context, cfg *config, span ddtrace.Span) {
+	if !cfg.deadlineSourceTag {
+		return
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return
+	}
+	source := deadlineSourceLocal
+	if inbound, ok := ctx.Value(inboundDeadlineKey{}).(time.Time); ok && !deadline.Before(inbound) {
+		// a deadline can only be shortened, so it is still the inbound one
+		source = deadlineSourceInherited
+	}
+	span.SetTag(tagDeadlineSource, source)
+}
+
 // finishWithError applies finish option and a tag with gRPC status code, disregarding OK, EOF and Canceled errors.
 func finishWithError(span ddtrace.Span, err error, cfg *config) {
 	if errors.Is(err, io.EOF) || errors.Is(err, context.Canceled) {
@@ -7,7 +7,9 @@ package grpc
 
 import (
 	"fmt"
+	"io"
 	"net"
+	"path/filepath"
 	"strings"
 	"sync/atomic"
 	"testing"
@@ -20,13 +22,17 @@ import (
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/namingschema"
 
 	"github.com/stretchr/testify/assert"
 	context "golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
 )
 
 func TestUnary(t *testing.T) {
@@ -574,6 +580,10 @@ func (s *fixtureServer) Ping(ctx context.Context, in *FixtureRequest) (*FixtureR
 		return &FixtureReply{Message: "disabled"}, nil
 	case in.Name == "invalid":
 		return nil, status.Error(codes.InvalidArgument, "invalid")
+	case in.Name == "panic":
+		panic("boom")
+	case in.Name == "trailer":
+		grpc.SetTrailer(ctx, metadata.Pairs("x-ratelimit-remaining", "42"))
 	}
 	return &FixtureReply{Message: "passed"}, nil
 }
@@ -654,7 +664,7 @@ func waitForSpans(mt mocktracer.Tracer, sz int, maxWait time.Duration) {
 }
 
 func TestAnalyticsSettings(t *testing.T) {
-	assertRate := func(t *testing.T, mt mocktracer.Tracer, rate interface{}, opts ...InterceptorOption) {
+	assertRates := func(t *testing.T, mt mocktracer.Tracer, clientRate, serverRate interface{}, opts ...InterceptorOption) {
 		rig, err := newRig(true, opts...)
 		if err != nil {
 			t.Fatalf("error setting up rig: %s", err)
@@ -681,8 +691,11 @@ func TestAnalyticsSettings(t *testing.T) {
 			}
 		}
 
-		assert.Equal(t, rate, clientSpan.Tag(ext.EventSampleRate))
-		assert.Equal(t, rate, serverSpan.Tag(ext.EventSampleRate))
+		assert.Equal(t, clientRate, clientSpan.Tag(ext.EventSampleRate))
+		assert.Equal(t, serverRate, serverSpan.Tag(ext.EventSampleRate))
+	}
+	assertRate := func(t *testing.T, mt mocktracer.Tracer, rate interface{}, opts ...InterceptorOption) {
+		assertRates(t, mt, rate, rate, opts...)
 	}
 
 	t.Run("defaults", func(t *testing.T) {
@@ -735,6 +748,27 @@ func TestAnalyticsSettings(t *testing.T) {
 
 		assertRate(t, mt, 0.23, WithAnalyticsRate(0.33), WithSpanOptions(tracer.AnalyticsRate(0.23)))
 	})
+
+	t.Run("client-server", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		assertRates(t, mt, 0.2, 0.8, WithClientAnalyticsRate(0.2), WithServerAnalyticsRate(0.8))
+	})
+
+	t.Run("server-override", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		assertRates(t, mt, 0.5, 0.9, WithAnalyticsRate(0.5), WithServerAnalyticsRate(0.9))
+	})
+
+	t.Run("client-invalid", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		assertRates(t, mt, nil, 0.9, WithClientAnalyticsRate(1.5), WithServerAnalyticsRate(0.9))
+	})
 }
 
 func TestIgnoredMethods(t *testing.T) {
@@ -1104,3 +1138,967 @@ func BenchmarkUnaryServerInterceptor(b *testing.B) {
 		}
 	})
 }
+
+func TestMetadataSampler(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	rig, err := newRig(false, WithMetadataSampler("x-tenant", map[string]float64{
+		"gold":   1.0,
+		"bronze": 0.1,
+	}))
+	if err != nil {
+		t.Fatalf("error setting up rig: %s", err)
+	}
+	defer rig.Close()
+
+	for tenant, want := range map[string]interface{}{
+		"gold":   1.0,
+		"bronze": 0.1,
+		"other":  nil,
+	} {
+		mt.Reset()
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-tenant", tenant)
+		_, err := rig.client.Ping(ctx, &FixtureRequest{Name: "pass"})
+		require.NoError(t, err)
+
+		spans := mt.FinishedSpans()
+		require.Len(t, spans, 1)
+		assert.Equal(t, tenant, spans[0].Tag(tagMetadataPrefix+"x-tenant"))
+		assert.Equal(t, want, spans[0].Tag(ext.EventSampleRate))
+	}
+}
+
+func TestRequestResourceNamer(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	rig, err := newRig(false, WithRequestResourceNamer(func(fullMethod string, req interface{}) string {
+		if r, ok := req.(*FixtureRequest); ok && r.Name == "named" {
+			return fullMethod + " " + r.Name
+		}
+		return ""
+	}))
+	if err != nil {
+		t.Fatalf("error setting up rig: %s", err)
+	}
+	defer rig.Close()
+
+	t.Run("named", func(t *testing.T) {
+		mt.Reset()
+		_, err := rig.client.Ping(context.Background(), &FixtureRequest{Name: "named"})
+		require.NoError(t, err)
+		spans := mt.FinishedSpans()
+		require.Len(t, spans, 1)
+		assert.Equal(t, "/grpc.Fixture/Ping named", spans[0].Tag(ext.ResourceName))
+	})
+
+	t.Run("default", func(t *testing.T) {
+		mt.Reset()
+		_, err := rig.client.Ping(context.Background(), &FixtureRequest{Name: "pass"})
+		require.NoError(t, err)
+		spans := mt.FinishedSpans()
+		require.Len(t, spans, 1)
+		assert.Equal(t, "/grpc.Fixture/Ping", spans[0].Tag(ext.ResourceName))
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		mt.Reset()
+		stream, err := rig.client.StreamPing(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&FixtureRequest{Name: "named"}))
+		_, err = stream.Recv()
+		require.NoError(t, err)
+		require.NoError(t, stream.CloseSend())
+		// to flush the spans
+		stream.Recv()
+		spans := mt.FinishedSpans()
+		require.NotEmpty(t, spans)
+		for _, s := range spans {
+			assert.Equal(t, "/grpc.Fixture/StreamPing", s.Tag(ext.ResourceName))
+		}
+	})
+}
+
+func TestForceSampleHeader(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	rig, err := newRig(false, WithForceSampleHeader("X-Debug-Keep"))
+	if err != nil {
+		t.Fatalf("error setting up rig: %s", err)
+	}
+	defer rig.Close()
+
+	for value, want := range map[string]interface{}{
+		"true":  true,
+		"1":     true,
+		"false": nil,
+		"bogus": nil,
+	} {
+		mt.Reset()
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-debug-keep", value)
+		_, err := rig.client.Ping(ctx, &FixtureRequest{Name: "pass"})
+		require.NoError(t, err)
+
+		spans := mt.FinishedSpans()
+		require.Len(t, spans, 1)
+		assert.Equal(t, want, spans[0].Tag(ext.ManualKeep), value)
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		rig, err := newRig(false)
+		if err != nil {
+			t.Fatalf("error setting up rig: %s", err)
+		}
+		defer rig.Close()
+
+		mt.Reset()
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-debug-keep", "true")
+		_, err = rig.client.Ping(ctx, &FixtureRequest{Name: "pass"})
+		require.NoError(t, err)
+
+		spans := mt.FinishedSpans()
+		require.Len(t, spans, 1)
+		assert.Nil(t, spans[0].Tag(ext.ManualKeep))
+	})
+
+	t.Run("priority", func(t *testing.T) {
+		mt.Reset()
+		ctx := metadata.AppendToOutgoingContext(context.Background(),
+			tracer.DefaultTraceIDHeader, "1234",
+			tracer.DefaultParentIDHeader, "5678",
+			tracer.DefaultPriorityHeader, "2",
+		)
+		_, err := rig.client.Ping(ctx, &FixtureRequest{Name: "pass"})
+		require.NoError(t, err)
+
+		spans := mt.FinishedSpans()
+		require.Len(t, spans, 1)
+		assert.Equal(t, uint64(1234), spans[0].TraceID())
+		assert.Equal(t, 2, spans[0].Tag(ext.SamplingPriority))
+	})
+}
+
+func TestPropagationError(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	rig, err := newRig(false)
+	if err != nil {
+		t.Fatalf("error setting up rig: %s", err)
+	}
+	defer rig.Close()
+
+	for name, tt := range map[string]struct {
+		md   []string
+		want interface{}
+	}{
+		"malformed": {
+			md:   []string{tracer.DefaultTraceIDHeader, "not-a-number", tracer.DefaultParentIDHeader, "5678"},
+			want: tracer.ErrSpanContextCorrupted.Error(),
+		},
+		"valid": {
+			md: []string{tracer.DefaultTraceIDHeader, "1234", tracer.DefaultParentIDHeader, "5678"},
+		},
+		"missing": {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			mt.Reset()
+			ctx := metadata.AppendToOutgoingContext(context.Background(), tt.md...)
+			_, err := rig.client.Ping(ctx, &FixtureRequest{Name: "pass"})
+			require.NoError(t, err)
+
+			spans := mt.FinishedSpans()
+			require.Len(t, spans, 1)
+			assert.Equal(t, tt.want, spans[0].Tag(tagPropagationError))
+			if tt.want != nil {
+				assert.Zero(t, spans[0].ParentID(), "the server span is a root span")
+			}
+		})
+	}
+}
+
+func TestUserAgentTag(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	for name, tt := range map[string]struct {
+		opts []Option
+		want string
+	}{
+		"enabled":  {opts: []Option{WithUserAgentTag()}, want: "my-sdk/1.2.3 grpc-go/"},
+		"disabled": {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rig, err := newRig(false, tt.opts...)
+			if err != nil {
+				t.Fatalf("error setting up rig: %s", err)
+			}
+			defer rig.Close()
+			conn, err := grpc.Dial(rig.listener.Addr().String(), grpc.WithInsecure(), grpc.WithUserAgent("my-sdk/1.2.3"))
+			require.NoError(t, err)
+			defer conn.Close()
+
+			mt.Reset()
+			_, err = NewFixtureClient(conn).Ping(context.Background(), &FixtureRequest{Name: "pass"})
+			require.NoError(t, err)
+
+			spans := mt.FinishedSpans()
+			require.Len(t, spans, 1)
+			if tt.want == "" {
+				assert.Nil(t, spans[0].Tag(tagUserAgent))
+				return
+			}
+			assert.True(t, strings.HasPrefix(spans[0].Tag(tagUserAgent).(string), tt.want), spans[0].Tag(tagUserAgent))
+		})
+	}
+
+	t.Run("stats", func(t *testing.T) {
+		rig, err := newServerStatsHandlerTestServer(NewServerStatsHandler(WithUserAgentTag()))
+		if err != nil {
+			t.Fatalf("error setting up rig: %s", err)
+		}
+		defer rig.Close()
+		conn, err := grpc.Dial(rig.listener.Addr().String(), grpc.WithInsecure(), grpc.WithUserAgent("my-sdk/1.2.3"))
+		require.NoError(t, err)
+		defer conn.Close()
+
+		mt.Reset()
+		_, err = NewFixtureClient(conn).Ping(context.Background(), &FixtureRequest{Name: "pass"})
+		require.NoError(t, err)
+
+		waitForSpans(mt, 1, time.Second)
+		spans := mt.FinishedSpans()
+		require.Len(t, spans, 1)
+		assert.True(t, strings.HasPrefix(spans[0].Tag(tagUserAgent).(string), "my-sdk/1.2.3 grpc-go/"), spans[0].Tag(tagUserAgent))
+	})
+
+	t.Run("absent", func(t *testing.T) {
+		span, _ := tracer.StartSpanFromContext(context.Background(), "grpc.server")
+		withUserAgentTag(metadata.NewIncomingContext(context.Background(), metadata.MD{}), &config{userAgentTag: true}, span)
+		withUserAgentTag(context.Background(), &config{userAgentTag: true}, span)
+		span.Finish()
+
+		spans := mt.FinishedSpans()
+		require.NotEmpty(t, spans)
+		assert.Nil(t, spans[len(spans)-1].Tag(tagUserAgent))
+	})
+}
+
+func TestConsumedMetadata(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	downstream, err := newRig(false)
+	require.NoError(t, err)
+	defer downstream.Close()
+
+	for name, newServer := range map[string]func() (*rig, error){
+		"interceptor": func() (*rig, error) { return newRig(false, WithConsumedMetadata("X-Edge-Route")) },
+		"stats": func() (*rig, error) {
+			return newServerStatsHandlerTestServer(NewServerStatsHandler(WithConsumedMetadata("X-Edge-Route")))
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rig, err := newServer()
+			require.NoError(t, err)
+			defer rig.Close()
+
+			mt.Reset()
+			ctx := metadata.AppendToOutgoingContext(context.Background(), "x-edge-route", "eu-1", "x-tenant", "acme")
+			_, err = rig.client.Ping(ctx, &FixtureRequest{Name: "pass"})
+			require.NoError(t, err)
+			waitForSpans(mt, 1, time.Second)
+			spans := mt.FinishedSpans()
+			require.Len(t, spans, 1)
+			assert.Equal(t, "eu-1", spans[0].Tag(tagMetadataPrefix+"x-edge-route"))
+
+			// the handler forwarding its incoming metadata does not propagate the consumed key
+			md := rig.fixtureServer.lastRequestMetadata.Load().(metadata.MD)
+			assert.NotContains(t, md, "x-edge-route")
+			_, err = downstream.client.Ping(metadata.NewOutgoingContext(context.Background(), md), &FixtureRequest{Name: "pass"})
+			require.NoError(t, err)
+			md = downstream.fixtureServer.lastRequestMetadata.Load().(metadata.MD)
+			assert.NotContains(t, md, "x-edge-route")
+			assert.Equal(t, []string{"acme"}, md.Get("x-tenant"))
+		})
+	}
+}
+
+func TestTrailerMetadataTags(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	for name, newServer := range map[string]func() (*rig, error){
+		"interceptor": func() (*rig, error) { return newRig(false, WithTrailerMetadataTags("X-RateLimit-Remaining")) },
+		"stats": func() (*rig, error) {
+			return newServerStatsHandlerTestServer(NewServerStatsHandler(WithTrailerMetadataTags("X-RateLimit-Remaining")))
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rig, err := newServer()
+			require.NoError(t, err)
+			defer rig.Close()
+
+			mt.Reset()
+			var trailer metadata.MD
+			_, err = rig.client.Ping(context.Background(), &FixtureRequest{Name: "trailer"}, grpc.Trailer(&trailer))
+			require.NoError(t, err)
+			assert.Equal(t, []string{"42"}, trailer.Get("x-ratelimit-remaining"), "the trailer is still sent to the client")
+			waitForSpans(mt, 1, time.Second)
+			spans := mt.FinishedSpans()
+			require.Len(t, spans, 1)
+			assert.Equal(t, "42", spans[0].Tag(tagTrailerPrefix+"x-ratelimit-remaining"))
+
+			mt.Reset()
+			_, err = rig.client.Ping(context.Background(), &FixtureRequest{Name: "pass"})
+			require.NoError(t, err)
+			waitForSpans(mt, 1, time.Second)
+			assert.Nil(t, mt.FinishedSpans()[0].Tag(tagTrailerPrefix+"x-ratelimit-remaining"))
+		})
+	}
+
+	t.Run("stream", func(t *testing.T) {
+		rig, err := newRig(false, WithStreamMessages(false), WithTrailerMetadataTags("x-ratelimit-remaining"))
+		require.NoError(t, err)
+		defer rig.Close()
+
+		mt.Reset()
+		stream, err := rig.client.StreamPing(context.Background())
+		require.NoError(t, err)
+		for _, name := range []string{"trailer", "break"} {
+			require.NoError(t, stream.Send(&FixtureRequest{Name: name}))
+			_, err = stream.Recv()
+			require.NoError(t, err)
+		}
+		_, err = stream.Recv()
+		require.Equal(t, io.EOF, err)
+		waitForSpans(mt, 1, time.Second)
+		spans := mt.FinishedSpans()
+		require.Len(t, spans, 1)
+		assert.Equal(t, "42", spans[0].Tag(tagTrailerPrefix+"x-ratelimit-remaining"))
+	})
+}
+
+func TestTransportTag(t *testing.T) {
+	t.Run("inproc", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		li := bufconn.Listen(1024 * 1024)
+		server := grpc.NewServer(grpc.UnaryInterceptor(UnaryServerInterceptor()))
+		RegisterFixtureServer(server, new(fixtureServer))
+		go server.Serve(li)
+		defer server.Stop()
+
+		conn, err := grpc.Dial("bufnet",
+			grpc.WithInsecure(),
+			grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+				return li.Dial()
+			}),
+			grpc.WithUnaryInterceptor(UnaryClientInterceptor()),
+		)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, err = NewFixtureClient(conn).Ping(context.Background(), &FixtureRequest{Name: "pass"})
+		require.NoError(t, err)
+		waitForSpans(mt, 2, time.Second)
+		spans := mt.FinishedSpans()
+		require.Len(t, spans, 2)
+		for _, s := range spans {
+			assert.Equal(t, transportInProc, s.Tag(tagTransport), s.OperationName())
+		}
+	})
+
+	t.Run("tcp", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+		rig, err := newRig(true)
+		require.NoError(t, err)
+		defer rig.Close()
+
+		_, err = rig.client.Ping(context.Background(), &FixtureRequest{Name: "pass"})
+		require.NoError(t, err)
+		waitForSpans(mt, 2, time.Second)
+		spans := mt.FinishedSpans()
+		require.Len(t, spans, 2)
+		for _, s := range spans {
+			assert.Equal(t, transportTCP, s.Tag(tagTransport), s.OperationName())
+		}
+	})
+
+	t.Run("no-peer", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		// in-process servers invoke the handlers without a peer in the context
+		interceptor := UnaryServerInterceptor()
+		_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/grpc.Fixture/Ping"},
+			func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil })
+		require.NoError(t, err)
+		spans := mt.FinishedSpans()
+		require.Len(t, spans, 1)
+		assert.Equal(t, transportInProc, spans[0].Tag(tagTransport))
+	})
+}
+
+func TestW3CPropagation(t *testing.T) {
+	t.Setenv("DD_TRACE_PROPAGATION_STYLE", "tracecontext")
+	tracer.Start(tracer.WithLogger(log.DiscardLogger{}))
+	defer tracer.Stop()
+
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		"traceparent", traceparent,
+		"tracestate", "dd=s:1,congo=t61rcWkgMzE",
+	))
+	info := &grpc.UnaryServerInfo{FullMethod: "/grpc.Fixture/Ping"}
+	var outgoing metadata.MD
+	_, err := UnaryServerInterceptor()(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		span, ok := tracer.SpanFromContext(ctx)
+		require.True(t, ok)
+		// the server span continues the trace of the traceparent
+		assert.Equal(t, uint64(0xa3ce929d0e0e4736), span.Context().TraceID())
+
+		// and its context is propagated downstream using the same style
+		invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			outgoing, _ = metadata.FromOutgoingContext(ctx)
+			return nil
+		}
+		return nil, UnaryClientInterceptor()(ctx, info.FullMethod, nil, nil, nil, invoker)
+	})
+	require.NoError(t, err)
+	require.Len(t, outgoing.Get("traceparent"), 1)
+	assert.True(t, strings.HasPrefix(outgoing.Get("traceparent")[0], "00-4bf92f3577b34da6a3ce929d0e0e4736-"), outgoing.Get("traceparent")[0])
+	assert.Empty(t, outgoing.Get(tracer.DefaultTraceIDHeader))
+}
+
+func TestMetadataTagsPropagationHeaders(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		"traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"tracestate", "dd=s:1",
+		"b3", "4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1",
+		"x-datadog-tags", "_dd.p.dm=-1",
+		"x-tenant", "acme",
+	))
+	info := &grpc.UnaryServerInfo{FullMethod: "/grpc.Fixture/Ping"}
+	_, err := UnaryServerInterceptor(WithMetadataTags())(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	require.NoError(t, err)
+	spans := mt.FinishedSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, []string{"acme"}, spans[0].Tag(tagMetadataPrefix+"x-tenant"))
+	for _, k := range []string{"traceparent", "tracestate", "b3", "x-datadog-tags"} {
+		assert.NotContains(t, spans[0].Tags(), tagMetadataPrefix+k)
+	}
+}
+
+func TestNamingSchema(t *testing.T) {
+	defer globalconfig.SetServiceName(globalconfig.ServiceName())
+	globalconfig.SetServiceName("app")
+
+	for _, tt := range []struct {
+		name        string
+		version     namingschema.Version
+		opts        []Option
+		client      string
+		peerService interface{}
+	}{
+		{name: "v0", version: namingschema.VersionV0, client: "grpc.client"},
+		{name: "v0/peer-service", version: namingschema.VersionV0, opts: []Option{WithPeerService("users")}, client: "grpc.client", peerService: "users"},
+		{name: "v1", version: namingschema.VersionV1, client: "app", peerService: "localhost"},
+		{name: "v1/peer-service", version: namingschema.VersionV1, opts: []Option{WithPeerService("users")}, client: "app", peerService: "users"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			defer namingschema.SetVersion(namingschema.SetVersion(tt.version))
+			mt := mocktracer.Start()
+			defer mt.Stop()
+
+			server := grpc.NewServer(grpc.UnaryInterceptor(UnaryServerInterceptor()))
+			RegisterFixtureServer(server, new(fixtureServer))
+			li, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			go server.Serve(li)
+			defer server.Stop()
+			_, port, _ := net.SplitHostPort(li.Addr().String())
+			conn, err := grpc.Dial("localhost:"+port, grpc.WithInsecure(), grpc.WithUnaryInterceptor(UnaryClientInterceptor(tt.opts...)))
+			require.NoError(t, err)
+			defer conn.Close()
+
+			_, err = NewFixtureClient(conn).Ping(context.Background(), &FixtureRequest{Name: "pass"})
+			require.NoError(t, err)
+
+			spans := mt.FinishedSpans()
+			require.Len(t, spans, 2)
+			serverSpan, clientSpan := spans[0], spans[1]
+			require.Equal(t, "grpc.server", serverSpan.OperationName())
+			require.Equal(t, "grpc.client", clientSpan.OperationName())
+			assert.Equal(t, "app", serverSpan.Tag(ext.ServiceName))
+			assert.Nil(t, serverSpan.Tag(ext.PeerService))
+			assert.Equal(t, tt.client, clientSpan.Tag(ext.ServiceName))
+			assert.Equal(t, tt.peerService, clientSpan.Tag(ext.PeerService))
+		})
+	}
+}
+
+func TestTimeoutTags(t *testing.T) {
+	assertTimeoutTags := func(t *testing.T, mt mocktracer.Tracer) {
+		spans := mt.FinishedSpans()
+		require.Len(t, spans, 2)
+		var clientSpan, serverSpan mocktracer.Span
+		for _, s := range spans {
+			switch s.OperationName() {
+			case "grpc.client":
+				clientSpan = s
+			case "grpc.server":
+				serverSpan = s
+			}
+		}
+		require.NotNil(t, clientSpan)
+		require.NotNil(t, serverSpan)
+		timeout, ok := clientSpan.Tag(tagTimeout).(int64)
+		require.True(t, ok)
+		assert.True(t, timeout > 0 && timeout <= 5000, timeout)
+		remaining, ok := serverSpan.Tag(tagTimeoutRemaining).(int64)
+		require.True(t, ok)
+		assert.True(t, remaining > 0 && remaining <= timeout, remaining)
+		assert.Nil(t, clientSpan.Tag(tagTimeoutRemaining))
+		assert.Nil(t, serverSpan.Tag(tagTimeout))
+	}
+
+	t.Run("interceptors", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+		rig, err := newRig(true)
+		if err != nil {
+			t.Fatalf("error setting up rig: %s", err)
+		}
+		defer rig.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err = rig.client.Ping(ctx, &FixtureRequest{Name: "pass"})
+		require.NoError(t, err)
+		assertTimeoutTags(t, mt)
+
+		// no tags are set without deadline
+		mt.Reset()
+		_, err = rig.client.Ping(context.Background(), &FixtureRequest{Name: "pass"})
+		require.NoError(t, err)
+		for _, s := range mt.FinishedSpans() {
+			assert.Nil(t, s.Tag(tagTimeout))
+			assert.Nil(t, s.Tag(tagTimeoutRemaining))
+		}
+	})
+
+	t.Run("stats-handlers", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+		server := grpc.NewServer(grpc.StatsHandler(NewServerStatsHandler()))
+		RegisterFixtureServer(server, new(fixtureServer))
+		li, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		go server.Serve(li)
+		defer server.Stop()
+		conn, err := grpc.Dial(li.Addr().String(), grpc.WithInsecure(), grpc.WithStatsHandler(NewClientStatsHandler()))
+		require.NoError(t, err)
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err = NewFixtureClient(conn).Ping(ctx, &FixtureRequest{Name: "pass"})
+		require.NoError(t, err)
+		waitForSpans(mt, 2, time.Second)
+		assertTimeoutTags(t, mt)
+	})
+}
+
+func TestMethodConfigTags(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	rig, err := newRig(false)
+	require.NoError(t, err)
+	defer rig.Close()
+
+	for name, tt := range map[string]struct {
+		opts          []Option
+		serviceConfig string
+		want          interface{}
+	}{
+		"enabled":   {opts: []Option{WithMethodConfigTags()}, serviceConfig: `{"methodConfig": [{"name": [{"service": "grpc.Fixture"}], "timeout": "1.5s"}]}`, want: int64(1500)},
+		"no-config": {opts: []Option{WithMethodConfigTags()}, serviceConfig: `{}`},
+		"disabled":  {serviceConfig: `{"methodConfig": [{"name": [{"service": "grpc.Fixture"}], "timeout": "1.5s"}]}`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			conn, err := grpc.Dial(rig.listener.Addr().String(),
+				grpc.WithInsecure(),
+				grpc.WithDefaultServiceConfig(tt.serviceConfig),
+				grpc.WithUnaryInterceptor(UnaryClientInterceptor(tt.opts...)),
+			)
+			require.NoError(t, err)
+			defer conn.Close()
+
+			mt.Reset()
+			_, err = NewFixtureClient(conn).Ping(context.Background(), &FixtureRequest{Name: "pass"})
+			require.NoError(t, err)
+
+			waitForSpans(mt, 2, time.Second)
+			for _, s := range mt.FinishedSpans() {
+				if s.OperationName() == "grpc.client" {
+					assert.Equal(t, tt.want, s.Tag(tagMethodConfigTimeout))
+				} else {
+					assert.Nil(t, s.Tag(tagMethodConfigTimeout))
+				}
+			}
+		})
+	}
+}
+
+func TestPeerServiceFromTarget(t *testing.T) {
+	for target, want := range map[string]string{
+		"localhost:50051":                   "localhost",
+		"10.0.0.1:50051":                    "10.0.0.1",
+		"[::1]:50051":                       "::1",
+		"users.internal":                    "users.internal",
+		"dns:///users.internal:443":         "users.internal",
+		"dns://8.8.8.8/users.internal:443":  "users.internal",
+		"passthrough:///users.internal:443": "users.internal",
+		"unix:///var/run/users.sock":        "/var/run/users.sock",
+		"unix:users.sock":                   "users.sock",
+	} {
+		assert.Equal(t, want, peerServiceFromTarget(target), target)
+	}
+}
+
+func TestRetryCollapse(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	rig, err := newRig(false)
+	if err != nil {
+		t.Fatalf("error setting up rig: %s", err)
+	}
+	defer rig.Close()
+
+	// retry retries failed calls up to 2 times.
+	retry := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) (err error) {
+		for i := 0; i < 3; i++ {
+			if err = invoker(ctx, method, req, reply, cc, opts...); err == nil {
+				return nil
+			}
+		}
+		return err
+	}
+	interceptor := UnaryClientInterceptor(WithServiceName("grpc"), WithRetryCollapse())
+	conn, err := grpc.Dial(rig.listener.Addr().String(),
+		grpc.WithInsecure(),
+		grpc.WithChainUnaryInterceptor(interceptor, retry, interceptor),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+	client := NewFixtureClient(conn)
+
+	t.Run("retried", func(t *testing.T) {
+		mt.Reset()
+		_, err := client.Ping(context.Background(), &FixtureRequest{Name: "invalid"})
+		require.Error(t, err)
+
+		var calls, attempts, servers []mocktracer.Span
+		for _, s := range mt.FinishedSpans() {
+			switch {
+			case s.OperationName() == "grpc.server":
+				servers = append(servers, s)
+			case s.ParentID() == 0:
+				calls = append(calls, s)
+			default:
+				attempts = append(attempts, s)
+			}
+		}
+		require.Len(t, calls, 1)
+		require.Len(t, attempts, 1)
+		call, attempt := calls[0], attempts[0]
+		assert.Equal(t, 2, call.Tag(tagRetries))
+		assert.Equal(t, "InvalidArgument,InvalidArgument", call.Tag(tagRetryCodes))
+		assert.Equal(t, call.SpanID(), attempt.ParentID())
+		assert.Equal(t, "InvalidArgument", attempt.Tag(tagCode))
+		assert.NotNil(t, attempt.Tag(ext.Error))
+		assert.Equal(t, "127.0.0.1", attempt.Tag(ext.TargetHost))
+		// the spans of the server are children of the span of the attempts
+		require.Len(t, servers, 3)
+		for _, s := range servers {
+			assert.Equal(t, attempt.SpanID(), s.ParentID())
+		}
+	})
+
+	t.Run("single", func(t *testing.T) {
+		mt.Reset()
+		_, err := client.Ping(context.Background(), &FixtureRequest{Name: "pass"})
+		require.NoError(t, err)
+
+		var clientSpans []mocktracer.Span
+		for _, s := range mt.FinishedSpans() {
+			if s.OperationName() == "grpc.client" {
+				clientSpans = append(clientSpans, s)
+			}
+		}
+		require.Len(t, clientSpans, 2)
+		for _, s := range clientSpans {
+			assert.Nil(t, s.Tag(tagRetries))
+			assert.Nil(t, s.Tag(tagRetryCodes))
+		}
+	})
+
+	t.Run("chained-once", func(t *testing.T) {
+		tp := new(log.RecordLogger)
+		defer log.UseLogger(tp)()
+		conn, err := grpc.Dial(rig.listener.Addr().String(),
+			grpc.WithInsecure(),
+			grpc.WithChainUnaryInterceptor(UnaryClientInterceptor(WithRetryCollapse()), retry),
+		)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		mt.Reset()
+		for i := 0; i < 2; i++ {
+			_, err = NewFixtureClient(conn).Ping(context.Background(), &FixtureRequest{Name: "pass"})
+			require.NoError(t, err)
+		}
+		// the call is still traced, and the warning is logged once
+		assert.Len(t, mt.FinishedSpans(), 4)
+		var warnings int
+		for _, l := range tp.Logs() {
+			if strings.Contains(l, "WithRetryCollapse has no effect") {
+				warnings++
+			}
+		}
+		assert.Equal(t, 1, warnings)
+	})
+}
+
+func TestRecovery(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	assertPanicSpan := func(t *testing.T) {
+		spans := mt.FinishedSpans()
+		require.Len(t, spans, 1)
+		span := spans[0]
+		assert.Equal(t, "grpc.server", span.OperationName())
+		assert.Equal(t, codes.Internal.String(), span.Tag(tagCode))
+		err, ok := span.Tag(ext.Error).(error)
+		require.True(t, ok)
+		assert.Equal(t, codes.Internal, status.Code(err))
+		assert.Contains(t, err.Error(), "panic: boom")
+	}
+
+	t.Run("unary", func(t *testing.T) {
+		rig, err := newRig(false, WithRecovery())
+		require.NoError(t, err)
+		defer rig.Close()
+
+		mt.Reset()
+		_, err = rig.client.Ping(context.Background(), &FixtureRequest{Name: "panic"})
+		assert.Equal(t, codes.Internal, status.Code(err))
+		assertPanicSpan(t)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		rig, err := newRig(false, WithRecovery(), WithStreamMessages(false))
+		require.NoError(t, err)
+		defer rig.Close()
+
+		mt.Reset()
+		stream, err := rig.client.StreamPing(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&FixtureRequest{Name: "panic"}))
+		_, err = stream.Recv()
+		assert.Equal(t, codes.Internal, status.Code(err))
+		assertPanicSpan(t)
+	})
+
+	t.Run("repanic", func(t *testing.T) {
+		var recovered interface{}
+		userRecovery := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+			defer func() {
+				if recovered = recover(); recovered != nil {
+					err = status.Error(codes.Unavailable, "recovered")
+				}
+			}()
+			return handler(ctx, req)
+		}
+		server := grpc.NewServer(grpc.ChainUnaryInterceptor(
+			userRecovery,
+			UnaryServerInterceptor(WithServiceName("grpc"), WithRepanic()),
+		))
+		RegisterFixtureServer(server, new(fixtureServer))
+		li, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		go server.Serve(li)
+		defer server.Stop()
+		conn, err := grpc.Dial(li.Addr().String(), grpc.WithInsecure())
+		require.NoError(t, err)
+		defer conn.Close()
+
+		mt.Reset()
+		_, err = NewFixtureClient(conn).Ping(context.Background(), &FixtureRequest{Name: "panic"})
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+		assert.Equal(t, "boom", recovered)
+		assertPanicSpan(t)
+	})
+}
+
+func TestPeerAddress(t *testing.T) {
+	// runStream sends a ping on a stream, then closes it.
+	runStream := func(t *testing.T, client FixtureClient) {
+		stream, err := client.StreamPing(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&FixtureRequest{Name: "pass"}))
+		_, err = stream.Recv()
+		require.NoError(t, err)
+		stream.CloseSend()
+		// to flush the spans
+		stream.Recv()
+	}
+	// clientSpan waits for the client span, which is finished asynchronously for streams.
+	clientSpan := func(t *testing.T, mt mocktracer.Tracer) mocktracer.Span {
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			for _, s := range mt.FinishedSpans() {
+				if s.OperationName() == "grpc.client" {
+					return s
+				}
+			}
+		}
+		t.Fatal("no client span")
+		return nil
+	}
+
+	t.Run("stream/interceptor", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+		rig, err := newRig(true)
+		require.NoError(t, err)
+		defer rig.Close()
+
+		runStream(t, rig.client)
+		span := clientSpan(t, mt)
+		assert.Equal(t, rig.listener.Addr().String(), span.Tag(tagPeerAddress))
+		assert.Equal(t, "127.0.0.1", span.Tag(ext.TargetHost))
+		assert.Equal(t, rig.port, span.Tag(ext.TargetPort))
+	})
+
+	t.Run("stream/stats", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+		rig, err := newClientStatsHandlerTestServer(NewClientStatsHandler())
+		require.NoError(t, err)
+		defer rig.Close()
+
+		runStream(t, rig.client)
+		span := clientSpan(t, mt)
+		assert.Equal(t, rig.listener.Addr().String(), span.Tag(tagPeerAddress))
+		assert.Equal(t, "127.0.0.1", span.Tag(ext.TargetHost))
+		assert.Equal(t, rig.port, span.Tag(ext.TargetPort))
+	})
+
+	t.Run("unary/unix", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		sock := filepath.Join(t.TempDir(), "grpc.sock")
+		li, err := net.Listen("unix", sock)
+		require.NoError(t, err)
+		server := grpc.NewServer()
+		RegisterFixtureServer(server, new(fixtureServer))
+		go server.Serve(li)
+		defer server.Stop()
+
+		conn, err := grpc.Dial(sock,
+			grpc.WithInsecure(),
+			grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", addr)
+			}),
+			grpc.WithUnaryInterceptor(UnaryClientInterceptor()),
+		)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, err = NewFixtureClient(conn).Ping(context.Background(), &FixtureRequest{Name: "pass"})
+		require.NoError(t, err)
+		span := clientSpan(t, mt)
+		assert.Equal(t, sock, span.Tag(tagPeerAddress))
+		assert.Equal(t, transportUDS, span.Tag(tagTransport))
+		assert.Nil(t, span.Tag(ext.TargetHost))
+		assert.Nil(t, span.Tag(ext.TargetPort))
+	})
+}
+
+func TestTraceHealthChecks(t *testing.T) {
+	for name, tt := range map[string]struct {
+		opts  []Option
+		spans int
+	}{
+		"default":  {spans: 0},
+		"enabled":  {opts: []Option{WithTraceHealthChecks(true)}, spans: 2},
+		"disabled": {opts: []Option{WithTraceHealthChecks(false)}, spans: 0},
+	} {
+		for setup, opts := range map[string]struct {
+			server grpc.ServerOption
+			client grpc.DialOption
+		}{
+			"interceptors": {
+				server: grpc.UnaryInterceptor(UnaryServerInterceptor(tt.opts...)),
+				client: grpc.WithUnaryInterceptor(UnaryClientInterceptor(tt.opts...)),
+			},
+			"stats-handlers": {
+				server: grpc.StatsHandler(NewServerStatsHandler(tt.opts...)),
+				client: grpc.WithStatsHandler(NewClientStatsHandler(tt.opts...)),
+			},
+		} {
+			t.Run(name+"/"+setup, func(t *testing.T) {
+				mt := mocktracer.Start()
+				defer mt.Stop()
+
+				li := bufconn.Listen(1024 * 1024)
+				server := grpc.NewServer(opts.server)
+				healthpb.RegisterHealthServer(server, health.NewServer())
+				RegisterFixtureServer(server, new(fixtureServer))
+				go server.Serve(li)
+				defer server.Stop()
+
+				conn, err := grpc.Dial("bufnet",
+					grpc.WithInsecure(),
+					grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+						return li.Dial()
+					}),
+					opts.client,
+				)
+				require.NoError(t, err)
+				defer conn.Close()
+
+				resp, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+				require.NoError(t, err)
+				assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+				waitForSpans(mt, tt.spans, time.Second)
+				spans := mt.FinishedSpans()
+				require.Len(t, spans, tt.spans)
+				for _, s := range spans {
+					assert.Equal(t, "/grpc.health.v1.Health/Check", s.Tag(ext.ResourceName))
+				}
+
+				// other methods are still traced
+				mt.Reset()
+				_, err = NewFixtureClient(conn).Ping(context.Background(), &FixtureRequest{Name: "pass"})
+				require.NoError(t, err)
+				waitForSpans(mt, 2, time.Second)
+				assert.Len(t, mt.FinishedSpans(), 2)
+			})
+		}
+	}
+}
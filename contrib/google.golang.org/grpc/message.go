@@ -0,0 +1,42 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package grpc
+
+import (
+	"sync/atomic"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// Directions of the messages reported in the "grpc.message.direction" tag.
+const (
+	messageSent     = "sent"
+	messageReceived = "received"
+)
+
+// messageCounter numbers the messages sent and received over a stream, as tagged on their
+// spans when enabled using WithMessageSpans.
+type messageCounter struct {
+	sent     uint32
+	received uint32
+}
+
+// setMessageTags tags span, the span of message m of a stream going in the given direction,
+// with the direction, the sequence number of the message in that direction, starting at 1,
+// and its size in bytes, when m is a protobuf message.
+func (mc *messageCounter) setMessageTags(span ddtrace.Span, direction string, m interface{}) {
+	n := &mc.received
+	if direction == messageSent {
+		n = &mc.sent
+	}
+	span.SetTag(tagMessageDirection, direction)
+	span.SetTag(tagMessageSeq, int(atomic.AddUint32(n, 1)))
+	if pm, ok := m.(proto.Message); ok {
+		span.SetTag(tagMessageSize, proto.Size(pm))
+	}
+}
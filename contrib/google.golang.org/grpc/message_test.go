@@ -0,0 +1,87 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package grpc
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	context "golang.org/x/net/context"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+)
+
+func TestWithMessageSpans(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	// stream sends three messages over a stream of rig, returning the message spans with
+	// a direction by the operation name of their parent.
+	stream := func(t *testing.T, rig *rig) map[string][]mocktracer.Span {
+		mt.Reset()
+		stream, err := rig.client.StreamPing(context.Background())
+		require.NoError(t, err)
+		for _, name := range []string{"pass", "pass", "break"} {
+			require.NoError(t, stream.Send(&FixtureRequest{Name: name}))
+			_, err = stream.Recv()
+			require.NoError(t, err)
+		}
+		_, err = stream.Recv()
+		require.Equal(t, io.EOF, err)
+		// 7 message spans and the call span on the client side, including the end of the
+		// stream, and 6 message spans and the call span on the server side
+		waitForSpans(mt, 15, time.Second)
+
+		spans := mt.FinishedSpans()
+		byID := make(map[uint64]mocktracer.Span, len(spans))
+		for _, s := range spans {
+			byID[s.SpanID()] = s
+		}
+		messages := make(map[string][]mocktracer.Span)
+		for _, s := range spans {
+			if s.OperationName() != "grpc.message" || s.Tag(tagMessageDirection) == nil {
+				continue
+			}
+			parent := byID[s.ParentID()].OperationName()
+			messages[parent] = append(messages[parent], s)
+		}
+		return messages
+	}
+
+	t.Run("enabled", func(t *testing.T) {
+		rig, err := newRig(true, WithMessageSpans())
+		require.NoError(t, err)
+		defer rig.Close()
+
+		messages := stream(t, rig)
+		size := proto.Size(&FixtureRequest{Name: "pass"})
+		for _, op := range []string{"grpc.client", "grpc.server"} {
+			seqs := map[string][]interface{}{}
+			for _, s := range messages[op] {
+				dir := s.Tag(tagMessageDirection).(string)
+				seqs[dir] = append(seqs[dir], s.Tag(tagMessageSeq))
+				if op == "grpc.client" && dir == messageSent && s.Tag(tagMessageSeq) == 1 {
+					assert.Equal(t, size, s.Tag(tagMessageSize))
+				}
+				assert.NotNil(t, s.Tag(tagMessageSize))
+			}
+			assert.ElementsMatch(t, []interface{}{1, 2, 3}, seqs[messageSent], op)
+			assert.ElementsMatch(t, []interface{}{1, 2, 3}, seqs[messageReceived], op)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		rig, err := newRig(true)
+		require.NoError(t, err)
+		defer rig.Close()
+
+		assert.Empty(t, stream(t, rig))
+	})
+}
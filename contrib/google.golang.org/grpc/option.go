@@ -6,10 +6,14 @@
 package grpc
 
 import (
+	"math"
+	"strings"
+
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/namingschema"
 
 	"google.golang.org/grpc/codes"
 )
@@ -20,6 +24,7 @@ type Option func(*config)
 
 type config struct {
 	serviceName         string
+	peerService         string
 	nonErrorCodes       map[codes.Code]bool
 	traceStreamCalls    bool
 	traceStreamMessages bool
@@ -31,6 +36,29 @@ type config struct {
 	withRequestTags     bool
 	spanOpts            []ddtrace.StartSpanOption
 	tags                map[string]interface{}
+	samplerMetadataKey  string
+	samplerRates        map[string]float64
+	resourceNamer       func(fullMethod string, req interface{}) string
+	forceSampleHeader   string
+	retryCollapse       bool
+	recovery            bool
+	repanic             bool
+	codecSpans          bool
+	userAgentTag        bool
+	concurrency         *concurrencyMetrics
+	consumedMetadata    []string
+	methodConfigTags    bool
+	trailerMetadataTags []string
+	normalizeResource   bool
+	traceHealthChecks   bool
+	activeStreamsTag    bool
+	protocolTag         bool
+	clientAnalyticsRate float64
+	serverAnalyticsRate float64
+	serverAddressTags   bool
+	messageSpans        bool
+	deadlineSourceTag   bool
+	namingSchema        namingschema.Version
 }
 
 func (cfg *config) serverServiceName() string {
@@ -44,10 +72,30 @@ func (cfg *config) serverServiceName() string {
 }
 
 func (cfg *config) clientServiceName() string {
-	if cfg.serviceName == "" {
-		return "grpc.client"
+	if cfg.serviceName != "" {
+		return cfg.serviceName
+	}
+	if cfg.namingSchema == namingschema.VersionV1 {
+		// client spans belong to the calling service, the remote one being
+		// reported in the peer.service tag.
+		if svc := globalconfig.ServiceName(); svc != "" {
+			return svc
+		}
+	}
+	return "grpc.client"
+}
+
+// healthService is the prefix of the full methods of the standard health checking service,
+// which are not traced unless enabled using WithTraceHealthChecks.
+const healthService = "/grpc.health.v1.Health/"
+
+// untraced reports whether the interceptors and stats handlers should create no spans for the
+// given full method.
+func (cfg *config) untraced(method string) bool {
+	if _, ok := cfg.untracedMethods[method]; ok {
+		return true
 	}
-	return cfg.serviceName
+	return !cfg.traceHealthChecks && strings.HasPrefix(method, healthService)
 }
 
 // InterceptorOption represents an option that can be passed to the grpc unary
@@ -60,14 +108,27 @@ func defaults(cfg *config) {
 	cfg.traceStreamCalls = true
 	cfg.traceStreamMessages = true
 	cfg.nonErrorCodes = map[codes.Code]bool{codes.Canceled: true}
+	cfg.namingSchema = namingschema.GetVersion()
+	cfg.clientAnalyticsRate = math.NaN()
+	cfg.serverAnalyticsRate = math.NaN()
 	// cfg.spanOpts = append(cfg.spanOpts, tracer.AnalyticsRate(globalconfig.AnalyticsRate()))
 	if internal.BoolEnv("DD_TRACE_GRPC_ANALYTICS_ENABLED", false) {
 		cfg.spanOpts = append(cfg.spanOpts, tracer.AnalyticsRate(1.0))
 	}
+	// the headers of all the propagation styles the tracer may be configured with, using
+	// DD_TRACE_PROPAGATION_STYLE, are ignored.
 	cfg.ignoredMetadata = map[string]struct{}{
 		"x-datadog-trace-id":          {},
 		"x-datadog-parent-id":         {},
 		"x-datadog-sampling-priority": {},
+		"x-datadog-origin":            {},
+		"x-datadog-tags":              {},
+		"traceparent":                 {},
+		"tracestate":                  {},
+		"x-b3-traceid":                {},
+		"x-b3-spanid":                 {},
+		"x-b3-sampled":                {},
+		"b3":                          {},
 	}
 }
 
@@ -78,6 +139,16 @@ func WithServiceName(name string) Option {
 	}
 }
 
+// WithPeerService sets the given name as the peer.service tag of client spans. By default, the
+// tag is only set when using the v1 naming schema, to the host of the target dialed by the client.
+// Since the target is not known to the client stats handler, this option is the only way to set
+// the tag on the spans it creates.
+func WithPeerService(name string) Option {
+	return func(cfg *config) {
+		cfg.peerService = name
+	}
+}
+
 // WithStreamCalls enables or disables tracing of streaming calls. This option does not apply to the
 // stats handler.
 func WithStreamCalls(enabled bool) Option {
@@ -94,6 +165,20 @@ func WithStreamMessages(enabled bool) Option {
 	}
 }
 
+// WithMessageSpans enables tracing each message sent and received over streams, as done by
+// WithStreamMessages, and tagging the "grpc.message" spans with the direction of the message in
+// the "grpc.message.direction" tag, either "sent" or "received", its sequence number in that
+// direction, starting at 1, in the "grpc.message.seq" tag, and its size in bytes in the
+// "grpc.message.size" tag. Computing these comes at a cost for streams of many messages, hence
+// it is disabled by default. The spans of failed messages, such as the one ending a stream, are
+// not tagged. This option does not apply to the stats handler.
+func WithMessageSpans() Option {
+	return func(cfg *config) {
+		cfg.traceStreamMessages = true
+		cfg.messageSpans = true
+	}
+}
+
 // NoDebugStack disables debug stacks for traces with errors. This is useful in situations
 // where errors are frequent and the overhead of calling debug.Stack may affect performance.
 func NoDebugStack() Option {
@@ -148,7 +233,8 @@ func WithIgnoredMethods(ms ...string) Option {
 }
 
 // WithUntracedMethods specifies full methods to be ignored by the server side and client
-// side interceptors. When a request's full method is in ms, no spans will be created.
+// side interceptors and stats handlers. When a request's full method is in ms, no spans
+// will be created.
 func WithUntracedMethods(ms ...string) Option {
 	ums := make(map[string]struct{}, len(ms))
 	for _, e := range ms {
@@ -200,3 +286,230 @@ func WithSpanOptions(opts ...ddtrace.StartSpanOption) Option {
 		cfg.spanOpts = append(cfg.spanOpts, opts...)
 	}
 }
+
+// WithMetadataSampler specifies a metadata key whose value in incoming requests identifies
+// a tenant. The server side interceptors tag spans with the tenant and apply the analytics
+// rate found for it in rates. Tenants not present in rates use the default rate.
+func WithMetadataSampler(key string, rates map[string]float64) Option {
+	return func(cfg *config) {
+		cfg.samplerMetadataKey = strings.ToLower(key)
+		cfg.samplerRates = make(map[string]float64, len(rates))
+		for tenant, rate := range rates {
+			if rate >= 0.0 && rate <= 1.0 {
+				cfg.samplerRates[tenant] = rate
+			}
+		}
+	}
+}
+
+// WithRequestResourceNamer specifies a function which computes the resource name of
+// unary server spans from the full method and the decoded request. Returning an
+// empty string keeps the default resource name. It has no effect on streaming calls.
+func WithRequestResourceNamer(namer func(fullMethod string, req interface{}) string) Option {
+	return func(cfg *config) {
+		cfg.resourceNamer = namer
+	}
+}
+
+// WithForceSampleHeader specifies a metadata key which incoming requests can use to force
+// the server span to be kept, by setting it to a true value (e.g. "true" or "1"). This is
+// disabled by default since it allows clients to affect sampling decisions.
+func WithForceSampleHeader(key string) Option {
+	return func(cfg *config) {
+		cfg.forceSampleHeader = strings.ToLower(key)
+	}
+}
+
+// WithRetryCollapse collapses the attempts made by a retry interceptor into tags on the
+// span of the call, reporting the number of retries and their codes in the "grpc.retries"
+// and "grpc.retry_codes" tags. The attempts share a single child span, started by the first
+// one and propagated to the server as the parent of the spans of all of them, which is
+// tagged with the peer and finished with the outcome of the last attempt. For this to work,
+// the same UnaryClientInterceptor must be chained both before and after the retry
+// interceptor, e.g.:
+//
+//	i := grpctrace.UnaryClientInterceptor(grpctrace.WithRetryCollapse())
+//	grpc.WithChainUnaryInterceptor(i, retryInterceptor, i)
+//
+// Chaining it only once is not supported, and a warning is logged when a call succeeds
+// without going through it twice. The retries made by grpc itself, as configured by the retry
+// policy of the service config, happen below the interceptors and are not collected.
+func WithRetryCollapse() Option {
+	return func(cfg *config) {
+		cfg.retryCollapse = true
+	}
+}
+
+// WithRecovery enables recovering from panics in server handlers. The panic is recorded as
+// an error on the server span, which is finished with codes.Internal, and the call returns
+// an error with that code to the client.
+func WithRecovery() Option {
+	return func(cfg *config) {
+		cfg.recovery = true
+	}
+}
+
+// WithRepanic is like WithRecovery, but the panic is raised again after being recorded on
+// the server span. This allows recovery interceptors chained before the server interceptors
+// to handle the panic, while still reflecting it in the trace.
+func WithRepanic() Option {
+	return func(cfg *config) {
+		cfg.recovery = true
+		cfg.repanic = true
+	}
+}
+
+// WithCodecSpans enables reporting the time spent serializing and deserializing the
+// messages of RPCs traced by the stats handlers, in nanoseconds, in the "grpc.serialize.duration"
+// and "grpc.deserialize.duration" tags of their spans. The durations are measured by the codec
+// returned by NewCodec, which must be used by the client or the server for them to be non-zero.
+func WithCodecSpans() Option {
+	return func(cfg *config) {
+		cfg.codecSpans = true
+	}
+}
+
+// WithUserAgentTag enables tagging server spans with the user agent of the client, as sent
+// in the "user-agent" metadata, in the "grpc.user_agent" tag. This allows attributing traffic
+// to client SDKs and their versions. Spans of calls without a user agent are not tagged.
+func WithUserAgentTag() Option {
+	return func(cfg *config) {
+		cfg.userAgentTag = true
+	}
+}
+
+// WithConcurrencyMetrics enables reporting the number of RPCs being handled by the server,
+// per method, in the "grpc.server.in_flight" gauge, sent every 10 seconds using the DogStatsD
+// client of the tracer. The gauge reports the highest number of RPCs in flight during each
+// interval. RPCs are accounted for until their handler returns, even when it panics.
+func WithConcurrencyMetrics() Option {
+	return func(cfg *config) {
+		cfg.concurrency = new(concurrencyMetrics)
+	}
+}
+
+// WithConsumedMetadata specifies metadata keys which the server side interceptors and stats
+// handler consume: their values in incoming requests are set as tags on the server span, under
+// the "grpc.metadata." prefix, and the keys are removed from the incoming metadata found in the
+// context passed to the handler. This prevents headers added by edge proxies for routing from
+// being propagated further by handlers forwarding the incoming metadata to downstream calls.
+func WithConsumedMetadata(keys ...string) Option {
+	return func(cfg *config) {
+		for _, k := range keys {
+			cfg.consumedMetadata = append(cfg.consumedMetadata, strings.ToLower(k))
+		}
+	}
+}
+
+// WithMethodConfigTags enables tagging client spans with the configuration applied to their
+// method by the service config of the connection, such as the one set using
+// grpc.WithDefaultServiceConfig. The timeout is reported in milliseconds in the
+// "grpc.method_config.timeout" tag. The retry policy is not reported, since it is not exposed
+// by grpc-go. This option does not apply to the stats handler.
+func WithMethodConfigTags() Option {
+	return func(cfg *config) {
+		cfg.methodConfigTags = true
+	}
+}
+
+// WithTrailerMetadataTags specifies trailer metadata keys which the server side interceptors and
+// stats handler report: once the handler returns, the values set under these keys using
+// grpc.SetTrailer (or the SetTrailer method of the server stream) are set as tags on the server
+// span, under the "grpc.response.trailer." prefix. Multiple values are joined with commas.
+func WithTrailerMetadataTags(keys ...string) Option {
+	return func(cfg *config) {
+		for _, k := range keys {
+			cfg.trailerMetadataTags = append(cfg.trailerMetadataTags, strings.ToLower(k))
+		}
+	}
+}
+
+// WithResourceNameNormalization enables normalizing the resource names of spans, such that calls
+// to different casings or versions of a method are grouped together: the resource is lowercased,
+// and the version ending its service and method names is stripped, e.g. "/acme.UsersV2/GetUser_v3"
+// becomes "/acme.users/getuser". The original full method is kept in the "grpc.method.full" tag.
+// When WithRequestResourceNamer is also used, the resource it returns is normalized.
+func WithResourceNameNormalization() Option {
+	return func(cfg *config) {
+		cfg.normalizeResource = true
+	}
+}
+
+// WithTraceHealthChecks enables or disables tracing the calls to the standard health checking
+// service, grpc.health.v1.Health, by the server side and client side interceptors and stats
+// handlers. These calls are not traced by default, since they are frequent and of little interest,
+// and would otherwise have to be listed using WithUntracedMethods.
+func WithTraceHealthChecks(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.traceHealthChecks = enabled
+	}
+}
+
+// WithActiveStreamsTag enables tagging server spans with the number of RPCs being handled over
+// their connection when they start, including their own, in the "grpc.conn.active_streams" tag.
+// This helps diagnosing connections running out of HTTP/2 streams, as limited by the
+// grpc.MaxConcurrentStreams server option. This option only applies to the server stats handler,
+// since the interceptors are not aware of connections.
+func WithActiveStreamsTag() Option {
+	return func(cfg *config) {
+		cfg.activeStreamsTag = true
+	}
+}
+
+// WithProtocolTag enables tagging spans with the protocol used over the connection of their RPC,
+// in the "grpc.protocol" tag. For TLS connections, it is the protocol negotiated using ALPN,
+// which is "h2" unless a proxy in between negotiated another one. Plaintext connections are
+// tagged with "h2c". This option does not apply to the client stats handler.
+func WithProtocolTag() Option {
+	return func(cfg *config) {
+		cfg.protocolTag = true
+	}
+}
+
+// WithClientAnalyticsRate sets the sampling rate for Trace Analytics events correlated to the
+// spans started on the client side, by the client interceptors and stats handler. It takes
+// precedence over the rate set using WithAnalytics, WithAnalyticsRate or WithSpanOptions, which
+// allows sampling the client and server sides of the same services independently.
+func WithClientAnalyticsRate(rate float64) Option {
+	return func(cfg *config) {
+		if rate >= 0.0 && rate <= 1.0 {
+			cfg.clientAnalyticsRate = rate
+		}
+	}
+}
+
+// WithServerAnalyticsRate sets the sampling rate for Trace Analytics events correlated to the
+// spans started on the server side, by the server interceptors and stats handler. It takes
+// precedence over the rate set using WithAnalytics, WithAnalyticsRate or WithSpanOptions, which
+// allows sampling the client and server sides of the same services independently.
+func WithServerAnalyticsRate(rate float64) Option {
+	return func(cfg *config) {
+		if rate >= 0.0 && rate <= 1.0 {
+			cfg.serverAnalyticsRate = rate
+		}
+	}
+}
+
+// WithServerAddressTags enables tagging server spans with the local address of the listener
+// which accepted their connection, in the "grpc.server.address" and "grpc.server.port" tags.
+// This helps telling apart the listeners of servers serving on several of them. Addresses
+// which are not IP addresses, such as Unix sockets, are reported in "grpc.server.address" only.
+// This option only applies to the server stats handler, since the interceptors are not aware
+// of connections.
+func WithServerAddressTags() Option {
+	return func(cfg *config) {
+		cfg.serverAddressTags = true
+	}
+}
+
+// WithDeadlineSourceTag enables tagging client spans with the source of the deadline of their
+// call, if any, in the "grpc.deadline.source" tag: "inherited" when it is the deadline of the
+// incoming RPC being handled, as propagated by its context, and "local" when it was set by the
+// client itself, or shortened by it. Calls without deadline are not tagged. The deadlines of
+// incoming RPCs are only known when the server interceptors or stats handler are configured
+// with this option as well; deadlines are reported as local otherwise.
+func WithDeadlineSourceTag() Option {
+	return func(cfg *config) {
+		cfg.deadlineSourceTag = true
+	}
+}
@@ -0,0 +1,120 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package grpc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	context "golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/test/bufconn"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+)
+
+// newTestTLSCredentials returns the credentials of a server using a self-signed certificate
+// for host, and the ones of a client trusting it.
+func newTestTLSCredentials(t *testing.T, host string) (server, client credentials.TransportCredentials) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: host},
+		DNSNames:              []string{host},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	server = credentials.NewServerTLSFromCert(&tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key})
+	client = credentials.NewClientTLSFromCert(pool, host)
+	return server, client
+}
+
+func TestProtocolTag(t *testing.T) {
+	serverCreds, clientCreds := newTestTLSCredentials(t, "bufnet")
+	for name, tt := range map[string]struct {
+		opts     []Option
+		tls      bool
+		protocol interface{}
+	}{
+		"tls":       {opts: []Option{WithProtocolTag()}, tls: true, protocol: protocolH2},
+		"plaintext": {opts: []Option{WithProtocolTag()}, protocol: protocolH2C},
+		"disabled":  {tls: true, protocol: nil},
+	} {
+		t.Run(name, func(t *testing.T) {
+			mt := mocktracer.Start()
+			defer mt.Stop()
+
+			opts := append(tt.opts, WithStreamMessages(false))
+			li := bufconn.Listen(1024 * 1024)
+			serverOpts := []grpc.ServerOption{
+				grpc.UnaryInterceptor(UnaryServerInterceptor(opts...)),
+				grpc.StreamInterceptor(StreamServerInterceptor(opts...)),
+			}
+			dialOpts := []grpc.DialOption{
+				grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+					return li.Dial()
+				}),
+				grpc.WithUnaryInterceptor(UnaryClientInterceptor(opts...)),
+				grpc.WithStreamInterceptor(StreamClientInterceptor(opts...)),
+			}
+			if tt.tls {
+				serverOpts = append(serverOpts, grpc.Creds(serverCreds))
+				dialOpts = append(dialOpts, grpc.WithTransportCredentials(clientCreds))
+			} else {
+				dialOpts = append(dialOpts, grpc.WithInsecure())
+			}
+			server := grpc.NewServer(serverOpts...)
+			RegisterFixtureServer(server, new(fixtureServer))
+			go server.Serve(li)
+			defer server.Stop()
+			conn, err := grpc.Dial("bufnet", dialOpts...)
+			require.NoError(t, err)
+			defer conn.Close()
+			client := NewFixtureClient(conn)
+
+			_, err = client.Ping(context.Background(), &FixtureRequest{Name: "pass"})
+			require.NoError(t, err)
+			stream, err := client.StreamPing(context.Background())
+			require.NoError(t, err)
+			require.NoError(t, stream.Send(&FixtureRequest{Name: "break"}))
+			_, err = stream.Recv()
+			require.NoError(t, err)
+			_, err = stream.Recv()
+			require.Equal(t, io.EOF, err)
+
+			// the unary and stream calls are traced by the client and the server
+			waitForSpans(mt, 4, time.Second)
+			spans := mt.FinishedSpans()
+			require.Len(t, spans, 4)
+			for _, s := range spans {
+				assert.Equal(t, tt.protocol, s.Tag(tagProtocol), "%s %s", s.OperationName(), s.Tag(tagMethodName))
+			}
+		})
+	}
+}
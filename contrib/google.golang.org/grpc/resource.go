@@ -0,0 +1,46 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package grpc
+
+import (
+	"regexp"
+	"strings"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+)
+
+// versionSuffix matches the version ending a segment of a method name, such as "V2" in
+// "GetUserV2", or "_v2" in "get_user_v2". A lowercase "v" must follow a separator, so that
+// names such as "GetDev2" are left untouched.
+var versionSuffix = regexp.MustCompile(`(?:[._-][vV]|V)[0-9]+$`)
+
+// normalizeResourceName lowercases resource and strips the version ending each of its
+// "/" separated segments, such that "/acme.UsersV2/GetUser_v3" becomes "/acme.users/getuser".
+func normalizeResourceName(resource string) string {
+	segments := strings.Split(resource, "/")
+	for i, s := range segments {
+		if loc := versionSuffix.FindStringIndex(s); loc != nil && loc[0] > 0 {
+			s = s[:loc[0]]
+		}
+		segments[i] = strings.ToLower(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// setResourceName sets resource as the resource of span, started for fullMethod. It is
+// normalized if enabled using WithResourceNameNormalization, in which case fullMethod is
+// kept in the "grpc.method.full" tag.
+func (cfg *config) setResourceName(span ddtrace.Span, fullMethod, resource string) {
+	if cfg.normalizeResource {
+		span.SetTag(tagMethodFull, fullMethod)
+		span.SetTag(ext.ResourceName, normalizeResourceName(resource))
+		return
+	}
+	if resource != fullMethod {
+		span.SetTag(ext.ResourceName, resource)
+	}
+}
@@ -0,0 +1,84 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package grpc
+
+import (
+	"testing"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	context "golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+func TestNormalizeResourceName(t *testing.T) {
+	for in, want := range map[string]string{
+		"/grpc.Fixture/Ping":           "/grpc.fixture/ping",
+		"/acme.UsersV2/GetUser_v3":     "/acme.users/getuser",
+		"/acme.users/get-user.v10":     "/acme.users/get-user",
+		"/acme.users.v1.Users/GetDev2": "/acme.users.v1.users/getdev2",
+		"/acme.Users/V2":               "/acme.users/v2",
+		"ping":                         "ping",
+	} {
+		assert.Equal(t, want, normalizeResourceName(in), in)
+	}
+}
+
+func TestWithResourceNameNormalization(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	const method = "/grpc.FixtureV2/Ping_v1"
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+	for name, tt := range map[string]struct {
+		opts     []Option
+		resource string
+		full     interface{}
+	}{
+		"disabled": {resource: method},
+		"enabled":  {opts: []Option{WithResourceNameNormalization()}, resource: "/grpc.fixture/ping", full: method},
+		"namer": {
+			opts: []Option{
+				WithResourceNameNormalization(),
+				WithRequestResourceNamer(func(fullMethod string, _ interface{}) string { return fullMethod + "/Tenant_V3" }),
+			},
+			resource: "/grpc.fixture/ping/tenant",
+			full:     method,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			mt.Reset()
+			_, err := UnaryServerInterceptor(tt.opts...)(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+			require.NoError(t, err)
+			spans := mt.FinishedSpans()
+			require.Len(t, spans, 1)
+			assert.Equal(t, tt.resource, spans[0].Tag(ext.ResourceName))
+			assert.Equal(t, tt.full, spans[0].Tag(tagMethodFull))
+			assert.Equal(t, method, spans[0].Tag(tagMethodName))
+		})
+	}
+
+	t.Run("client", func(t *testing.T) {
+		rig, err := newRig(true, WithResourceNameNormalization())
+		require.NoError(t, err)
+		defer rig.Close()
+
+		mt.Reset()
+		_, err = rig.client.Ping(context.Background(), &FixtureRequest{Name: "pass"})
+		require.NoError(t, err)
+		waitForSpans(mt, 2, time.Second)
+		spans := mt.FinishedSpans()
+		require.Len(t, spans, 2)
+		for _, s := range spans {
+			assert.Equal(t, "/grpc.fixture/ping", s.Tag(ext.ResourceName), s.OperationName())
+			assert.Equal(t, "/grpc.Fixture/Ping", s.Tag(tagMethodFull), s.OperationName())
+		}
+	})
+}
@@ -0,0 +1,90 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package grpc
+
+import (
+	"strings"
+	"sync"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+
+	context "golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+type retryCollectorKey struct{}
+
+// retryCollector records the attempts made by a retry interceptor chained between
+// two UnaryClientInterceptors configured using WithRetryCollapse.
+type retryCollector struct {
+	mu       sync.Mutex // guards below fields
+	span     ddtrace.Span
+	attempts []retryAttempt
+}
+
+type retryAttempt struct {
+	err  error
+	peer peer.Peer
+}
+
+// invoke calls invoker, recording the attempt. The attempts share a single span, started
+// by the first one, which is propagated to the server as their parent and is finished once
+// the whole call completes.
+func (rc *retryCollector) invoke(ctx context.Context, cfg *config, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	rc.mu.Lock()
+	if rc.span == nil {
+		rc.span, _ = startSpanFromContext(
+			ctx,
+			method,
+			"grpc.client",
+			cfg.clientServiceName(),
+			cfg.clientSpanOptions(
+				tracer.Tag(ext.Component, "google.golang.org/grpc"),
+				tracer.Tag(ext.SpanKind, ext.SpanKindClient))...,
+		)
+		cfg.setResourceName(rc.span, method, method)
+		rc.span.SetTag(tagMethodKind, methodKindUnary)
+		setPeerService(rc.span, cfg, connTarget(cc))
+	}
+	span := rc.span
+	rc.mu.Unlock()
+
+	var a retryAttempt
+	ctx = injectSpanIntoContext(tracer.ContextWithSpan(ctx, span))
+	a.err = invoker(ctx, method, req, reply, cc, append(opts, grpc.Peer(&a.peer))...)
+	rc.mu.Lock()
+	rc.attempts = append(rc.attempts, a)
+	rc.mu.Unlock()
+	return a.err
+}
+
+// collapse tags span, the span of the whole call, with the number of retries and their
+// codes, and finishes the span of the attempts with the outcome of the last one. It
+// reports false if no attempt was recorded.
+func (rc *retryCollector) collapse(cfg *config, span ddtrace.Span) bool {
+	rc.mu.Lock()
+	attempts, attempt := rc.attempts, rc.span
+	rc.mu.Unlock()
+	if len(attempts) == 0 {
+		return false
+	}
+	if n := len(attempts) - 1; n > 0 {
+		codes := make([]string, n)
+		for i, a := range attempts[:n] {
+			codes[i] = status.Code(a.err).String()
+		}
+		span.SetTag(tagRetries, n)
+		span.SetTag(tagRetryCodes, strings.Join(codes, ","))
+	}
+	last := attempts[len(attempts)-1]
+	setSpanTargetFromPeer(attempt, last.peer)
+	finishWithError(attempt, last.err, cfg)
+	return true
+}
@@ -6,6 +6,10 @@
 package grpc
 
 import (
+	"net"
+	"strconv"
+	"strings"
+
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
@@ -16,14 +20,20 @@ import (
 	"github.com/golang/protobuf/proto"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 )
 
 type serverStream struct {
 	grpc.ServerStream
-	cfg    *config
-	method string
-	ctx    context.Context
+	cfg      *config
+	method   string
+	ctx      context.Context
+	trailers *trailerRecorder
+	// messages numbers the messages of the stream, if enabled using WithMessageSpans.
+	messages messageCounter
 }
 
 // Context returns the ServerStream Context.
@@ -38,21 +48,34 @@ func (ss *serverStream) Context() context.Context {
 	return ss.ctx
 }
 
+// SetTrailer records the trailer metadata to be tagged on the server span, if enabled
+// using WithTrailerMetadataTags.
+func (ss *serverStream) SetTrailer(md metadata.MD) {
+	if ss.trailers != nil {
+		ss.trailers.record(md)
+	}
+	ss.ServerStream.SetTrailer(md)
+}
+
 func (ss *serverStream) RecvMsg(m interface{}) (err error) {
 	_, im := ss.cfg.ignoredMethods[ss.method]
-	_, um := ss.cfg.untracedMethods[ss.method]
+	um := ss.cfg.untraced(ss.method)
 	if ss.cfg.traceStreamMessages && !im && !um {
 		span, _ := startSpanFromContext(
 			ss.ctx,
 			ss.method,
 			"grpc.message",
 			ss.cfg.serverServiceName(),
-			ss.cfg.startSpanOptions(tracer.Measured())...,
+			ss.cfg.serverSpanOptions(tracer.Measured())...,
 		)
 		span.SetTag(ext.Component, "google.golang.org/grpc")
+		ss.cfg.setResourceName(span, ss.method, ss.method)
 		defer func() {
 			withMetadataTags(ss.ctx, ss.cfg, span)
 			withRequestTags(ss.cfg, m, span)
+			if ss.cfg.messageSpans && err == nil {
+				ss.messages.setMessageTags(span, messageReceived, m)
+			}
 			finishWithError(span, err, ss.cfg)
 		}()
 	}
@@ -62,17 +85,23 @@ func (ss *serverStream) RecvMsg(m interface{}) (err error) {
 
 func (ss *serverStream) SendMsg(m interface{}) (err error) {
 	_, im := ss.cfg.ignoredMethods[ss.method]
-	_, um := ss.cfg.untracedMethods[ss.method]
+	um := ss.cfg.untraced(ss.method)
 	if ss.cfg.traceStreamMessages && !im && !um {
 		span, _ := startSpanFromContext(
 			ss.ctx,
 			ss.method,
 			"grpc.message",
 			ss.cfg.serverServiceName(),
-			ss.cfg.startSpanOptions(tracer.Measured())...,
+			ss.cfg.serverSpanOptions(tracer.Measured())...,
 		)
 		span.SetTag(ext.Component, "google.golang.org/grpc")
-		defer func() { finishWithError(span, err, ss.cfg) }()
+		ss.cfg.setResourceName(span, ss.method, ss.method)
+		defer func() {
+			if ss.cfg.messageSpans && err == nil {
+				ss.messages.setMessageTags(span, messageSent, m)
+			}
+			finishWithError(span, err, ss.cfg)
+		}()
 	}
 	err = ss.ServerStream.SendMsg(m)
 	return err
@@ -88,9 +117,11 @@ func StreamServerInterceptor(opts ...Option) grpc.StreamServerInterceptor {
 	log.Debug("contrib/google.golang.org/grpc: Configuring StreamServerInterceptor: %#v", cfg)
 	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
 		ctx := ss.Context()
+		defer cfg.trackInFlight(info.FullMethod)()
+		var trailers *trailerRecorder
 		// if we've enabled call tracing, create a span
 		_, im := cfg.ignoredMethods[info.FullMethod]
-		_, um := cfg.untracedMethods[info.FullMethod]
+		um := cfg.untraced(info.FullMethod)
 		if cfg.traceStreamCalls && !im && !um {
 			var span ddtrace.Span
 			span, ctx = startSpanFromContext(
@@ -98,10 +129,11 @@ func StreamServerInterceptor(opts ...Option) grpc.StreamServerInterceptor {
 				info.FullMethod,
 				"grpc.server",
 				cfg.serverServiceName(),
-				cfg.startSpanOptions(tracer.Measured(),
+				cfg.serverSpanOptions(tracer.Measured(),
 					tracer.Tag(ext.Component, "google.golang.org/grpc"),
 					tracer.Tag(ext.SpanKind, ext.SpanKindServer))...,
 			)
+			cfg.setResourceName(span, info.FullMethod, info.FullMethod)
 			switch {
 			case info.IsServerStream && info.IsClientStream:
 				span.SetTag(tagMethodKind, methodKindBidiStream)
@@ -110,7 +142,27 @@ func StreamServerInterceptor(opts ...Option) grpc.StreamServerInterceptor {
 			case info.IsClientStream:
 				span.SetTag(tagMethodKind, methodKindClientStream)
 			}
-			defer func() { finishWithError(span, err, cfg) }()
+			withMetadataSampler(ctx, cfg, span)
+			withForceSampleHeader(ctx, cfg, span)
+			withUserAgentTag(ctx, cfg, span)
+			withTransportTag(ctx, span)
+			withProtocolTag(ctx, cfg, span)
+			ctx = withConsumedMetadata(ctx, cfg, span)
+			ctx, trailers = withTrailerRecorder(ctx, cfg)
+			withDeadlineTag(ctx, span, tagTimeoutRemaining)
+			ctx = withInboundDeadline(ctx, cfg)
+			defer func() {
+				if cfg.recovery {
+					if r := recover(); r != nil {
+						err = recoverPanic(span, cfg, r)
+						return
+					}
+				}
+				if trailers != nil {
+					trailers.setTags(span, cfg.trailerMetadataTags)
+				}
+				finishWithError(span, err, cfg)
+			}()
 			if appsec.Enabled() {
 				handler = appsecStreamHandlerMiddleware(span, handler)
 			}
@@ -123,6 +175,7 @@ func StreamServerInterceptor(opts ...Option) grpc.StreamServerInterceptor {
 			cfg:          cfg,
 			method:       info.FullMethod,
 			ctx:          ctx,
+			trailers:     trailers,
 		})
 	}
 }
@@ -135,9 +188,10 @@ func UnaryServerInterceptor(opts ...Option) grpc.UnaryServerInterceptor {
 		fn(cfg)
 	}
 	log.Debug("contrib/google.golang.org/grpc: Configuring UnaryServerInterceptor: %#v", cfg)
-	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer cfg.trackInFlight(info.FullMethod)()
 		_, im := cfg.ignoredMethods[info.FullMethod]
-		_, um := cfg.untracedMethods[info.FullMethod]
+		um := cfg.untraced(info.FullMethod)
 		if im || um {
 			return handler(ctx, req)
 		}
@@ -146,22 +200,83 @@ func UnaryServerInterceptor(opts ...Option) grpc.UnaryServerInterceptor {
 			info.FullMethod,
 			"grpc.server",
 			cfg.serverServiceName(),
-			cfg.startSpanOptions(tracer.Measured(),
+			cfg.serverSpanOptions(tracer.Measured(),
 				tracer.Tag(ext.Component, "google.golang.org/grpc"),
 				tracer.Tag(ext.SpanKind, ext.SpanKindServer))...,
 		)
 		span.SetTag(tagMethodKind, methodKindUnary)
+		resource := info.FullMethod
+		if cfg.resourceNamer != nil {
+			if r := cfg.resourceNamer(info.FullMethod, req); r != "" {
+				resource = r
+			}
+		}
+		cfg.setResourceName(span, info.FullMethod, resource)
+		withMetadataSampler(ctx, cfg, span)
+		withForceSampleHeader(ctx, cfg, span)
+		withUserAgentTag(ctx, cfg, span)
+		withTransportTag(ctx, span)
+		withProtocolTag(ctx, cfg, span)
+		ctx = withConsumedMetadata(ctx, cfg, span)
+		ctx, trailers := withTrailerRecorder(ctx, cfg)
+		withDeadlineTag(ctx, span, tagTimeoutRemaining)
+		ctx = withInboundDeadline(ctx, cfg)
 		withMetadataTags(ctx, cfg, span)
 		withRequestTags(cfg, req, span)
 		if appsec.Enabled() {
 			handler = appsecUnaryHandlerMiddleware(span, handler)
 		}
-		resp, err := handler(ctx, req)
+		if cfg.recovery {
+			defer func() {
+				if r := recover(); r != nil {
+					err = recoverPanic(span, cfg, r)
+				}
+			}()
+		}
+		resp, err = handler(ctx, req)
+		if trailers != nil {
+			trailers.setTags(span, cfg.trailerMetadataTags)
+		}
 		finishWithError(span, err, cfg)
 		return resp, err
 	}
 }
 
+// recoverPanic records the recovered panic r as an error on span and finishes it with
+// codes.Internal. It returns the error to be returned to the client, unless re-panicking
+// was requested using WithRepanic.
+func recoverPanic(span ddtrace.Span, cfg *config, r interface{}) error {
+	err := status.Errorf(codes.Internal, "panic: %v", r)
+	span.SetTag(tagCode, codes.Internal.String())
+	opts := []tracer.FinishOption{tracer.WithError(err)}
+	if cfg.noDebugStack {
+		opts = append(opts, tracer.NoDebugStack())
+	}
+	span.Finish(opts...)
+	if cfg.repanic {
+		panic(r)
+	}
+	return err
+}
+
+// withTransportTag tags the span with the transport the RPC of ctx was received over. RPCs
+// without a peer, such as the ones of in-process servers, are received in-process.
+func withTransportTag(ctx context.Context, span ddtrace.Span) {
+	var addr net.Addr
+	if p, ok := peer.FromContext(ctx); ok {
+		addr = p.Addr
+	}
+	span.SetTag(tagTransport, transportOf(addr))
+}
+
+// withProtocolTag tags the span with the protocol used over the connection the RPC of ctx was
+// received over, if enabled using WithProtocolTag.
+func withProtocolTag(ctx context.Context, cfg *config, span ddtrace.Span) {
+	if p, ok := peer.FromContext(ctx); ok {
+		setProtocolTag(span, cfg, p)
+	}
+}
+
 func withMetadataTags(ctx context.Context, cfg *config, span ddtrace.Span) {
 	if cfg.withMetadataTags {
 		md, _ := metadata.FromIncomingContext(ctx) // nil is ok
@@ -173,6 +288,80 @@ func withMetadataTags(ctx context.Context, cfg *config, span ddtrace.Span) {
 	}
 }
 
+// withMetadataSampler tags the span with the tenant found in the incoming metadata
+// under the key configured using WithMetadataSampler and applies its analytics rate.
+func withMetadataSampler(ctx context.Context, cfg *config, span ddtrace.Span) {
+	if cfg.samplerMetadataKey == "" {
+		return
+	}
+	md, _ := metadata.FromIncomingContext(ctx) // nil is ok
+	vs := md.Get(cfg.samplerMetadataKey)
+	if len(vs) == 0 {
+		return
+	}
+	span.SetTag(tagMetadataPrefix+cfg.samplerMetadataKey, vs[0])
+	if rate, ok := cfg.samplerRates[vs[0]]; ok {
+		span.SetTag(ext.EventSampleRate, rate)
+	}
+}
+
+// withForceSampleHeader marks the span to be kept when the incoming metadata holds a
+// true value under the key configured using WithForceSampleHeader.
+func withForceSampleHeader(ctx context.Context, cfg *config, span ddtrace.Span) {
+	if cfg.forceSampleHeader == "" {
+		return
+	}
+	md, _ := metadata.FromIncomingContext(ctx) // nil is ok
+	vs := md.Get(cfg.forceSampleHeader)
+	if len(vs) == 0 {
+		return
+	}
+	if keep, err := strconv.ParseBool(vs[0]); err == nil && keep {
+		span.SetTag(ext.ManualKeep, true)
+	}
+}
+
+// withUserAgentTag tags the span with the user agent found in the incoming metadata,
+// if enabled using WithUserAgentTag.
+func withUserAgentTag(ctx context.Context, cfg *config, span ddtrace.Span) {
+	if !cfg.userAgentTag {
+		return
+	}
+	md, _ := metadata.FromIncomingContext(ctx) // nil is ok
+	if vs := md.Get("user-agent"); len(vs) > 0 && vs[0] != "" {
+		span.SetTag(tagUserAgent, vs[0])
+	}
+}
+
+// withConsumedMetadata tags the span with the values of the metadata keys set using
+// WithConsumedMetadata and returns a copy of ctx in which they are removed from the
+// incoming metadata.
+func withConsumedMetadata(ctx context.Context, cfg *config, span ddtrace.Span) context.Context {
+	if len(cfg.consumedMetadata) == 0 {
+		return ctx
+	}
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+	var consumed metadata.MD
+	for _, k := range cfg.consumedMetadata {
+		vs, ok := md[k]
+		if !ok {
+			continue
+		}
+		if consumed == nil {
+			consumed = md.Copy()
+		}
+		span.SetTag(tagMetadataPrefix+k, strings.Join(vs, ","))
+		delete(consumed, k)
+	}
+	if consumed == nil {
+		return ctx
+	}
+	return metadata.NewIncomingContext(ctx, consumed)
+}
+
 func withRequestTags(cfg *config, req interface{}, span ddtrace.Span) {
 	if cfg.withRequestTags {
 		var m jsonpb.Marshaler
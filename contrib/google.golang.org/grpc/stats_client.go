@@ -6,8 +6,6 @@
 package grpc
 
 import (
-	"net"
-
 	context "golang.org/x/net/context"
 	"google.golang.org/grpc/stats"
 
@@ -29,15 +27,33 @@ func NewClientStatsHandler(opts ...Option) stats.Handler {
 
 type clientStatsHandler struct{ cfg *config }
 
-// TagRPC starts a new span for the initiated RPC request.
+// fullMethodPendingKey is the context key marking RPCs for which the method name was
+// not known in TagRPC, so that it is set from the outgoing header instead.
+type fullMethodPendingKey struct{}
+
+// TagRPC starts a new span for the initiated RPC request, unless its method is untraced.
+// The method name and resource are set when starting the span, so that they are available
+// to any code reading them during the RPC, however fast it is.
 func (h *clientStatsHandler) TagRPC(ctx context.Context, rti *stats.RPCTagInfo) context.Context {
-	_, ctx = startSpanFromContext(
+	if h.cfg.untraced(rti.FullMethodName) {
+		return ctx
+	}
+	span, ctx := startSpanFromContext(
 		ctx,
 		rti.FullMethodName,
 		"grpc.client",
 		h.cfg.clientServiceName(),
-		h.cfg.spanOpts...,
+		withAnalyticsRate(h.cfg.spanOpts, h.cfg.clientAnalyticsRate)...,
 	)
+	withDeadlineTag(ctx, span, tagTimeout)
+	withDeadlineSourceTag(ctx, h.cfg, span)
+	h.cfg.setResourceName(span, rti.FullMethodName, rti.FullMethodName)
+	if rti.FullMethodName == "" {
+		ctx = context.WithValue(ctx, fullMethodPendingKey{}, true)
+	}
+	if h.cfg.codecSpans {
+		ctx = withCodecTimer(ctx)
+	}
 	ctx = injectSpanIntoContext(ctx)
 	return ctx
 }
@@ -48,16 +64,25 @@ func (h *clientStatsHandler) HandleRPC(ctx context.Context, rs stats.RPCStats) {
 	if !ok {
 		return
 	}
+	ct, timed := codecTimerFromContext(ctx)
+	if timed {
+		ct.handle(rs)
+	}
 	switch rs := rs.(type) {
 	case *stats.OutHeader:
-		host, port, err := net.SplitHostPort(rs.RemoteAddr.String())
-		if err == nil {
-			if host != "" {
-				span.SetTag(ext.TargetHost, host)
-			}
-			span.SetTag(ext.TargetPort, port)
+		if rs.FullMethod != "" && ctx.Value(fullMethodPendingKey{}) != nil {
+			span.SetTag(ext.ResourceName, rs.FullMethod)
+			span.SetTag(tagMethodName, rs.FullMethod)
+			h.cfg.setResourceName(span, rs.FullMethod, rs.FullMethod)
 		}
+		setSpanTargetFromAddr(span, rs.RemoteAddr)
+		// the target is not known to stats handlers, so that only the peer service
+		// set using WithPeerService is used.
+		setPeerService(span, h.cfg, "")
 	case *stats.End:
+		if timed {
+			ct.setTags(span)
+		}
 		finishWithError(span, rs.Error, h.cfg)
 	}
 }
@@ -19,6 +19,7 @@ import (
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/namingschema"
 )
 
 func TestClientStatsHandler(t *testing.T) {
@@ -59,6 +60,98 @@ func TestClientStatsHandler(t *testing.T) {
 	assert.Equal("bar", tags["foo"])
 }
 
+// beginRecorder records the tags of the client span when the RPC begins.
+type beginRecorder struct {
+	stats.Handler
+	tags chan map[string]interface{}
+}
+
+func (h *beginRecorder) HandleRPC(ctx context.Context, rs stats.RPCStats) {
+	if _, ok := rs.(*stats.Begin); ok {
+		if span, ok := tracer.SpanFromContext(ctx); ok {
+			h.tags <- span.(mocktracer.Span).Tags()
+		}
+	}
+	h.Handler.HandleRPC(ctx, rs)
+}
+
+func TestClientStatsHandlerResource(t *testing.T) {
+	t.Run("begin", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		h := &beginRecorder{Handler: NewClientStatsHandler(), tags: make(chan map[string]interface{}, 1)}
+		server, err := newClientStatsHandlerTestServer(h)
+		if err != nil {
+			t.Fatalf("failed to start test server: %s", err)
+		}
+		defer server.Close()
+
+		_, err = server.client.Ping(context.Background(), &FixtureRequest{Name: "pass"})
+		assert.NoError(t, err)
+		tags := <-h.tags
+		assert.Equal(t, "/grpc.Fixture/Ping", tags[ext.ResourceName])
+		assert.Equal(t, "/grpc.Fixture/Ping", tags[tagMethodName])
+	})
+
+	t.Run("tag", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		h := NewClientStatsHandler()
+		ctx := h.TagRPC(context.Background(), &stats.RPCTagInfo{FullMethodName: "/grpc.Fixture/Ping"})
+		span, ok := tracer.SpanFromContext(ctx)
+		assert.True(t, ok)
+		assert.Equal(t, "/grpc.Fixture/Ping", span.(mocktracer.Span).Tag(ext.ResourceName))
+		assert.Equal(t, "/grpc.Fixture/Ping", span.(mocktracer.Span).Tag(tagMethodName))
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		h := NewClientStatsHandler()
+		ctx := h.TagRPC(context.Background(), &stats.RPCTagInfo{})
+		h.HandleRPC(ctx, &stats.OutHeader{Client: true, FullMethod: "/grpc.Fixture/Ping"})
+		h.HandleRPC(ctx, &stats.End{Client: true})
+
+		spans := mt.FinishedSpans()
+		assert.Len(t, spans, 1)
+		assert.Equal(t, "/grpc.Fixture/Ping", spans[0].Tag(ext.ResourceName))
+		assert.Equal(t, "/grpc.Fixture/Ping", spans[0].Tag(tagMethodName))
+	})
+}
+
+func TestClientStatsHandlerPeerService(t *testing.T) {
+	defer namingschema.SetVersion(namingschema.SetVersion(namingschema.VersionV1))
+
+	for name, tt := range map[string]struct {
+		opts        []Option
+		peerService interface{}
+	}{
+		"default":    {peerService: nil},
+		"configured": {opts: []Option{WithPeerService("users")}, peerService: "users"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			mt := mocktracer.Start()
+			defer mt.Stop()
+
+			server, err := newClientStatsHandlerTestServer(NewClientStatsHandler(tt.opts...))
+			if err != nil {
+				t.Fatalf("failed to start test server: %s", err)
+			}
+			defer server.Close()
+
+			_, err = server.client.Ping(context.Background(), &FixtureRequest{Name: "pass"})
+			assert.NoError(t, err)
+			spans := mt.FinishedSpans()
+			assert.Len(t, spans, 1)
+			// the address of the peer is never used as its service
+			assert.Equal(t, tt.peerService, spans[0].Tag(ext.PeerService))
+		})
+	}
+}
+
 func newClientStatsHandlerTestServer(statsHandler stats.Handler) (*rig, error) {
 	server := grpc.NewServer()
 	fixtureServer := new(fixtureServer)
@@ -6,13 +6,18 @@
 package grpc
 
 import (
+	"net"
+	"sync/atomic"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 
 	context "golang.org/x/net/context"
 	"google.golang.org/grpc/stats"
 )
 
-// NewServerStatsHandler returns a gRPC server stats.Handler to trace RPC calls.
+// NewServerStatsHandler returns a gRPC server stats.Handler to trace RPC calls. The span
+// of the first RPC received over each connection is tagged with "grpc.new_connection".
 func NewServerStatsHandler(opts ...Option) stats.Handler {
 	cfg := new(config)
 	defaults(cfg)
@@ -28,16 +33,39 @@ type serverStatsHandler struct {
 	cfg *config
 }
 
-// TagRPC starts a new span for the initiated RPC request.
+// TagRPC starts a new span for the initiated RPC request, unless its method is untraced.
 func (h *serverStatsHandler) TagRPC(ctx context.Context, rti *stats.RPCTagInfo) context.Context {
+	if h.cfg.untraced(rti.FullMethodName) {
+		return ctx
+	}
 	h.cfg.spanOpts = append(h.cfg.spanOpts, tracer.Measured())
-	_, ctx = startSpanFromContext(
+	span, ctx := startSpanFromContext(
 		ctx,
 		rti.FullMethodName,
 		"grpc.server",
 		h.cfg.serverServiceName(),
-		h.cfg.spanOpts...,
+		withAnalyticsRate(h.cfg.spanOpts, h.cfg.serverAnalyticsRate)...,
 	)
+	h.cfg.setResourceName(span, rti.FullMethodName, rti.FullMethodName)
+	withUserAgentTag(ctx, h.cfg, span)
+	withTransportTag(ctx, span)
+	withProtocolTag(ctx, h.cfg, span)
+	ctx = withConsumedMetadata(ctx, h.cfg, span)
+	withDeadlineTag(ctx, span, tagTimeoutRemaining)
+	ctx = withInboundDeadline(ctx, h.cfg)
+	withNewConnectionTag(ctx, span)
+	if h.cfg.activeStreamsTag {
+		ctx = withActiveStreamsTag(ctx, span)
+	}
+	if h.cfg.serverAddressTags {
+		withServerAddressTags(ctx, span)
+	}
+	if h.cfg.concurrency != nil {
+		ctx = withInFlight(ctx, h.cfg.trackInFlight(rti.FullMethodName))
+	}
+	if h.cfg.codecSpans {
+		ctx = withCodecTimer(ctx)
+	}
 	return ctx
 }
 
@@ -47,15 +75,107 @@ func (h *serverStatsHandler) HandleRPC(ctx context.Context, rs stats.RPCStats) {
 	if !ok {
 		return
 	}
+	ct, timed := codecTimerFromContext(ctx)
+	if timed {
+		ct.handle(rs)
+	}
+	if v, ok := rs.(*stats.OutTrailer); ok {
+		withTrailerTags(span, v.Trailer, h.cfg.trailerMetadataTags)
+	}
 	if v, ok := rs.(*stats.End); ok {
+		if timed {
+			ct.setTags(span)
+		}
+		endInFlight(ctx)
+		endActiveStream(ctx)
 		finishWithError(span, v.Error, h.cfg)
 	}
 }
 
-// TagConn implements stats.Handler.
-func (h *serverStatsHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
-	return ctx
+// connState tracks the state of a connection, as tagged by TagConn.
+type connState struct {
+	// rpcs is the number of RPCs received over the connection.
+	rpcs uint32
+	// ended is set once the connection is closed.
+	ended uint32
+	// streams is the number of RPCs being handled over the connection, if counted
+	// as enabled using WithActiveStreamsTag.
+	streams int32
+	// localAddr is the local address of the connection, if known.
+	localAddr net.Addr
+}
+
+type connStateKey struct{}
+
+// TagConn implements stats.Handler. It tags the connection with its state, found in the
+// context of the RPCs received over it.
+func (h *serverStatsHandler) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	state := new(connState)
+	if info != nil {
+		state.localAddr = info.LocalAddr
+	}
+	return context.WithValue(ctx, connStateKey{}, state)
 }
 
 // HandleConn implements stats.Handler.
-func (h *serverStatsHandler) HandleConn(_ context.Context, _ stats.ConnStats) {}
+func (h *serverStatsHandler) HandleConn(ctx context.Context, cs stats.ConnStats) {
+	if _, ok := cs.(*stats.ConnEnd); !ok {
+		return
+	}
+	if state, ok := ctx.Value(connStateKey{}).(*connState); ok {
+		atomic.StoreUint32(&state.ended, 1)
+	}
+}
+
+// withNewConnectionTag tags span with "grpc.new_connection" if it is the span of the first
+// RPC received over the connection of ctx.
+func withNewConnectionTag(ctx context.Context, span ddtrace.Span) {
+	state, ok := ctx.Value(connStateKey{}).(*connState)
+	if !ok || atomic.LoadUint32(&state.ended) == 1 {
+		return
+	}
+	if atomic.AddUint32(&state.rpcs, 1) == 1 {
+		span.SetTag(tagNewConnection, true)
+	}
+}
+
+// withServerAddressTags tags span with the local address of the connection of ctx, if known.
+func withServerAddressTags(ctx context.Context, span ddtrace.Span) {
+	state, ok := ctx.Value(connStateKey{}).(*connState)
+	if !ok || state.localAddr == nil {
+		return
+	}
+	addr := state.localAddr.String()
+	if _, isTCP := state.localAddr.(*net.TCPAddr); isTCP {
+		if host, port, err := net.SplitHostPort(addr); err == nil {
+			span.SetTag(tagServerAddress, host)
+			span.SetTag(tagServerPort, port)
+			return
+		}
+	}
+	if addr != "" {
+		span.SetTag(tagServerAddress, addr)
+	}
+}
+
+type activeStreamKey struct{}
+
+// withActiveStreamsTag counts the RPC of ctx as active on its connection until it ends, and tags
+// span with the number of RPCs active on the connection, including this one, in the
+// "grpc.conn.active_streams" tag.
+func withActiveStreamsTag(ctx context.Context, span ddtrace.Span) context.Context {
+	state, ok := ctx.Value(connStateKey{}).(*connState)
+	if !ok {
+		return ctx
+	}
+	span.SetTag(tagConnActiveStreams, int(atomic.AddInt32(&state.streams, 1)))
+	return context.WithValue(ctx, activeStreamKey{}, state)
+}
+
+// endActiveStream stops counting the RPC of ctx as active on its connection, if counted by
+// withActiveStreamsTag.
+func endActiveStream(ctx context.Context) {
+	if state, ok := ctx.Value(activeStreamKey{}).(*connState); ok {
+		atomic.AddInt32(&state.streams, -1)
+	}
+}
@@ -82,3 +82,126 @@ func newServerStatsHandlerTestServer(statsHandler stats.Handler) (*rig, error) {
 		client:        NewFixtureClient(conn),
 	}, nil
 }
+
+func TestServerStatsHandlerNewConnection(t *testing.T) {
+	rig, err := newServerStatsHandlerTestServer(NewServerStatsHandler())
+	if err != nil {
+		t.Fatalf("failed to start test server: %s", err)
+	}
+	defer rig.Close()
+
+	mt := mocktracer.Start()
+	defer mt.Stop()
+	ping := func(client FixtureClient) interface{} {
+		mt.Reset()
+		_, err := client.Ping(context.Background(), &FixtureRequest{Name: "name"})
+		assert.NoError(t, err)
+		waitForSpans(mt, 1, time.Second)
+		spans := mt.FinishedSpans()
+		assert.Len(t, spans, 1)
+		return spans[0].Tag(tagNewConnection)
+	}
+
+	assert.Equal(t, true, ping(rig.client))
+	assert.Nil(t, ping(rig.client))
+
+	// a fresh dial opens a new connection
+	conn, err := grpc.Dial(rig.listener.Addr().String(), grpc.WithInsecure())
+	assert.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, true, ping(NewFixtureClient(conn)))
+	assert.Nil(t, ping(NewFixtureClient(conn)))
+}
+
+func TestServerStatsHandlerActiveStreams(t *testing.T) {
+	rig, err := newServerStatsHandlerTestServer(NewServerStatsHandler(WithActiveStreamsTag()))
+	if err != nil {
+		t.Fatalf("failed to start test server: %s", err)
+	}
+	defer rig.Close()
+
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	// open concurrent streams over the same connection, waiting for each of them to be
+	// handled by the server before opening the next one.
+	var streams []Fixture_StreamPingClient
+	for i := 0; i < 3; i++ {
+		stream, err := rig.client.StreamPing(context.Background())
+		assert.NoError(t, err)
+		assert.NoError(t, stream.Send(&FixtureRequest{Name: "pass"}))
+		_, err = stream.Recv()
+		assert.NoError(t, err)
+		streams = append(streams, stream)
+	}
+	for _, stream := range streams {
+		assert.NoError(t, stream.Send(&FixtureRequest{Name: "break"}))
+		_, err := stream.Recv()
+		assert.NoError(t, err)
+	}
+	waitForSpans(mt, len(streams), time.Second)
+	spans := mt.FinishedSpans()
+	assert.Len(t, spans, len(streams))
+	var counts []interface{}
+	for _, s := range spans {
+		counts = append(counts, s.Tag(tagConnActiveStreams))
+	}
+	assert.ElementsMatch(t, []interface{}{1, 2, 3}, counts)
+
+	// the count drops once the streams end
+	mt.Reset()
+	_, err = rig.client.Ping(context.Background(), &FixtureRequest{Name: "name"})
+	assert.NoError(t, err)
+	waitForSpans(mt, 1, time.Second)
+	spans = mt.FinishedSpans()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, 1, spans[0].Tag(tagConnActiveStreams))
+}
+
+func TestServerStatsHandlerServerAddressTags(t *testing.T) {
+	rig, err := newServerStatsHandlerTestServer(NewServerStatsHandler(WithServerAddressTags()))
+	if err != nil {
+		t.Fatalf("failed to start test server: %s", err)
+	}
+	defer rig.Close()
+
+	// serve on a second listener
+	li, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go rig.server.Serve(li)
+	conn, err := grpc.Dial(li.Addr().String(), grpc.WithInsecure())
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	mt := mocktracer.Start()
+	defer mt.Stop()
+	for _, tt := range []struct {
+		client   FixtureClient
+		listener net.Listener
+	}{
+		{client: rig.client, listener: rig.listener},
+		{client: NewFixtureClient(conn), listener: li},
+	} {
+		mt.Reset()
+		_, err := tt.client.Ping(context.Background(), &FixtureRequest{Name: "name"})
+		assert.NoError(t, err)
+		waitForSpans(mt, 1, time.Second)
+		spans := mt.FinishedSpans()
+		assert.Len(t, spans, 1)
+		host, port, _ := net.SplitHostPort(tt.listener.Addr().String())
+		assert.Equal(t, host, spans[0].Tag(tagServerAddress))
+		assert.Equal(t, port, spans[0].Tag(tagServerPort))
+	}
+
+	t.Run("unknown", func(t *testing.T) {
+		mt.Reset()
+		ctx := NewServerStatsHandler().TagConn(context.Background(), nil)
+		span, _ := tracer.StartSpanFromContext(ctx, "grpc.server")
+		withServerAddressTags(ctx, span)
+		span.Finish()
+		spans := mt.FinishedSpans()
+		assert.Len(t, spans, 1)
+		assert.Nil(t, spans[0].Tag(tagServerAddress))
+		assert.Nil(t, spans[0].Tag(tagServerPort))
+	})
+}
@@ -8,10 +8,78 @@ package grpc
 // Tags used for gRPC
 const (
 	tagMethodName     = "grpc.method.name"
+	tagMethodFull     = "grpc.method.full"
 	tagMethodKind     = "grpc.method.kind"
 	tagCode           = "grpc.code"
 	tagMetadataPrefix = "grpc.metadata."
 	tagRequest        = "grpc.request"
+	tagRetries        = "grpc.retries"
+	tagRetryCodes     = "grpc.retry_codes"
+	tagPeerAddress    = "grpc.peer.address"
+	tagUserAgent      = "grpc.user_agent"
+	tagTransport      = "grpc.transport"
+	tagProtocol       = "grpc.protocol"
+
+	// tagTrailerPrefix prefixes the tags holding the trailer metadata sent by servers.
+	tagTrailerPrefix = "grpc.response.trailer."
+
+	// tagTimeout holds the time remaining until the deadline of a call, in milliseconds,
+	// when it is sent by the client. tagTimeoutRemaining holds the time remaining until
+	// the same deadline, as propagated to the server, when the server starts handling it.
+	tagTimeout          = "grpc.timeout_ms"
+	tagTimeoutRemaining = "grpc.timeout_remaining_ms"
+
+	// tagMethodConfigTimeout holds the timeout applied to a method by the service config
+	// of the client connection, in milliseconds.
+	tagMethodConfigTimeout = "grpc.method_config.timeout"
+
+	// tagNewConnection is set on the span of the first RPC received over a connection.
+	tagNewConnection = "grpc.new_connection"
+
+	// tagConnActiveStreams holds the number of RPCs being handled over the connection of
+	// a server span when it starts, including its own.
+	tagConnActiveStreams = "grpc.conn.active_streams"
+
+	// tagServerAddress and tagServerPort hold the local address of the listener which
+	// accepted the connection of a server span.
+	tagServerAddress = "grpc.server.address"
+	tagServerPort    = "grpc.server.port"
+
+	// tagMessageDirection, tagMessageSeq and tagMessageSize hold the direction, the sequence
+	// number in that direction and the size in bytes of a streamed message.
+	tagMessageDirection = "grpc.message.direction"
+	tagMessageSeq       = "grpc.message.seq"
+	tagMessageSize      = "grpc.message.size"
+
+	// tagDeadlineSource holds the source of the deadline of a client span, either
+	// deadlineSourceLocal or deadlineSourceInherited.
+	tagDeadlineSource = "grpc.deadline.source"
+
+	// tagPropagationError holds the error returned when extracting the span
+	// context from the metadata of an incoming request.
+	tagPropagationError = "_dd.propagation_error"
+
+	tagSerializeDuration   = "grpc.serialize.duration"
+	tagDeserializeDuration = "grpc.deserialize.duration"
+)
+
+// Transports reported in the "grpc.transport" tag.
+const (
+	transportTCP    = "tcp"
+	transportUDS    = "uds"
+	transportInProc = "inproc"
+)
+
+// Values of the grpc.protocol tag, other than the ones negotiated using ALPN.
+const (
+	protocolH2  = "h2"
+	protocolH2C = "h2c"
+)
+
+// Values of the grpc.deadline.source tag.
+const (
+	deadlineSourceLocal     = "local"
+	deadlineSourceInherited = "inherited"
 )
 
 const (
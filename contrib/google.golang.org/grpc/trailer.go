@@ -0,0 +1,69 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package grpc
+
+import (
+	"strings"
+	"sync"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+
+	context "golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// trailerRecorder is a grpc.ServerTransportStream recording the trailer metadata set by
+// the handler of an RPC, so that it can be tagged once the handler returns.
+type trailerRecorder struct {
+	grpc.ServerTransportStream
+
+	mu      sync.Mutex
+	trailer metadata.MD
+}
+
+// SetTrailer implements grpc.ServerTransportStream.
+func (r *trailerRecorder) SetTrailer(md metadata.MD) error {
+	r.record(md)
+	return r.ServerTransportStream.SetTrailer(md)
+}
+
+// record adds md to the recorded trailer metadata.
+func (r *trailerRecorder) record(md metadata.MD) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trailer = metadata.Join(r.trailer, md)
+}
+
+// setTags tags span with the values of the recorded trailer metadata under keys.
+func (r *trailerRecorder) setTags(span ddtrace.Span, keys []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	withTrailerTags(span, r.trailer, keys)
+}
+
+// withTrailerRecorder returns a copy of ctx in which the trailer metadata set by the handler
+// is recorded, if enabled using WithTrailerMetadataTags. The returned recorder is nil otherwise.
+func withTrailerRecorder(ctx context.Context, cfg *config) (context.Context, *trailerRecorder) {
+	if len(cfg.trailerMetadataTags) == 0 {
+		return ctx, nil
+	}
+	stream := grpc.ServerTransportStreamFromContext(ctx)
+	if stream == nil {
+		return ctx, nil
+	}
+	r := &trailerRecorder{ServerTransportStream: stream}
+	return grpc.NewContextWithServerTransportStream(ctx, r), r
+}
+
+// withTrailerTags tags span with the values found in the trailer metadata md under keys.
+func withTrailerTags(span ddtrace.Span, md metadata.MD, keys []string) {
+	for _, k := range keys {
+		if vs := md.Get(k); len(vs) > 0 {
+			span.SetTag(tagTrailerPrefix+k, strings.Join(vs, ","))
+		}
+	}
+}
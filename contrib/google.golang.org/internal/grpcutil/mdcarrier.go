@@ -15,15 +15,18 @@ import (
 
 // MDCarrier implements tracer.TextMapWriter and tracer.TextMapReader on top
 // of gRPC's metadata, allowing it to be used as a span context carrier for
-// distributed tracing.
+// distributed tracing. Since it exposes all the keys of the metadata, it can be
+// used with any of the propagation styles configured in the tracer, such as
+// W3C trace context or B3, and not only with the Datadog headers.
 type MDCarrier metadata.MD
 
 var _ tracer.TextMapWriter = (*MDCarrier)(nil)
 var _ tracer.TextMapReader = (*MDCarrier)(nil)
 
-// Get will return the first entry in the metadata at the given key.
+// Get will return the first entry in the metadata at the given key. Key will be lowercased to
+// match the metadata implementation.
 func (mdc MDCarrier) Get(key string) string {
-	if m := mdc[key]; len(m) > 0 {
+	if m := mdc[strings.ToLower(key)]; len(m) > 0 {
 		return m[0]
 	}
 	return ""
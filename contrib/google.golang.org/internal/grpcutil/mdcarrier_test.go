@@ -44,6 +44,7 @@ func TestMDCarrierGet(t *testing.T) {
 
 	assert.Equal("v1", mdc.Get("k1"))
 	assert.Equal("v2", mdc.Get("k2"))
+	assert.Equal("v1", mdc.Get("K1"))
 }
 
 func TestMDCarrierForeachKey(t *testing.T) {
@@ -8,6 +8,7 @@ package http // import "gopkg.in/DataDog/dd-trace-go.v1/contrib/net/http"
 
 import (
 	"net/http"
+	"strings"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
@@ -23,6 +24,15 @@ type ServeMux struct {
 // NewServeMux allocates and returns an http.ServeMux augmented with the
 // global tracer.
 func NewServeMux(opts ...Option) *ServeMux {
+	return WrapServeMux(http.NewServeMux(), opts...)
+}
+
+// WrapServeMux returns a ServeMux tracing all the requests served by the given
+// http.ServeMux. The resource name of the spans is made of the request method and
+// the pattern the request matched, such that requests to parametrized routes like
+// "/users/{id}" with Go 1.22+ enhanced routing are grouped under the same resource.
+// Requests matching no pattern use the request method as the resource name.
+func WrapServeMux(mux *http.ServeMux, opts ...Option) *ServeMux {
 	cfg := new(config)
 	defaults(cfg)
 	for _, fn := range opts {
@@ -32,7 +42,7 @@ func NewServeMux(opts ...Option) *ServeMux {
 	cfg.spanOpts = append(cfg.spanOpts, tracer.Tag(ext.Component, "net/http"))
 	log.Debug("contrib/net/http: Configuring ServeMux: %#v", cfg)
 	return &ServeMux{
-		ServeMux: http.NewServeMux(),
+		ServeMux: mux,
 		cfg:      cfg,
 	}
 }
@@ -47,10 +57,14 @@ func (mux *ServeMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// get the resource associated to this request
-	_, route := mux.Handler(r)
+	_, pattern := mux.Handler(r)
+	route := patternRoute(pattern)
 	resource := mux.cfg.resourceNamer(r)
 	if resource == "" {
-		resource = r.Method + " " + route
+		resource = r.Method
+		if route != "" {
+			resource += " " + route
+		}
 	}
 
 	TraceAndServe(mux.ServeMux, w, r, &ServeConfig{
@@ -61,6 +75,16 @@ func (mux *ServeMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// patternRoute returns the route part of the given ServeMux pattern. Since Go 1.22,
+// patterns may be prefixed with a method, e.g. "GET /users/{id}", which is removed
+// as the request method is already part of the resource name.
+func patternRoute(pattern string) string {
+	if i := strings.IndexAny(pattern, " \t"); i >= 0 {
+		return strings.TrimLeft(pattern[i+1:], " \t")
+	}
+	return pattern
+}
+
 // WrapHandler wraps an http.Handler with tracing using the given service and resource.
 // If the WithResourceNamer option is provided as part of opts, it will take precedence over the resource argument.
 func WrapHandler(h http.Handler, service, resource string, opts ...Option) http.Handler {
@@ -0,0 +1,60 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+//go:build go1.22
+// +build go1.22
+
+// The module targets an older Go version, enable the Go 1.22 enhanced routing.
+//go:debug httpmuxgo121=0
+
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+)
+
+func TestServeMuxPatternResource(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}", handler200)
+	mux.HandleFunc("/files/{path...}", handler200)
+	mux.HandleFunc("/200", handler200)
+	router := WrapServeMux(mux, WithServiceName("my-service"))
+
+	for _, tt := range []struct {
+		method, url string
+		code        int
+		resource    string
+		route       string
+	}{
+		{"GET", "/users/1", 200, "GET /users/{id}", "/users/{id}"},
+		{"GET", "/users/2", 200, "GET /users/{id}", "/users/{id}"},
+		{"POST", "/files/a/b.txt", 200, "POST /files/{path...}", "/files/{path...}"},
+		{"GET", "/200", 200, "GET /200", "/200"},
+		{"GET", "/unknown", 404, "GET", ""},
+	} {
+		t.Run(tt.method+tt.url, func(t *testing.T) {
+			mt := mocktracer.Start()
+			defer mt.Stop()
+
+			r := httptest.NewRequest(tt.method, tt.url, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			assert.Equal(t, tt.code, w.Code)
+
+			spans := mt.FinishedSpans()
+			require.Len(t, spans, 1)
+			assert.Equal(t, tt.resource, spans[0].Tag(ext.ResourceName))
+			assert.Equal(t, tt.route, spans[0].Tag(ext.HTTPRoute))
+		})
+	}
+}
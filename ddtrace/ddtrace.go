@@ -98,6 +98,10 @@ type FinishConfig struct {
 	// finishing.
 	Error error
 
+	// ErrorFlag marks the span as errored before finishing, without an error value
+	// holding its message, type and stack. It is ignored when Error is set.
+	ErrorFlag bool
+
 	// NoDebugStack will prevent any set errors from generating an attached stack trace tag.
 	NoDebugStack bool
 
@@ -28,6 +28,7 @@ const (
 	DBSystemMySQL              = "mysql"
 	DBSystemPostgreSQL         = "postgresql"
 	DBSystemMicrosoftSQLServer = "mssql"
+	DBSystemSQLite             = "sqlite"
 	// DBSystemOtherSQL is used for other SQL databases not listed above.
 	DBSystemOtherSQL      = "other_sql"
 	DBSystemElasticsearch = "elasticsearch"
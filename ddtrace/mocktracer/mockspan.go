@@ -29,6 +29,11 @@ type Span interface {
 	// ParentID returns the span's parent ID.
 	ParentID() uint64
 
+	// Parent returns the span's parent, looking it up among the finished spans of
+	// the mock tracer. It returns false if the span has no parent or if the parent
+	// has not finished.
+	Parent() (Span, bool)
+
 	// StartTime returns the time when the span has started.
 	StartTime() time.Time
 
@@ -47,6 +52,9 @@ type Span interface {
 	// Context returns the span's SpanContext.
 	Context() ddtrace.SpanContext
 
+	// Events returns the events added to the span, in order.
+	Events() []Event
+
 	// Stringer allows pretty-printing the span's fields for debugging.
 	fmt.Stringer
 }
@@ -96,10 +104,21 @@ func newSpan(t *mocktracer, operationName string, cfg *ddtrace.StartSpanConfig)
 	return s
 }
 
+// Event is an event added to a span using tracer.AddEvent.
+type Event struct {
+	// Name is the name of the event.
+	Name string
+	// Time is the time at which the event was added.
+	Time time.Time
+	// Attributes holds the attributes of the event.
+	Attributes map[string]interface{}
+}
+
 type mockspan struct {
 	sync.RWMutex // guards below fields
 	name         string
 	tags         map[string]interface{}
+	events       []Event
 	finishTime   time.Time
 	finished     bool
 
@@ -155,12 +174,45 @@ func (s *mockspan) Tags() map[string]interface{} {
 	return cp
 }
 
+// AddEvent records an event with the given name and attributes on the span.
+func (s *mockspan) AddEvent(name string, attrs map[string]interface{}) {
+	e := Event{Name: name, Time: time.Now(), Attributes: make(map[string]interface{}, len(attrs))}
+	for k, v := range attrs {
+		e.Attributes[k] = v
+	}
+	s.Lock()
+	defer s.Unlock()
+	if s.finished {
+		return
+	}
+	s.events = append(s.events, e)
+}
+
+func (s *mockspan) Events() []Event {
+	s.RLock()
+	defer s.RUnlock()
+	// copy
+	return append([]Event(nil), s.events...)
+}
+
 func (s *mockspan) TraceID() uint64 { return s.context.traceID }
 
 func (s *mockspan) SpanID() uint64 { return s.context.spanID }
 
 func (s *mockspan) ParentID() uint64 { return s.parentID }
 
+func (s *mockspan) Parent() (Span, bool) {
+	if s.parentID == 0 || s.tracer == nil {
+		return nil, false
+	}
+	for _, p := range s.tracer.FinishedSpans() {
+		if p.SpanID() == s.parentID && p.TraceID() == s.TraceID() {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
 func (s *mockspan) OperationName() string {
 	s.RLock()
 	defer s.RUnlock()
@@ -201,6 +253,8 @@ func (s *mockspan) Finish(opts ...ddtrace.FinishOption) {
 	}
 	if cfg.Error != nil {
 		s.SetTag(ext.Error, cfg.Error)
+	} else if cfg.ErrorFlag {
+		s.SetTag(ext.Error, true)
 	}
 	if cfg.NoDebugStack {
 		s.SetTag(ext.ErrorStack, "<debug stack disabled>")
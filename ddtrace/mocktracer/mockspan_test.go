@@ -178,6 +178,15 @@ func TestSpanFinish(t *testing.T) {
 	assert.Equal(want, s.Tag(ext.Error))
 }
 
+func TestSpanFinishWithErrorFlag(t *testing.T) {
+	s := basicSpan("http.request")
+	s.Finish(tracer.WithErrorFlag(true))
+
+	assert := assert.New(t)
+	assert.Equal(true, s.Tag(ext.Error))
+	assert.Nil(s.Tag(ext.ErrorMsg))
+}
+
 func TestSpanFinishTwice(t *testing.T) {
 	s := basicSpan("http.request")
 	wantError := errors.New("some error")
@@ -258,3 +267,44 @@ func TestSetUser(t *testing.T) {
 	})
 
 }
+
+func TestSpanEvents(t *testing.T) {
+	assert := assert.New(t)
+	s := basicSpan("http.request")
+	tracer.AddEvent(s, "cache.miss", map[string]interface{}{"key": "user:1"})
+	s.AddEvent("retry", nil)
+	s.Finish()
+	s.AddEvent("ignored", nil)
+
+	events := s.Events()
+	require.Len(t, events, 2)
+	assert.Equal("cache.miss", events[0].Name)
+	assert.Equal(map[string]interface{}{"key": "user:1"}, events[0].Attributes)
+	assert.Equal("retry", events[1].Name)
+	assert.Empty(events[1].Attributes)
+	assert.False(events[0].Time.IsZero())
+	assert.False(events[1].Time.Before(events[0].Time))
+}
+
+func TestSpanParent(t *testing.T) {
+	mt := newMockTracer()
+	parent := mt.StartSpan("http.request")
+	child := mt.StartSpan("db.query", tracer.ChildOf(parent.Context())).(Span)
+	child.(ddtrace.Span).Finish()
+
+	// the parent has not finished yet
+	_, ok := child.Parent()
+	assert.False(t, ok)
+
+	parent.Finish()
+	p, ok := child.Parent()
+	assert.True(t, ok)
+	assert.Equal(t, parent, p)
+
+	// remote parents are not resolved
+	remote := mt.StartSpan("grpc.server", tracer.ChildOf(&spanContext{traceID: 1, spanID: 2})).(Span)
+	remote.(ddtrace.Span).Finish()
+	assert.Equal(t, uint64(2), remote.ParentID())
+	_, ok = remote.Parent()
+	assert.False(t, ok)
+}
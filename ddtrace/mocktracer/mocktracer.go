@@ -33,6 +33,10 @@ type Tracer interface {
 	// FinishedSpans returns the set of finished spans.
 	FinishedSpans() []Span
 
+	// Trace returns the finished spans having the given trace ID, in the order
+	// they finished.
+	Trace(traceID uint64) []Span
+
 	// Reset resets the spans and services recorded in the tracer. This is
 	// especially useful when running tests in a loop, where a clean start
 	// is desired for FinishedSpans calls.
@@ -102,6 +106,18 @@ func (t *mocktracer) FinishedSpans() []Span {
 	return t.finishedSpans
 }
 
+func (t *mocktracer) Trace(traceID uint64) []Span {
+	t.RLock()
+	defer t.RUnlock()
+	var spans []Span
+	for _, s := range t.finishedSpans {
+		if s.TraceID() == traceID {
+			spans = append(spans, s)
+		}
+	}
+	return spans
+}
+
 func (t *mocktracer) Reset() {
 	t.Lock()
 	defer t.Unlock()
@@ -94,6 +94,33 @@ func TestTracerFinishedSpans(t *testing.T) {
 	assert.Equal(t, 2, found)
 }
 
+func TestTracerTrace(t *testing.T) {
+	mt := newMockTracer()
+	root := mt.StartSpan("http.request")
+	child := mt.StartSpan("db.query", tracer.ChildOf(root.Context()))
+	grandchild := mt.StartSpan("db.fetch", tracer.ChildOf(child.Context()))
+	other := mt.StartSpan("http.request")
+	grandchild.Finish()
+	other.Finish()
+	child.Finish()
+	assert.Equal(t, []Span{grandchild.(Span), child.(Span)}, mt.Trace(root.Context().TraceID()))
+	root.Finish()
+
+	trace := mt.Trace(root.Context().TraceID())
+	assert.Equal(t, []Span{grandchild.(Span), child.(Span), root.(Span)}, trace)
+	assert.Equal(t, []Span{other.(Span)}, mt.Trace(other.Context().TraceID()))
+	assert.Empty(t, mt.Trace(0))
+
+	p, ok := grandchild.(Span).Parent()
+	assert.True(t, ok)
+	assert.Equal(t, child, p)
+	p, ok = p.Parent()
+	assert.True(t, ok)
+	assert.Equal(t, root, p)
+	_, ok = p.Parent()
+	assert.False(t, ok)
+}
+
 func TestTracerOpenSpans(t *testing.T) {
 	mt := newMockTracer()
 	assert.Empty(t, mt.OpenSpans())
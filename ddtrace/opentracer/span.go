@@ -82,6 +82,9 @@ func (s *span) SetOperationName(operationName string) opentracing.Span {
 }
 
 func (s *span) SetTag(key string, value interface{}) opentracing.Span {
+	if kind, ok := spanKind(key, value); ok {
+		value = kind
+	}
 	s.Span.SetTag(key, value)
 	return s
 }
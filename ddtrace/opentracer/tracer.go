@@ -31,6 +31,7 @@ import (
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 
 	opentracing "github.com/opentracing/opentracing-go"
+	otext "github.com/opentracing/opentracing-go/ext"
 )
 
 // New creates, instantiates and returns an Opentracing compatible version of the
@@ -61,6 +62,10 @@ func (t *opentracer) StartSpan(operationName string, options ...opentracing.Star
 		}
 	}
 	for k, v := range sso.Tags {
+		if kind, ok := spanKind(k, v); ok {
+			opts = append(opts, tracer.SpanKind(kind))
+			continue
+		}
 		opts = append(opts, tracer.Tag(k, v))
 	}
 	return &span{
@@ -119,3 +124,18 @@ func translateError(err error) error {
 		return err
 	}
 }
+
+// spanKind returns the span kind held by value when key is the Opentracing span kind
+// tag, so that it is stored as a plain string like the one set by our integrations.
+func spanKind(key string, value interface{}) (kind string, ok bool) {
+	if key != string(otext.SpanKind) {
+		return "", false
+	}
+	switch v := value.(type) {
+	case otext.SpanKindEnum:
+		return string(v), true
+	case string:
+		return v, true
+	}
+	return "", false
+}
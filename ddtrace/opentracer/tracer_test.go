@@ -10,10 +10,13 @@ import (
 	"testing"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 
 	"github.com/opentracing/opentracing-go"
+	otext "github.com/opentracing/opentracing-go/ext"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -111,3 +114,31 @@ func TestExtractError(t *testing.T) {
 		})
 	}
 }
+
+func TestSpanKind(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+	ot := &opentracer{internal.GetGlobalTracer()}
+
+	for kind, want := range map[otext.SpanKindEnum]string{
+		otext.SpanKindRPCClientEnum:   ext.SpanKindClient,
+		otext.SpanKindRPCServerEnum:   ext.SpanKindServer,
+		otext.SpanKindProducerEnum:    ext.SpanKindProducer,
+		otext.SpanKindConsumerEnum:    ext.SpanKindConsumer,
+		otext.SpanKindEnum("unknown"): "unknown",
+	} {
+		t.Run(want, func(t *testing.T) {
+			mt.Reset()
+			ot.StartSpan("op", opentracing.Tag{Key: string(otext.SpanKind), Value: kind}).Finish()
+			sp := ot.StartSpan("op")
+			otext.SpanKind.Set(sp, kind)
+			sp.Finish()
+
+			spans := mt.FinishedSpans()
+			assert.Len(t, spans, 2)
+			for _, s := range spans {
+				assert.Equal(t, want, s.Tag(ext.SpanKind))
+			}
+		})
+	}
+}
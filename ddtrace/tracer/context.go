@@ -7,15 +7,29 @@ package tracer
 
 import (
 	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"sync/atomic"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/samplernames"
 )
 
 type contextKey struct{}
 
+// keyContextCancelled is set on spans started with StartSpanWithContextCancel which
+// were finished because their context was cancelled.
+const keyContextCancelled = "context.cancelled"
+
 var activeSpanKey = contextKey{}
 
+type remoteParentContextKey struct{}
+
+var remoteParentKey = remoteParentContextKey{}
+
 // ContextWithSpan returns a copy of the given context which includes the span s.
 func ContextWithSpan(ctx context.Context, s Span) context.Context {
 	return context.WithValue(ctx, activeSpanKey, s)
@@ -35,9 +49,47 @@ func SpanFromContext(ctx context.Context) (Span, bool) {
 	return &internal.NoopSpan{}, false
 }
 
+// SpanContextSnapshot returns the context of the span contained in the given context, or
+// the parent set using WithRemoteParent if it contains no span. It returns nil if there is
+// neither. Together with WithRemoteParent, it allows carrying the active span to another
+// goroutine without carrying the whole context, e.g. when dispatching work to a pool of
+// workers which run with their own context:
+//
+//	job.parent = tracer.SpanContextSnapshot(ctx)
+//	// in the worker
+//	ctx := tracer.WithRemoteParent(workerCtx, job.parent)
+//	span, ctx := tracer.StartSpanFromContext(ctx, "job.run")
+//
+// Passing the context itself, along with its deadline, cancellation and values, should be
+// preferred whenever possible.
+func SpanContextSnapshot(ctx context.Context) ddtrace.SpanContext {
+	if ctx == nil {
+		return nil
+	}
+	if s, ok := SpanFromContext(ctx); ok {
+		return s.Context()
+	}
+	if sctx, ok := ctx.Value(remoteParentKey).(ddtrace.SpanContext); ok {
+		return sctx
+	}
+	return nil
+}
+
+// WithRemoteParent returns a copy of the given context which makes sctx, as captured using
+// SpanContextSnapshot, the parent of the spans started from it using StartSpanFromContext,
+// unless the context contains a span. The parent is not a span of the returned context,
+// which SpanFromContext does not report. It returns ctx unchanged if sctx is nil.
+func WithRemoteParent(ctx context.Context, sctx ddtrace.SpanContext) context.Context {
+	if sctx == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, remoteParentKey, sctx)
+}
+
 // StartSpanFromContext returns a new span with the given operation name and options. If a span
-// is found in the context, it will be used as the parent of the resulting span. If the ChildOf
-// option is passed, it will only be used as the parent if there is no span found in `ctx`.
+// is found in the context, it will be used as the parent of the resulting span, as will a parent
+// set using WithRemoteParent otherwise. If the ChildOf option is passed, it will only be used as
+// the parent if there is no such parent found in `ctx`.
 func StartSpanFromContext(ctx context.Context, operationName string, opts ...StartSpanOption) (Span, context.Context) {
 	// copy opts in case the caller reuses the slice in parallel
 	// we will add at least 1, at most 2 items
@@ -49,6 +101,8 @@ func StartSpanFromContext(ctx context.Context, operationName string, opts ...Sta
 		ctx = context.Background()
 	} else if s, ok := SpanFromContext(ctx); ok {
 		optsLocal = append(optsLocal, ChildOf(s.Context()))
+	} else if sctx, ok := ctx.Value(remoteParentKey).(ddtrace.SpanContext); ok {
+		optsLocal = append(optsLocal, ChildOf(sctx))
 	}
 	optsLocal = append(optsLocal, withContext(ctx))
 	s := StartSpan(operationName, optsLocal...)
@@ -60,3 +114,109 @@ func StartSpanFromContext(ctx context.Context, operationName string, opts ...Sta
 	}
 	return s, ContextWithSpan(ctx, s)
 }
+
+// StartSpanWithContextCancel is like StartSpanFromContext, but the returned span is also
+// finished once ctx is done, such that the lifecycle of spans of cancellable tasks can be
+// tied to their context. The span is tagged with "context.cancelled" set to true when
+// finished because ctx was cancelled. It is safe to call Finish on the span before or after
+// ctx is done, the first call having effect. ctx must be cancelled, or the span finished, to
+// release the resources associated with it. Spans which are not created by this tracer, such
+// as the ones of a mock tracer or when the tracer is not started, are not finished with ctx.
+func StartSpanWithContextCancel(ctx context.Context, operationName string, opts ...StartSpanOption) (Span, context.Context) {
+	s, sctx := StartSpanFromContext(ctx, operationName, opts...)
+	if ctx == nil || ctx.Done() == nil {
+		// the context can never be done
+		return s, sctx
+	}
+	sp, ok := s.(*span)
+	if !ok {
+		// there is no way to know when other spans are finished, and so to stop watching ctx
+		return s, sctx
+	}
+	finished := sp.finishedChan()
+	go func() {
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.Canceled) {
+				s.SetTag(keyContextCancelled, true)
+			}
+			s.Finish()
+		case <-finished:
+		}
+	}()
+	return s, sctx
+}
+
+// OTelSpanContext holds the identifiers of an OpenTelemetry span, as found in a context by
+// the function set using SetOTelSpanContextFunc.
+type OTelSpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+}
+
+// otelSpanContextFunc holds the func(context.Context) (OTelSpanContext, bool) set using
+// SetOTelSpanContextFunc.
+var otelSpanContextFunc atomic.Value
+
+// SetOTelSpanContextFunc sets the function used by StartSpanFromOTelContext to find the
+// OpenTelemetry span of a context, which reports whether one was found. Since the tracer does
+// not depend on the OpenTelemetry API, applications using it set it as follows:
+//
+//	tracer.SetOTelSpanContextFunc(func(ctx context.Context) (tracer.OTelSpanContext, bool) {
+//		sc := trace.SpanContextFromContext(ctx)
+//		return tracer.OTelSpanContext{
+//			TraceID: sc.TraceID(),
+//			SpanID:  sc.SpanID(),
+//			Sampled: sc.IsSampled(),
+//		}, sc.IsValid()
+//	})
+//
+// Passing nil unsets it.
+func SetOTelSpanContextFunc(fn func(ctx context.Context) (OTelSpanContext, bool)) {
+	otelSpanContextFunc.Store(fn)
+}
+
+// StartSpanFromOTelContext is like StartSpanFromContext, but the OpenTelemetry span found in the
+// context, if any, is used as the parent of the resulting span when the context does not contain
+// a span of this tracer. This links the spans started by code migrated to this tracer to the
+// ones of code still using the OpenTelemetry SDK, without using a bridge between the two. As
+// with W3C trace context propagation, the lower 64 bits of the OpenTelemetry trace ID are used
+// as the trace ID, and unsampled OpenTelemetry spans lead to dropping the trace. It requires
+// SetOTelSpanContextFunc to have been called, and is equivalent to StartSpanFromContext otherwise.
+func StartSpanFromOTelContext(ctx context.Context, operationName string, opts ...StartSpanOption) (Span, context.Context) {
+	if ctx != nil {
+		if sctx := otelParent(ctx); sctx != nil {
+			ctx = WithRemoteParent(ctx, sctx)
+		}
+	}
+	return StartSpanFromContext(ctx, operationName, opts...)
+}
+
+// otelParent returns the context of the OpenTelemetry span found in ctx using the function set
+// using SetOTelSpanContextFunc, or nil if there is none.
+func otelParent(ctx context.Context) *spanContext {
+	fn, _ := otelSpanContextFunc.Load().(func(ctx context.Context) (OTelSpanContext, bool))
+	if fn == nil {
+		return nil
+	}
+	osc, ok := fn(ctx)
+	if !ok {
+		return nil
+	}
+	sctx := &spanContext{
+		traceID: binary.BigEndian.Uint64(osc.TraceID[8:]),
+		spanID:  binary.BigEndian.Uint64(osc.SpanID[:]),
+	}
+	if sctx.traceID == 0 || sctx.spanID == 0 {
+		return nil
+	}
+	// keep the full trace ID for W3C trace context propagation
+	setPropagatingTag(sctx, w3cTraceIDTag, hex.EncodeToString(osc.TraceID[:]))
+	priority := ext.PriorityAutoReject
+	if osc.Sampled {
+		priority = ext.PriorityAutoKeep
+	}
+	sctx.setSamplingPriority(priority, samplernames.Unknown)
+	return sctx
+}
@@ -7,10 +7,15 @@ package tracer
 
 import (
 	"context"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
 )
 
@@ -107,6 +112,130 @@ func TestStartSpanFromContextRace(t *testing.T) {
 	assert.ElementsMatch(t, outputs, expectedTraceIDs)
 }
 
+func TestSpanContextSnapshot(t *testing.T) {
+	tracer, _, _, stop := startTestTracer(t)
+	defer stop()
+
+	assert.Nil(t, SpanContextSnapshot(context.Background()))
+	assert.Nil(t, SpanContextSnapshot(nil))
+	assert.Equal(t, context.Background(), WithRemoteParent(context.Background(), nil))
+
+	root, ctx := StartSpanFromContext(context.Background(), "dispatch")
+	defer root.Finish()
+	snapshot := SpanContextSnapshot(ctx)
+	assert.Equal(t, root.Context(), snapshot)
+
+	jobs := make(chan ddtrace.SpanContext)
+	children := make(chan *span)
+	go func() {
+		// the worker starts from its own context
+		for parent := range jobs {
+			ctx := WithRemoteParent(context.Background(), parent)
+			_, ok := SpanFromContext(ctx)
+			assert.False(t, ok)
+			assert.Equal(t, parent, SpanContextSnapshot(ctx))
+
+			child, ctx := StartSpanFromContext(ctx, "job.run")
+			grandchild, _ := StartSpanFromContext(ctx, "job.step")
+			grandchild.Finish()
+			child.Finish()
+			children <- child.(*span)
+			children <- grandchild.(*span)
+		}
+	}()
+	jobs <- snapshot
+	close(jobs)
+	child, grandchild := <-children, <-children
+
+	rs := root.(*span)
+	assert.Equal(t, rs.TraceID, child.TraceID)
+	assert.Equal(t, rs.SpanID, child.ParentID)
+	assert.Equal(t, rs.TraceID, grandchild.TraceID)
+	assert.Equal(t, child.SpanID, grandchild.ParentID)
+
+	// the active span takes precedence over the remote parent
+	other := tracer.StartSpan("other")
+	defer other.Finish()
+	ctx = ContextWithSpan(WithRemoteParent(context.Background(), snapshot), other)
+	assert.Equal(t, other.Context(), SpanContextSnapshot(ctx))
+	s, _ := StartSpanFromContext(ctx, "child")
+	assert.Equal(t, other.(*span).SpanID, s.(*span).ParentID)
+}
+
+// otelSpanKey is the key of the stubbed OpenTelemetry spans of contexts.
+type otelSpanKey struct{}
+
+func TestStartSpanFromOTelContext(t *testing.T) {
+	t.Setenv(headerPropagationStyleInject, "tracecontext")
+	tracer, _, _, stop := startTestTracer(t)
+	defer stop()
+
+	otelSpan := OTelSpanContext{
+		TraceID: [16]byte{0x10, 0, 0, 0, 0, 0, 0, 0, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11},
+		SpanID:  [8]byte{0x22, 0x22, 0x22, 0x22, 0x22, 0x22, 0x22, 0x22},
+		Sampled: true,
+	}
+	otelCtx := context.WithValue(context.Background(), otelSpanKey{}, otelSpan)
+
+	t.Run("unset", func(t *testing.T) {
+		s, _ := StartSpanFromOTelContext(otelCtx, "db.query")
+		defer s.Finish()
+		assert.Zero(t, s.(*span).ParentID)
+	})
+
+	SetOTelSpanContextFunc(func(ctx context.Context) (OTelSpanContext, bool) {
+		osc, ok := ctx.Value(otelSpanKey{}).(OTelSpanContext)
+		return osc, ok
+	})
+	defer SetOTelSpanContextFunc(nil)
+
+	t.Run("otel-parent", func(t *testing.T) {
+		s, ctx := StartSpanFromOTelContext(otelCtx, "db.query")
+		child, _ := StartSpanFromContext(ctx, "db.fetch")
+		child.Finish()
+		s.Finish()
+
+		ss := s.(*span)
+		assert.Equal(t, uint64(0x1111111111111111), ss.TraceID)
+		assert.Equal(t, uint64(0x2222222222222222), ss.ParentID)
+		assert.Equal(t, float64(ext.PriorityAutoKeep), ss.Metrics[keySamplingPriority])
+		assert.Equal(t, ss.TraceID, child.(*span).TraceID)
+		assert.Equal(t, ss.SpanID, child.(*span).ParentID)
+
+		// the full trace ID is propagated
+		carrier := TextMapCarrier{}
+		assert.NoError(t, tracer.Inject(child.Context(), carrier))
+		assert.Contains(t, carrier[traceparentHeader], "00-10000000000000001111111111111111-")
+	})
+
+	t.Run("not-sampled", func(t *testing.T) {
+		unsampled := otelSpan
+		unsampled.Sampled = false
+		s, _ := StartSpanFromOTelContext(context.WithValue(context.Background(), otelSpanKey{}, unsampled), "db.query")
+		s.Finish()
+		assert.Equal(t, float64(ext.PriorityAutoReject), s.(*span).Metrics[keySamplingPriority])
+	})
+
+	t.Run("no-otel-span", func(t *testing.T) {
+		s, _ := StartSpanFromOTelContext(context.Background(), "db.query")
+		s.Finish()
+		assert.Zero(t, s.(*span).ParentID)
+
+		s, _ = StartSpanFromOTelContext(nil, "db.query")
+		s.Finish()
+		assert.Zero(t, s.(*span).ParentID)
+	})
+
+	t.Run("active-span", func(t *testing.T) {
+		// the active span takes precedence over the OpenTelemetry span
+		parent, ctx := StartSpanFromContext(otelCtx, "http.request")
+		defer parent.Finish()
+		s, _ := StartSpanFromOTelContext(ctx, "db.query")
+		s.Finish()
+		assert.Equal(t, parent.(*span).SpanID, s.(*span).ParentID)
+	})
+}
+
 func TestStartSpanFromNilContext(t *testing.T) {
 	_, _, _, stop := startTestTracer(t)
 	defer stop()
@@ -125,3 +254,88 @@ func TestStartSpanFromNilContext(t *testing.T) {
 	assert.True(ok)
 	assert.Equal(child, ctxSpan)
 }
+
+func TestStartSpanWithContextCancel(t *testing.T) {
+	_, _, _, stop := startTestTracer(t)
+	defer stop()
+
+	// waitFinished waits for s to be finished and returns it.
+	waitFinished := func(t *testing.T, s Span) *span {
+		sp := s.(*span)
+		select {
+		case <-sp.finishedChan():
+		case <-time.After(time.Second * timeMultiplicator):
+			t.Fatal("span was not finished")
+		}
+		return sp
+	}
+
+	t.Run("cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		s, sctx := StartSpanWithContextCancel(ctx, "task")
+		got, ok := SpanFromContext(sctx)
+		assert.True(t, ok)
+		assert.Equal(t, s, got)
+
+		cancel()
+		sp := waitFinished(t, s)
+		sp.RLock()
+		defer sp.RUnlock()
+		assert.Equal(t, "true", sp.Meta[keyContextCancelled])
+		assert.True(t, sp.Duration > 0)
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+		s, _ := StartSpanWithContextCancel(ctx, "task")
+		sp := waitFinished(t, s)
+		sp.RLock()
+		defer sp.RUnlock()
+		assert.NotContains(t, sp.Meta, keyContextCancelled)
+	})
+
+	t.Run("finish", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		s, _ := StartSpanWithContextCancel(ctx, "task")
+		s.Finish()
+		sp := waitFinished(t, s)
+		sp.RLock()
+		duration := sp.Duration
+		sp.RUnlock()
+
+		// cancelling after the span finished has no effect
+		cancel()
+		time.Sleep(10 * time.Millisecond)
+		sp.RLock()
+		defer sp.RUnlock()
+		assert.Equal(t, duration, sp.Duration)
+		assert.NotContains(t, sp.Meta, keyContextCancelled)
+	})
+
+	t.Run("background", func(t *testing.T) {
+		s, _ := StartSpanWithContextCancel(context.Background(), "task")
+		sp := s.(*span)
+		select {
+		case <-sp.finishedChan():
+			t.Fatal("span should not be finished")
+		case <-time.After(10 * time.Millisecond):
+		}
+		s.Finish()
+		waitFinished(t, s)
+	})
+}
+
+func TestStartSpanWithContextCancelNoop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	before := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		s, _ := StartSpanWithContextCancel(ctx, "task")
+		_, ok := s.(internal.NoopSpan)
+		require.True(t, ok)
+		s.Finish()
+	}
+	// no goroutine is left watching ctx for spans which are not created by the tracer
+	assert.Less(t, runtime.NumGoroutine(), before+100)
+}
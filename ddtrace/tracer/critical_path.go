@@ -0,0 +1,62 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"sort"
+	"time"
+)
+
+// tagCriticalPath sets on the local root span of the finished trace, if part of it, the
+// time during which at least one of its direct children was running, in milliseconds, as
+// an approximation of the critical path of the trace. Overlapping children are only
+// counted once, and the time spent by the children outside of the root span is ignored.
+func (t *tracer) tagCriticalPath(info *finishedTrace) {
+	if !t.config.criticalPath || len(info.spans) == 0 {
+		return
+	}
+	var root *span
+	for _, s := range info.spans {
+		if (readOnlySpan{s}).IsRoot() {
+			root = s
+			break
+		}
+	}
+	if root == nil {
+		return
+	}
+	type interval struct{ start, end int64 }
+	var children []interval
+	for _, s := range info.spans {
+		if s == root || s.ParentID != root.SpanID {
+			continue
+		}
+		start, end := s.Start, s.Start+s.Duration
+		if start < root.Start {
+			start = root.Start
+		}
+		if rootEnd := root.Start + root.Duration; end > rootEnd {
+			end = rootEnd
+		}
+		if end > start {
+			children = append(children, interval{start, end})
+		}
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].start < children[j].start })
+	var total, end int64
+	for _, c := range children {
+		if c.start > end {
+			end = c.start
+		}
+		if c.end > end {
+			total += c.end - end
+			end = c.end
+		}
+	}
+	root.Lock()
+	root.setMetric(keyCriticalPath, float64(total)/float64(time.Millisecond))
+	root.Unlock()
+}
@@ -0,0 +1,61 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithCriticalPath(t *testing.T) {
+	start := time.Now()
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+
+	t.Run("enabled", func(t *testing.T) {
+		tracer, transport, flush, stop := startTestTracer(t, WithCriticalPath())
+		defer stop()
+
+		root := tracer.StartSpan("http.request", StartTime(at(0)))
+		// two overlapping children covering 10ms to 60ms
+		a := tracer.StartSpan("a", ChildOf(root.Context()), StartTime(at(10)))
+		tracer.StartSpan("a.child", ChildOf(a.Context()), StartTime(at(15))).Finish(FinishTime(at(90)))
+		a.Finish(FinishTime(at(40)))
+		tracer.StartSpan("b", ChildOf(root.Context()), StartTime(at(30))).Finish(FinishTime(at(60)))
+		// a child contained in another one
+		tracer.StartSpan("c", ChildOf(root.Context()), StartTime(at(20))).Finish(FinishTime(at(25)))
+		// a child running past the end of the root span, counted until 100ms
+		tracer.StartSpan("d", ChildOf(root.Context()), StartTime(at(80))).Finish(FinishTime(at(120)))
+		root.Finish(FinishTime(at(100)))
+		flush(1)
+
+		traces := transport.Traces()
+		require.Len(t, traces, 1)
+		for _, s := range traces[0] {
+			if s.SpanID == root.(*span).SpanID {
+				assert.InDelta(t, 70.0, s.Metrics[keyCriticalPath], 0.001)
+				continue
+			}
+			assert.NotContains(t, s.Metrics, keyCriticalPath)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		tracer, transport, flush, stop := startTestTracer(t)
+		defer stop()
+
+		root := tracer.StartSpan("http.request", StartTime(at(0)))
+		tracer.StartSpan("a", ChildOf(root.Context()), StartTime(at(10))).Finish(FinishTime(at(40)))
+		root.Finish(FinishTime(at(100)))
+		flush(1)
+
+		traces := transport.Traces()
+		require.Len(t, traces, 1)
+		assert.NotContains(t, traces[0][0].Metrics, keyCriticalPath)
+	})
+}
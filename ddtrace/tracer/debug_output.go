@@ -0,0 +1,73 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"encoding/json"
+	"math"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+)
+
+// debugSpan is the JSON representation of the spans written to the writer set using
+// WithDebugSpanOutput. Its fields are named as in the payloads sent to the agent.
+type debugSpan struct {
+	Name     string             `json:"name"`
+	Service  string             `json:"service"`
+	Resource string             `json:"resource"`
+	Type     string             `json:"type,omitempty"`
+	Start    int64              `json:"start"`
+	Duration int64              `json:"duration"`
+	Meta     map[string]string  `json:"meta,omitempty"`
+	Metrics  map[string]float64 `json:"metrics,omitempty"`
+	SpanID   uint64             `json:"span_id"`
+	TraceID  uint64             `json:"trace_id"`
+	ParentID uint64             `json:"parent_id"`
+	Error    int32              `json:"error"`
+}
+
+// writeDebugSpans writes the spans of trace to the writer set using WithDebugSpanOutput, if
+// any, one JSON object per line. It is only called by the worker, once the spans are finished.
+func (t *tracer) writeDebugSpans(trace []*span) {
+	w := t.config.debugSpanOutput
+	if w == nil {
+		return
+	}
+	for _, s := range trace {
+		ds := debugSpan{
+			Name:     s.Name,
+			Service:  s.Service,
+			Resource: s.Resource,
+			Type:     s.Type,
+			Start:    s.Start,
+			Duration: s.Duration,
+			Meta:     s.Meta,
+			SpanID:   s.SpanID,
+			TraceID:  s.TraceID,
+			ParentID: s.ParentID,
+			Error:    s.Error,
+		}
+		for k, v := range s.Metrics {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				// not representable in JSON
+				continue
+			}
+			if ds.Metrics == nil {
+				ds.Metrics = make(map[string]float64, len(s.Metrics))
+			}
+			ds.Metrics[k] = v
+		}
+		bs, err := json.Marshal(ds)
+		if err != nil {
+			log.Error("Error encoding debug span output: %v", err)
+			continue
+		}
+		if _, err := w.Write(append(bs, '\n')); err != nil {
+			log.Error("Error writing debug span output: %v", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithDebugSpanOutput(t *testing.T) {
+	var buf bytes.Buffer
+	tracer, transport, flush, stop := startTestTracer(t, WithDebugSpanOutput(&buf))
+	defer stop()
+
+	root := tracer.StartSpan("http.request", ServiceName("web"), ResourceName("GET /"))
+	child := tracer.StartSpan("db.query", ChildOf(root.Context()), SpanType("sql"))
+	child.SetTag("rows", 3)
+	child.SetTag("ratio", math.NaN())
+	child.Finish()
+	root.Finish()
+	flush(1)
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+	spans := make(map[string]debugSpan)
+	for _, line := range lines {
+		var s debugSpan
+		require.NoError(t, json.Unmarshal([]byte(line), &s), line)
+		spans[s.Name] = s
+	}
+	rs, cs := root.(*span), child.(*span)
+	c := spans["db.query"]
+	assert.Equal(t, "sql", c.Type)
+	assert.Equal(t, cs.SpanID, c.SpanID)
+	assert.Equal(t, rs.SpanID, c.ParentID)
+	assert.Equal(t, cs.Duration, c.Duration)
+	assert.Equal(t, 3.0, c.Metrics["rows"])
+	assert.NotContains(t, c.Metrics, "ratio")
+	r := spans["http.request"]
+	assert.Equal(t, "web", r.Service)
+	assert.Equal(t, "GET /", r.Resource)
+	assert.Equal(t, rs.TraceID, r.TraceID)
+	assert.Equal(t, rs.Meta["language"], r.Meta["language"])
+
+	// the spans are still sent to the agent
+	assert.Len(t, transport.Traces(), 1)
+}
@@ -0,0 +1,87 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"fmt"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
+)
+
+// EffectiveConfig is a read-only snapshot of the configuration of a running tracer, as
+// resolved from the options passed to Start, the environment variables and the defaults.
+type EffectiveConfig struct {
+	// AgentURL is the URL traces are sent to.
+	AgentURL string
+	// Env is the environment of the traced application.
+	Env string
+	// Service is the default service of spans.
+	Service string
+	// Version is the version of the traced application.
+	Version string
+	// SampleRate is the sampling rate applied to the traces which match no sampling rule.
+	// It is NaN when not set, the rates sent by the agent being used instead.
+	SampleRate float64
+	// InjectStyles and ExtractStyles are the styles of the headers the trace context is
+	// propagated with, in the order they are used, such as "tracecontext" or "datadog".
+	// They are nil when propagation is disabled.
+	InjectStyles  []string
+	ExtractStyles []string
+	// Debug reports whether debug mode is enabled.
+	Debug bool
+}
+
+// Config returns the configuration of the running tracer, reporting whether one was
+// started. It is meant for diagnosing the way the configuration was resolved, such as the
+// precedence of the environment variables, and is safe to call concurrently with tracing.
+func Config() (EffectiveConfig, bool) {
+	t, ok := internal.GetGlobalTracer().(*tracer)
+	if !ok {
+		return EffectiveConfig{}, false
+	}
+	c := EffectiveConfig{
+		AgentURL:   t.config.transport.endpoint(),
+		Env:        t.config.env,
+		Service:    t.config.serviceName,
+		Version:    t.config.version,
+		SampleRate: t.rulesSampling.traces.globalRate,
+		Debug:      t.config.debug,
+	}
+	if cp, ok := t.config.propagator.(*chainedPropagator); ok {
+		c.InjectStyles = propagationStyles(cp.injectors)
+		c.ExtractStyles = propagationStyles(cp.extractors)
+	} else {
+		// a custom propagator set using WithPropagator
+		style := fmt.Sprintf("%T", t.config.propagator)
+		c.InjectStyles = []string{style}
+		c.ExtractStyles = []string{style}
+	}
+	return c, true
+}
+
+// propagationStyles returns the styles of the given propagators, as named in the
+// DD_TRACE_PROPAGATION_STYLE environment variables.
+func propagationStyles(ps []Propagator) []string {
+	if len(ps) == 0 {
+		return nil
+	}
+	styles := make([]string, len(ps))
+	for i, p := range ps {
+		switch p.(type) {
+		case *propagator:
+			styles[i] = "datadog"
+		case *propagatorW3c:
+			styles[i] = "tracecontext"
+		case *propagatorB3:
+			styles[i] = "b3multi"
+		case *propagatorB3SingleHeader:
+			styles[i] = "b3 single header"
+		default:
+			styles[i] = fmt.Sprintf("%T", p)
+		}
+	}
+	return styles
+}
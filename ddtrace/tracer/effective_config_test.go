@@ -0,0 +1,69 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
+)
+
+func TestConfig(t *testing.T) {
+	t.Run("not-started", func(t *testing.T) {
+		_, ok := Config()
+		assert.False(t, ok)
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		_, _, _, stop := startTestTracer(t, WithService("orders"))
+		defer stop()
+
+		c, ok := Config()
+		require.True(t, ok)
+		assert.Equal(t, "http://localhost:9/v0.4/traces", c.AgentURL)
+		assert.Equal(t, "orders", c.Service)
+		assert.True(t, math.IsNaN(c.SampleRate))
+		assert.Equal(t, []string{"tracecontext", "datadog"}, c.InjectStyles)
+		assert.Equal(t, []string{"tracecontext", "datadog"}, c.ExtractStyles)
+	})
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("DD_ENV", "staging")
+		t.Setenv("DD_SERVICE", "billing")
+		t.Setenv("DD_VERSION", "1.2.3")
+		t.Setenv("DD_TRACE_SAMPLE_RATE", "0.5")
+		t.Setenv("DD_TRACE_PROPAGATION_STYLE_INJECT", "datadog,b3 single header")
+		t.Setenv("DD_TRACE_PROPAGATION_STYLE_EXTRACT", "none")
+		// options take precedence over the environment
+		_, _, _, stop := startTestTracer(t, WithEnv("production"))
+		defer stop()
+		defer globalconfig.SetServiceName("")
+
+		c, ok := Config()
+		require.True(t, ok)
+		assert.Equal(t, EffectiveConfig{
+			AgentURL:     "http://localhost:9/v0.4/traces",
+			Env:          "production",
+			Service:      "billing",
+			Version:      "1.2.3",
+			SampleRate:   0.5,
+			InjectStyles: []string{"datadog", "b3 single header"},
+		}, c)
+	})
+
+	t.Run("custom-propagator", func(t *testing.T) {
+		_, _, _, stop := startTestTracer(t, WithPropagator(&propagator{&PropagatorConfig{}}))
+		defer stop()
+
+		c, ok := Config()
+		require.True(t, ok)
+		assert.Equal(t, []string{"*tracer.propagator"}, c.InjectStyles)
+	})
+}
@@ -0,0 +1,69 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/clock"
+)
+
+// defaultFlushJitter is the fraction of the flush interval by which it is randomized
+// by default, as changed using WithFlushJitter.
+const defaultFlushJitter = 0.1
+
+// maxFlushJitter is the maximum fraction set using WithFlushJitter, ensuring that the
+// intervals between flushes stay positive.
+const maxFlushJitter = 0.9
+
+// jitterFloat64 returns the random number in [0, 1) by which jitter randomizes a duration;
+// replaced in tests.
+var jitterFloat64 = func() float64 { return random.Float64() }
+
+// jitter returns d randomized within ±fraction of its value.
+func jitter(d time.Duration, fraction float64) time.Duration {
+	return d + time.Duration((2*jitterFloat64()-1)*fraction*float64(d))
+}
+
+// jitterTicker is similar to time.Ticker, but randomizes each interval between its ticks
+// within ±fraction of its value, such that processes started together do not keep
+// flushing at the same time.
+type jitterTicker struct {
+	// C receives the time of each tick. Like with time.Ticker, ticks are dropped
+	// when it is not read from in time.
+	C <-chan time.Time
+
+	stop chan struct{}
+}
+
+// newJitterTicker returns a started jitterTicker, ticking every interval ±fraction, as
+// measured by the clock of the internal clock package.
+func newJitterTicker(interval time.Duration, fraction float64) *jitterTicker {
+	c := make(chan time.Time, 1)
+	t := &jitterTicker{C: c, stop: make(chan struct{})}
+	go func() {
+		timer := clock.NewTimer(jitter(interval, fraction))
+		defer timer.Stop()
+		for {
+			select {
+			case now := <-timer.C():
+				select {
+				case c <- now:
+				default:
+				}
+				timer.Reset(jitter(interval, fraction))
+			case <-t.stop:
+				return
+			}
+		}
+	}()
+	return t
+}
+
+// Stop stops the ticker. No more ticks are sent after it returns.
+func (t *jitterTicker) Stop() {
+	close(t.stop)
+}
@@ -0,0 +1,76 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/clock"
+)
+
+func TestWithFlushJitter(t *testing.T) {
+	assert.Equal(t, defaultFlushJitter, newConfig().flushJitter)
+	assert.Equal(t, 0.25, newConfig(WithFlushJitter(0.25)).flushJitter)
+	assert.Equal(t, 0.0, newConfig(WithFlushJitter(0)).flushJitter)
+	assert.Equal(t, 0.0, newConfig(WithFlushJitter(-1)).flushJitter)
+	assert.Equal(t, maxFlushJitter, newConfig(WithFlushJitter(2)).flushJitter)
+}
+
+func TestJitter(t *testing.T) {
+	min, max := flushInterval, flushInterval
+	for i := 0; i < 1000; i++ {
+		d := jitter(flushInterval, 0.1)
+		assert.True(t, d >= 1800*time.Millisecond && d <= 2200*time.Millisecond, d)
+		if d < min {
+			min = d
+		}
+		if d > max {
+			max = d
+		}
+	}
+	// the intervals are spread over the window
+	assert.True(t, max-min > 200*time.Millisecond, max-min)
+	assert.Equal(t, flushInterval, jitter(flushInterval, 0))
+}
+
+func TestJitterTicker(t *testing.T) {
+	start := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(start)
+	defer clock.Set(fake)()
+	// the random numbers drawn for each interval, and the resulting intervals
+	draws := []float64{0.5, 0, 0.75, 0.999}
+	want := []time.Duration{
+		flushInterval,
+		flushInterval / 2,
+		flushInterval * 5 / 4,
+		flushInterval*3/2 - flushInterval/1000,
+	}
+	var n int
+	defer func(old func() float64) { jitterFloat64 = old }(jitterFloat64)
+	jitterFloat64 = func() float64 {
+		f := draws[n%len(draws)]
+		n++
+		return f
+	}
+
+	ticker := newJitterTicker(flushInterval, 0.5)
+	defer ticker.Stop()
+	now := start
+	for _, d := range want {
+		// wait for the timer of the next tick to be started
+		fake.BlockUntil(1)
+		fake.Advance(d - time.Millisecond)
+		fake.Advance(time.Millisecond)
+		now = now.Add(d)
+		// the tick is sent when the interval elapses, rather than before
+		assert.Equal(t, now, <-ticker.C)
+	}
+	// the next interval is drawn before jitterFloat64 is restored
+	fake.BlockUntil(1)
+}
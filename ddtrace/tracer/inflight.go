@@ -0,0 +1,90 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"container/list"
+	"sync"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+)
+
+// keyForcedFinish is set on the spans finished by the tracer because their trace was
+// evicted from the open traces, as limited using WithMaxInFlightTraces.
+const keyForcedFinish = "_dd.forced_finish"
+
+// openTraces tracks the traces having unfinished spans, from the oldest to the most
+// recently started one, in order to bound their number to max.
+type openTraces struct {
+	mu    sync.Mutex
+	max   int
+	ll    *list.List // most recently started traces first
+	elems map[*trace]*list.Element
+}
+
+func newOpenTraces(max int) *openTraces {
+	return &openTraces{
+		max:   max,
+		ll:    list.New(),
+		elems: make(map[*trace]*list.Element),
+	}
+}
+
+// add tracks the given trace, which started having unfinished spans. It returns the
+// oldest traces which exceed the maximum, which are not tracked anymore and should
+// be force-finished by the caller.
+func (o *openTraces) add(t *trace) (evicted []*trace) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, ok := o.elems[t]; ok {
+		return nil
+	}
+	o.elems[t] = o.ll.PushFront(t)
+	for o.ll.Len() > o.max {
+		oldest := o.ll.Back()
+		o.ll.Remove(oldest)
+		delete(o.elems, oldest.Value.(*trace))
+		evicted = append(evicted, oldest.Value.(*trace))
+	}
+	if len(evicted) > 0 {
+		log.Warn("more than %d traces are open, force-finishing the spans of the %d oldest ones", o.max, len(evicted))
+	}
+	return evicted
+}
+
+// remove stops tracking the given trace, whose spans are all finished.
+func (o *openTraces) remove(t *trace) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if e, ok := o.elems[t]; ok {
+		o.ll.Remove(e)
+		delete(o.elems, t)
+	}
+}
+
+// len returns the number of open traces.
+func (o *openTraces) len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.ll.Len()
+}
+
+// forceFinish finishes the unfinished spans of the trace, tagging them with
+// keyForcedFinish, such that the trace is flushed and its memory released.
+func (t *trace) forceFinish() {
+	t.mu.RLock()
+	spans := make([]*span, len(t.spans))
+	copy(spans, t.spans)
+	t.mu.RUnlock()
+	for _, s := range spans {
+		s.Lock()
+		if !s.finished {
+			s.setMetric(keyForcedFinish, 1)
+		}
+		s.Unlock()
+		s.Finish()
+	}
+}
@@ -0,0 +1,52 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithMaxInFlightTraces(t *testing.T) {
+	tracer, transport, flush, stop := startTestTracer(t, WithMaxInFlightTraces(2))
+	defer stop()
+
+	var roots []*span
+	for i := 0; i < 5; i++ {
+		root := tracer.StartSpan("leaked").(*span)
+		child := tracer.StartSpan("child", ChildOf(root.Context())).(*span)
+		if i == 0 {
+			// finished spans are not tagged when their trace is force-finished
+			child.Finish()
+		}
+		roots = append(roots, root)
+	}
+	assert.Equal(t, 2, tracer.openTraces.len())
+	flush(3)
+
+	// the 3 oldest traces were flushed
+	traces := transport.Traces()
+	require.Len(t, traces, 3)
+	for i, trace := range traces {
+		require.Len(t, trace, 2)
+		assert.Equal(t, roots[i].TraceID, trace[0].TraceID)
+		assert.Equal(t, 1.0, trace[0].Metrics[keyForcedFinish])
+		if i == 0 {
+			assert.NotContains(t, trace[1].Metrics, keyForcedFinish)
+		} else {
+			assert.Equal(t, 1.0, trace[1].Metrics[keyForcedFinish])
+		}
+	}
+
+	// the traces finished normally are not tracked anymore
+	roots[3].context.trace.forceFinish()
+	roots[4].Finish()
+	assert.Equal(t, 1, tracer.openTraces.len())
+	tracer.StartSpan("root").Finish()
+	assert.Equal(t, 1, tracer.openTraces.len())
+}
@@ -0,0 +1,46 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/samplernames"
+)
+
+// sampleLate makes the sampling decision of the trace of the given span context, when it
+// was deferred using WithLateSamplingDecision and has not been made yet. The decision is
+// made using the local root span of the trace, with the tags it has at that point.
+func (t *tracer) sampleLate(ctx ddtrace.SpanContext) {
+	if !t.config.lateSamplingDecision {
+		return
+	}
+	sc, ok := ctx.(*spanContext)
+	if !ok || sc.trace == nil || sc.trace.root == nil {
+		return
+	}
+	if _, ok := sc.trace.samplingPriority(); ok {
+		return
+	}
+	t.sample(sc.trace.root)
+}
+
+// keepOnError keeps the trace of the span s, which is locked, when it gets its first error
+// while its sampling decision is deferred using WithLateSamplingDecision and not made yet.
+func keepOnError(s *span) {
+	t, ok := internal.GetGlobalTracer().(*tracer)
+	if !ok || !t.config.lateSamplingDecision || s.context == nil || s.context.trace == nil {
+		return
+	}
+	tr := s.context.trace
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if _, ok := tr.samplingPriorityLocked(); ok {
+		return
+	}
+	tr.setSamplingPriorityLocked(ext.PriorityAutoKeep, samplernames.Default)
+}
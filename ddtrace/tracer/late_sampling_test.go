@@ -0,0 +1,88 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"errors"
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLateSamplingDecision(t *testing.T) {
+	// finishTrace finishes a trace whose child fails if fail is set, and returns the
+	// sampling priority of the flushed root span.
+	finishTrace := func(t *testing.T, fail bool, opts ...StartOption) float64 {
+		tracer, transport, flush, stop := startTestTracer(t, append(opts, WithSamplingRules([]SamplingRule{RateRule(0)}))...)
+		defer stop()
+
+		root := tracer.StartSpan("http.request").(*span)
+		child := tracer.StartSpan("db.query", ChildOf(root.Context()))
+		if fail {
+			child.Finish(WithError(errors.New("timeout")))
+		} else {
+			child.Finish()
+		}
+		root.Finish()
+		flush(1)
+
+		traces := transport.Traces()
+		require.Len(t, traces, 1)
+		for _, s := range traces[0] {
+			if s.SpanID == root.SpanID {
+				return s.Metrics[keySamplingPriority]
+			}
+		}
+		t.Fatal("root span not found")
+		return 0
+	}
+
+	t.Run("error", func(t *testing.T) {
+		assert.Equal(t, float64(ext.PriorityAutoKeep), finishTrace(t, true, WithLateSamplingDecision()))
+	})
+
+	t.Run("no-error", func(t *testing.T) {
+		assert.Equal(t, float64(ext.PriorityUserReject), finishTrace(t, false, WithLateSamplingDecision()))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		// the decision made when the root starts is not changed by the error
+		assert.Equal(t, float64(ext.PriorityUserReject), finishTrace(t, true))
+	})
+
+	t.Run("undecided", func(t *testing.T) {
+		tracer, _, _, stop := startTestTracer(t, WithLateSamplingDecision())
+		defer stop()
+
+		root := tracer.StartSpan("http.request").(*span)
+		_, ok := root.context.samplingPriority()
+		assert.False(t, ok)
+		root.Finish()
+		_, ok = root.context.samplingPriority()
+		assert.True(t, ok)
+	})
+
+	t.Run("inject", func(t *testing.T) {
+		tracer, _, _, stop := startTestTracer(t, WithLateSamplingDecision(), WithSamplingRules([]SamplingRule{RateRule(0)}))
+		defer stop()
+
+		root := tracer.StartSpan("http.request")
+		defer root.Finish()
+		child := tracer.StartSpan("http.client", ChildOf(root.Context()))
+		defer child.Finish()
+		carrier := TextMapCarrier{}
+		require.NoError(t, tracer.Inject(child.Context(), carrier))
+		assert.Equal(t, "-1", carrier[DefaultPriorityHeader])
+
+		// an error after the decision was propagated does not change it
+		child.SetTag(ext.Error, errors.New("timeout"))
+		p, _ := root.(*span).context.samplingPriority()
+		assert.Equal(t, ext.PriorityUserReject, p)
+	})
+}
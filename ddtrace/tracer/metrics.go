@@ -94,6 +94,8 @@ func (t *tracer) reportRuntimeMetrics(interval time.Duration) {
 	}
 }
 
+// reportHealthMetrics periodically reports the counters about the spans handled by
+// the tracer at the given interval.
 func (t *tracer) reportHealthMetrics(interval time.Duration) {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
@@ -103,6 +105,10 @@ func (t *tracer) reportHealthMetrics(interval time.Duration) {
 			t.statsd.Count("datadog.tracer.spans_started", int64(atomic.SwapUint32(&t.spansStarted, 0)), nil, 1)
 			t.statsd.Count("datadog.tracer.spans_finished", int64(atomic.SwapUint32(&t.spansFinished, 0)), nil, 1)
 			t.statsd.Count("datadog.tracer.traces_dropped", int64(atomic.SwapUint32(&t.tracesDropped, 0)), []string{"reason:trace_too_large"}, 1)
+			if t.config.healthMetrics {
+				t.statsd.Count("datadog.tracer.spans_dropped", int64(atomic.SwapUint32(&t.spansDropped, 0)), []string{"reason:trace_too_large"}, 1)
+			}
+			t.statsd.Count("datadog.tracer.spans_dropped", int64(atomic.SwapUint32(&t.spansFiltered, 0)), []string{"reason:span_filter"}, 1)
 		case <-t.stop:
 			return
 		}
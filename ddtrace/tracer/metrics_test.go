@@ -292,6 +292,43 @@ func TestReportHealthMetrics(t *testing.T) {
 	assert.Equal(int64(0), counts["datadog.tracer.traces_dropped"])
 }
 
+func TestReportHealthMetricsEnabled(t *testing.T) {
+	defer func(old time.Duration) { statsInterval = old }(statsInterval)
+	statsInterval = time.Nanosecond
+
+	t.Run("on", func(t *testing.T) {
+		var tg testStatsdClient
+		tracer, _, flush, stop := startTestTracer(t, withStatsdClient(&tg), WithHealthMetrics())
+		defer stop()
+
+		tracer.StartSpan("operation").Finish()
+		flush(1)
+		tg.Wait(4, 1*time.Second)
+
+		counts := tg.Counts()
+		assert.Equal(t, int64(1), counts["datadog.tracer.spans_started"])
+		assert.Equal(t, int64(0), counts["datadog.tracer.spans_dropped"])
+		assert.Equal(t, int64(0), counts["datadog.tracer.traces_dropped"])
+	})
+
+	t.Run("off", func(t *testing.T) {
+		var tg testStatsdClient
+		tracer, _, flush, stop := startTestTracer(t, withStatsdClient(&tg))
+		defer stop()
+
+		tracer.StartSpan("operation").Finish()
+		flush(1)
+		tg.Wait(3, 1*time.Second)
+
+		assert.Contains(t, tg.CallsByName(), "datadog.tracer.spans_started")
+		for _, c := range tg.CountCalls() {
+			if c.name == "datadog.tracer.spans_dropped" {
+				assert.NotContains(t, c.tags, "reason:trace_too_large")
+			}
+		}
+	})
+}
+
 func TestTracerMetrics(t *testing.T) {
 	assert := assert.New(t)
 	var tg testStatsdClient
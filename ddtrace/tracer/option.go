@@ -7,8 +7,10 @@ package tracer
 
 import (
 	"context"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"io"
 	"math"
 	"net"
 	"net/http"
@@ -28,6 +30,7 @@ import (
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/traceprof"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/version"
 
+	"github.com/DataDog/datadog-agent/pkg/obfuscate"
 	"github.com/DataDog/datadog-go/v5/statsd"
 )
 
@@ -42,6 +45,10 @@ var (
 
 	// defaultMaxTagsHeaderLen specifies the default maximum length of the X-Datadog-Tags header value.
 	defaultMaxTagsHeaderLen = 128
+
+	// containerID returns the container ID detected from the cgroup file.
+	// Replaced in tests
+	containerID = internal.ContainerID
 )
 
 // config holds the tracer configuration.
@@ -64,6 +71,14 @@ type config struct {
 	// failure.
 	sendRetries int
 
+	// payloadReplays is the number of later flushes on which a trace payload which failed
+	// to be sent with a retryable error is sent again. It is set using WithPayloadReplay.
+	payloadReplays int
+
+	// maxPayloadSize is the size in bytes of the encoded payload above which a flush
+	// to the transport is triggered, instead of waiting for the flush interval.
+	maxPayloadSize int
+
 	// logStartup, when true, causes various startup info to be written
 	// when the tracer starts.
 	logStartup bool
@@ -97,12 +112,22 @@ type config struct {
 	// transport specifies the Transport interface which will be used to send data to the agent.
 	transport transport
 
+	// additionalAgentURLs are the URLs of the agents to which traces are sent in addition
+	// to agentURL, as set using WithAdditionalAgentURL.
+	additionalAgentURLs []string
+
+	// additionalTransports are the transports sending traces to additionalAgentURLs.
+	additionalTransports []transport
+
 	// propagator propagates span context cross-process
 	propagator Propagator
 
 	// httpClient specifies the HTTP client to be used by the agent's transport.
 	httpClient *http.Client
 
+	// agentTransport, when set, configures the reuse of the connections to the agent.
+	agentTransport *agentTransportConfig
+
 	// hostname is automatically assigned when the DD_TRACE_REPORT_HOSTNAME is set to true,
 	// and is added as a special tag to the root span of traces.
 	hostname string
@@ -111,9 +136,16 @@ type config struct {
 	// will be used.
 	logger ddtrace.Logger
 
+	// logRateLimit, when non-nil, overrides the interval over which identical errors
+	// logged by the tracer are collapsed into a single message.
+	logRateLimit *time.Duration
+
 	// runtimeMetrics specifies whether collection of runtime metrics is enabled.
 	runtimeMetrics bool
 
+	// healthMetrics specifies whether the tracer reports metrics about its own health.
+	healthMetrics bool
+
 	// dogstatsdAddr specifies the address to connect for sending metrics to the
 	// Datadog Agent. If not set, it defaults to "localhost:8125" or to the
 	// combination of the environment variables DD_AGENT_HOST and DD_DOGSTATSD_PORT.
@@ -131,6 +163,58 @@ type config struct {
 	// to the entire trace if any spans satisfy the criteria
 	traceRules []SamplingRule
 
+	// obfuscatorConfig, when set, overrides the SQL obfuscation settings advertised by the agent.
+	obfuscatorConfig *ObfuscatorConfig
+
+	// spanFilter, when set, reports whether a finished span should be kept.
+	spanFilter func(ReadOnlySpan) bool
+
+	// spanProcessors are called with each span before it is finished, in order.
+	spanProcessors []func(Span)
+
+	// processTags describe the process, and are sent once per payload.
+	processTags map[string]string
+
+	// lateSamplingDecision defers the sampling decision of traces until their local root
+	// finishes, one of their spans gets an error, or their context is injected.
+	lateSamplingDecision bool
+
+	// samplingSeed, when set, makes the sampling decisions of traces a deterministic
+	// function of their trace ID and this seed.
+	samplingSeed *uint64
+
+	// maxInFlightTraces, when positive, is the maximum number of traces having unfinished
+	// spans, beyond which the oldest ones are force-finished.
+	maxInFlightTraces int
+
+	// criticalPath reports whether local root spans are tagged with the critical path
+	// of their trace.
+	criticalPath bool
+
+	// panicRecovery reports whether spans finished using FinishWithRecover record the
+	// panics propagating through them. It is set using WithPanicRecovery.
+	panicRecovery bool
+
+	// idGenerator, when set, generates the IDs of spans and of the traces they start, in place
+	// of the default random source. It is set using WithIDGenerator.
+	idGenerator func() uint64
+
+	// idGenerator128, when set, generates the 128-bit IDs of the traces started by root spans.
+	// It is set using WithIDGenerator128.
+	idGenerator128 func() [16]byte
+
+	// debugSpanOutput, when set, is written the finished spans, as JSON lines. It is set
+	// using WithDebugSpanOutput.
+	debugSpanOutput io.Writer
+
+	// traceRateLimit, when positive, is the maximum number of traces kept per second,
+	// regardless of the sampling decisions.
+	traceRateLimit float64
+
+	// flushJitter is the fraction of the flush interval by which each interval between
+	// flushes is randomized, in [0, 0.9]. Zero disables it.
+	flushJitter float64
+
 	// tickChan specifies a channel which will receive the time every time the tracer must flush.
 	// It defaults to time.Ticker; replaced in tests.
 	tickChan <-chan time.Time
@@ -150,6 +234,22 @@ type config struct {
 
 	// disableHostnameDetection specifies whether the tracer should disable hostname detection.
 	disableHostnameDetection bool
+
+	// containerID holds the ID of the container the tracer runs in. It is detected from
+	// /proc/self/cgroup and can be overridden using WithContainerID.
+	containerID string
+
+	// entityID holds the entity ID (e.g. the Kubernetes pod UID) set through DD_ENTITY_ID.
+	entityID string
+
+	// deploymentStage holds the stage of the deployment the tracer runs in (e.g. "canary"),
+	// set through DD_DEPLOYMENT_STAGE or WithDeploymentStage.
+	deploymentStage string
+
+	// otlpEndpoint, when set, is the URL of an OTLP/HTTP receiver to which traces are
+	// also sent, along with the otlpHeaders.
+	otlpEndpoint string
+	otlpHeaders  map[string]string
 }
 
 // HasFeature reports whether feature f is enabled.
@@ -169,6 +269,8 @@ const maxPropagatedTagsLength = 512
 func newConfig(opts ...StartOption) *config {
 	c := new(config)
 	c.sampler = NewAllSampler()
+	c.maxPayloadSize = payloadSizeLimit
+	c.flushJitter = defaultFlushJitter
 
 	if internal.BoolEnv("DD_TRACE_ANALYTICS_ENABLED", false) {
 		globalconfig.SetAnalyticsRate(1.0)
@@ -186,6 +288,9 @@ func newConfig(opts ...StartOption) *config {
 	if v := os.Getenv("DD_ENV"); v != "" {
 		c.env = v
 	}
+	c.containerID = containerID()
+	c.entityID = os.Getenv("DD_ENTITY_ID")
+	c.deploymentStage = os.Getenv("DD_DEPLOYMENT_STAGE")
 	if v := os.Getenv("DD_TRACE_FEATURES"); v != "" {
 		WithFeatureFlags(strings.FieldsFunc(v, func(r rune) bool {
 			return r == ',' || r == ' '
@@ -215,6 +320,7 @@ func newConfig(opts ...StartOption) *config {
 	}
 	c.logStartup = internal.BoolEnv("DD_TRACE_STARTUP_LOGS", true)
 	c.runtimeMetrics = internal.BoolEnv("DD_RUNTIME_METRICS_ENABLED", false)
+	c.healthMetrics = internal.BoolEnv("DD_TRACE_HEALTH_METRICS_ENABLED", false)
 	c.debug = internal.BoolEnv("DD_TRACE_DEBUG", false)
 	c.enabled = internal.BoolEnv("DD_TRACE_ENABLED", true)
 	c.profilerEndpoints = internal.BoolEnv(traceprof.EndpointEnvVar, true)
@@ -229,7 +335,8 @@ func newConfig(opts ...StartOption) *config {
 			c.agentURL = url
 		}
 	}
-	if c.agentURL.Scheme == "unix" {
+	uds := c.agentURL.Scheme == "unix"
+	if uds {
 		// If we're connecting over UDS we can just rely on the agent to provide the hostname
 		log.Debug("connecting to agent over unix, do not set hostname on any traces")
 		c.disableHostnameDetection = true
@@ -241,6 +348,9 @@ func newConfig(opts ...StartOption) *config {
 	} else if c.httpClient == nil {
 		c.httpClient = defaultClient
 	}
+	if c.agentTransport != nil {
+		c.httpClient = c.agentTransport.apply(c.httpClient)
+	}
 	WithGlobalTag(ext.RuntimeID, globalconfig.RuntimeID())(c)
 	if c.env == "" {
 		if v, ok := c.globalTags["env"]; ok {
@@ -267,7 +377,17 @@ func newConfig(opts ...StartOption) *config {
 		}
 	}
 	if c.transport == nil {
-		c.transport = newHTTPTransport(c.agentURL.String(), c.httpClient)
+		c.transport = c.newAgentTransport(c.agentURL.String(), c.httpClient)
+	}
+	if len(c.additionalAgentURLs) > 0 {
+		client := c.httpClient
+		if uds {
+			// additional agents are reached over TCP, even when the main one is reached over UDS
+			client = defaultClient
+		}
+		for _, u := range c.additionalAgentURLs {
+			c.additionalTransports = append(c.additionalTransports, c.newAgentTransport(u, client))
+		}
 	}
 	if c.propagator == nil {
 		envKey := "DD_TRACE_X_DATADOG_TAGS_MAX_LENGTH"
@@ -290,6 +410,9 @@ func newConfig(opts ...StartOption) *config {
 	if c.debug {
 		log.SetLevel(log.LevelDebug)
 	}
+	if c.debugSpanOutput != nil {
+		log.Warn("Debug span output is enabled: all the finished spans are written as JSON. This is meant for local development only.")
+	}
 	c.loadAgentFeatures()
 	if c.statsdClient == nil {
 		// configure statsd client
@@ -504,6 +627,16 @@ func WithLogger(logger ddtrace.Logger) StartOption {
 	}
 }
 
+// WithLogRateLimit sets the interval over which identical errors logged by the tracer,
+// such as failures to reach the agent or dropped traces, are collapsed into a single
+// message reporting how many occurrences were suppressed. It overrides DD_LOGGING_RATE
+// from Start until Stop. A zero duration disables collapsing, logging every error as it occurs.
+func WithLogRateLimit(d time.Duration) StartOption {
+	return func(c *config) {
+		c.logRateLimit = &d
+	}
+}
+
 // WithPrioritySampling is deprecated, and priority sampling is enabled by default.
 // When using distributed tracing, the priority sampling value is propagated in order to
 // get all the parts of a distributed trace sampled.
@@ -524,6 +657,16 @@ func WithDebugStack(enabled bool) StartOption {
 	}
 }
 
+// WithErrorStackTraces enables or disables capturing the stack trace in the "error.stack" tag
+// of spans when an error is recorded on them, using WithError or the "error" tag. Capturing is
+// enabled by default. Individual spans may still skip it when it is enabled, by finishing with
+// the NoDebugStack FinishOption.
+func WithErrorStackTraces(enabled bool) StartOption {
+	return func(c *config) {
+		c.noDebugStack = !enabled
+	}
+}
+
 // WithDebugMode enables debug mode on the tracer, resulting in more verbose logging.
 func WithDebugMode(enabled bool) StartOption {
 	return func(c *config) {
@@ -549,6 +692,31 @@ func WithSendRetries(retries int) StartOption {
 	}
 }
 
+// WithPayloadReplay enables sending trace payloads again on later flushes when all the
+// attempts to send them failed with a retryable status code from the agent (408, 429 or
+// 5xx), at most `replays` times, with an exponential backoff between them. Payloads are
+// dropped once the replays are exhausted, or when too many of them are already waiting.
+// It complements WithSendRetries, whose retries are immediate.
+func WithPayloadReplay(replays int) StartOption {
+	return func(c *config) {
+		c.payloadReplays = replays
+	}
+}
+
+// WithMaxPayloadSize sets the size in bytes of the encoded traces above which they are
+// flushed to the agent, instead of waiting for the next flush interval. This is useful
+// when large payloads can not be sent reliably. Values outside of the range accepted by
+// the agent are ignored.
+func WithMaxPayloadSize(bytes int) StartOption {
+	return func(c *config) {
+		if bytes <= 0 || bytes > payloadMaxLimit {
+			log.Warn("ignoring invalid max payload size %d, must be between 1 and %d", bytes, int(payloadMaxLimit))
+			return
+		}
+		c.maxPayloadSize = bytes
+	}
+}
+
 // WithPropagator sets an alternative propagator to be used by the tracer.
 func WithPropagator(p Propagator) StartOption {
 	return func(c *config) {
@@ -590,6 +758,17 @@ func WithAgentAddr(addr string) StartOption {
 	}
 }
 
+// WithAdditionalAgentURL sends traces to the agent at the given URL, e.g. "http://10.0.0.2:8126",
+// in addition to the main agent, which is useful to dual-ship traces while migrating agents.
+// It can be used multiple times to add more agents. Each payload is sent to all of them, failures
+// to reach one not affecting the others. Only the main agent is used for anything but traces,
+// such as sampling rates, stats and remote configuration.
+func WithAdditionalAgentURL(agentURL string) StartOption {
+	return func(c *config) {
+		c.additionalAgentURLs = append(c.additionalAgentURLs, strings.TrimSuffix(agentURL, "/"))
+	}
+}
+
 // WithEnv sets the environment to which all traces started by the tracer will be submitted.
 // The default value is the environment variable DD_ENV, if it is set.
 func WithEnv(env string) StartOption {
@@ -599,7 +778,9 @@ func WithEnv(env string) StartOption {
 }
 
 // WithServiceMapping determines service "from" to be renamed to service "to".
-// This option is is case sensitive and can be used multiple times.
+// This option is is case sensitive and can be used multiple times. Mappings are
+// applied both when a span is started and when it is finished, so that services
+// set as tags during the lifetime of a span are renamed too.
 func WithServiceMapping(from, to string) StartOption {
 	return func(c *config) {
 		if c.serviceMappings == nil {
@@ -620,6 +801,38 @@ func WithGlobalTag(k string, v interface{}) StartOption {
 	}
 }
 
+// WithProcessTags sets tags describing the process, such as its runtime or the host it runs on,
+// which apply to all of its spans. As opposed to WithGlobalTag, they are not set on every span,
+// but sent once in each payload sent to the agent, in the "_dd.tags.process" tag of its first
+// span, as a comma-separated list of key:value pairs. This option may be used multiple times.
+func WithProcessTags(tags map[string]string) StartOption {
+	return func(c *config) {
+		if c.processTags == nil {
+			c.processTags = make(map[string]string, len(tags))
+		}
+		for k, v := range tags {
+			c.processTags[k] = v
+		}
+	}
+}
+
+// WithFlushJitter randomizes each interval between the periodic flushes of traces to the agent
+// within ±fraction of its value, e.g. a fraction of 0.2 spreads flushes between 1.6 and 2.4
+// seconds apart. This prevents many instances started at the same time from flushing in sync,
+// which causes load spikes on the agent. Fractions are capped at 0.9, and zero disables the
+// randomization. It defaults to 0.1.
+func WithFlushJitter(fraction float64) StartOption {
+	return func(c *config) {
+		switch {
+		case fraction < 0:
+			fraction = 0
+		case fraction > maxFlushJitter:
+			fraction = maxFlushJitter
+		}
+		c.flushJitter = fraction
+	}
+}
+
 // WithSampler sets the given sampler to be used with the tracer. By default
 // an all-permissive sampler is used.
 func WithSampler(s Sampler) StartOption {
@@ -628,6 +841,250 @@ func WithSampler(s Sampler) StartOption {
 	}
 }
 
+// ObfuscatorConfig holds the settings of the obfuscation of SQL resources done by the tracer when
+// computing stats, matching the ones of the agent's SQL obfuscator. In all cases, literals are
+// replaced with "?", and lists of values, such as those of IN clauses, are collapsed into a single
+// "( ? )" group.
+type ObfuscatorConfig struct {
+	// TableNames enables collecting the names of the tables found in queries. It corresponds
+	// to the "table_names" feature of the agent.
+	TableNames bool
+
+	// ReplaceDigits enables replacing the digits of table names and identifiers with "?", such
+	// that queries on sharded tables, e.g. "orders_2023", are quantized together. It corresponds
+	// to the "quantize_sql_tables" feature of the agent.
+	ReplaceDigits bool
+
+	// KeepSQLAlias keeps the column aliases of queries, e.g. "AS total", instead of removing them.
+	// It corresponds to the "keep_sql_alias" feature of the agent.
+	KeepSQLAlias bool
+
+	// DollarQuotedFunc keeps the body of Postgres dollar quoted functions, obfuscating it instead
+	// of replacing it with "?". It corresponds to the "dollar_quoted_func" feature of the agent.
+	DollarQuotedFunc bool
+}
+
+// WithSamplingSeed makes the probabilistic sampling decisions of traces, taken by the sampling
+// rules and the rates received from the agent, a deterministic function of their trace ID and
+// the given seed. The same trace IDs are then consistently kept or dropped by all tracers using
+// the same seed, across runs, while different seeds sample different subsets of traces. Note that
+// the rate limit of sampling rules still applies. This option is meant for testing, such as
+// for reproducible load tests: since the decisions differ from the ones of other tracers and of
+// the agent, it should not be used in production with distributed traces.
+func WithSamplingSeed(seed uint64) StartOption {
+	return func(c *config) {
+		c.samplingSeed = &seed
+	}
+}
+
+// WithSpanFilter sets a function called with each finished span once its trace is complete,
+// which reports whether the span should be kept. Spans for which fn returns false are discarded
+// and not sent to the agent. Discarding the local root span of a trace discards the whole trace,
+// while discarding any other span only removes that span, its children being reparented to its
+// closest kept ancestor. The function is called from the tracer's worker and should be fast.
+func WithSpanFilter(fn func(ReadOnlySpan) bool) StartOption {
+	return func(c *config) {
+		c.spanFilter = fn
+	}
+}
+
+// WithSpanProcessor adds a function called with each span as it is being finished, before it
+// is marked as such, allowing to add, change or remove its tags and metrics, e.g. to redact
+// sensitive values. Tags can be removed using the RemoveTag method of the span, which implements
+// TagRemover. Processors are called in the order they were added, from the goroutine finishing
+// the span, and should be fast. They are not called on spans of a stopped tracer.
+func WithSpanProcessor(fn func(Span)) StartOption {
+	return func(c *config) {
+		c.spanProcessors = append(c.spanProcessors, fn)
+	}
+}
+
+// WithLateSamplingDecision defers the sampling decision of new traces, normally made when their
+// local root span starts, until the root finishes, such that sampling rules match the tags it has
+// then. A trace is kept as soon as one of its spans gets an error before the decision is made,
+// making it reliable to keep erroneous traces. Since the decision is propagated, it is also made
+// when the context of the trace is injected, e.g. into the headers of an outgoing request. Traces
+// continuing a propagated decision are not affected.
+func WithLateSamplingDecision() StartOption {
+	return func(c *config) {
+		c.lateSamplingDecision = true
+	}
+}
+
+// WithMaxInFlightTraces bounds the memory used by traces whose spans are never finished, such
+// as leaked spans in long-lived processes, by limiting the number of traces having unfinished
+// spans to n. When a trace is started while n traces are open, the unfinished spans of the
+// oldest one are finished, tagged with "_dd.forced_finish", and the trace is flushed. A warning
+// is logged when this happens. There is no limit by default.
+func WithMaxInFlightTraces(n int) StartOption {
+	return func(c *config) {
+		c.maxInFlightTraces = n
+	}
+}
+
+// WithCriticalPath enables tagging the local root span of each trace with an approximation
+// of the critical path of the trace: the time during which at least one of its direct
+// children was running, in milliseconds, in the "_dd.critical_path_ms" metric. This is a
+// heuristic, computed once all the spans of the trace are finished, which does not account
+// for the spans of the trace in other services.
+func WithCriticalPath() StartOption {
+	return func(c *config) {
+		c.criticalPath = true
+	}
+}
+
+// WithPanicRecovery enables recording the panics propagating through spans finished using
+// FinishWithRecover: such spans are marked as errored with the panic and the stack at which it
+// occurred, and the panic keeps propagating. Spans finished using their Finish method are not
+// affected.
+func WithPanicRecovery() StartOption {
+	return func(c *config) {
+		c.panicRecovery = true
+	}
+}
+
+// WithIDGenerator sets the function generating the IDs of spans, and of the traces started by root
+// spans, in place of the default random source, e.g. to draw them from a hardware random number
+// generator, or to make them deterministic in tests. It must be safe for concurrent use. The IDs
+// it returns must be unique; zero is not a valid ID and is replaced by a random one.
+func WithIDGenerator(gen func() uint64) StartOption {
+	return func(c *config) {
+		c.idGenerator = gen
+	}
+}
+
+// WithIDGenerator128 sets the function generating the 128-bit IDs of the traces started by root
+// spans, in place of the IDs of these spans. The lower 64 bits are used as the trace ID of spans
+// and the full ID is propagated using the W3C trace context headers. IDs whose lower 64 bits are
+// zero are not valid and are ignored. It must be safe for concurrent use, and takes precedence
+// over WithIDGenerator for trace IDs.
+func WithIDGenerator128(gen func() [16]byte) StartOption {
+	return func(c *config) {
+		c.idGenerator128 = gen
+	}
+}
+
+// WithDebugSpanOutput enables writing each finished span to w as a line of JSON, in addition to
+// sending it to the agent, e.g. to see the spans of an application in development without running
+// an agent. All spans are written, whether they are sampled or not. Writing is synchronous with
+// the processing of finished traces, so this option is meant for local development only and must
+// not be used in production.
+func WithDebugSpanOutput(w io.Writer) StartOption {
+	return func(c *config) {
+		c.debugSpanOutput = w
+	}
+}
+
+// newSpanID returns the ID of a new span started at startTime, as generated by the generator
+// set using WithIDGenerator, if any.
+func (c *config) newSpanID(startTime int64) uint64 {
+	if c.idGenerator != nil {
+		if id := c.idGenerator(); id != 0 {
+			return id
+		}
+	}
+	return generateSpanID(startTime)
+}
+
+// newTraceID128 returns a new 128-bit trace ID, as generated by the generator set using
+// WithIDGenerator128, reporting whether one is set and generated a valid ID.
+func (c *config) newTraceID128() ([16]byte, bool) {
+	if c.idGenerator128 == nil {
+		return [16]byte{}, false
+	}
+	id := c.idGenerator128()
+	return id, binary.BigEndian.Uint64(id[8:]) != 0
+}
+
+// WithObfuscatorConfig sets the settings of the obfuscation of SQL resources done by the tracer
+// when computing stats. By default, the tracer uses the settings advertised by the agent, which
+// should be overridden when they differ from the agent's obfuscation of the "sql.query" tag, for
+// the resources of both to match.
+func WithObfuscatorConfig(cfg ObfuscatorConfig) StartOption {
+	return func(c *config) {
+		c.obfuscatorConfig = &cfg
+	}
+}
+
+// sqlObfuscationConfig returns the configuration of the SQL obfuscator of the tracer, as set
+// using WithObfuscatorConfig or, by default, according to the features of the agent.
+func (c *config) sqlObfuscationConfig() obfuscate.SQLConfig {
+	if oc := c.obfuscatorConfig; oc != nil {
+		return obfuscate.SQLConfig{
+			TableNames:       oc.TableNames,
+			ReplaceDigits:    oc.ReplaceDigits,
+			KeepSQLAlias:     oc.KeepSQLAlias,
+			DollarQuotedFunc: oc.DollarQuotedFunc,
+			Cache:            c.agent.HasFlag("sql_cache"),
+		}
+	}
+	return obfuscate.SQLConfig{
+		TableNames:       c.agent.HasFlag("table_names"),
+		ReplaceDigits:    c.agent.HasFlag("quantize_sql_tables") || c.agent.HasFlag("replace_sql_digits"),
+		KeepSQLAlias:     c.agent.HasFlag("keep_sql_alias"),
+		DollarQuotedFunc: c.agent.HasFlag("dollar_quoted_func"),
+		Cache:            c.agent.HasFlag("sql_cache"),
+	}
+}
+
+// WithRateLimit sets the maximum number of traces kept per second by the tracer. Once a
+// trace is to be kept according to the sampler, the sampling rules or the agent rates,
+// the limit is consulted and traces exceeding it are dropped instead. This puts an absolute
+// cap on the volume of kept traces. The effective rate of the limiter is set in the
+// "_dd.limit_psr" metric of the root spans it is applied on. Traces kept manually after
+// the sampling decision are not affected.
+func WithRateLimit(tracesPerSecond float64) StartOption {
+	return func(c *config) {
+		c.traceRateLimit = tracesPerSecond
+	}
+}
+
+// agentTransportConfig holds the keep-alive settings of the connections to the agent.
+type agentTransportConfig struct {
+	maxIdleConns int
+	idleTimeout  time.Duration
+}
+
+// apply returns a copy of client using these keep-alive settings. The client is returned
+// unchanged if its transport is not an *http.Transport.
+func (at *agentTransportConfig) apply(client *http.Client) *http.Client {
+	t, ok := client.Transport.(*http.Transport)
+	if !ok {
+		log.Warn("Agent transport settings ignored: the HTTP client does not use an *http.Transport.")
+		return client
+	}
+	t = t.Clone()
+	if at.maxIdleConns <= 0 {
+		t.DisableKeepAlives = true
+	} else {
+		t.MaxIdleConns = at.maxIdleConns
+		t.MaxIdleConnsPerHost = at.maxIdleConns
+		t.IdleConnTimeout = at.idleTimeout
+	}
+	return &http.Client{
+		Transport:     t,
+		CheckRedirect: client.CheckRedirect,
+		Jar:           client.Jar,
+		Timeout:       client.Timeout,
+	}
+}
+
+// WithAgentTransport sets how the connections to the agent are reused. Up to maxIdleConns
+// connections are kept alive between flushes, and closed after staying idle for idleTimeout,
+// or never when it is zero. Keeping connections alive avoids connection churn under bursty
+// flush patterns. A maxIdleConns of zero or less disables keep-alives, opening a connection
+// per request. The settings apply to the HTTP client set using WithHTTPClient, if any, as
+// long as it uses an *http.Transport. By default, up to 2 connections are kept alive for
+// 90 seconds.
+func WithAgentTransport(maxIdleConns int, idleTimeout time.Duration) StartOption {
+	return func(c *config) {
+		c.agentTransport = &agentTransportConfig{
+			maxIdleConns: maxIdleConns,
+			idleTimeout:  idleTimeout,
+		}
+	}
+}
+
 // WithHTTPRoundTripper is deprecated. Please consider using WithHTTPClient instead.
 // The function allows customizing the underlying HTTP transport for emitting spans.
 func WithHTTPRoundTripper(r http.RoundTripper) StartOption {
@@ -684,6 +1141,17 @@ func WithRuntimeMetrics() StartOption {
 	}
 }
 
+// WithHealthMetrics enables reporting additional metrics about the health of the tracer
+// itself: the number of spans dropped, every 10 seconds, and the number of errors returned
+// by the agent. They are sent to the statsd client as "datadog.tracer.*" metrics, along with
+// the metrics about the spans started and finished and the traces dropped, which are always
+// reported. It can also be enabled by setting DD_TRACE_HEALTH_METRICS_ENABLED to true.
+func WithHealthMetrics() StartOption {
+	return func(cfg *config) {
+		cfg.healthMetrics = true
+	}
+}
+
 // WithDogstatsdAddress specifies the address to connect to for sending metrics to the Datadog
 // Agent. It should be a "host:port" string, or the path to a unix domain socket.If not set, it
 // attempts to determine the address of the statsd service according to the following rules:
@@ -732,6 +1200,16 @@ func WithUniversalVersion(version string) StartOption {
 	}
 }
 
+// WithDeploymentStage sets the stage of the deployment the tracer runs in, such as "canary"
+// or "stable", overriding the DD_DEPLOYMENT_STAGE environment variable. It is set as the
+// "deployment.stage" tag on local root spans, allowing to compare the spans of the stages
+// of a rollout independently of their version.
+func WithDeploymentStage(stage string) StartOption {
+	return func(c *config) {
+		c.deploymentStage = stage
+	}
+}
+
 // WithHostname allows specifying the hostname with which to mark outgoing traces.
 func WithHostname(name string) StartOption {
 	return func(c *config) {
@@ -739,6 +1217,27 @@ func WithHostname(name string) StartOption {
 	}
 }
 
+// WithOTLPExporter enables sending traces to the given OTLP/HTTP endpoint, such as an
+// OpenTelemetry collector, in addition to the Datadog agent. Spans are converted to
+// OTLP spans, their tags and metrics becoming attributes, and posted as protobuf
+// encoded requests along with the given headers, using the HTTP client set using
+// WithHTTPClient, if any. Only the traces kept by sampling are exported. The traces
+// path (/v1/traces) is appended to endpoints which have no path, e.g. "http://localhost:4318".
+func WithOTLPExporter(endpoint string, headers map[string]string) StartOption {
+	return func(c *config) {
+		c.otlpEndpoint = endpoint
+		c.otlpHeaders = headers
+	}
+}
+
+// WithContainerID sets the container ID with which to mark local root spans, overriding the
+// one detected from /proc/self/cgroup. It is also sent to the agent for tagging.
+func WithContainerID(id string) StartOption {
+	return func(c *config) {
+		c.containerID = id
+	}
+}
+
 // WithTraceEnabled allows specifying whether tracing will be enabled
 func WithTraceEnabled(enabled bool) StartOption {
 	return func(c *config) {
@@ -810,6 +1309,12 @@ func SpanType(name string) StartSpanOption {
 	return Tag(ext.SpanType, name)
 }
 
+// SpanKind sets the given span kind on the started span. It should be one of the
+// ext.SpanKind* values, such as ext.SpanKindClient or ext.SpanKindServer.
+func SpanKind(kind string) StartSpanOption {
+	return Tag(ext.SpanKind, kind)
+}
+
 var measuredTag = Tag(keyMeasured, 1)
 
 // Measured marks this span to be measured for metrics and stats calculations.
@@ -882,6 +1387,16 @@ func WithError(err error) FinishOption {
 	}
 }
 
+// WithErrorFlag marks the span as having had an error when errored is true, without
+// attaching an error value to it, such as when an HTTP server responds with a 500 status
+// and no Go error is available. As opposed to WithError, no error message, type or stack
+// trace tags are set. It has no effect if errored is false, or if WithError is also used.
+func WithErrorFlag(errored bool) FinishOption {
+	return func(cfg *ddtrace.FinishConfig) {
+		cfg.ErrorFlag = errored
+	}
+}
+
 // NoDebugStack prevents any error presented using the WithError finishing option
 // from generating a stack trace. This is useful in situations where errors are frequent
 // and performance is critical.
@@ -21,6 +21,7 @@ import (
 	"time"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/traceprof"
 
 	"github.com/stretchr/testify/assert"
@@ -953,3 +954,114 @@ func TestWithLogStartup(t *testing.T) {
 	WithLogStartup(true)(c)
 	assert.True(t, c.logStartup)
 }
+
+func TestWithLogRateLimit(t *testing.T) {
+	tp := new(log.RecordLogger)
+	defer log.UseLogger(tp)()
+	defer log.ResetRate()
+
+	t.Run("collapsed", func(t *testing.T) {
+		tp.Reset()
+		tp.Ignore("Loading features", "appsec", "telemetry")
+		Start(withTransport(newDummyTransport()), WithLogStartup(false), WithLogRateLimit(time.Hour))
+		defer Stop()
+		for i := 0; i < 3; i++ {
+			log.Error("failure sending traces (attempt %d), will retry: %v", i+1, "connection refused")
+		}
+		assert.Len(t, tp.Logs(), 0)
+		log.Flush()
+		logs := tp.Logs()
+		require.Len(t, logs, 1)
+		assert.Contains(t, logs[0], "failure sending traces (attempt 1), will retry: connection refused, 2 additional messages skipped")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		tp.Reset()
+		tp.Ignore("Loading features", "appsec", "telemetry")
+		Start(withTransport(newDummyTransport()), WithLogStartup(false), WithLogRateLimit(0))
+		defer Stop()
+		for i := 0; i < 3; i++ {
+			log.Error("lost %d traces: %v", 1, "connection refused")
+		}
+		assert.Len(t, tp.Logs(), 3)
+	})
+
+	t.Run("scoped", func(t *testing.T) {
+		tp.Reset()
+		tp.Ignore("Loading features", "appsec", "telemetry")
+		// the limit only applies to the started tracer
+		newConfig(WithLogRateLimit(0))
+		log.Error("lost %d traces: %v", 1, "connection refused")
+		assert.Len(t, tp.Logs(), 0)
+		log.Flush()
+
+		// and is reset once it is stopped
+		Start(withTransport(newDummyTransport()), WithLogStartup(false), WithLogRateLimit(0))
+		Stop()
+		tp.Reset()
+		log.Error("lost %d traces: %v", 1, "connection refused")
+		assert.Len(t, tp.Logs(), 0)
+		log.Flush()
+		assert.Len(t, tp.Logs(), 1)
+	})
+}
+
+func TestWithMaxPayloadSize(t *testing.T) {
+	c := newConfig()
+	assert.Equal(t, int(payloadSizeLimit), c.maxPayloadSize)
+	WithMaxPayloadSize(1024)(c)
+	assert.Equal(t, 1024, c.maxPayloadSize)
+	WithMaxPayloadSize(0)(c)
+	assert.Equal(t, 1024, c.maxPayloadSize)
+	WithMaxPayloadSize(payloadMaxLimit + 1)(c)
+	assert.Equal(t, 1024, c.maxPayloadSize)
+}
+
+func TestWithObfuscatorConfig(t *testing.T) {
+	t.Run("agent", func(t *testing.T) {
+		c := newConfig()
+		c.agent.featureFlags = map[string]struct{}{"quantize_sql_tables": {}, "keep_sql_alias": {}}
+		oc := c.sqlObfuscationConfig()
+		assert.True(t, oc.ReplaceDigits)
+		assert.True(t, oc.KeepSQLAlias)
+		assert.False(t, oc.TableNames)
+		assert.False(t, oc.DollarQuotedFunc)
+	})
+
+	t.Run("override", func(t *testing.T) {
+		c := newConfig(WithObfuscatorConfig(ObfuscatorConfig{TableNames: true, DollarQuotedFunc: true}))
+		c.agent.featureFlags = map[string]struct{}{"quantize_sql_tables": {}, "keep_sql_alias": {}, "sql_cache": {}}
+		oc := c.sqlObfuscationConfig()
+		assert.False(t, oc.ReplaceDigits)
+		assert.False(t, oc.KeepSQLAlias)
+		assert.True(t, oc.TableNames)
+		assert.True(t, oc.DollarQuotedFunc)
+		assert.True(t, oc.Cache)
+	})
+
+	// expected outputs of the agent's SQL obfuscator for the same settings: literals are
+	// replaced with "?", dropping the commas following them, lists of values are collapsed,
+	// aliases and comments dropped, and tokens separated by single spaces
+	for _, tt := range []struct {
+		cfg   ObfuscatorConfig
+		query string
+		want  string
+	}{
+		{ObfuscatorConfig{}, "SELECT username AS person FROM users WHERE id=4", "SELECT username FROM users WHERE id = ?"},
+		{ObfuscatorConfig{KeepSQLAlias: true}, "SELECT username AS person FROM users WHERE id=4", "SELECT username AS person FROM users WHERE id = ?"},
+		{ObfuscatorConfig{}, "SELECT * FROM sales_2019 WHERE price > 10.5", "SELECT * FROM sales_2019 WHERE price > ?"},
+		{ObfuscatorConfig{ReplaceDigits: true}, "SELECT * FROM sales_2019 WHERE price > 10.5", "SELECT * FROM sales_? WHERE price > ?"},
+		{ObfuscatorConfig{}, "SELECT id FROM users WHERE id IN (1, 2, 3)", "SELECT id FROM users WHERE id IN ( ? )"},
+		{ObfuscatorConfig{}, "SELECT * FROM t WHERE a = $1 AND b = ?", "SELECT * FROM t WHERE a = ? AND b = ?"},
+		{ObfuscatorConfig{}, "UPDATE users SET name = 'bob', age = 30 WHERE id = 1", "UPDATE users SET name = ? age = ? WHERE id = ?"},
+		{ObfuscatorConfig{}, "INSERT INTO items (id, name) VALUES (1, 'a'), (2, 'b')", "INSERT INTO items ( id, name ) VALUES ( ? )"},
+		{ObfuscatorConfig{}, "DELETE FROM users WHERE email = 'a@b.c' -- cleanup", "DELETE FROM users WHERE email = ?"},
+		{ObfuscatorConfig{}, "SELECT $func$INSERT INTO t VALUES ('a', 1)$func$ FROM users", "SELECT ? FROM users"},
+		{ObfuscatorConfig{DollarQuotedFunc: true}, "SELECT $func$INSERT INTO t VALUES ('a', 1)$func$ FROM users", "SELECT $func$INSERT INTO t VALUES ( ? )$func$ FROM users"},
+	} {
+		t.Run(tt.query, func(t *testing.T) {
+			tracer := newUnstartedTracer(WithObfuscatorConfig(tt.cfg))
+			assert.Equal(t, tt.want, obfuscatedResource(tracer.obfuscator, "sql", tt.query))
+		})
+	}
+}
@@ -0,0 +1,379 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/version"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// otlpTracesPath is the path on which OTLP/HTTP receivers accept traces.
+const otlpTracesPath = "/v1/traces"
+
+// OTLP span kinds, as defined in opentelemetry/proto/trace/v1/trace.proto.
+const (
+	otlpSpanKindUnspecified = 0
+	otlpSpanKindInternal    = 1
+	otlpSpanKindServer      = 2
+	otlpSpanKindClient      = 3
+	otlpSpanKindProducer    = 4
+	otlpSpanKindConsumer    = 5
+)
+
+// otlpStatusCodeError is the OTLP status code of spans which ended with an error.
+const otlpStatusCodeError = 2
+
+// otlpTraceWriter sends traces to an OTLP/HTTP receiver, such as the OpenTelemetry
+// collector, as protobuf encoded ExportTraceServiceRequest messages. Spans are
+// converted when added, so that the finished spans are not retained until the flush.
+type otlpTraceWriter struct {
+	config  *config
+	url     string
+	headers map[string]string
+	client  *http.Client
+
+	// spans holds the encoded OTLP spans, as repeated ScopeSpans fields, by service.
+	spans map[string][]byte
+	// size is the total size of the encoded spans.
+	size int
+
+	// climit limits the number of concurrent outgoing connections
+	climit chan struct{}
+
+	// wg waits for all uploads to finish
+	wg sync.WaitGroup
+
+	// statsd is used to send metrics
+	statsd statsdClient
+}
+
+func newOTLPTraceWriter(c *config, statsdClient statsdClient) *otlpTraceWriter {
+	client := c.httpClient
+	if c.agentURL.Scheme == "unix" {
+		// the client of the agent would dial its socket; the receiver is reached over TCP
+		client = defaultClient
+	}
+	return &otlpTraceWriter{
+		config:  c,
+		url:     otlpTracesURL(c.otlpEndpoint),
+		headers: c.otlpHeaders,
+		client:  client,
+		spans:   make(map[string][]byte),
+		climit:  make(chan struct{}, concurrentConnectionLimit),
+		statsd:  statsdClient,
+	}
+}
+
+// otlpSampled reports whether trace is kept by sampling, i.e. it has no sampling
+// priority or a positive one. Only such traces are exported.
+func otlpSampled(trace []*span) bool {
+	if len(trace) == 0 {
+		return false
+	}
+	s := trace[0]
+	if s.context != nil {
+		if p, ok := s.context.samplingPriority(); ok {
+			return p > 0
+		}
+	}
+	if p, ok := s.Metrics[keySamplingPriority]; ok {
+		return p > 0
+	}
+	return true
+}
+
+// otlpTracesURL returns the URL to send traces to for the given endpoint. The
+// traces path is appended to endpoints which only specify a host.
+func otlpTracesURL(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Path != "" && u.Path != "/") {
+		return endpoint
+	}
+	u.Path = otlpTracesPath
+	return u.String()
+}
+
+func (w *otlpTraceWriter) add(trace []*span) {
+	if !otlpSampled(trace) {
+		// the agent receives these for its stats, but they are not meant to be stored
+		return
+	}
+	for _, s := range trace {
+		b := w.spans[s.Service]
+		n := len(b)
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendBytes(b, encodeOTLPSpan(s))
+		w.spans[s.Service] = b
+		w.size += len(b) - n
+	}
+	if w.size > w.config.maxPayloadSize {
+		w.statsd.Incr("datadog.tracer.otlp.flush_triggered", []string{"reason:size"}, 1)
+		w.flush()
+	}
+}
+
+func (w *otlpTraceWriter) stop() {
+	w.flush()
+	w.wg.Wait()
+}
+
+// flush sends any currently buffered spans to the receiver.
+func (w *otlpTraceWriter) flush() {
+	if w.size == 0 {
+		return
+	}
+	body := w.encodeRequest()
+	w.spans = make(map[string][]byte)
+	w.size = 0
+	w.wg.Add(1)
+	w.climit <- struct{}{}
+	go func() {
+		defer func() {
+			<-w.climit
+			w.wg.Done()
+		}()
+		var err error
+		for attempt := 0; attempt <= w.config.sendRetries; attempt++ {
+			if err = w.send(body); err == nil {
+				w.statsd.Count("datadog.tracer.otlp.flush_bytes", int64(len(body)), nil, 1)
+				return
+			}
+			log.Error("failure sending OTLP traces (attempt %d), will retry: %v", attempt+1, err)
+			time.Sleep(time.Millisecond)
+		}
+		w.statsd.Incr("datadog.tracer.otlp.payloads_dropped", nil, 1)
+		log.Error("lost OTLP traces payload: %v", err)
+	}()
+}
+
+// send posts the encoded request body to the receiver.
+func (w *otlpTraceWriter) send(body []byte) error {
+	req, err := http.NewRequest("POST", w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("cannot create http request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	for k, v := range w.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if code := resp.StatusCode; code >= 400 {
+		return fmt.Errorf("%s", http.StatusText(code))
+	}
+	return nil
+}
+
+// encodeRequest returns the ExportTraceServiceRequest holding the buffered spans,
+// with one ResourceSpans per service.
+func (w *otlpTraceWriter) encodeRequest() []byte {
+	services := make([]string, 0, len(w.spans))
+	for svc := range w.spans {
+		services = append(services, svc)
+	}
+	sort.Strings(services)
+
+	var scope []byte
+	scope = appendOTLPString(scope, 1, "dd-trace-go")
+	scope = appendOTLPString(scope, 2, version.Tag)
+
+	var req []byte
+	for _, svc := range services {
+		var resource []byte
+		resource = appendOTLPAttribute(resource, 1, "service.name", svc)
+		if w.config.env != "" {
+			resource = appendOTLPAttribute(resource, 1, "deployment.environment", w.config.env)
+		}
+		if w.config.version != "" {
+			resource = appendOTLPAttribute(resource, 1, "service.version", w.config.version)
+		}
+		resource = appendOTLPAttribute(resource, 1, "telemetry.sdk.name", "datadog")
+		resource = appendOTLPAttribute(resource, 1, "telemetry.sdk.language", "go")
+		resource = appendOTLPAttribute(resource, 1, "telemetry.sdk.version", version.Tag)
+
+		scopeSpans := appendOTLPMessage(nil, 1, scope)
+		scopeSpans = append(scopeSpans, w.spans[svc]...)
+
+		var rs []byte
+		rs = appendOTLPMessage(rs, 1, resource)
+		rs = appendOTLPMessage(rs, 2, scopeSpans)
+		req = appendOTLPMessage(req, 1, rs)
+	}
+	return req
+}
+
+// otlpTraceID returns the 128-bit ID of the trace of s: the full ID of traces continued
+// from W3C trace context headers or started using WithIDGenerator128, and the 64-bit ID of
+// the trace in the low order bytes otherwise.
+func otlpTraceID(s *span) [16]byte {
+	var id [16]byte
+	binary.BigEndian.PutUint64(id[8:], s.TraceID)
+	if s.context == nil || s.context.trace == nil {
+		return id
+	}
+	t := s.context.trace
+	t.mu.RLock()
+	full := t.propagatingTags[w3cTraceIDTag]
+	t.mu.RUnlock()
+	if len(full) != 32 {
+		return id
+	}
+	var full128 [16]byte
+	if _, err := hex.Decode(full128[:], []byte(full)); err != nil || binary.BigEndian.Uint64(full128[8:]) != s.TraceID {
+		return id
+	}
+	return full128
+}
+
+// encodeOTLPSpan encodes s as an OTLP Span message. Trace IDs are mapped to their full
+// 128-bit value when known (see otlpTraceID), tags and metrics to attributes, and errors
+// to the span status.
+func encodeOTLPSpan(s *span) []byte {
+	var spanID, parentID [8]byte
+	traceID := otlpTraceID(s)
+	binary.BigEndian.PutUint64(spanID[:], s.SpanID)
+
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendBytes(b, traceID[:])
+	b = protowire.AppendTag(b, 2, protowire.BytesType)
+	b = protowire.AppendBytes(b, spanID[:])
+	if s.ParentID != 0 {
+		binary.BigEndian.PutUint64(parentID[:], s.ParentID)
+		b = protowire.AppendTag(b, 4, protowire.BytesType)
+		b = protowire.AppendBytes(b, parentID[:])
+	}
+	b = appendOTLPString(b, 5, s.Name)
+	if kind := otlpSpanKind(s.Meta[ext.SpanKind]); kind != otlpSpanKindUnspecified {
+		b = protowire.AppendTag(b, 6, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(kind))
+	}
+	b = protowire.AppendTag(b, 7, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, uint64(s.Start))
+	b = protowire.AppendTag(b, 8, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, uint64(s.Start+s.Duration))
+
+	b = appendOTLPAttribute(b, 9, "resource.name", s.Resource)
+	if s.Type != "" {
+		b = appendOTLPAttribute(b, 9, "span.type", s.Type)
+	}
+	keys := make([]string, 0, len(s.Meta))
+	for k := range s.Meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b = appendOTLPAttribute(b, 9, k, s.Meta[k])
+	}
+	keys = keys[:0]
+	for k := range s.Metrics {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b = appendOTLPAttribute(b, 9, k, s.Metrics[k])
+	}
+
+	if s.Error != 0 {
+		var status []byte
+		status = appendOTLPString(status, 2, s.Meta[ext.ErrorMsg])
+		status = protowire.AppendTag(status, 3, protowire.VarintType)
+		status = protowire.AppendVarint(status, otlpStatusCodeError)
+		b = appendOTLPMessage(b, 15, status)
+	}
+	return b
+}
+
+// otlpSpanKind returns the OTLP span kind corresponding to the given span.kind tag.
+func otlpSpanKind(kind string) int {
+	switch kind {
+	case ext.SpanKindServer:
+		return otlpSpanKindServer
+	case ext.SpanKindClient:
+		return otlpSpanKindClient
+	case ext.SpanKindProducer:
+		return otlpSpanKindProducer
+	case ext.SpanKindConsumer:
+		return otlpSpanKindConsumer
+	case ext.SpanKindInternal:
+		return otlpSpanKindInternal
+	default:
+		return otlpSpanKindUnspecified
+	}
+}
+
+// appendOTLPString appends the string field num to b, if not empty.
+func appendOTLPString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+// appendOTLPMessage appends the embedded message field num to b.
+func appendOTLPMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+// appendOTLPAttribute appends the KeyValue field num to b, holding the given key
+// and a string or double value.
+func appendOTLPAttribute(b []byte, num protowire.Number, key string, value interface{}) []byte {
+	var v []byte
+	switch value := value.(type) {
+	case string:
+		v = protowire.AppendTag(v, 1, protowire.BytesType)
+		v = protowire.AppendString(v, value)
+	case float64:
+		v = protowire.AppendTag(v, 4, protowire.Fixed64Type)
+		v = protowire.AppendFixed64(v, math.Float64bits(value))
+	}
+	var kv []byte
+	kv = appendOTLPString(kv, 1, key)
+	kv = appendOTLPMessage(kv, 2, v)
+	return appendOTLPMessage(b, num, kv)
+}
+
+// multiTraceWriter sends traces to all of its writers.
+type multiTraceWriter []traceWriter
+
+func (w multiTraceWriter) add(trace []*span) {
+	for _, tw := range w {
+		tw.add(trace)
+	}
+}
+
+func (w multiTraceWriter) flush() {
+	for _, tw := range w {
+		tw.flush()
+	}
+}
+
+func (w multiTraceWriter) stop() {
+	for _, tw := range w {
+		tw.stop()
+	}
+}
@@ -0,0 +1,266 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+)
+
+// otlpMessage holds the decoded fields of a protobuf message, by field number.
+// Values are []byte for length-delimited fields and uint64 otherwise.
+type otlpMessage map[protowire.Number][]interface{}
+
+func decodeOTLPMessage(t *testing.T, b []byte) otlpMessage {
+	m := make(otlpMessage)
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		require.True(t, n > 0, "invalid tag")
+		b = b[n:]
+		var v interface{}
+		switch typ {
+		case protowire.BytesType:
+			v, n = protowire.ConsumeBytes(b)
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			v, n = protowire.ConsumeFixed64(b)
+		default:
+			t.Fatalf("unexpected wire type %v", typ)
+		}
+		require.True(t, n > 0, "invalid value")
+		b = b[n:]
+		m[num] = append(m[num], v)
+	}
+	return m
+}
+
+func (m otlpMessage) message(t *testing.T, num protowire.Number) otlpMessage {
+	require.Len(t, m[num], 1)
+	return decodeOTLPMessage(t, m[num][0].([]byte))
+}
+
+func (m otlpMessage) messages(t *testing.T, num protowire.Number) []otlpMessage {
+	var msgs []otlpMessage
+	for _, v := range m[num] {
+		msgs = append(msgs, decodeOTLPMessage(t, v.([]byte)))
+	}
+	return msgs
+}
+
+// attributes decodes the KeyValue fields num of m into a map.
+func (m otlpMessage) attributes(t *testing.T, num protowire.Number) map[string]interface{} {
+	attrs := make(map[string]interface{})
+	for _, kv := range m.messages(t, num) {
+		v := kv.message(t, 2)
+		if s, ok := v[1]; ok {
+			attrs[string(kv[1][0].([]byte))] = string(s[0].([]byte))
+		} else {
+			attrs[string(kv[1][0].([]byte))] = math.Float64frombits(v[4][0].(uint64))
+		}
+	}
+	return attrs
+}
+
+// fakeOTLPReceiver returns a server receiving OTLP requests into the returned channel.
+func fakeOTLPReceiver(t *testing.T) (*httptest.Server, <-chan *http.Request, <-chan otlpMessage) {
+	reqs := make(chan *http.Request, 10)
+	msgs := make(chan otlpMessage, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		reqs <- r
+		msgs <- decodeOTLPMessage(t, b)
+	}))
+	return srv, reqs, msgs
+}
+
+func TestOTLPExporter(t *testing.T) {
+	srv, reqs, msgs := fakeOTLPReceiver(t)
+	defer srv.Close()
+
+	tracer, transport, flush, stop := startTestTracer(t,
+		WithService("otlp-service"),
+		WithEnv("test"),
+		WithOTLPExporter(srv.URL, map[string]string{"X-Api-Key": "secret"}),
+	)
+	defer stop()
+
+	root := tracer.StartSpan("http.request", ResourceName("GET /"), SpanType(ext.SpanTypeWeb), Tag(ext.SpanKind, ext.SpanKindServer)).(*span)
+	child := tracer.StartSpan("db.query", ChildOf(root.Context()), Tag(ext.SpanKind, ext.SpanKindClient)).(*span)
+	child.Finish(WithError(errors.New("query failed")))
+	root.SetTag("count", 3)
+	root.Finish()
+	flush(1)
+
+	// the agent still receives the traces
+	assert.Len(t, transport.Traces(), 1)
+
+	var req *http.Request
+	var msg otlpMessage
+	select {
+	case req = <-reqs:
+		msg = <-msgs
+	case <-time.After(time.Second * timeMultiplicator):
+		t.Fatal("timed out waiting for the OTLP request")
+	}
+	assert.Equal(t, "/v1/traces", req.URL.Path)
+	assert.Equal(t, "application/x-protobuf", req.Header.Get("Content-Type"))
+	assert.Equal(t, "secret", req.Header.Get("X-Api-Key"))
+
+	rs := msg.messages(t, 1)
+	require.Len(t, rs, 1)
+	resource := rs[0].message(t, 1).attributes(t, 1)
+	assert.Equal(t, "otlp-service", resource["service.name"])
+	assert.Equal(t, "test", resource["deployment.environment"])
+	assert.Equal(t, "go", resource["telemetry.sdk.language"])
+
+	scopeSpans := rs[0].message(t, 2)
+	assert.Equal(t, "dd-trace-go", string(scopeSpans.message(t, 1)[1][0].([]byte)))
+	spans := scopeSpans.messages(t, 2)
+	require.Len(t, spans, 2)
+	byName := make(map[string]otlpMessage)
+	for _, s := range spans {
+		byName[string(s[5][0].([]byte))] = s
+	}
+
+	s := byName["http.request"]
+	require.NotNil(t, s)
+	traceID := s[1][0].([]byte)
+	require.Len(t, traceID, 16)
+	assert.Equal(t, root.TraceID, binary.BigEndian.Uint64(traceID[8:]))
+	assert.Equal(t, root.SpanID, binary.BigEndian.Uint64(s[2][0].([]byte)))
+	assert.Nil(t, s[4])
+	assert.Equal(t, uint64(otlpSpanKindServer), s[6][0])
+	assert.Equal(t, uint64(root.Start), s[7][0])
+	assert.Equal(t, uint64(root.Start+root.Duration), s[8][0])
+	attrs := s.attributes(t, 9)
+	assert.Equal(t, "GET /", attrs["resource.name"])
+	assert.Equal(t, ext.SpanTypeWeb, attrs["span.type"])
+	assert.Equal(t, 3.0, attrs["count"])
+	assert.Nil(t, s[15])
+
+	s = byName["db.query"]
+	require.NotNil(t, s)
+	assert.Equal(t, traceID, s[1][0])
+	assert.Equal(t, root.SpanID, binary.BigEndian.Uint64(s[4][0].([]byte)))
+	assert.Equal(t, uint64(otlpSpanKindClient), s[6][0])
+	status := s.message(t, 15)
+	assert.Equal(t, "query failed", string(status[2][0].([]byte)))
+	assert.Equal(t, uint64(otlpStatusCodeError), status[3][0])
+}
+
+func TestOTLPTraceID128(t *testing.T) {
+	tracer, _, _, stop := startTestTracer(t)
+	defer stop()
+
+	t.Run("w3c", func(t *testing.T) {
+		sctx, err := tracer.Extract(TextMapCarrier{
+			traceparentHeader: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		})
+		require.NoError(t, err)
+		s := tracer.StartSpan("http.request", ChildOf(sctx)).(*span)
+		id := otlpTraceID(s)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", hex.EncodeToString(id[:]))
+
+		msg := decodeOTLPMessage(t, encodeOTLPSpan(s))
+		assert.Equal(t, id[:], msg[1][0])
+	})
+
+	t.Run("generator", func(t *testing.T) {
+		tracer, _, _, stop := startTestTracer(t, WithIDGenerator128(func() [16]byte {
+			return [16]byte{0x12, 0x34, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x56, 0x78}
+		}))
+		defer stop()
+		s := tracer.StartSpan("http.request").(*span)
+		id := otlpTraceID(s)
+		assert.Equal(t, "12340000000000000000000000005678", hex.EncodeToString(id[:]))
+	})
+
+	t.Run("64-bit", func(t *testing.T) {
+		s := tracer.StartSpan("http.request").(*span)
+		id := otlpTraceID(s)
+		assert.Equal(t, make([]byte, 8), id[:8])
+		assert.Equal(t, s.TraceID, binary.BigEndian.Uint64(id[8:]))
+	})
+}
+
+// countingRoundTripper counts the OTLP requests it forwards to the default transport.
+type countingRoundTripper struct {
+	n int32
+}
+
+func (rt *countingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Path == otlpTracesPath {
+		atomic.AddInt32(&rt.n, 1)
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestOTLPTraceWriter(t *testing.T) {
+	srv, _, msgs := fakeOTLPReceiver(t)
+	defer srv.Close()
+
+	rt := new(countingRoundTripper)
+	c := newConfig(
+		WithOTLPExporter(srv.URL, nil),
+		WithHTTPClient(&http.Client{Transport: rt}),
+		withTransport(newDummyTransport()),
+	)
+	w := newOTLPTraceWriter(c, &testStatsdClient{})
+
+	kept, dropped, unset := newBasicSpan("kept"), newBasicSpan("dropped"), newBasicSpan("unset")
+	kept.Metrics[keySamplingPriority] = ext.PriorityAutoKeep
+	dropped.Metrics[keySamplingPriority] = ext.PriorityAutoReject
+	w.add([]*span{kept})
+	w.add([]*span{dropped})
+	w.add([]*span{unset})
+	w.flush()
+	w.wg.Wait()
+
+	// the configured HTTP client is used
+	assert.EqualValues(t, 1, atomic.LoadInt32(&rt.n))
+	var names []string
+	for _, rs := range (<-msgs).messages(t, 1) {
+		for _, s := range rs.message(t, 2).messages(t, 2) {
+			names = append(names, string(s[5][0].([]byte)))
+		}
+	}
+	assert.ElementsMatch(t, []string{"kept", "unset"}, names)
+}
+
+func TestOTLPTracesURL(t *testing.T) {
+	for in, out := range map[string]string{
+		"http://localhost:4318":           "http://localhost:4318/v1/traces",
+		"http://localhost:4318/":          "http://localhost:4318/v1/traces",
+		"http://localhost:4318/v1/traces": "http://localhost:4318/v1/traces",
+		"https://collector/custom/path":   "https://collector/custom/path",
+	} {
+		assert.Equal(t, out, otlpTracesURL(in))
+	}
+}
+
+func TestOTLPExporterDisabled(t *testing.T) {
+	tracer, _, _, stop := startTestTracer(t)
+	defer stop()
+
+	_, ok := tracer.traceWriter.(multiTraceWriter)
+	assert.False(t, ok)
+}
@@ -101,6 +101,15 @@ func (p *payload) reset() {
 	}
 }
 
+// clone returns a copy of p holding the same items, which can be read independently of p.
+func (p *payload) clone() *payload {
+	c := newPayload()
+	c.buf.Write(p.buf.Bytes())
+	atomic.StoreUint32(&c.count, atomic.LoadUint32(&p.count))
+	c.updateHeader()
+	return c
+}
+
 // clear empties the payload buffers.
 func (p *payload) clear() {
 	p.buf = bytes.Buffer{}
@@ -0,0 +1,30 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"sort"
+	"strings"
+)
+
+// keyProcessTags holds the tags describing the process, as set using WithProcessTags. The
+// payloads of the agent API have no header of their own, so the tags are set on the first
+// span of the first trace chunk of each payload, from which they apply to the whole payload.
+const keyProcessTags = "_dd.tags.process"
+
+// encodeProcessTags encodes the given tags as a comma-separated list of key:value pairs,
+// sorted by key. It returns an empty string when there are no tags.
+func encodeProcessTags(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(tags))
+	for k, v := range tags {
+		pairs = append(pairs, k+":"+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
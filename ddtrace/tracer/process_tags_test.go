@@ -0,0 +1,49 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeProcessTags(t *testing.T) {
+	assert.Equal(t, "", encodeProcessTags(nil))
+	assert.Equal(t, "host:i-123,runtime:go", encodeProcessTags(map[string]string{"runtime": "go", "host": "i-123"}))
+}
+
+func TestWithProcessTags(t *testing.T) {
+	transport := newDummyTransport()
+	c := newConfig(
+		withTransport(transport),
+		WithProcessTags(map[string]string{"runtime": "go"}),
+		WithProcessTags(map[string]string{"host": "i-123"}),
+	)
+	h := newAgentTraceWriter(c, newPrioritySampler(), &testStatsdClient{})
+
+	// the process tags are sent once per payload, on the first span of its first chunk
+	for payload := 0; payload < 2; payload++ {
+		for i := 0; i < 3; i++ {
+			h.add([]*span{makeSpan(0), makeSpan(0)})
+		}
+		h.flush()
+		h.wg.Wait()
+
+		traces := transport.Traces()
+		assert.Len(t, traces, 3)
+		var count int
+		for _, trace := range traces {
+			for _, s := range trace {
+				if _, ok := s.Meta[keyProcessTags]; ok {
+					count++
+				}
+			}
+		}
+		assert.Equal(t, 1, count)
+		assert.Equal(t, "host:i-123,runtime:go", traces[0][0].Meta[keyProcessTags])
+	}
+}
@@ -202,6 +202,7 @@ type traceRulesSampler struct {
 	rules      []SamplingRule // the rules to match spans with
 	globalRate float64        // a rate to apply when no rules match a span
 	limiter    *rateLimiter   // used to limit the volume of spans sampled
+	seed       *uint64        // the seed of the sampling decisions, as set using WithSamplingSeed
 }
 
 // newTraceRulesSampler configures a *traceRulesSampler instance using the given set of rules.
@@ -263,13 +264,13 @@ func (rs *traceRulesSampler) apply(span *span) bool {
 		return false
 	}
 
-	rs.applyRule(span, rate, time.Now())
+	rs.applyRule(span, rate, nowTime())
 	return true
 }
 
 func (rs *traceRulesSampler) applyRule(span *span, rate float64, now time.Time) {
 	span.SetTag(keyRulesSamplerAppliedRate, rate)
-	if !sampledByRate(span.TraceID, rate) {
+	if !sampledByRate(seededSampleKey(span.TraceID, rs.samplingSeed()), rate) {
 		span.setSamplingPriority(ext.PriorityUserReject, samplernames.RuleRate)
 		return
 	}
@@ -283,6 +284,15 @@ func (rs *traceRulesSampler) applyRule(span *span, rate float64, now time.Time)
 	span.SetTag(keyRulesSamplerLimiterRate, rate)
 }
 
+// samplingSeed returns the seed set using WithSamplingSeed, if any. It is safe to call on
+// a nil rules sampler.
+func (rs *traceRulesSampler) samplingSeed() *uint64 {
+	if rs == nil {
+		return nil
+	}
+	return rs.seed
+}
+
 // limit returns the rate limit set in the rules sampler, controlled by DD_TRACE_RATE_LIMIT, and
 // true if rules sampling is enabled. If not present it returns math.NaN() and false.
 func (rs *traceRulesSampler) limit() (float64, bool) {
@@ -313,7 +323,7 @@ func newRateLimiter() *rateLimiter {
 	}
 	return &rateLimiter{
 		limiter:  rate.NewLimiter(rate.Limit(limit), int(math.Ceil(limit))),
-		prevTime: time.Now(),
+		prevTime: nowTime(),
 	}
 }
 
@@ -429,7 +439,16 @@ func newSingleSpanRateLimiter(mps float64) *rateLimiter {
 	}
 	return &rateLimiter{
 		limiter:  rate.NewLimiter(rate.Limit(limit), int(math.Ceil(limit))),
-		prevTime: time.Now(),
+		prevTime: nowTime(),
+	}
+}
+
+// newTraceRateLimiter returns a rate limiter which restricts the number of traces kept
+// per second to the given limit, as set using WithRateLimit, starting at the given time.
+func newTraceRateLimiter(limit float64, start time.Time) *rateLimiter {
+	return &rateLimiter{
+		limiter:  rate.NewLimiter(rate.Limit(limit), int(math.Ceil(limit))),
+		prevTime: start,
 	}
 }
 
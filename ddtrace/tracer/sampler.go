@@ -90,12 +90,29 @@ func sampledByRate(n uint64, rate float64) bool {
 	return true
 }
 
+// seededSampleKey returns the number the sampling decision of the trace with the given ID is
+// based on. It is the trace ID itself, unless a seed is set using WithSamplingSeed, in which case
+// it is a deterministic function of the trace ID and the seed, hashed using the finalizer of
+// SplitMix64, such that different seeds sample different traces.
+func seededSampleKey(traceID uint64, seed *uint64) uint64 {
+	if seed == nil {
+		return traceID
+	}
+	z := (traceID ^ *seed) + 0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
 // prioritySampler holds a set of per-service sampling rates and applies
 // them to spans.
 type prioritySampler struct {
 	mu          sync.RWMutex
 	rates       map[string]float64
 	defaultRate float64
+
+	// seed, when set, is the seed of the sampling decisions, as set using WithSamplingSeed.
+	seed *uint64
 }
 
 func newPrioritySampler() *prioritySampler {
@@ -141,7 +158,7 @@ func (ps *prioritySampler) getRate(spn *span) float64 {
 // to modify the span.
 func (ps *prioritySampler) apply(spn *span) {
 	rate := ps.getRate(spn)
-	if sampledByRate(spn.TraceID, rate) {
+	if sampledByRate(seededSampleKey(spn.TraceID, ps.seed), rate) {
 		spn.setSamplingPriority(ext.PriorityAutoKeep, samplernames.AgentRate)
 	} else {
 		spn.setSamplingPriority(ext.PriorityAutoReject, samplernames.AgentRate)
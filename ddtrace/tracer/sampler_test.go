@@ -13,11 +13,13 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/clock"
 
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/time/rate"
@@ -869,6 +871,94 @@ func BenchmarkRulesSampler(b *testing.B) {
 	})
 }
 
+func TestWithRateLimit(t *testing.T) {
+	// each subtest uses a fake clock, advanced by the test between the spans
+	start := time.Now()
+
+	t.Run("converges", func(t *testing.T) {
+		fake := clock.NewFake(start)
+		defer clock.Set(fake)()
+		const limit = 100
+		tracer := newTracer(WithRateLimit(limit))
+		defer tracer.Stop()
+
+		var kept, dropped int64
+		var wg sync.WaitGroup
+		const seconds, steps, perStep = 10, 100, 50 // 5000 traces per second
+		for i := 0; i < seconds*steps; i++ {
+			for j := 0; j < perStep; j++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					s := tracer.StartSpan("http.request").(*span)
+					p, _ := s.context.samplingPriority()
+					if p > 0 {
+						atomic.AddInt64(&kept, 1)
+					} else {
+						atomic.AddInt64(&dropped, 1)
+					}
+				}()
+			}
+			wg.Wait()
+			fake.Advance(time.Second / steps)
+		}
+		assert.EqualValues(t, seconds*steps*perStep, kept+dropped)
+		// the bucket starts full, then refills at the limit
+		assert.InDelta(t, limit*seconds+limit, kept, limit/10)
+		rate := float64(kept-limit) / seconds
+		assert.InEpsilon(t, limit, rate, 0.05)
+	})
+
+	t.Run("decision", func(t *testing.T) {
+		fake := clock.NewFake(start)
+		defer clock.Set(fake)()
+		tracer := newTracer(WithRateLimit(1))
+		defer tracer.Stop()
+
+		s := tracer.StartSpan("http.request").(*span)
+		p, _ := s.context.samplingPriority()
+		assert.Equal(t, ext.PriorityAutoKeep, p)
+		assert.Equal(t, 1.0, s.Metrics[keyRulesSamplerLimiterRate])
+
+		s = tracer.StartSpan("http.request").(*span)
+		p, _ = s.context.samplingPriority()
+		assert.Equal(t, ext.PriorityAutoReject, p)
+		assert.Equal(t, 0.5, s.Metrics[keyRulesSamplerLimiterRate])
+		assert.NotContains(t, s.context.trace.propagatingTags, keyDecisionMaker)
+
+		// traces kept manually are not limited
+		s = tracer.StartSpan("http.request", Tag(ext.ManualKeep, true)).(*span)
+		p, _ = s.context.samplingPriority()
+		assert.Equal(t, ext.PriorityUserKeep, p)
+		child := tracer.StartSpan("child", ChildOf(s.Context())).(*span)
+		p, _ = child.context.samplingPriority()
+		assert.Equal(t, ext.PriorityUserKeep, p)
+	})
+
+	t.Run("rules", func(t *testing.T) {
+		fake := clock.NewFake(start)
+		defer clock.Set(fake)()
+		tracer := newTracer(WithRateLimit(1), WithSamplingRules([]SamplingRule{RateRule(1)}))
+		defer tracer.Stop()
+
+		s := tracer.StartSpan("http.request").(*span)
+		p, _ := s.context.samplingPriority()
+		assert.Equal(t, ext.PriorityUserKeep, p)
+
+		// traces kept by the rules are downgraded to user reject
+		s = tracer.StartSpan("http.request").(*span)
+		p, _ = s.context.samplingPriority()
+		assert.Equal(t, ext.PriorityUserReject, p)
+		assert.Equal(t, 0.5, s.Metrics[keyRulesSamplerLimiterRate])
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		tracer := newTracer()
+		defer tracer.Stop()
+		assert.Nil(t, tracer.rateLimiter)
+	})
+}
+
 func TestGlobMatch(t *testing.T) {
 	for i, tt := range []struct {
 		pattern     string
@@ -1002,3 +1092,51 @@ func BenchmarkGlobMatchSpan(b *testing.B) {
 		}
 	})
 }
+
+func TestWithSamplingSeed(t *testing.T) {
+	// decisions returns the sampling decisions of a new tracer for the traces with IDs 1 to 50.
+	decisions := func(opts ...StartOption) []bool {
+		tracer := newTracer(append(opts, WithSamplingRules([]SamplingRule{RateRule(0.5)}))...)
+		defer tracer.Stop()
+		kept := make([]bool, 50)
+		for i := range kept {
+			s := tracer.StartSpan("http.request", WithSpanID(uint64(i+1))).(*span)
+			p, _ := s.context.samplingPriority()
+			kept[i] = p > 0
+		}
+		return kept
+	}
+	count := func(kept []bool) (n int) {
+		for _, k := range kept {
+			if k {
+				n++
+			}
+		}
+		return n
+	}
+
+	seeded := decisions(WithSamplingSeed(42))
+	assert.Equal(t, seeded, decisions(WithSamplingSeed(42)), "the same seed yields the same decisions")
+	assert.NotEqual(t, seeded, decisions(WithSamplingSeed(43)), "different seeds yield different decisions")
+	assert.InDelta(t, 25, count(seeded), 12)
+
+	t.Run("priority", func(t *testing.T) {
+		ps := newPrioritySampler()
+		ps.defaultRate = 0.5
+		seed := uint64(42)
+		ps.seed = &seed
+		for i, kept := range seeded {
+			s := newBasicSpan("http.request")
+			s.TraceID = uint64(i + 1)
+			ps.apply(s)
+			p, _ := s.context.samplingPriority()
+			assert.Equal(t, kept, p > 0, "the agent rates use the seed like the rules")
+		}
+	})
+
+	t.Run("unseeded", func(t *testing.T) {
+		for i := uint64(1); i < 100; i++ {
+			assert.Equal(t, i, seededSampleKey(i, nil))
+		}
+	})
+}
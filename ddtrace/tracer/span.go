@@ -10,6 +10,7 @@ package tracer
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"math"
 	"os"
@@ -75,9 +76,12 @@ type span struct {
 	ParentID uint64             `msg:"parent_id"`         // identifier of the span's direct parent
 	Error    int32              `msg:"error"`             // error status of the span; 0 means no errors
 
-	noDebugStack bool         `msg:"-"` // disables debug stack traces
-	finished     bool         `msg:"-"` // true if the span has been submitted to a tracer.
-	context      *spanContext `msg:"-"` // span propagation context
+	noDebugStack bool          `msg:"-"` // disables debug stack traces
+	finished     bool          `msg:"-"` // true if the span has been submitted to a tracer.
+	context      *spanContext  `msg:"-"` // span propagation context
+	events       []spanEvent   `msg:"-"` // events added to the span, encoded in its meta when finished
+	done         chan struct{} `msg:"-"` // closed when the span is finished, if created by finishedChan
+	processed    bool          `msg:"-"` // true once the span processors have been run on the span
 
 	pprofCtxActive  context.Context `msg:"-"` // contains pprof.WithLabel labels to tell the profiler more about this span
 	pprofCtxRestore context.Context `msg:"-"` // contains pprof.WithLabel labels of the parent span (if any) that need to be restored when this span finishes
@@ -191,6 +195,59 @@ func (s *span) root() *span {
 	return s.context.trace.root
 }
 
+// spanEvent is a named and timestamped event which occurred during the span's lifetime.
+type spanEvent struct {
+	Name         string                 `json:"name"`
+	TimeUnixNano int64                  `json:"time_unix_nano"`
+	Attributes   map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// AddEvent records an event with the given name and attributes, timestamped with
+// the current time. Events are useful to mark milestones within long spans. The
+// attributes must be serializable to JSON.
+func (s *span) AddEvent(name string, attrs map[string]interface{}) {
+	e := spanEvent{Name: name, TimeUnixNano: now()}
+	if len(attrs) > 0 {
+		e.Attributes = make(map[string]interface{}, len(attrs))
+		for k, v := range attrs {
+			e.Attributes[k] = v
+		}
+	}
+	s.Lock()
+	defer s.Unlock()
+	if s.finished {
+		return
+	}
+	s.events = append(s.events, e)
+}
+
+// finishedChan returns a channel which is closed once the span is finished.
+func (s *span) finishedChan() <-chan struct{} {
+	s.Lock()
+	defer s.Unlock()
+	if s.done == nil {
+		s.done = make(chan struct{})
+		if s.finished {
+			close(s.done)
+		}
+	}
+	return s.done
+}
+
+// encodeEvents encodes the events of the span as JSON into its meta.
+// It must be called with the span locked.
+func (s *span) encodeEvents() {
+	if len(s.events) == 0 {
+		return
+	}
+	b, err := json.Marshal(s.events)
+	if err != nil {
+		log.Error("Error encoding events of span %q: %v", s.Name, err)
+		return
+	}
+	s.setMeta(keySpanEvents, string(b))
+}
+
 // SetUser associates user information to the current trace which the
 // provided span belongs to. The options can be used to tune which user
 // bit of information gets monitored. In case of distributed traces,
@@ -260,7 +317,9 @@ func (s *span) setTagError(value interface{}, cfg errorConfig) {
 		if yes {
 			if s.Error == 0 {
 				// new error
-				atomic.AddInt32(&s.context.errors, 1)
+				if atomic.AddInt32(&s.context.errors, 1) == 1 {
+					keepOnError(s)
+				}
 			}
 			s.Error = 1
 		} else {
@@ -411,6 +470,11 @@ func (s *span) setMetric(key string, v float64) {
 // of its part of the tracing session.
 func (s *span) Finish(opts ...ddtrace.FinishOption) {
 	t := now()
+	if t <= s.Start {
+		// the operation was faster than the resolution of the clock, do not report it as
+		// instantaneous.
+		t = s.Start + 1
+	}
 	if len(opts) > 0 {
 		cfg := ddtrace.FinishConfig{
 			NoDebugStack: s.noDebugStack,
@@ -429,11 +493,24 @@ func (s *span) Finish(opts ...ddtrace.FinishOption) {
 				stackSkip:    cfg.SkipStackFrames,
 			})
 			s.Unlock()
+		} else if cfg.ErrorFlag {
+			s.Lock()
+			s.setTagError(true, errorConfig{})
+			s.Unlock()
 		}
 	}
 	if s.taskEnd != nil {
 		s.taskEnd()
 	}
+	if t, ok := internal.GetGlobalTracer().(*tracer); ok {
+		if len(t.config.spanProcessors) > 0 {
+			s.process(t.config.spanProcessors)
+		}
+		if s.root() == s {
+			// the decision deferred using WithLateSamplingDecision is made once the root finishes
+			t.sampleLate(s.context)
+		}
+	}
 	s.finish(t)
 
 	if s.pprofCtxRestore != nil {
@@ -443,6 +520,39 @@ func (s *span) Finish(opts ...ddtrace.FinishOption) {
 	}
 }
 
+// FinishWithRecover finishes the given span with the given options, like its Finish method. It
+// must be deferred right after starting the span, e.g.:
+//
+//	span, ctx := tracer.StartSpanFromContext(ctx, "process.order")
+//	defer tracer.FinishWithRecover(span)
+//
+// When the tracer is started using WithPanicRecovery and the function starting the span panics,
+// the span is marked as errored with the panic and the stack at which it occurred, before the
+// panic keeps propagating. Otherwise, it is equivalent to deferring span.Finish.
+func FinishWithRecover(span ddtrace.Span, opts ...ddtrace.FinishOption) {
+	if t, ok := internal.GetGlobalTracer().(*tracer); !ok || !t.config.panicRecovery {
+		span.Finish(opts...)
+		return
+	}
+	// recover only stops a panic when called directly by the deferred function.
+	r := recover()
+	if r == nil {
+		span.Finish(opts...)
+		return
+	}
+	span.Finish(append(opts, WithError(panicError(r)))...)
+	panic(r)
+}
+
+// panicError returns the error recorded on spans for the panic value r, wrapping r when it is
+// an error.
+func panicError(r interface{}) error {
+	if err, ok := r.(error); ok {
+		return fmt.Errorf("panic: %w", err)
+	}
+	return fmt.Errorf("panic: %v", r)
+}
+
 // SetOperationName sets or changes the operation name.
 func (s *span) SetOperationName(operationName string) {
 	s.Lock()
@@ -474,10 +584,18 @@ func (s *span) finish(finishTime int64) {
 		s.Duration = 0
 	}
 	s.finished = true
+	s.encodeEvents()
+	if s.done != nil {
+		close(s.done)
+	}
 
 	keep := true
 	if t, ok := internal.GetGlobalTracer().(*tracer); ok {
 		// we have an active tracer
+		if newSvc, ok := t.config.serviceMappings[s.Service]; ok {
+			// the service may have been changed after the span was started
+			s.Service = newSvc
+		}
 		if t.config.canComputeStats() && shouldComputeStats(s) {
 			// the agent supports computed stats
 			select {
@@ -665,6 +783,17 @@ const (
 
 	//keyTracerHostname holds the tracer detected hostname, only present when not connected over UDS to agent.
 	keyTracerHostname = "_dd.tracer_hostname"
+	// keySpanEvents holds the JSON encoded events added to the span.
+	keySpanEvents = "events"
+	// keyContainerID holds the ID of the container the tracer runs in, set on local root spans.
+	keyContainerID = "_dd.container_id"
+	// keyEntityID holds the entity ID set through DD_ENTITY_ID, set on local root spans.
+	keyEntityID = "_dd.entity_id"
+	// keyDeploymentStage holds the deployment stage set through DD_DEPLOYMENT_STAGE, set on local root spans.
+	keyDeploymentStage = "deployment.stage"
+	// keyCriticalPath holds the approximate critical path of a trace in milliseconds, set on
+	// local root spans when enabled using WithCriticalPath.
+	keyCriticalPath = "_dd.critical_path_ms"
 )
 
 // The following set of tags is used for user monitoring and set through calls to span.SetUser().
@@ -0,0 +1,108 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// ReadOnlySpan provides read access to a finished span. It is passed to the
+// function set using WithSpanFilter.
+type ReadOnlySpan interface {
+	// OperationName returns the operation name of the span.
+	OperationName() string
+	// ServiceName returns the service name of the span.
+	ServiceName() string
+	// ResourceName returns the resource name of the span.
+	ResourceName() string
+	// SpanType returns the type of the span.
+	SpanType() string
+	// Tag returns the value of the tag with the given key, or nil if it is not set.
+	// String tags are returned as a string and numeric tags as a float64.
+	Tag(key string) interface{}
+	// StartTime returns the time at which the span was started.
+	StartTime() time.Time
+	// Duration returns the duration of the span.
+	Duration() time.Duration
+	// IsError reports whether the span was marked as an error.
+	IsError() bool
+	// IsRoot reports whether the span is the local root of its trace.
+	IsRoot() bool
+}
+
+// readOnlySpan implements ReadOnlySpan on top of a finished span.
+type readOnlySpan struct{ s *span }
+
+var _ ReadOnlySpan = readOnlySpan{}
+
+func (r readOnlySpan) OperationName() string { return r.s.Name }
+
+func (r readOnlySpan) ServiceName() string { return r.s.Service }
+
+func (r readOnlySpan) ResourceName() string { return r.s.Resource }
+
+func (r readOnlySpan) SpanType() string { return r.s.Type }
+
+func (r readOnlySpan) Tag(key string) interface{} {
+	if v, ok := r.s.Meta[key]; ok {
+		return v
+	}
+	if v, ok := r.s.Metrics[key]; ok {
+		return v
+	}
+	return nil
+}
+
+func (r readOnlySpan) StartTime() time.Time { return time.Unix(0, r.s.Start) }
+
+func (r readOnlySpan) Duration() time.Duration { return time.Duration(r.s.Duration) }
+
+func (r readOnlySpan) IsError() bool { return r.s.Error != 0 }
+
+func (r readOnlySpan) IsRoot() bool {
+	return r.s.context != nil && r.s.context.trace != nil && r.s.context.trace.root == r.s
+}
+
+// filterTrace applies the span filter to the spans of the finished trace. When the
+// local root span is rejected, the whole trace is dropped. Otherwise, the rejected spans
+// are removed and their children are reparented to their closest kept ancestor.
+func (t *tracer) filterTrace(info *finishedTrace) {
+	filter := t.config.spanFilter
+	if filter == nil || len(info.spans) == 0 {
+		return
+	}
+	var removed map[uint64]uint64 // span ID of the removed spans to their parent ID
+	kept := make([]*span, 0, len(info.spans))
+	for _, s := range info.spans {
+		ro := readOnlySpan{s}
+		if filter(ro) {
+			kept = append(kept, s)
+			continue
+		}
+		if ro.IsRoot() {
+			atomic.AddUint32(&t.spansFiltered, uint32(len(info.spans)))
+			info.spans = nil
+			return
+		}
+		if removed == nil {
+			removed = make(map[uint64]uint64)
+		}
+		removed[s.SpanID] = s.ParentID
+	}
+	if len(removed) == 0 {
+		return
+	}
+	for _, s := range kept {
+		parent, ok := removed[s.ParentID]
+		for ok {
+			s.ParentID = parent
+			parent, ok = removed[parent]
+		}
+	}
+	atomic.AddUint32(&t.spansFiltered, uint32(len(removed)))
+	info.spans = kept
+}
@@ -0,0 +1,92 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithSpanFilter(t *testing.T) {
+	t.Run("root", func(t *testing.T) {
+		tracer, transport, flush, stop := startTestTracer(t, WithSpanFilter(func(s ReadOnlySpan) bool {
+			return s.ResourceName() != "/health"
+		}))
+		defer stop()
+
+		root := tracer.StartSpan("http.request", ResourceName("/health"))
+		tracer.StartSpan("child", ChildOf(root.Context())).Finish()
+		root.Finish()
+		root = tracer.StartSpan("http.request", ResourceName("/users"))
+		tracer.StartSpan("child", ChildOf(root.Context())).Finish()
+		root.Finish()
+		flush(1)
+
+		traces := transport.Traces()
+		require.Len(t, traces, 1)
+		require.Len(t, traces[0], 2)
+		assert.Equal(t, "/users", traces[0][0].Resource)
+		assert.Equal(t, uint32(2), tracer.spansFiltered)
+	})
+
+	t.Run("child", func(t *testing.T) {
+		tracer, transport, flush, stop := startTestTracer(t, WithSpanFilter(func(s ReadOnlySpan) bool {
+			return s.OperationName() != "cache.get"
+		}))
+		defer stop()
+
+		root := tracer.StartSpan("http.request").(*span)
+		mid := tracer.StartSpan("cache.get", ChildOf(root.Context())).(*span)
+		inner := tracer.StartSpan("cache.get", ChildOf(mid.Context())).(*span)
+		leaf := tracer.StartSpan("db.query", ChildOf(inner.Context())).(*span)
+		leaf.Finish()
+		inner.Finish()
+		mid.Finish()
+		root.Finish()
+		flush(1)
+
+		traces := transport.Traces()
+		require.Len(t, traces, 1)
+		require.Len(t, traces[0], 2)
+		assert.Equal(t, root.SpanID, traces[0][0].SpanID)
+		assert.Equal(t, leaf.SpanID, traces[0][1].SpanID)
+		assert.Equal(t, root.SpanID, traces[0][1].ParentID)
+		assert.Equal(t, uint32(2), tracer.spansFiltered)
+	})
+
+	t.Run("read-only", func(t *testing.T) {
+		var seen []ReadOnlySpan
+		tracer, _, flush, stop := startTestTracer(t, WithSpanFilter(func(s ReadOnlySpan) bool {
+			seen = append(seen, s)
+			return true
+		}))
+		defer stop()
+
+		root := tracer.StartSpan("http.request", ServiceName("web"), SpanType("web"), Tag("count", 2))
+		child := tracer.StartSpan("db.query", ChildOf(root.Context()), Tag("db.user", "bob"))
+		child.Finish(WithError(errors.New("failed")))
+		root.Finish()
+		flush(1)
+
+		require.Len(t, seen, 2)
+		r, c := seen[0], seen[1]
+		assert.True(t, r.IsRoot())
+		assert.False(t, c.IsRoot())
+		assert.Equal(t, "http.request", r.OperationName())
+		assert.Equal(t, "web", r.ServiceName())
+		assert.Equal(t, "web", r.SpanType())
+		assert.Equal(t, 2.0, r.Tag("count"))
+		assert.Nil(t, r.Tag("missing"))
+		assert.False(t, r.IsError())
+		assert.Equal(t, "bob", c.Tag("db.user"))
+		assert.True(t, c.IsError())
+		assert.Equal(t, root.(*span).Start, r.StartTime().UnixNano())
+		assert.Equal(t, root.(*span).Duration, int64(r.Duration()))
+	})
+}
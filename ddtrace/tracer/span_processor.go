@@ -0,0 +1,44 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+// TagRemover is implemented by the spans passed to the functions set using WithSpanProcessor,
+// allowing them to remove tags.
+type TagRemover interface {
+	// RemoveTag removes the tag or metric with the given key from the span.
+	RemoveTag(key string)
+}
+
+var _ TagRemover = (*span)(nil)
+
+// RemoveTag implements TagRemover.
+func (s *span) RemoveTag(key string) {
+	s.Lock()
+	defer s.Unlock()
+	// We don't lock spans when flushing, so we could have a data race when
+	// modifying a span as it's being flushed. This protects us against that
+	// race, since spans are marked `finished` before we flush them.
+	if s.finished {
+		return
+	}
+	delete(s.Meta, key)
+	delete(s.Metrics, key)
+}
+
+// process calls the given span processors with s, unless it is already finished or was
+// already processed, e.g. by a processor finishing the span itself.
+func (s *span) process(processors []func(Span)) {
+	s.Lock()
+	if s.finished || s.processed {
+		s.Unlock()
+		return
+	}
+	s.processed = true
+	s.Unlock()
+	for _, fn := range processors {
+		fn(s)
+	}
+}
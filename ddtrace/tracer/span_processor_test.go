@@ -0,0 +1,68 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithSpanProcessor(t *testing.T) {
+	var calls []string
+	tracer, transport, flush, stop := startTestTracer(t,
+		WithSpanProcessor(func(s Span) {
+			calls = append(calls, "redact")
+			s.SetTag("region", "eu")
+			s.(TagRemover).RemoveTag("user.email")
+			s.(TagRemover).RemoveTag("retries")
+		}),
+		WithSpanProcessor(func(s Span) {
+			calls = append(calls, "count")
+			s.SetTag("processed", 1)
+		}),
+	)
+	defer stop()
+
+	root := tracer.StartSpan("http.request", Tag("user.email", "bob@example.com"), Tag("retries", 2))
+	child := tracer.StartSpan("db.query", ChildOf(root.Context()))
+	child.Finish()
+	root.Finish()
+	root.Finish() // finishing again does not call the processors
+	flush(1)
+
+	assert.Equal(t, []string{"redact", "count", "redact", "count"}, calls)
+	traces := transport.Traces()
+	require.Len(t, traces, 1)
+	require.Len(t, traces[0], 2)
+	for _, s := range traces[0] {
+		assert.Equal(t, "eu", s.Meta["region"])
+		assert.Equal(t, 1.0, s.Metrics["processed"])
+		assert.NotContains(t, s.Meta, "user.email")
+		assert.NotContains(t, s.Metrics, "retries")
+	}
+}
+
+func TestSpanProcessorFinish(t *testing.T) {
+	tracer, transport, flush, stop := startTestTracer(t, WithSpanProcessor(func(s Span) {
+		// a processor finishing the span is not called again
+		s.Finish()
+	}))
+	defer stop()
+
+	tracer.StartSpan("http.request").Finish()
+	flush(1)
+	assert.Len(t, transport.Traces(), 1)
+}
+
+func TestSpanRemoveTagFinished(t *testing.T) {
+	s := newBasicSpan("http.request")
+	s.SetTag("key", "value")
+	s.Finish()
+	s.RemoveTag("key")
+	assert.Equal(t, "value", s.Meta["key"])
+}
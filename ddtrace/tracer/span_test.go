@@ -6,6 +6,7 @@
 package tracer
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -16,11 +17,13 @@ import (
 	"time"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/clock"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/samplernames"
 
 	"github.com/DataDog/datadog-agent/pkg/obfuscate"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/tinylib/msgp/msgp"
 )
 
 // newSpan creates a new span. This is a low-level function, required for testing and advanced usage.
@@ -228,6 +231,135 @@ func TestSpanFinishWithError(t *testing.T) {
 	assert.NotEmpty(span.Meta[ext.ErrorStack])
 }
 
+func TestSpanFinishWithErrorFlag(t *testing.T) {
+	assert := assert.New(t)
+
+	span := newBasicSpan("web.request")
+	span.Finish(WithErrorFlag(true))
+	assert.Equal(int32(1), span.Error)
+	assert.Equal(int32(1), span.context.errors)
+	assert.NotContains(span.Meta, ext.ErrorMsg)
+	assert.NotContains(span.Meta, ext.ErrorType)
+	assert.NotContains(span.Meta, ext.ErrorStack)
+
+	span = newBasicSpan("web.request")
+	span.Finish(WithErrorFlag(false))
+	assert.Equal(int32(0), span.Error)
+
+	// the error value takes precedence
+	span = newBasicSpan("web.request")
+	span.Finish(WithErrorFlag(true), WithError(errors.New("test error")))
+	assert.Equal(int32(1), span.Error)
+	assert.Equal("test error", span.Meta[ext.ErrorMsg])
+}
+
+func TestSpanFinishShortDuration(t *testing.T) {
+	tracer, _, _, stop := startTestTracer(t)
+	defer stop()
+
+	t.Run("short", func(t *testing.T) {
+		s := tracer.StartSpan("cache.get").(*span)
+		s.Finish()
+		assert.Greater(t, s.Duration, int64(0))
+		assert.Less(t, s.Duration, int64(time.Second))
+
+		// the duration is preserved in the payload
+		p := newPayload()
+		p.push(spanList{s})
+		var got spanLists
+		require.NoError(t, msgp.Decode(p, &got))
+		assert.Equal(t, s.Duration, got[0][0].Duration)
+	})
+
+	t.Run("finish-time", func(t *testing.T) {
+		s := tracer.StartSpan("cache.get").(*span)
+		s.Finish(FinishTime(time.Unix(0, s.Start).Add(2 * time.Millisecond)))
+		assert.Equal(t, int64(2*time.Millisecond), s.Duration)
+	})
+
+	t.Run("start-time", func(t *testing.T) {
+		start := time.Now().Add(-time.Minute)
+		s := tracer.StartSpan("cache.get", StartTime(start)).(*span)
+		s.Finish()
+		assert.GreaterOrEqual(t, s.Duration, int64(time.Minute))
+	})
+
+	t.Run("clock-resolution", func(t *testing.T) {
+		defer clock.Set(clock.NewFake(time.Now()))()
+		s := tracer.StartSpan("cache.get").(*span)
+		s.Finish()
+		assert.Equal(t, int64(1), s.Duration)
+	})
+}
+
+func TestFinishWithRecover(t *testing.T) {
+	t.Run("panic", func(t *testing.T) {
+		tracer, _, _, stop := startTestTracer(t, WithPanicRecovery())
+		defer stop()
+
+		var s *span
+		assert.PanicsWithValue(t, "out of stock", func() {
+			s = tracer.StartSpan("process.order").(*span)
+			defer FinishWithRecover(s)
+			panic("out of stock")
+		})
+		require.NotNil(t, s)
+		assert.True(t, s.finished)
+		assert.Equal(t, int32(1), s.Error)
+		assert.Equal(t, "panic: out of stock", s.Meta[ext.ErrorMsg])
+		assert.Contains(t, s.Meta[ext.ErrorStack], "TestFinishWithRecover")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		tracer, _, _, stop := startTestTracer(t)
+		defer stop()
+
+		var s *span
+		assert.PanicsWithValue(t, "out of stock", func() {
+			s = tracer.StartSpan("process.order").(*span)
+			defer FinishWithRecover(s)
+			panic("out of stock")
+		})
+		require.NotNil(t, s)
+		assert.True(t, s.finished)
+		assert.Equal(t, int32(0), s.Error)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		tracer, _, _, stop := startTestTracer(t, WithPanicRecovery())
+		defer stop()
+
+		errOutOfStock := errors.New("out of stock")
+		var s *span
+		assert.PanicsWithError(t, "out of stock", func() {
+			s = tracer.StartSpan("process.order").(*span)
+			defer FinishWithRecover(s)
+			panic(errOutOfStock)
+		})
+		require.NotNil(t, s)
+		assert.Equal(t, int32(1), s.Error)
+		assert.Equal(t, "panic: out of stock", s.Meta[ext.ErrorMsg])
+
+		// the error of the panic is wrapped
+		assert.ErrorIs(t, panicError(errOutOfStock), errOutOfStock)
+		assert.Equal(t, "panic: out of stock", panicError("out of stock").Error())
+	})
+
+	t.Run("no-panic", func(t *testing.T) {
+		tracer, _, _, stop := startTestTracer(t, WithPanicRecovery())
+		defer stop()
+
+		var s *span
+		assert.NotPanics(t, func() {
+			s = tracer.StartSpan("process.order").(*span)
+			defer FinishWithRecover(s)
+		})
+		require.NotNil(t, s)
+		assert.True(t, s.finished)
+		assert.Equal(t, int32(0), s.Error)
+	})
+}
+
 func TestSpanFinishWithErrorNoDebugStack(t *testing.T) {
 	assert := assert.New(t)
 
@@ -823,3 +955,31 @@ type stringer struct{}
 func (s *stringer) String() string {
 	return "string"
 }
+
+func TestSpanAddEvent(t *testing.T) {
+	assert := assert.New(t)
+	s := newBasicSpan("http.request")
+	attrs := map[string]interface{}{"key": "user:1", "hits": 2}
+	AddEvent(s, "cache.miss", attrs)
+	attrs["key"] = "modified"
+	s.AddEvent("retry", nil)
+	s.Finish()
+	s.AddEvent("ignored", nil)
+
+	var events []spanEvent
+	require.NoError(t, json.Unmarshal([]byte(s.Meta[keySpanEvents]), &events))
+	require.Len(t, events, 2)
+	assert.Equal("cache.miss", events[0].Name)
+	assert.Equal(map[string]interface{}{"key": "user:1", "hits": 2.0}, events[0].Attributes)
+	assert.Equal("retry", events[1].Name)
+	assert.Empty(events[1].Attributes)
+	assert.True(events[0].TimeUnixNano >= s.Start)
+	assert.True(events[1].TimeUnixNano >= events[0].TimeUnixNano)
+	assert.True(events[1].TimeUnixNano <= s.Start+s.Duration)
+}
+
+func TestSpanNoEvents(t *testing.T) {
+	s := newBasicSpan("http.request")
+	s.Finish()
+	assert.NotContains(t, s.Meta, keySpanEvents)
+}
@@ -277,6 +277,13 @@ func (t *trace) setSamplingPriorityLocked(p int, sampler samplernames.SamplerNam
 // push pushes a new span into the trace. If the buffer is full, it returns
 // a errBufferFull error.
 func (t *trace) push(sp *span) {
+	var evicted []*trace
+	defer func() {
+		// the evicted traces are finished once this trace is unlocked
+		for _, e := range evicted {
+			e.forceFinish()
+		}
+	}()
 	t.mu.Lock()
 	defer t.mu.Unlock()
 	if t.full {
@@ -286,11 +293,15 @@ func (t *trace) push(sp *span) {
 	if len(t.spans) >= traceMaxSize {
 		// capacity is reached, we will not be able to complete this trace.
 		t.full = true
-		t.spans = nil // GC
 		log.Error("trace buffer full (%d), dropping trace", traceMaxSize)
 		if haveTracer {
 			atomic.AddUint32(&tr.tracesDropped, 1)
+			atomic.AddUint32(&tr.spansDropped, uint32(len(t.spans)+1))
+			if tr.openTraces != nil {
+				tr.openTraces.remove(t)
+			}
 		}
+		t.spans = nil // GC
 		return
 	}
 	if v, ok := sp.Metrics[keySamplingPriority]; ok {
@@ -299,6 +310,9 @@ func (t *trace) push(sp *span) {
 	t.spans = append(t.spans, sp)
 	if haveTracer {
 		atomic.AddUint32(&tr.spansStarted, 1)
+		if len(t.spans) == 1 && tr.openTraces != nil {
+			evicted = tr.openTraces.add(t)
+		}
 	}
 }
 
@@ -350,6 +364,9 @@ func (t *trace) finishedOne(s *span) {
 	if !ok {
 		return
 	}
+	if tr.openTraces != nil {
+		tr.openTraces.remove(t)
+	}
 	if hn := tr.hostname(); hn != "" {
 		s.setMeta(keyTracerHostname, hn)
 	}
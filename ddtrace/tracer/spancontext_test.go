@@ -8,6 +8,7 @@ package tracer
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -358,7 +359,7 @@ func TestSpanContextPushFull(t *testing.T) {
 	traceMaxSize = 2
 	tp := new(log.RecordLogger)
 	tp.Ignore("appsec: ", telemetry.LogPrefix)
-	_, _, _, stop := startTestTracer(t, WithLogger(tp), WithLambdaMode(true))
+	tracer, _, _, stop := startTestTracer(t, WithLogger(tp), WithLambdaMode(true))
 	defer stop()
 
 	span1 := newBasicSpan("span1")
@@ -376,6 +377,8 @@ func TestSpanContextPushFull(t *testing.T) {
 	buffer.push(span3)
 	log.Flush()
 	assert.Contains(tp.Logs()[0], "ERROR: trace buffer full (2)")
+	assert.Equal(uint32(1), atomic.LoadUint32(&tracer.tracesDropped))
+	assert.Equal(uint32(3), atomic.LoadUint32(&tracer.spansDropped))
 }
 
 func TestSpanContextBaggage(t *testing.T) {
@@ -6,11 +6,13 @@
 package tracer
 
 import (
+	"sort"
 	"strconv"
 	"strings"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 )
@@ -58,6 +60,10 @@ const (
 	sqlCommentEnv           = "dde"
 )
 
+// sqlCommentKeys holds the keys of the tags injected from the span context, sorted as
+// required by the sqlcommenter specification.
+var sqlCommentKeys = []string{sqlCommentDBService, sqlCommentEnv, sqlCommentParentService, sqlCommentParentVersion, sqlCommentTraceParent}
+
 // Current trace context version (see https://www.w3.org/TR/trace-context/#version)
 const w3cContextVersion = "00"
 
@@ -69,17 +75,27 @@ type SQLCommentCarrier struct {
 	Mode          DBMPropagationMode
 	DBServiceName string
 	SpanID        uint64
+	// Tags holds additional tags to inject in the comment, such as the IDs of application
+	// requests. They are injected regardless of the mode, unless they are empty or use the
+	// key of a tag injected from the span context.
+	Tags map[string]string
 }
 
 // Inject injects a span context in the carrier's Query field as a comment.
 func (c *SQLCommentCarrier) Inject(spanCtx ddtrace.SpanContext) error {
-	c.SpanID = generateSpanID(now())
+	if t, ok := internal.GetGlobalTracer().(*tracer); ok {
+		c.SpanID = t.config.newSpanID(now())
+	} else {
+		c.SpanID = generateSpanID(now())
+	}
 	tags := make(map[string]string)
 	switch c.Mode {
 	case DBMPropagationModeUndefined:
 		fallthrough
 	case DBMPropagationModeDisabled:
-		return nil
+		if len(c.Tags) == 0 {
+			return nil
+		}
 	case DBMPropagationModeFull:
 		var (
 			sampled int64
@@ -110,10 +126,41 @@ func (c *SQLCommentCarrier) Inject(spanCtx ddtrace.SpanContext) error {
 		}
 		tags[sqlCommentDBService] = c.DBServiceName
 	}
-	c.Query = commentQuery(c.Query, tags)
+	keys := sqlCommentKeys
+	if len(c.Tags) > 0 {
+		keys = c.mergeTags(tags)
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	c.Query = commentQuery(c.Query, keys, tags)
 	return nil
 }
 
+// mergeTags adds the additional tags of the carrier to tags, and returns the sorted keys of
+// the resulting tags.
+func (c *SQLCommentCarrier) mergeTags(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags)+len(c.Tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+outer:
+	for k, v := range c.Tags {
+		if v == "" {
+			continue
+		}
+		for _, dk := range sqlCommentKeys {
+			if k == dk {
+				continue outer
+			}
+		}
+		tags[k] = v
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // encodeTraceParent encodes trace parent as per the w3c trace context spec (https://www.w3.org/TR/trace-context/#version).
 func encodeTraceParent(traceID uint64, spanID uint64, sampled int64) string {
 	var b strings.Builder
@@ -145,16 +192,15 @@ var (
 )
 
 // commentQuery returns the given query with the tags from the SQLCommentCarrier applied to it as a
-// prepended SQL comment. The format of the comment follows the sqlcommenter spec.
+// prepended SQL comment, in the order of the given keys. The format of the comment follows the sqlcommenter spec.
 // See https://google.github.io/sqlcommenter/spec/ for more details.
-func commentQuery(query string, tags map[string]string) string {
+func commentQuery(query string, orderedKeys []string, tags map[string]string) string {
 	if len(tags) == 0 {
 		return ""
 	}
 	var b strings.Builder
-	// the sqlcommenter specification dictates that tags should be sorted. Since we know all injected keys,
-	// we skip a sorting operation by specifying the order of keys statically
-	orderedKeys := []string{sqlCommentDBService, sqlCommentEnv, sqlCommentParentService, sqlCommentParentVersion, sqlCommentTraceParent}
+	// the sqlcommenter specification dictates that tags should be sorted. Unless additional tags are
+	// injected, we know all injected keys and skip a sorting operation by using sqlCommentKeys.
 	first := true
 	for _, k := range orderedKeys {
 		if v, ok := tags[k]; ok {
@@ -25,6 +25,7 @@ func TestSQLCommentCarrier(t *testing.T) {
 		mode              DBMPropagationMode
 		injectSpan        bool
 		samplingPriority  int
+		tags              map[string]string
 		expectedQuery     string
 		expectedSpanIDGen bool
 	}{
@@ -68,6 +69,32 @@ func TestSQLCommentCarrier(t *testing.T) {
 			expectedQuery:     "/*dddbs='whiskey-db',dde='test-env',ddps='whiskey-service%20%21%23%24%25%26%27%28%29%2A%2B%2C%2F%3A%3B%3D%3F%40%5B%5D',ddpv='1.0.0',traceparent='00-0000000000000000000000000000000a-<span_id>-01'*/ SELECT * from FOO -- test query",
 			expectedSpanIDGen: true,
 		},
+		{
+			name:              "tags",
+			query:             "SELECT * from FOO",
+			mode:              DBMPropagationModeService,
+			injectSpan:        true,
+			tags:              map[string]string{"request_id": "req-1'*/ DROP TABLE foo; --", "app": "web", "dde": "other-env", "empty": ""},
+			expectedQuery:     "/*app='web',dddbs='whiskey-db',dde='test-env',ddps='whiskey-service%20%21%23%24%25%26%27%28%29%2A%2B%2C%2F%3A%3B%3D%3F%40%5B%5D',ddpv='1.0.0',request_id='req-1%27%2A%2F%20DROP%20TABLE%20foo%3B%20--'*/ SELECT * from FOO",
+			expectedSpanIDGen: false,
+		},
+		{
+			name:              "tags-disabled",
+			query:             "SELECT * from FOO",
+			mode:              DBMPropagationModeDisabled,
+			injectSpan:        true,
+			tags:              map[string]string{"request_id": "42"},
+			expectedQuery:     "/*request_id='42'*/ SELECT * from FOO",
+			expectedSpanIDGen: false,
+		},
+		{
+			name:              "tags-empty",
+			query:             "SELECT * from FOO",
+			mode:              DBMPropagationModeDisabled,
+			tags:              map[string]string{"request_id": ""},
+			expectedQuery:     "SELECT * from FOO",
+			expectedSpanIDGen: false,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -84,7 +111,7 @@ func TestSQLCommentCarrier(t *testing.T) {
 				spanCtx = root.Context()
 			}
 
-			carrier := SQLCommentCarrier{Query: tc.query, Mode: tc.mode, DBServiceName: "whiskey-db"}
+			carrier := SQLCommentCarrier{Query: tc.query, Mode: tc.mode, DBServiceName: "whiskey-db", Tags: tc.tags}
 			err := carrier.Inject(spanCtx)
 			require.NoError(t, err)
 			expected := strings.ReplaceAll(tc.expectedQuery, "<span_id>", fmt.Sprintf("%016s", strconv.FormatUint(carrier.SpanID, 16)))
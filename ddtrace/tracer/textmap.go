@@ -787,6 +787,38 @@ func (*propagatorW3c) extractTextMap(reader TextMapReader) (ddtrace.SpanContext,
 	return &ctx, nil
 }
 
+// ExtractTraceparent returns the span context encoded in the given W3C traceparent
+// string, e.g. "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01". It is useful
+// when the trace context is received as a single value rather than a set of headers,
+// such as in message attributes. The returned error wraps ErrSpanContextNotFound or
+// ErrSpanContextCorrupted.
+func ExtractTraceparent(s string) (ddtrace.SpanContext, error) {
+	version := strings.SplitN(strings.Trim(s, "\t -"), "-", 2)[0]
+	if v, err := strconv.ParseUint(version, 16, 8); version != "" && (len(version) != 2 || err != nil || v == 255) {
+		return nil, fmt.Errorf("invalid traceparent version %q: %w", version, ErrSpanContextCorrupted)
+	}
+	var ctx spanContext
+	if err := parseTraceparent(&ctx, s); err != nil {
+		return nil, fmt.Errorf("invalid traceparent %q: %w", s, err)
+	}
+	return &ctx, nil
+}
+
+// FormatTraceparent returns the W3C traceparent string encoding the given span context,
+// or an empty string if the span context is invalid. It is the counterpart of
+// ExtractTraceparent.
+func FormatTraceparent(sc ddtrace.SpanContext) string {
+	ctx, ok := sc.(*spanContext)
+	if !ok || ctx.trace == nil {
+		return ""
+	}
+	carrier := TextMapCarrier{}
+	if err := (&propagatorW3c{}).injectTextMap(ctx, carrier); err != nil {
+		return ""
+	}
+	return carrier[traceparentHeader]
+}
+
 // parseTraceparent attempts to parse traceparentHeader which describes the position
 // of the incoming request in its trace graph in a portable, fixed-length format.
 // The format of the traceparentHeader is `-` separated string with in the
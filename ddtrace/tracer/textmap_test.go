@@ -1568,6 +1568,80 @@ func TestW3CExtractsBaggage(t *testing.T) {
 	assert.True(t, found)
 }
 
+func TestTraceparent(t *testing.T) {
+	t.Run("round-trip", func(t *testing.T) {
+		for _, tp := range []string{
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+			"00-00000000000000000000000000000001-0000000000000002-01",
+		} {
+			sc, err := ExtractTraceparent(tp)
+			require.NoError(t, err)
+			assert.Equal(t, tp, FormatTraceparent(sc))
+		}
+	})
+
+	t.Run("extract", func(t *testing.T) {
+		sc, err := ExtractTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		require.NoError(t, err)
+		assert.Equal(t, uint64(0xa3ce929d0e0e4736), sc.TraceID())
+		assert.Equal(t, uint64(0x00f067aa0ba902b7), sc.SpanID())
+		p, ok := sc.(*spanContext).samplingPriority()
+		assert.True(t, ok)
+		assert.Equal(t, 1, p)
+	})
+
+	t.Run("future-version", func(t *testing.T) {
+		sc, err := ExtractTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra")
+		require.NoError(t, err)
+		assert.Equal(t, uint64(0x00f067aa0ba902b7), sc.SpanID())
+	})
+
+	t.Run("continue", func(t *testing.T) {
+		tracer, _, _, stop := startTestTracer(t)
+		defer stop()
+
+		sc, err := ExtractTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		require.NoError(t, err)
+		child := tracer.StartSpan("child", ChildOf(sc))
+		defer child.Finish()
+		assert.Equal(t, sc.TraceID(), child.Context().TraceID())
+		assert.Equal(t, fmt.Sprintf("00-4bf92f3577b34da6a3ce929d0e0e4736-%016x-01", child.Context().SpanID()),
+			FormatTraceparent(child.Context()))
+	})
+
+	t.Run("format/invalid", func(t *testing.T) {
+		assert.Equal(t, "", FormatTraceparent(nil))
+		assert.Equal(t, "", FormatTraceparent(&spanContext{}))
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		for name, tc := range map[string]struct {
+			in  string
+			err error
+			msg string
+		}{
+			"empty":           {"", ErrSpanContextNotFound, `invalid traceparent ""`},
+			"version/ff":      {"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", ErrSpanContextCorrupted, "invalid traceparent version"},
+			"version/hex":     {"zz-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", ErrSpanContextCorrupted, "invalid traceparent version"},
+			"version/long":    {"000-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", ErrSpanContextCorrupted, "invalid traceparent version"},
+			"v0/extra":        {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", ErrSpanContextCorrupted, `invalid traceparent "00-`},
+			"short":           {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", ErrSpanContextCorrupted, `invalid traceparent "00-`},
+			"trace-id/hex":    {"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01", ErrSpanContextCorrupted, `invalid traceparent "00-`},
+			"trace-id/zero":   {"00-00000000000000000000000000000000-00f067aa0ba902b7-01", ErrSpanContextNotFound, `invalid traceparent "00-`},
+			"span-id/zero":    {"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", ErrSpanContextNotFound, `invalid traceparent "00-`},
+			"flags/malformed": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0z", ErrSpanContextCorrupted, `invalid traceparent "00-`},
+		} {
+			t.Run(name, func(t *testing.T) {
+				sc, err := ExtractTraceparent(tc.in)
+				assert.Nil(t, sc)
+				assert.True(t, errors.Is(err, tc.err), "got %v", err)
+				assert.Contains(t, err.Error(), tc.msg)
+			})
+		}
+	})
+}
+
 func TestNonePropagator(t *testing.T) {
 	t.Run("inject/none", func(t *testing.T) {
 		t.Setenv(headerPropagationStyleInject, "none")
@@ -1679,6 +1753,49 @@ func TestNonePropagator(t *testing.T) {
 	})
 }
 
+func TestDecisionMakerPropagation(t *testing.T) {
+	t.Run("set-on-decision", func(t *testing.T) {
+		tracer := newTracer(WithSamplingRules([]SamplingRule{RateRule(1)}))
+		defer tracer.Stop()
+		root := tracer.StartSpan("web.request").(*span)
+		defer root.Finish()
+
+		assert.Equal(t, "-3", root.context.trace.propagatingTags[keyDecisionMaker])
+	})
+
+	t.Run("propagate", func(t *testing.T) {
+		tracer := newTracer(WithSamplingRules([]SamplingRule{RateRule(1)}))
+		defer tracer.Stop()
+		root := tracer.StartSpan("web.request")
+		defer root.Finish()
+
+		dst := TextMapCarrier{}
+		require.NoError(t, tracer.Inject(root.Context(), dst))
+		assertTraceTags(t, "_dd.p.dm=-3", dst[traceTagsHeader])
+	})
+
+	t.Run("inherit", func(t *testing.T) {
+		tracer := newTracer()
+		defer tracer.Stop()
+		sctx, err := tracer.Extract(TextMapCarrier{
+			DefaultTraceIDHeader:  "1",
+			DefaultParentIDHeader: "2",
+			DefaultPriorityHeader: "2",
+			traceTagsHeader:       "_dd.p.dm=-3",
+		})
+		require.NoError(t, err)
+		child := tracer.StartSpan("db.query", ChildOf(sctx)).(*span)
+		defer child.Finish()
+		// a local decision must not overwrite the inherited decision maker
+		child.SetTag(ext.ManualKeep, true)
+		assert.Equal(t, "-3", child.context.trace.propagatingTags[keyDecisionMaker])
+
+		dst := TextMapCarrier{}
+		require.NoError(t, tracer.Inject(child.Context(), dst))
+		assertTraceTags(t, "_dd.p.dm=-3", dst[traceTagsHeader])
+	})
+}
+
 func assertTraceTags(t *testing.T, expected, actual string) {
 	assert.ElementsMatch(t, strings.Split(expected, ","), strings.Split(actual, ","))
 }
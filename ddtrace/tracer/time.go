@@ -8,10 +8,45 @@
 
 package tracer
 
-import "time"
+import (
+	"sync/atomic"
+	"time"
 
-// nowTime returns the current time, as computed by Time.Now().
-var nowTime func() time.Time = func() time.Time { return time.Now() }
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/clock"
+)
 
-// now returns the current UNIX time in nanoseconds, as computed by Time.UnixNano().
-var now func() int64 = func() int64 { return time.Now().UnixNano() }
+// nowTime returns the current time, as computed by Time.Now() unless a clock is set
+// using the internal clock package.
+var nowTime func() time.Time = clock.Now
+
+// clockAnchor is a reading of the wall clock, along with the monotonic clock reading
+// taken with it.
+type clockAnchor struct {
+	wall int64     // UNIX time in nanoseconds
+	mono time.Time // holds the monotonic clock reading
+}
+
+// clockAnchorRefresh is the age past which a new anchor is taken by now, so that the
+// times it returns do not drift away from the wall clock.
+const clockAnchorRefresh = time.Minute
+
+// anchor holds the current *clockAnchor of now.
+var anchor atomic.Value
+
+// now returns the current UNIX time in nanoseconds, as the time of a recent reading of the
+// wall clock plus the time elapsed since, as measured by the monotonic clock. The durations
+// of spans are thus measured with nanosecond precision and are not affected by adjustments
+// of the wall clock, except for the spans running while a new anchor is taken, every minute.
+// The clock set using the internal clock package is used instead, if any.
+var now func() int64 = func() int64 {
+	if c := clock.Get(); c != nil {
+		return c.Now().UnixNano()
+	}
+	t := time.Now()
+	a, _ := anchor.Load().(*clockAnchor)
+	if a == nil || t.Sub(a.mono) >= clockAnchorRefresh {
+		a = &clockAnchor{wall: t.UnixNano(), mono: t}
+		anchor.Store(a)
+	}
+	return a.wall + int64(t.Sub(a.mono))
+}
@@ -0,0 +1,47 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+//go:build !windows
+// +build !windows
+
+package tracer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNow(t *testing.T) {
+	// start and end with a fresh anchor
+	anchor.Store((*clockAnchor)(nil))
+	defer anchor.Store((*clockAnchor)(nil))
+
+	t.Run("wall-clock", func(t *testing.T) {
+		before := time.Now().UnixNano()
+		n := now()
+		after := time.Now().UnixNano()
+		assert.GreaterOrEqual(t, n, before)
+		assert.LessOrEqual(t, n, after)
+	})
+
+	t.Run("monotonic", func(t *testing.T) {
+		// an anchor whose wall clock reading is an hour late, as if the wall clock was
+		// adjusted since it was taken
+		mono := time.Now()
+		anchor.Store(&clockAnchor{wall: mono.Add(-time.Hour).UnixNano(), mono: mono})
+		n := now()
+		assert.GreaterOrEqual(t, n, mono.Add(-time.Hour).UnixNano())
+		assert.Less(t, n, mono.Add(-59*time.Minute).UnixNano())
+	})
+
+	t.Run("refresh", func(t *testing.T) {
+		mono := time.Now().Add(-clockAnchorRefresh)
+		anchor.Store(&clockAnchor{wall: mono.Add(-time.Hour).UnixNano(), mono: mono})
+		before := time.Now().UnixNano()
+		assert.GreaterOrEqual(t, now(), before)
+	})
+}
@@ -10,6 +10,7 @@ import (
 
 	"golang.org/x/sys/windows"
 
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/clock"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 )
 
@@ -29,7 +30,7 @@ func lowPrecisionNow() int64 {
 // We use this method of initializing now over an init function due to dependency issues. The init
 // function may run after other declarations, such as that in payload_test:19, which results in a
 // nil dereference panic.
-var now func() int64 = func() func() int64 {
+var systemNow func() int64 = func() func() int64 {
 	if err := windows.LoadGetSystemTimePreciseAsFileTime(); err != nil {
 		log.Warn("Unable to load high precison timer, defaulting to time.Now()")
 		return lowPrecisionNow
@@ -38,7 +39,7 @@ var now func() int64 = func() func() int64 {
 	}
 }()
 
-var nowTime func() time.Time = func() func() time.Time {
+var systemNowTime func() time.Time = func() func() time.Time {
 	if err := windows.LoadGetSystemTimePreciseAsFileTime(); err != nil {
 		log.Warn("Unable to load high precison timer, defaulting to time.Now()")
 		return func() time.Time { return time.Unix(0, lowPrecisionNow()) }
@@ -46,3 +47,21 @@ var nowTime func() time.Time = func() func() time.Time {
 		return func() time.Time { return time.Unix(0, highPrecisionNow()) }
 	}
 }()
+
+// now returns the current UNIX time in nanoseconds, from the clock set using the internal
+// clock package, if any.
+var now func() int64 = func() int64 {
+	if c := clock.Get(); c != nil {
+		return c.Now().UnixNano()
+	}
+	return systemNow()
+}
+
+// nowTime returns the current time, from the clock set using the internal clock package,
+// if any.
+var nowTime func() time.Time = func() time.Time {
+	if c := clock.Get(); c != nil {
+		return c.Now()
+	}
+	return systemNowTime()
+}
@@ -7,6 +7,8 @@ package tracer
 
 import (
 	gocontext "context"
+	"encoding/binary"
+	"encoding/hex"
 	"os"
 	"runtime/pprof"
 	rt "runtime/trace"
@@ -19,9 +21,11 @@ import (
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/hostname"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/remoteconfig"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/samplernames"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/traceprof"
 
 	"github.com/DataDog/datadog-agent/pkg/obfuscate"
@@ -72,7 +76,7 @@ type tracer struct {
 
 	// These integers track metrics about spans and traces as they are started,
 	// finished, and dropped
-	spansStarted, spansFinished, tracesDropped uint32
+	spansStarted, spansFinished, spansDropped, tracesDropped uint32
 
 	// Records the number of dropped P0 traces and spans.
 	droppedP0Traces, droppedP0Spans uint32
@@ -80,12 +84,23 @@ type tracer struct {
 	// partialTrace the number of partially dropped traces.
 	partialTraces uint32
 
+	// spansFiltered records the number of spans discarded by the span filter.
+	spansFiltered uint32
+
 	// rulesSampling holds an instance of the rules sampler used to apply either trace sampling,
 	// or single span sampling rules on spans. These are user-defined
 	// rules for applying a sampling rate to spans that match the designated service
 	// or operation name.
 	rulesSampling *rulesSampler
 
+	// rateLimiter limits the number of traces kept per second, as set using WithRateLimit.
+	// It is nil when no limit is set.
+	rateLimiter *rateLimiter
+
+	// openTraces tracks the traces having unfinished spans, when their number is limited
+	// using WithMaxInFlightTraces.
+	openTraces *openTraces
+
 	// obfuscator holds the obfuscator used to obfuscate resources in aggregated stats.
 	// obfuscator may be nil if disabled.
 	obfuscator *obfuscate.Obfuscator
@@ -132,6 +147,9 @@ func Start(opts ...StartOption) {
 		return
 	}
 	internal.SetGlobalTracer(t)
+	if t.config.logRateLimit != nil {
+		log.SetRate(*t.config.logRateLimit)
+	}
 	if t.config.logStartup {
 		logStartup(t)
 	}
@@ -198,12 +216,30 @@ func SetUser(s Span, id string, opts ...UserMonitoringOption) {
 	sp.SetUser(id, opts...)
 }
 
+// AddEvent records an event with the given name and attributes on the provided span,
+// timestamped with the current time. Events mark milestones within a span, such as
+// the steps of a long running operation. It does nothing for spans which do not
+// support events.
+func AddEvent(s Span, name string, attrs map[string]interface{}) {
+	if s == nil {
+		return
+	}
+	sp, ok := s.(interface {
+		AddEvent(string, map[string]interface{})
+	})
+	if !ok {
+		return
+	}
+	sp.AddEvent(name, attrs)
+}
+
 // payloadQueueSize is the buffer size of the trace channel.
 const payloadQueueSize = 1000
 
 func newUnstartedTracer(opts ...StartOption) *tracer {
 	c := newConfig(opts...)
 	sampler := newPrioritySampler()
+	sampler.seed = c.samplingSeed
 	statsd, err := newStatsdClient(c)
 	if err != nil {
 		log.Warn("Runtime and health metrics disabled: %v", err)
@@ -214,6 +250,9 @@ func newUnstartedTracer(opts ...StartOption) *tracer {
 	} else {
 		writer = newAgentTraceWriter(c, sampler, statsd)
 	}
+	if c.otlpEndpoint != "" {
+		writer = multiTraceWriter{writer, newOTLPTraceWriter(c, statsd)}
+	}
 	traces, spans, err := samplingRulesFromEnv()
 	if err != nil {
 		log.Warn("DIAGNOSTICS Error(s) parsing sampling rules: found errors:%s", err)
@@ -235,16 +274,17 @@ func newUnstartedTracer(opts ...StartOption) *tracer {
 		pid:              os.Getpid(),
 		stats:            newConcentrator(c, defaultStatsBucketSize),
 		obfuscator: obfuscate.NewObfuscator(obfuscate.Config{
-			SQL: obfuscate.SQLConfig{
-				TableNames:       c.agent.HasFlag("table_names"),
-				ReplaceDigits:    c.agent.HasFlag("quantize_sql_tables") || c.agent.HasFlag("replace_sql_digits"),
-				KeepSQLAlias:     c.agent.HasFlag("keep_sql_alias"),
-				DollarQuotedFunc: c.agent.HasFlag("dollar_quoted_func"),
-				Cache:            c.agent.HasFlag("sql_cache"),
-			},
+			SQL: c.sqlObfuscationConfig(),
 		}),
 		statsd: statsd,
 	}
+	t.rulesSampling.traces.seed = c.samplingSeed
+	if c.traceRateLimit > 0 {
+		t.rateLimiter = newTraceRateLimiter(c.traceRateLimit, nowTime())
+	}
+	if c.maxInFlightTraces > 0 {
+		t.openTraces = newOpenTraces(c.maxInFlightTraces)
+	}
 	return t
 }
 
@@ -252,6 +292,7 @@ func newTracer(opts ...StartOption) *tracer {
 	t := newUnstartedTracer(opts...)
 	c := t.config
 	t.statsd.Incr("datadog.tracer.started", nil, 1)
+	globalconfig.SetStatsd(t.statsd)
 	if c.runtimeMetrics {
 		log.Debug("Runtime metrics enabled.")
 		t.wg.Add(1)
@@ -265,12 +306,15 @@ func newTracer(opts ...StartOption) *tracer {
 		defer t.wg.Done()
 		tick := t.config.tickChan
 		if tick == nil {
-			ticker := time.NewTicker(flushInterval)
+			ticker := newJitterTicker(flushInterval, c.flushJitter)
 			defer ticker.Stop()
 			tick = ticker.C
 		}
 		t.worker(tick)
 	}()
+	if c.healthMetrics {
+		log.Debug("Health metrics enabled.")
+	}
 	t.wg.Add(1)
 	go func() {
 		defer t.wg.Done()
@@ -309,6 +353,9 @@ func (t *tracer) worker(tick <-chan time.Time) {
 	for {
 		select {
 		case trace := <-t.out:
+			t.filterTrace(trace)
+			t.tagCriticalPath(trace)
+			t.writeDebugSpans(trace.spans)
 			t.sampleFinishedTrace(trace)
 			if len(trace.spans) != 0 {
 				t.traceWriter.add(trace.spans)
@@ -334,6 +381,9 @@ func (t *tracer) worker(tick <-chan time.Time) {
 			for {
 				select {
 				case trace := <-t.out:
+					t.filterTrace(trace)
+					t.tagCriticalPath(trace)
+					t.writeDebugSpans(trace.spans)
 					t.sampleFinishedTrace(trace)
 					if len(trace.spans) != 0 {
 						t.traceWriter.add(trace.spans)
@@ -355,11 +405,13 @@ type finishedTrace struct {
 
 // sampleFinishedTrace applies single-span sampling to the provided trace, which is considered to be finished.
 func (t *tracer) sampleFinishedTrace(info *finishedTrace) {
-	if len(info.spans) > 0 {
-		if p, ok := info.spans[0].context.samplingPriority(); ok && p > 0 {
-			// The trace is kept, no need to run single span sampling rules.
-			return
-		}
+	if len(info.spans) == 0 {
+		// The trace was discarded by the span filter.
+		return
+	}
+	if p, ok := info.spans[0].context.samplingPriority(); ok && p > 0 {
+		// The trace is kept, no need to run single span sampling rules.
+		return
 	}
 	var kept []*span
 	if t.rulesSampling.HasSpanRules() {
@@ -435,7 +487,7 @@ func (t *tracer) StartSpan(operationName string, options ...ddtrace.StartSpanOpt
 	}
 	id := opts.SpanID
 	if id == 0 {
-		id = generateSpanID(startTime)
+		id = t.config.newSpanID(startTime)
 	}
 	// span defaults
 	span := &span{
@@ -470,7 +522,18 @@ func (t *tracer) StartSpan(operationName string, options ...ddtrace.StartSpanOpt
 			}
 		}
 	}
+	var traceID128 [16]byte
+	hasTraceID128 := false
+	if context == nil {
+		if traceID128, hasTraceID128 = t.config.newTraceID128(); hasTraceID128 {
+			span.TraceID = binary.BigEndian.Uint64(traceID128[8:])
+		}
+	}
 	span.context = newSpanContext(span, context)
+	if hasTraceID128 {
+		// keep the full trace ID for W3C trace context propagation
+		setPropagatingTag(span.context, w3cTraceIDTag, hex.EncodeToString(traceID128[:]))
+	}
 	span.setMetric(ext.Pid, float64(t.pid))
 	span.setMeta("language", "go")
 
@@ -500,7 +563,19 @@ func (t *tracer) StartSpan(operationName string, options ...ddtrace.StartSpanOpt
 	if t.config.env != "" {
 		span.setMeta(ext.Environment, t.config.env)
 	}
-	if _, ok := span.context.samplingPriority(); !ok {
+	if context == nil || context.span == nil {
+		// local root span
+		if t.config.containerID != "" {
+			span.setMeta(keyContainerID, t.config.containerID)
+		}
+		if t.config.entityID != "" {
+			span.setMeta(keyEntityID, t.config.entityID)
+		}
+		if t.config.deploymentStage != "" {
+			span.setMeta(keyDeploymentStage, t.config.deploymentStage)
+		}
+	}
+	if _, ok := span.context.samplingPriority(); !ok && !t.config.lateSamplingDecision {
 		// if not already sampled or a brand new trace, sample it
 		t.sample(span)
 	}
@@ -577,13 +652,22 @@ func (t *tracer) Stop() {
 	t.stats.Stop()
 	t.wg.Wait()
 	t.traceWriter.stop()
+	if globalconfig.Statsd() == globalconfig.StatsdClient(t.statsd) {
+		// not replaced by a tracer started since
+		globalconfig.SetStatsd(nil)
+	}
 	t.statsd.Close()
+	if t.config.logRateLimit != nil {
+		log.ResetRate()
+	}
 	appsec.Stop()
 	stopTelemetry()
 }
 
 // Inject uses the configured or default TextMap Propagator.
 func (t *tracer) Inject(ctx ddtrace.SpanContext, carrier interface{}) error {
+	// the sampling decision is propagated, so it can no longer be deferred
+	t.sampleLate(ctx)
 	return t.config.propagator.Inject(ctx, carrier)
 }
 
@@ -609,10 +693,32 @@ func (t *tracer) sample(span *span) {
 	if rs, ok := sampler.(RateSampler); ok && rs.Rate() < 1 {
 		span.setMetric(sampleRateMetricKey, rs.Rate())
 	}
-	if t.rulesSampling.SampleTrace(span) {
+	if !t.rulesSampling.SampleTrace(span) {
+		t.prioritySampling.apply(span)
+	}
+	t.applyRateLimit(span)
+}
+
+// applyRateLimit downgrades the sampling decision of the given root span to drop
+// when keeping its trace would exceed the limit set using WithRateLimit.
+func (t *tracer) applyRateLimit(span *span) {
+	if t.rateLimiter == nil {
+		return
+	}
+	p, ok := span.context.samplingPriority()
+	if !ok || p <= 0 {
+		return
+	}
+	sampled, rate := t.rateLimiter.allowOne(nowTime())
+	span.SetTag(keyRulesSamplerLimiterRate, rate)
+	if sampled {
 		return
 	}
-	t.prioritySampling.apply(span)
+	if p == ext.PriorityUserKeep {
+		span.setSamplingPriority(ext.PriorityUserReject, samplernames.Unknown)
+	} else {
+		span.setSamplingPriority(ext.PriorityAutoReject, samplernames.Unknown)
+	}
 }
 
 func startExecutionTracerTask(ctx gocontext.Context, span *span) (gocontext.Context, func()) {
@@ -19,6 +19,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -29,6 +30,7 @@ import (
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
 	maininternal "gopkg.in/DataDog/dd-trace-go.v1/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/clock"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/telemetry"
@@ -496,11 +498,7 @@ func TestSamplingDecision(t *testing.T) {
 		defer os.Unsetenv("DD_TRACE_SAMPLE_RATE")
 		tracer, _, _, stop := startTestTracer(t)
 		// Don't allow the rate limiter to reset while the test is running.
-		current := time.Now()
-		nowTime = func() time.Time { return current }
-		defer func() {
-			nowTime = func() time.Time { return time.Now() }
-		}()
+		defer clock.Set(clock.NewFake(time.Now()))()
 		defer stop()
 		tracer.config.featureFlags = make(map[string]struct{})
 		tracer.config.serviceName = "test_service"
@@ -637,6 +635,54 @@ func TestTracerRuntimeMetrics(t *testing.T) {
 	})
 }
 
+func TestTracerSharesStatsd(t *testing.T) {
+	var tg testStatsdClient
+	trc, _, _, stop := startTestTracer(t, withStatsdClient(&tg))
+	assert.Equal(t, globalconfig.StatsdClient(trc.statsd), globalconfig.Statsd())
+	stop()
+	assert.Nil(t, globalconfig.Statsd())
+}
+
+func TestTracerHealthMetrics(t *testing.T) {
+	t.Run("on", func(t *testing.T) {
+		tp := new(log.RecordLogger)
+		tp.Ignore("appsec: ", telemetry.LogPrefix)
+		tracer := newTracer(WithHealthMetrics(), WithLogger(tp), WithDebugMode(true))
+		defer tracer.Stop()
+		assert.Contains(t, tp.Logs()[0], "DEBUG: Health metrics enabled")
+	})
+
+	t.Run("env", func(t *testing.T) {
+		os.Setenv("DD_TRACE_HEALTH_METRICS_ENABLED", "true")
+		defer os.Unsetenv("DD_TRACE_HEALTH_METRICS_ENABLED")
+		tp := new(log.RecordLogger)
+		tp.Ignore("appsec: ", telemetry.LogPrefix)
+		tracer := newTracer(WithLogger(tp), WithDebugMode(true))
+		defer tracer.Stop()
+		assert.Contains(t, tp.Logs()[0], "DEBUG: Health metrics enabled")
+	})
+
+	t.Run("overrideEnv", func(t *testing.T) {
+		os.Setenv("DD_TRACE_HEALTH_METRICS_ENABLED", "false")
+		defer os.Unsetenv("DD_TRACE_HEALTH_METRICS_ENABLED")
+		tp := new(log.RecordLogger)
+		tp.Ignore("appsec: ", telemetry.LogPrefix)
+		tracer := newTracer(WithHealthMetrics(), WithLogger(tp), WithDebugMode(true))
+		defer tracer.Stop()
+		assert.Contains(t, tp.Logs()[0], "DEBUG: Health metrics enabled")
+	})
+
+	t.Run("off", func(t *testing.T) {
+		tp := new(log.RecordLogger)
+		tp.Ignore("appsec: ", telemetry.LogPrefix)
+		tracer := newTracer(WithLogger(tp), WithDebugMode(true))
+		defer tracer.Stop()
+		for _, l := range tp.Logs() {
+			assert.NotContains(t, l, "Health metrics enabled")
+		}
+	})
+}
+
 func TestTracerStartSpanOptions(t *testing.T) {
 	tracer := newTracer()
 	defer tracer.Stop()
@@ -647,10 +693,12 @@ func TestTracerStartSpanOptions(t *testing.T) {
 		ResourceName("test.resource"),
 		StartTime(now),
 		WithSpanID(420),
+		SpanKind(ext.SpanKindServer),
 	}
 	span := tracer.StartSpan("web.request", opts...).(*span)
 	assert := assert.New(t)
 	assert.Equal("test", span.Type)
+	assert.Equal(ext.SpanKindServer, span.Meta[ext.SpanKind])
 	assert.Equal("test.service", span.Service)
 	assert.Equal("test.resource", span.Resource)
 	assert.Equal(now.UnixNano(), span.Start)
@@ -659,6 +707,45 @@ func TestTracerStartSpanOptions(t *testing.T) {
 	assert.Equal(1.0, span.Metrics[keyTopLevel])
 }
 
+func TestTracerWithClock(t *testing.T) {
+	current := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(current)
+	defer clock.Set(fake)()
+	transport := newDummyTransport()
+	tracer := newTracer(withTransport(transport), WithFlushJitter(0))
+	internal.SetGlobalTracer(tracer)
+	defer internal.SetGlobalTracer(&internal.NoopTracer{})
+	defer tracer.Stop()
+	// wait for the flush timer to be started
+	fake.BlockUntil(1)
+
+	root := tracer.StartSpan("web.request").(*span)
+	fake.Advance(150 * time.Millisecond)
+	root.AddEvent("checkpoint", nil)
+	fake.Advance(250 * time.Millisecond)
+	root.Finish()
+
+	assert := assert.New(t)
+	assert.Equal(current.UnixNano(), root.Start)
+	assert.Equal(int64(400*time.Millisecond), root.Duration)
+	assert.Equal(root.Start+int64(150*time.Millisecond), root.events[0].TimeUnixNano)
+
+	// explicit start and finish times take precedence over the clock
+	start := current.Add(-time.Second)
+	s := tracer.StartSpan("web.request", StartTime(start)).(*span)
+	s.Finish(FinishTime(current))
+	assert.Equal(start.UnixNano(), s.Start)
+	assert.Equal(int64(time.Second), s.Duration)
+
+	// the traces are only flushed when the clock reaches the flush interval; it is
+	// advanced again in case the worker received the tick before the traces
+	assert.Zero(transport.Len())
+	assert.Eventually(func() bool {
+		fake.Advance(flushInterval)
+		return transport.Len() == 2
+	}, time.Second*timeMultiplicator, 10*time.Millisecond)
+}
+
 func TestTracerStartChildSpan(t *testing.T) {
 	t.Run("own-service", func(t *testing.T) {
 		assert := assert.New(t)
@@ -855,6 +942,55 @@ func TestTracerSpanTags(t *testing.T) {
 	assert.Equal("value", span.Meta["key"])
 }
 
+func TestTracerIDGenerator(t *testing.T) {
+	t.Run("64", func(t *testing.T) {
+		var n uint64
+		tracer, _, _, stop := startTestTracer(t, WithIDGenerator(func() uint64 {
+			return atomic.AddUint64(&n, 1)
+		}))
+		defer stop()
+
+		root := tracer.StartSpan("root").(*span)
+		child := tracer.StartSpan("child", ChildOf(root.Context())).(*span)
+		other := tracer.StartSpan("other").(*span)
+		assert.Equal(t, uint64(1), root.SpanID)
+		assert.Equal(t, uint64(1), root.TraceID)
+		assert.Equal(t, uint64(2), child.SpanID)
+		assert.Equal(t, uint64(1), child.TraceID)
+		assert.Equal(t, uint64(3), other.SpanID)
+		assert.Equal(t, uint64(3), other.TraceID)
+
+		carrier := SQLCommentCarrier{Mode: DBMPropagationModeFull}
+		assert.NoError(t, carrier.Inject(child.Context()))
+		assert.Equal(t, uint64(4), carrier.SpanID)
+	})
+
+	t.Run("zero", func(t *testing.T) {
+		tracer, _, _, stop := startTestTracer(t, WithIDGenerator(func() uint64 { return 0 }))
+		defer stop()
+
+		assert.NotZero(t, tracer.StartSpan("root").(*span).SpanID)
+	})
+
+	t.Run("128", func(t *testing.T) {
+		id := [16]byte{0x0a, 0xf7, 0x65, 0x19, 0x16, 0xcd, 0x43, 0xdd, 0x84, 0x48, 0xeb, 0x21, 0x1c, 0x80, 0x31, 0x9c}
+		tracer, _, _, stop := startTestTracer(t,
+			WithIDGenerator(func() uint64 { return 42 }),
+			WithIDGenerator128(func() [16]byte { return id }),
+		)
+		defer stop()
+
+		root := tracer.StartSpan("root").(*span)
+		assert.Equal(t, uint64(42), root.SpanID)
+		assert.Equal(t, uint64(0x8448eb211c80319c), root.TraceID)
+
+		child := tracer.StartSpan("child", ChildOf(root.Context())).(*span)
+		carrier := TextMapCarrier{}
+		assert.NoError(t, tracer.Inject(child.Context(), carrier))
+		assert.Equal(t, "00-0af7651916cd43dd8448eb211c80319c-000000000000002a-01", carrier[traceparentHeader])
+	})
+}
+
 func TestTracerSpanGlobalTags(t *testing.T) {
 	assert := assert.New(t)
 	tracer := newTracer(WithGlobalTag("key", "value"))
@@ -906,6 +1042,30 @@ func TestTracerSpanServiceMappings(t *testing.T) {
 		s := tracer.StartSpan("web.request").(*span)
 		assert.Equal("new_service", s.Service)
 	})
+
+	t.Run("finish", func(t *testing.T) {
+		tracer, _, _, stop := startTestTracer(t,
+			WithServiceMapping("mysql.db", "orders-mysql"),
+			WithServiceMapping("redis", "orders-redis"))
+		defer stop()
+		s := tracer.StartSpan("mysql.query").(*span)
+		s.SetTag(ext.ServiceName, "mysql.db")
+		s.Finish()
+		assert.Equal("orders-mysql", s.Service)
+
+		s = tracer.StartSpan("redis.command", ServiceName("redis")).(*span)
+		s.Finish()
+		assert.Equal("orders-redis", s.Service)
+	})
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("DD_SERVICE_MAPPING", "mysql.db:orders-mysql,redis:orders-redis")
+		tracer, _, _, stop := startTestTracer(t)
+		defer stop()
+		s := tracer.StartSpan("mysql.query", ServiceName("mysql.db")).(*span)
+		s.Finish()
+		assert.Equal("orders-mysql", s.Service)
+	})
 }
 
 func TestTracerNoDebugStack(t *testing.T) {
@@ -928,6 +1088,60 @@ func TestTracerNoDebugStack(t *testing.T) {
 		s.SetTag(ext.Error, err)
 		assert.Empty(s.Meta[ext.ErrorStack])
 	})
+
+}
+
+func TestTracerWithErrorStackTraces(t *testing.T) {
+	err := errors.New("test error")
+
+	t.Run("default", func(t *testing.T) {
+		tracer := newTracer()
+		defer tracer.Stop()
+		s := tracer.StartSpan("web.request").(*span)
+		s.Finish(WithError(err))
+		assert.NotEmpty(t, s.Meta[ext.ErrorStack])
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		tracer := newTracer(WithErrorStackTraces(false))
+		defer tracer.Stop()
+		s := tracer.StartSpan("web.request").(*span)
+		s.Finish(WithError(err))
+		assert.Equal(t, "test error", s.Meta[ext.ErrorMsg])
+		assert.NotContains(t, s.Meta, ext.ErrorStack)
+
+		s = tracer.StartSpan("web.request").(*span)
+		s.SetTag(ext.Error, err)
+		s.Finish()
+		assert.NotContains(t, s.Meta, ext.ErrorStack)
+	})
+
+	t.Run("per-span", func(t *testing.T) {
+		tracer := newTracer(WithErrorStackTraces(true))
+		defer tracer.Stop()
+		s := tracer.StartSpan("web.request").(*span)
+		s.Finish(WithError(err), NoDebugStack())
+		assert.NotContains(t, s.Meta, ext.ErrorStack)
+	})
+}
+
+func BenchmarkSpanFinishWithError(b *testing.B) {
+	err := errors.New("test error")
+	for name, opt := range map[string]StartOption{
+		"stack-traces":    WithErrorStackTraces(true),
+		"no-stack-traces": WithErrorStackTraces(false),
+	} {
+		b.Run(name, func(b *testing.B) {
+			tracer := newTracer(withTransport(newDummyTransport()), opt)
+			defer tracer.Stop()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				s := tracer.StartSpan("web.request")
+				s.Finish(WithError(err))
+			}
+		})
+	}
 }
 
 // newDefaultTransport return a default transport for this tracing client
@@ -1606,6 +1820,127 @@ func TestTracerReportsHostname(t *testing.T) {
 	})
 }
 
+func TestTracerReportsContainerID(t *testing.T) {
+	const cid = "8c046cb0b72cd4c99f51b5591cd5b095967f58ee003710a45280c28ee1a9c7fa"
+	defer func(old func() string) { containerID = old }(containerID)
+	containerID = func() string { return cid }
+
+	t.Run("detected", func(t *testing.T) {
+		tracer, _, _, stop := startTestTracer(t)
+		defer stop()
+
+		root := tracer.StartSpan("root").(*span)
+		child := tracer.StartSpan("child", ChildOf(root.Context())).(*span)
+		child.Finish()
+		root.Finish()
+
+		assert := assert.New(t)
+		assert.Equal(cid, root.Meta[keyContainerID])
+		_, ok := child.Meta[keyContainerID]
+		assert.False(ok)
+		_, ok = root.Meta[keyEntityID]
+		assert.False(ok)
+	})
+
+	t.Run("WithContainerID", func(t *testing.T) {
+		tracer, _, _, stop := startTestTracer(t, WithContainerID("my-container"))
+		defer stop()
+
+		root := tracer.StartSpan("root").(*span)
+		root.Finish()
+
+		assert.Equal(t, "my-container", root.Meta[keyContainerID])
+	})
+
+	t.Run("DD_ENTITY_ID", func(t *testing.T) {
+		os.Setenv("DD_ENTITY_ID", "en-pod-uid")
+		defer os.Unsetenv("DD_ENTITY_ID")
+
+		tracer, _, _, stop := startTestTracer(t)
+		defer stop()
+
+		root := tracer.StartSpan("root").(*span)
+		child := tracer.StartSpan("child", ChildOf(root.Context())).(*span)
+		child.Finish()
+		root.Finish()
+
+		assert := assert.New(t)
+		assert.Equal("en-pod-uid", root.Meta[keyEntityID])
+		assert.Equal(cid, root.Meta[keyContainerID])
+		_, ok := child.Meta[keyEntityID]
+		assert.False(ok)
+	})
+
+	t.Run("remote-parent", func(t *testing.T) {
+		tracer, _, _, stop := startTestTracer(t)
+		defer stop()
+
+		sctx, err := tracer.Extract(TextMapCarrier{
+			DefaultTraceIDHeader:  "1",
+			DefaultParentIDHeader: "2",
+		})
+		assert.NoError(t, err)
+		sp := tracer.StartSpan("local-root", ChildOf(sctx)).(*span)
+		sp.Finish()
+
+		assert.Equal(t, cid, sp.Meta[keyContainerID])
+	})
+
+	t.Run("headers", func(t *testing.T) {
+		os.Setenv("DD_ENTITY_ID", "en-pod-uid")
+		defer os.Unsetenv("DD_ENTITY_ID")
+
+		c := newConfig(WithContainerID("my-container"))
+		transport := c.transport.(*httpTransport)
+		assert.Equal(t, "my-container", transport.headers["Datadog-Container-ID"])
+		assert.Equal(t, "en-pod-uid", transport.headers["Datadog-Entity-ID"])
+	})
+}
+
+func TestDeploymentStage(t *testing.T) {
+	t.Run("DD_DEPLOYMENT_STAGE", func(t *testing.T) {
+		os.Setenv("DD_DEPLOYMENT_STAGE", "canary")
+		defer os.Unsetenv("DD_DEPLOYMENT_STAGE")
+
+		tracer, _, _, stop := startTestTracer(t)
+		defer stop()
+
+		root := tracer.StartSpan("root").(*span)
+		child := tracer.StartSpan("child", ChildOf(root.Context())).(*span)
+		child.Finish()
+		root.Finish()
+
+		assert := assert.New(t)
+		assert.Equal("canary", root.Meta[keyDeploymentStage])
+		_, ok := child.Meta[keyDeploymentStage]
+		assert.False(ok)
+	})
+
+	t.Run("unset", func(t *testing.T) {
+		tracer, _, _, stop := startTestTracer(t)
+		defer stop()
+
+		root := tracer.StartSpan("root").(*span)
+		root.Finish()
+
+		_, ok := root.Meta[keyDeploymentStage]
+		assert.False(t, ok)
+	})
+
+	t.Run("WithDeploymentStage", func(t *testing.T) {
+		os.Setenv("DD_DEPLOYMENT_STAGE", "canary")
+		defer os.Unsetenv("DD_DEPLOYMENT_STAGE")
+
+		tracer, _, _, stop := startTestTracer(t, WithDeploymentStage("stable"))
+		defer stop()
+
+		root := tracer.StartSpan("root").(*span)
+		root.Finish()
+
+		assert.Equal(t, "stable", root.Meta[keyDeploymentStage])
+	})
+}
+
 func TestVersion(t *testing.T) {
 	t.Run("normal", func(t *testing.T) {
 		tracer, _, _, stop := startTestTracer(t, WithServiceVersion("4.5.6"))
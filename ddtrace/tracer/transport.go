@@ -7,6 +7,7 @@ package tracer
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -107,6 +108,19 @@ func newHTTPTransport(url string, client *http.Client) *httpTransport {
 	}
 }
 
+// newAgentTransport returns a transport sending traces to the agent at the given URL, using
+// the given client, with the headers identifying the container of the tracer.
+func (c *config) newAgentTransport(url string, client *http.Client) *httpTransport {
+	t := newHTTPTransport(url, client)
+	if c.containerID != "" {
+		t.headers["Datadog-Container-ID"] = c.containerID
+	}
+	if c.entityID != "" {
+		t.headers["Datadog-Entity-ID"] = c.entityID
+	}
+	return t
+}
+
 func (t *httpTransport) sendStats(p *statsPayload) error {
 	var buf bytes.Buffer
 	if err := msgp.Encode(&buf, p); err != nil {
@@ -173,13 +187,31 @@ func (t *httpTransport) send(p *payload) (body io.ReadCloser, err error) {
 		response.Body.Close()
 		txt := http.StatusText(code)
 		if n > 0 {
-			return nil, fmt.Errorf("%s (Status: %s)", msg[:n], txt)
+			return nil, &statusError{code: code, msg: fmt.Sprintf("%s (Status: %s)", msg[:n], txt)}
 		}
-		return nil, fmt.Errorf("%s", txt)
+		return nil, &statusError{code: code, msg: txt}
 	}
 	return response.Body, nil
 }
 
+// statusError is returned by the transport when the agent replies with an error status code.
+type statusError struct {
+	code int
+	msg  string
+}
+
+func (e *statusError) Error() string { return e.msg }
+
+// isRetryable reports whether err is a status error which is worth retrying later:
+// a request timeout, too many requests, or any server error.
+func isRetryable(err error) bool {
+	var se *statusError
+	if !errors.As(err, &se) {
+		return false
+	}
+	return se.code == http.StatusRequestTimeout || se.code == http.StatusTooManyRequests || se.code >= 500
+}
+
 func (t *httpTransport) endpoint() string {
 	return t.traceURL
 }
@@ -16,7 +16,9 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -269,6 +271,61 @@ func TestWithHTTPClient(t *testing.T) {
 	assert.Equal(hits, 2)
 }
 
+func TestWithAgentTransport(t *testing.T) {
+	for name, tt := range map[string]struct {
+		opts  []StartOption
+		conns int32 // expected number of connections opened by the flushes
+	}{
+		"keep-alive":  {opts: []StartOption{WithAgentTransport(2, time.Minute)}, conns: 1},
+		"per-request": {opts: []StartOption{WithAgentTransport(0, 0)}, conns: 5},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var conns int32
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.Copy(io.Discard, r.Body)
+			}))
+			srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+				if state == http.StateNew {
+					atomic.AddInt32(&conns, 1)
+				}
+			}
+			srv.Start()
+			defer srv.Close()
+			u, err := url.Parse(srv.URL)
+			require.NoError(t, err)
+
+			c := newConfig(append([]StartOption{WithAgentAddr(u.Host)}, tt.opts...)...)
+			// close the connection used to load the agent features
+			c.httpClient.CloseIdleConnections()
+			atomic.StoreInt32(&conns, 0)
+			for i := 0; i < 5; i++ {
+				p, err := encode(getTestTrace(1, 1))
+				require.NoError(t, err)
+				rc, err := c.transport.send(p)
+				require.NoError(t, err)
+				io.Copy(io.Discard, rc)
+				rc.Close()
+			}
+			assert.Equal(t, tt.conns, atomic.LoadInt32(&conns))
+		})
+	}
+
+	t.Run("client", func(t *testing.T) {
+		rt := &http.Transport{MaxIdleConns: 100}
+		c := newConfig(WithHTTPClient(&http.Client{Transport: rt, Timeout: time.Second}), WithAgentTransport(10, time.Minute))
+		got := c.httpClient.Transport.(*http.Transport)
+		assert.Equal(t, 10, got.MaxIdleConnsPerHost)
+		assert.Equal(t, time.Minute, got.IdleConnTimeout)
+		assert.Equal(t, time.Second, c.httpClient.Timeout)
+		assert.Equal(t, 100, rt.MaxIdleConns) // the given transport is not modified
+	})
+
+	t.Run("default", func(t *testing.T) {
+		c := newConfig()
+		assert.Equal(t, defaultClient, c.httpClient)
+	})
+}
+
 func TestWithUDS(t *testing.T) {
 	// disable instrumentation telemetry to prevent flaky number of requests
 	t.Setenv("DD_INSTRUMENTATION_TELEMETRY_ENABLED", "false")
@@ -305,3 +362,40 @@ func TestWithUDS(t *testing.T) {
 	assert.Len(rt.reqs, 1)
 	assert.Equal(hits, 2)
 }
+
+func TestWithUDSFromEnv(t *testing.T) {
+	// disable instrumentation telemetry to prevent flaky number of requests
+	t.Setenv("DD_INSTRUMENTATION_TELEMETRY_ENABLED", "false")
+	t.Setenv("DD_TRACE_STARTUP_LOGS", "0")
+	assert := assert.New(t)
+	dir, err := os.MkdirTemp("", "socket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	udsPath := filepath.Join(dir, "apm.socket")
+	defer os.RemoveAll(dir)
+	unixListener, err := net.Listen("unix", udsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var traces int32
+	srv := http.Server{Handler: http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/traces") {
+			atomic.AddInt32(&traces, 1)
+		}
+	})}
+	go srv.Serve(unixListener)
+	defer srv.Close()
+
+	t.Setenv("DD_TRACE_AGENT_URL", "unix://"+udsPath)
+	trc := newTracer()
+	defer trc.Stop()
+	assert.True(trc.config.disableHostnameDetection)
+	assert.Equal("http", trc.config.agentURL.Scheme)
+
+	p, err := encode(getTestTrace(1, 1))
+	assert.NoError(err)
+	_, err = trc.config.transport.send(p)
+	assert.NoError(err)
+	assert.EqualValues(1, atomic.LoadInt32(&traces))
+}
@@ -49,8 +49,39 @@ type agentTraceWriter struct {
 
 	// statsd is used to send metrics
 	statsd statsdClient
+
+	// processTags holds the encoded process tags set on the first span of each payload.
+	processTags string
+
+	// mu guards replays
+	mu sync.Mutex
+
+	// replays holds the payloads waiting to be sent again, as enabled by WithPayloadReplay.
+	replays []replay
 }
 
+// replay is a payload which failed to be sent with a retryable error, to be sent again
+// on a later flush.
+type replay struct {
+	p *payload
+	// n is the number of times the payload was replayed so far, plus one.
+	n int
+	// due is the time from which the payload is sent again.
+	due time.Time
+}
+
+const (
+	// maxReplays is the maximum number of payloads waiting to be sent again.
+	maxReplays = 8
+
+	// maxReplayBackoff is the maximum time waited before sending a payload again.
+	maxReplayBackoff = time.Minute
+)
+
+// replayBackoff is the time waited before sending a payload again for the first time,
+// doubled on every subsequent replay; replaced in tests.
+var replayBackoff = time.Second
+
 func newAgentTraceWriter(c *config, s *prioritySampler, statsdClient statsdClient) *agentTraceWriter {
 	return &agentTraceWriter{
 		config:           c,
@@ -58,15 +89,21 @@ func newAgentTraceWriter(c *config, s *prioritySampler, statsdClient statsdClien
 		climit:           make(chan struct{}, concurrentConnectionLimit),
 		prioritySampling: s,
 		statsd:           statsdClient,
+		processTags:      encodeProcessTags(c.processTags),
 	}
 }
 
 func (h *agentTraceWriter) add(trace []*span) {
+	if h.processTags != "" && len(trace) > 0 && h.payload.itemCount() == 0 {
+		// the process tags are only set on the first chunk of the payload, rather than
+		// repeated in each of its chunks
+		trace[0].setMeta(keyProcessTags, h.processTags)
+	}
 	if err := h.payload.push(trace); err != nil {
 		h.statsd.Incr("datadog.tracer.traces_dropped", []string{"reason:encoding_error"}, 1)
 		log.Error("Error encoding msgpack: %v", err)
 	}
-	if h.payload.size() > payloadSizeLimit {
+	if h.payload.size() > h.config.maxPayloadSize {
 		h.statsd.Incr("datadog.tracer.flush_triggered", []string{"reason:size"}, 1)
 		h.flush()
 	}
@@ -76,24 +113,49 @@ func (h *agentTraceWriter) stop() {
 	h.statsd.Incr("datadog.tracer.flush_triggered", []string{"reason:shutdown"}, 1)
 	h.flush()
 	h.wg.Wait()
+	// last chance for the payloads waiting to be sent again, including the ones queued by
+	// the final flush; they are not queued again, having exhausted their replays.
+	for _, r := range h.takeReplays(time.Time{}) {
+		h.send(r.p, h.config.payloadReplays)
+	}
+	h.wg.Wait()
+	for _, r := range h.takeReplays(time.Time{}) {
+		count := r.p.itemCount()
+		h.statsd.Count("datadog.tracer.traces_dropped", int64(count), []string{"reason:send_failed"}, 1)
+		log.Error("lost %d traces: tracer stopped before they could be sent again", count)
+		r.p.clear()
+	}
 }
 
-// flush will push any currently buffered traces to the server.
+// flush will push any currently buffered traces to the server, along with the
+// payloads which are due to be sent again.
 func (h *agentTraceWriter) flush() {
+	for _, r := range h.takeReplays(time.Now()) {
+		h.send(r.p, r.n)
+	}
 	if h.payload.itemCount() == 0 {
 		return
 	}
-	h.wg.Add(1)
-	h.climit <- struct{}{}
 	oldp := h.payload
 	h.payload = newPayload()
-	go func(p *payload) {
+	h.send(oldp, 0)
+}
+
+// send sends p to the agent in the background. replayed is the number of times p was
+// already replayed.
+func (h *agentTraceWriter) send(p *payload, replayed int) {
+	h.wg.Add(1)
+	h.climit <- struct{}{}
+	go func() {
+		var queued bool
 		defer func(start time.Time) {
 			// Once the payload has been used, clear the buffer for garbage
 			// collection to avoid a memory leak when references to this object
 			// may still be kept by faulty transport implementations or the
 			// standard library. See dd-trace-go#976
-			p.clear()
+			if !queued {
+				p.clear()
+			}
 
 			<-h.climit
 			h.wg.Done()
@@ -105,7 +167,8 @@ func (h *agentTraceWriter) flush() {
 		for attempt := 0; attempt <= h.config.sendRetries; attempt++ {
 			size, count = p.size(), p.itemCount()
 			log.Debug("Sending payload: size: %d traces: %d\n", size, count)
-			rc, err := h.config.transport.send(p)
+			var rc io.ReadCloser
+			rc, err = h.config.transport.send(p)
 			if err == nil {
 				log.Debug("sent traces after %d attempts", attempt+1)
 				h.statsd.Count("datadog.tracer.flush_bytes", int64(size), nil, 1)
@@ -113,15 +176,106 @@ func (h *agentTraceWriter) flush() {
 				if err := h.prioritySampling.readRatesJSON(rc); err != nil {
 					h.statsd.Incr("datadog.tracer.decode_error", nil, 1)
 				}
+				h.sendAdditional(p)
 				return
 			}
 			log.Error("failure sending traces (attempt %d), will retry: %v", attempt+1, err)
+			if h.config.healthMetrics {
+				h.statsd.Incr("datadog.tracer.api.errors", nil, 1)
+			}
 			p.reset()
 			time.Sleep(time.Millisecond)
 		}
+		if replayed < h.config.payloadReplays && isRetryable(err) && h.queueReplay(p, replayed+1) {
+			queued = true
+			log.Debug("will send %d traces again on a later flush", count)
+			return
+		}
 		h.statsd.Count("datadog.tracer.traces_dropped", int64(count), []string{"reason:send_failed"}, 1)
 		log.Error("lost %d traces: %v", count, err)
-	}(oldp)
+		h.sendAdditional(p)
+	}()
+}
+
+// queueReplay queues p to be sent again for the nth time, after an exponential backoff.
+// It reports whether p was queued, which it is not when too many payloads are waiting.
+func (h *agentTraceWriter) queueReplay(p *payload, n int) bool {
+	backoff := maxReplayBackoff
+	if shift := n - 1; shift < 32 && replayBackoff<<shift < maxReplayBackoff {
+		backoff = replayBackoff << shift
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.replays) >= maxReplays {
+		return false
+	}
+	h.replays = append(h.replays, replay{p: p, n: n, due: time.Now().Add(backoff)})
+	h.statsd.Incr("datadog.tracer.payloads_replayed", nil, 1)
+	return true
+}
+
+// takeReplays removes and returns the queued payloads which are due at the given time,
+// or all of them when it is zero.
+func (h *agentTraceWriter) takeReplays(now time.Time) []replay {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var due []replay
+	kept := h.replays[:0]
+	for _, r := range h.replays {
+		if now.IsZero() || !now.Before(r.due) {
+			due = append(due, r)
+		} else {
+			kept = append(kept, r)
+		}
+	}
+	h.replays = kept
+	return due
+}
+
+// additionalAgentTimeout is the time given to each additional agent set using
+// WithAdditionalAgentURL to receive a payload, retries included; replaced in tests.
+var additionalAgentTimeout = 10 * time.Second
+
+// sendAdditional sends the payload p, already sent to the main agent, to the additional
+// agents set using WithAdditionalAgentURL. Each agent receives its own copy of p in the
+// background, such that slow or failing agents delay neither the others nor the flushes.
+func (h *agentTraceWriter) sendAdditional(p *payload) {
+	for _, t := range h.config.additionalTransports {
+		h.wg.Add(1)
+		go func(t transport, p *payload) {
+			defer h.wg.Done()
+			done := make(chan error, 1)
+			go func() {
+				defer p.clear()
+				done <- sendWithRetries(t, p, h.config.sendRetries)
+			}()
+			timer := time.NewTimer(additionalAgentTimeout)
+			defer timer.Stop()
+			select {
+			case err := <-done:
+				if err != nil {
+					log.Error("lost %d traces sent to additional agent %s: %v", p.itemCount(), t.endpoint(), err)
+				}
+			case <-timer.C:
+				log.Error("lost %d traces sent to additional agent %s: timed out after %s", p.itemCount(), t.endpoint(), additionalAgentTimeout)
+			}
+		}(t, p.clone())
+	}
+}
+
+// sendWithRetries sends p using t, retrying up to the given number of times on failure.
+func sendWithRetries(t transport, p *payload, retries int) error {
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		p.reset()
+		var rc io.ReadCloser
+		if rc, err = t.send(p); err == nil {
+			rc.Close()
+			return nil
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return err
 }
 
 // logWriter specifies the output target of the logTraceWriter; replaced in tests.
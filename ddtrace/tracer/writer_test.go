@@ -12,8 +12,14 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -416,6 +422,184 @@ func TestTraceWriterFlushRetries(t *testing.T) {
 	}
 }
 
+func TestTraceWriterAPIErrors(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		t.Run(fmt.Sprint(enabled), func(t *testing.T) {
+			p := &failingTransport{failCount: 2, assert: assert.New(t)}
+			c := newConfig(func(c *config) {
+				c.transport = p
+				c.sendRetries = 1
+				c.healthMetrics = enabled
+			})
+			var statsd testStatsdClient
+
+			h := newAgentTraceWriter(c, nil, &statsd)
+			h.add([]*span{makeSpan(0)})
+			h.flush()
+			h.wg.Wait()
+
+			if enabled {
+				assert.Equal(t, int64(2), statsd.Counts()["datadog.tracer.api.errors"])
+			} else {
+				assert.NotContains(t, statsd.Counts(), "datadog.tracer.api.errors")
+			}
+		})
+	}
+}
+
+func TestTraceWriterPayloadReplay(t *testing.T) {
+	defer func(old time.Duration) { replayBackoff = old }(replayBackoff)
+	replayBackoff = 10 * time.Millisecond
+
+	// agent returns a fake agent replying with the given status codes in turn, then 200.
+	agent := func(codes ...int) (*httptest.Server, *int32) {
+		var received int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := atomic.AddInt32(&received, 1)
+			if int(n) <= len(codes) {
+				w.WriteHeader(codes[n-1])
+				return
+			}
+			w.Write([]byte(`{}`))
+		}))
+		return srv, &received
+	}
+	// run sends a payload through srv and flushes until it's no longer waiting to be sent
+	// again, or stops the writer after the given number of flushes.
+	run := func(srv *httptest.Server, replays, flushes int) *testStatsdClient {
+		c := newConfig(func(c *config) {
+			c.transport = newHTTPTransport(srv.URL, defaultClient)
+			c.payloadReplays = replays
+		})
+		var statsd testStatsdClient
+		h := newAgentTraceWriter(c, newPrioritySampler(), &statsd)
+		h.add([]*span{makeSpan(0)})
+		h.flush()
+		h.wg.Wait()
+		for i := 0; i < flushes; i++ {
+			time.Sleep(2 * replayBackoff << i)
+			h.flush()
+			h.wg.Wait()
+		}
+		h.stop()
+		return &statsd
+	}
+
+	t.Run("delivered", func(t *testing.T) {
+		srv, received := agent(http.StatusServiceUnavailable)
+		defer srv.Close()
+		statsd := run(srv, 3, 1)
+
+		assert.EqualValues(t, 2, atomic.LoadInt32(received))
+		assert.EqualValues(t, 1, statsd.Counts()["datadog.tracer.flush_traces"])
+		assert.EqualValues(t, 1, statsd.Counts()["datadog.tracer.payloads_replayed"])
+		assert.NotContains(t, statsd.Counts(), "datadog.tracer.traces_dropped")
+	})
+
+	t.Run("exhausted", func(t *testing.T) {
+		srv, received := agent(http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusRequestTimeout)
+		defer srv.Close()
+		statsd := run(srv, 2, 2)
+
+		assert.EqualValues(t, 3, atomic.LoadInt32(received))
+		assert.EqualValues(t, 2, statsd.Counts()["datadog.tracer.payloads_replayed"])
+		assert.EqualValues(t, 1, statsd.Counts()["datadog.tracer.traces_dropped"])
+		assert.NotContains(t, statsd.Counts(), "datadog.tracer.flush_traces")
+	})
+
+	t.Run("not-retryable", func(t *testing.T) {
+		srv, received := agent(http.StatusBadRequest)
+		defer srv.Close()
+		statsd := run(srv, 3, 1)
+
+		assert.EqualValues(t, 1, atomic.LoadInt32(received))
+		assert.NotContains(t, statsd.Counts(), "datadog.tracer.payloads_replayed")
+		assert.EqualValues(t, 1, statsd.Counts()["datadog.tracer.traces_dropped"])
+	})
+
+	t.Run("shutdown", func(t *testing.T) {
+		srv, received := agent(http.StatusBadGateway)
+		defer srv.Close()
+		// the payload is sent again on stop, before being due
+		statsd := run(srv, 3, 0)
+
+		assert.EqualValues(t, 2, atomic.LoadInt32(received))
+		assert.EqualValues(t, 1, statsd.Counts()["datadog.tracer.flush_traces"])
+	})
+
+	// stop sends a payload through srv on stop only
+	stop := func(srv *httptest.Server) *testStatsdClient {
+		c := newConfig(func(c *config) {
+			c.transport = newHTTPTransport(srv.URL, defaultClient)
+			c.payloadReplays = 3
+		})
+		var statsd testStatsdClient
+		h := newAgentTraceWriter(c, newPrioritySampler(), &statsd)
+		h.add([]*span{makeSpan(0)})
+		h.stop()
+		assert.Empty(t, h.replays)
+		return &statsd
+	}
+
+	t.Run("final-flush", func(t *testing.T) {
+		srv, received := agent(http.StatusServiceUnavailable)
+		defer srv.Close()
+		// the final flush fails, and its payload is sent again before stopping
+		statsd := stop(srv)
+
+		assert.EqualValues(t, 2, atomic.LoadInt32(received))
+		assert.EqualValues(t, 1, statsd.Counts()["datadog.tracer.flush_traces"])
+		assert.NotContains(t, statsd.Counts(), "datadog.tracer.traces_dropped")
+	})
+
+	t.Run("final-flush-dropped", func(t *testing.T) {
+		srv, received := agent(http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+		defer srv.Close()
+		statsd := stop(srv)
+
+		assert.EqualValues(t, 2, atomic.LoadInt32(received))
+		assert.EqualValues(t, 1, statsd.Counts()["datadog.tracer.traces_dropped"])
+		assert.NotContains(t, statsd.Counts(), "datadog.tracer.flush_traces")
+	})
+}
+
+func TestTraceWriterMaxPayloadSize(t *testing.T) {
+	p := newPayload()
+	p.push([]*span{makeSpan(10)})
+	// allow a few traces per payload
+	limit := p.size() * 3
+
+	for name, tc := range map[string]struct {
+		opts    []StartOption
+		flushed bool
+	}{
+		"default": {flushed: false},
+		"small":   {opts: []StartOption{WithMaxPayloadSize(limit)}, flushed: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			transport := newDummyTransport()
+			c := newConfig(append(tc.opts, withTransport(transport))...)
+			var statsd testStatsdClient
+			h := newAgentTraceWriter(c, nil, &statsd)
+			for i := 0; i < 20; i++ {
+				h.add([]*span{makeSpan(10)})
+			}
+			h.wg.Wait()
+
+			statsd.mu.Lock()
+			flushes := statsd.counts["datadog.tracer.flush_triggered"]
+			statsd.mu.Unlock()
+			if !tc.flushed {
+				assert.Zero(t, flushes)
+				assert.Zero(t, transport.Len())
+				return
+			}
+			assert.Greater(t, flushes, int64(1))
+			assert.Greater(t, transport.Len(), 1)
+		})
+	}
+}
+
 func BenchmarkJsonEncodeSpan(b *testing.B) {
 	s := makeSpan(10)
 	s.Metrics["nan"] = math.NaN()
@@ -436,3 +620,104 @@ func BenchmarkJsonEncodeFloat(b *testing.B) {
 		encodeFloat(bs, float64(1e-9))
 	}
 }
+
+// fakeAgent is an agent recording the trace payloads it receives.
+type fakeAgent struct {
+	*httptest.Server
+	mu       sync.Mutex
+	payloads [][]byte
+}
+
+func newFakeAgent(t *testing.T, status int) *fakeAgent {
+	a := new(fakeAgent)
+	a.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v0.4/traces" {
+			return
+		}
+		b, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		a.mu.Lock()
+		a.payloads = append(a.payloads, b)
+		a.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(a.Close)
+	return a
+}
+
+func (a *fakeAgent) received() [][]byte {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.payloads
+}
+
+func TestTraceWriterAdditionalAgents(t *testing.T) {
+	t.Setenv("DD_INSTRUMENTATION_TELEMETRY_ENABLED", "false")
+	for name, status := range map[string]int{
+		"main-ok":     http.StatusOK,
+		"main-failed": http.StatusInternalServerError,
+	} {
+		t.Run(name, func(t *testing.T) {
+			main := newFakeAgent(t, status)
+			failing := newFakeAgent(t, http.StatusServiceUnavailable)
+			second := newFakeAgent(t, http.StatusOK)
+			u, err := url.Parse(main.URL)
+			require.NoError(t, err)
+			c := newConfig(
+				WithAgentAddr(u.Host),
+				WithAdditionalAgentURL(failing.URL),
+				WithAdditionalAgentURL(second.URL+"/"),
+			)
+			require.Len(t, c.additionalTransports, 2)
+
+			h := newAgentTraceWriter(c, newPrioritySampler(), &testStatsdClient{})
+			h.add([]*span{makeSpan(0)})
+			h.flush()
+			h.wg.Wait()
+
+			require.NotEmpty(t, main.received())
+			want := main.received()[0]
+			assert.NotEmpty(t, want)
+			// the failing agent received every attempt, without blocking the next one
+			assert.Len(t, failing.received(), c.sendRetries+1)
+			for _, p := range failing.received() {
+				assert.Equal(t, want, p)
+			}
+			require.Len(t, second.received(), 1)
+			assert.Equal(t, want, second.received()[0])
+		})
+	}
+
+	t.Run("slow", func(t *testing.T) {
+		defer func(old time.Duration) { additionalAgentTimeout = old }(additionalAgentTimeout)
+		additionalAgentTimeout = 50 * time.Millisecond
+
+		main := newFakeAgent(t, http.StatusOK)
+		second := newFakeAgent(t, http.StatusOK)
+		release := make(chan struct{})
+		slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		}))
+		defer slow.Close()
+		defer close(release)
+		u, err := url.Parse(main.URL)
+		require.NoError(t, err)
+		c := newConfig(
+			WithAgentAddr(u.Host),
+			WithAdditionalAgentURL(slow.URL),
+			WithAdditionalAgentURL(second.URL),
+		)
+
+		h := newAgentTraceWriter(c, newPrioritySampler(), &testStatsdClient{})
+		start := time.Now()
+		h.add([]*span{makeSpan(0)})
+		h.flush()
+		h.wg.Wait()
+
+		// the slow agent does not delay the others, and is given up on after the timeout
+		assert.Less(t, time.Since(start), time.Second)
+		require.Len(t, main.received(), 1)
+		require.Len(t, second.received(), 1)
+		assert.Equal(t, main.received()[0], second.received()[0])
+	})
+}
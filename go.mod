@@ -14,6 +14,7 @@ require (
 	github.com/aws/aws-sdk-go v1.34.28
 	github.com/aws/aws-sdk-go-v2 v1.0.0
 	github.com/aws/aws-sdk-go-v2/config v1.0.0
+	github.com/aws/aws-sdk-go-v2/service/sns v1.0.0
 	github.com/aws/aws-sdk-go-v2/service/sqs v1.0.0
 	github.com/aws/smithy-go v1.11.0
 	github.com/bradfitz/gomemcache v0.0.0-20220106215444-fb4bf637b56d
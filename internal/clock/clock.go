@@ -0,0 +1,198 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+// Package clock provides the clock used by the tracer and the integrations to read the
+// current time and to wait. The system clock is used unless tests replace it using Set,
+// e.g. with a Fake clock, in order to control time deterministically.
+package clock
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Clock reads the current time and creates timers.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// NewTimer returns a timer sending the current time on its channel after d.
+	NewTimer(d time.Duration) Timer
+
+	// AfterFunc returns a timer calling f after d. Its channel is nil.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is the equivalent of time.Timer for a Clock.
+type Timer interface {
+	// C returns the channel on which the time is sent when the timer fires.
+	C() <-chan time.Time
+
+	// Stop prevents the timer from firing. It reports whether it was active.
+	Stop() bool
+
+	// Reset changes the timer to fire after d. It reports whether it was active.
+	Reset(d time.Duration) bool
+}
+
+// holder wraps the clock set using Set, since atomic.Value requires a consistent type.
+type holder struct{ c Clock }
+
+var current atomic.Value // holder
+
+// Set makes Now, NewTimer and AfterFunc use c until the returned function is called,
+// which restores the previous clock. It is meant for tests.
+func Set(c Clock) (restore func()) {
+	old := Get()
+	current.Store(holder{c})
+	return func() { current.Store(holder{old}) }
+}
+
+// Get returns the clock set using Set, or nil when the system clock is used.
+func Get() Clock {
+	h, _ := current.Load().(holder)
+	return h.c
+}
+
+// Now returns the current time.
+func Now() time.Time {
+	if c := Get(); c != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+// NewTimer returns a timer sending the current time on its channel after d.
+func NewTimer(d time.Duration) Timer {
+	if c := Get(); c != nil {
+		return c.NewTimer(d)
+	}
+	return systemTimer{time.NewTimer(d)}
+}
+
+// AfterFunc returns a timer calling f in its own goroutine after d.
+func AfterFunc(d time.Duration, f func()) Timer {
+	if c := Get(); c != nil {
+		return c.AfterFunc(d, f)
+	}
+	return systemTimer{time.AfterFunc(d, f)}
+}
+
+type systemTimer struct{ t *time.Timer }
+
+func (t systemTimer) C() <-chan time.Time        { return t.t.C }
+func (t systemTimer) Stop() bool                 { return t.t.Stop() }
+func (t systemTimer) Reset(d time.Duration) bool { return t.t.Reset(d) }
+
+// Fake is a Clock whose time only changes when advanced using Advance, which fires the
+// timers which are then due.
+type Fake struct {
+	mu     sync.Mutex // guards below fields
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFake returns a Fake clock set to the given time.
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+// Now implements Clock.
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// NewTimer implements Clock.
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	return f.add(&fakeTimer{f: f, c: make(chan time.Time, 1)}, d)
+}
+
+// AfterFunc implements Clock. Unlike with the system clock, f is called by Advance.
+func (f *Fake) AfterFunc(d time.Duration, fn func()) Timer {
+	return f.add(&fakeTimer{f: f, fn: fn}, d)
+}
+
+func (f *Fake) add(t *fakeTimer, d time.Duration) *fakeTimer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t.when = f.now.Add(d)
+	f.timers = append(f.timers, t)
+	return t
+}
+
+// BlockUntil blocks until at least n timers are waiting to fire, e.g. to ensure that a
+// goroutine started a timer before advancing the clock.
+func (f *Fake) BlockUntil(n int) {
+	for {
+		f.mu.Lock()
+		pending := len(f.timers)
+		f.mu.Unlock()
+		if pending >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Advance moves the time of the clock forward by d, and fires the timers which are due,
+// in the order of their deadlines.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	f.now = f.now.Add(d)
+	now := f.now
+	var due, kept []*fakeTimer
+	for _, t := range f.timers {
+		if t.when.After(now) {
+			kept = append(kept, t)
+		} else {
+			due = append(due, t)
+		}
+	}
+	f.timers = kept
+	sort.SliceStable(due, func(i, j int) bool { return due[i].when.Before(due[j].when) })
+	f.mu.Unlock()
+
+	for _, t := range due {
+		if t.fn != nil {
+			t.fn()
+			continue
+		}
+		select {
+		case t.c <- now:
+		default:
+		}
+	}
+}
+
+type fakeTimer struct {
+	f    *Fake
+	c    chan time.Time
+	fn   func()
+	when time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+
+func (t *fakeTimer) Stop() bool {
+	t.f.mu.Lock()
+	defer t.f.mu.Unlock()
+	for i, ft := range t.f.timers {
+		if ft == t {
+			t.f.timers = append(t.f.timers[:i], t.f.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	active := t.Stop()
+	t.f.add(t, d)
+	return active
+}
@@ -0,0 +1,52 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSet(t *testing.T) {
+	assert.Nil(t, Get())
+	start := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	fake := NewFake(start)
+	restore := Set(fake)
+	assert.Equal(t, fake, Get())
+	assert.Equal(t, start, Now())
+
+	restore()
+	assert.Nil(t, Get())
+	assert.WithinDuration(t, time.Now(), Now(), time.Minute)
+}
+
+func TestFake(t *testing.T) {
+	start := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	fake := NewFake(start)
+	defer Set(fake)()
+
+	timer := NewTimer(time.Second)
+	var calls []string
+	AfterFunc(2*time.Second, func() { calls = append(calls, "after") })
+	stopped := AfterFunc(time.Second, func() { calls = append(calls, "stopped") })
+	assert.True(t, stopped.Stop())
+	assert.False(t, stopped.Stop())
+
+	fake.BlockUntil(2)
+	fake.Advance(999 * time.Millisecond)
+	assert.Len(t, timer.C(), 0)
+	fake.Advance(time.Millisecond)
+	assert.Equal(t, start.Add(time.Second), <-timer.C())
+	assert.Empty(t, calls)
+
+	assert.False(t, timer.Reset(time.Second))
+	fake.Advance(time.Second)
+	assert.Equal(t, start.Add(2*time.Second), <-timer.C())
+	assert.Equal(t, []string{"after"}, calls)
+	assert.Equal(t, start.Add(2*time.Second), fake.Now())
+}
@@ -24,6 +24,7 @@ type config struct {
 	analyticsRate float64
 	serviceName   string
 	runtimeID     string
+	statsd        StatsdClient
 }
 
 // AnalyticsRate returns the sampling rate at which events should be marked. It uses
@@ -62,3 +63,24 @@ func RuntimeID() string {
 	defer cfg.mu.RUnlock()
 	return cfg.runtimeID
 }
+
+// StatsdClient is the subset of the DogStatsD client of the tracer which integrations
+// can use to send their own metrics.
+type StatsdClient interface {
+	Gauge(name string, value float64, tags []string, rate float64) error
+}
+
+// Statsd returns the DogStatsD client of the running tracer, or nil when no tracer
+// is running.
+func Statsd() StatsdClient {
+	cfg.mu.RLock()
+	defer cfg.mu.RUnlock()
+	return cfg.statsd
+}
+
+// SetStatsd sets the DogStatsD client of the running tracer.
+func SetStatsd(c StatsdClient) {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	cfg.statsd = c
+}
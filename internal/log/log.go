@@ -96,6 +96,9 @@ var (
 	erragg  = map[string]*errorReport{} // aggregated errors
 	errrate = time.Minute               // the rate at which errors are reported
 	erron   bool                        // true if errors are being aggregated
+
+	// baserate is the rate at which errors are reported unless set using SetRate.
+	baserate = time.Minute
 )
 
 func init() {
@@ -106,6 +109,23 @@ func init() {
 			errrate = time.Duration(sec) * time.Second
 		}
 	}
+	baserate = errrate
+}
+
+// SetRate sets the rate at which aggregated errors are reported, overriding the value
+// of DD_LOGGING_RATE. A zero rate causes errors to be reported as they occur.
+func SetRate(d time.Duration) {
+	errmu.Lock()
+	defer errmu.Unlock()
+	errrate = d
+}
+
+// ResetRate restores the rate at which aggregated errors are reported to the value of
+// DD_LOGGING_RATE, or to one minute if it is not set.
+func ResetRate() {
+	errmu.Lock()
+	defer errmu.Unlock()
+	errrate = baserate
 }
 
 type errorReport struct {
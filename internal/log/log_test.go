@@ -115,6 +115,19 @@ func TestLog(t *testing.T) {
 			assert.Len(t, tp.Lines(), 1)
 		})
 
+		t.Run("rate", func(t *testing.T) {
+			tp.Reset()
+			defer func(old time.Duration) { errrate = old }(errrate)
+			SetRate(10 * time.Millisecond)
+
+			Error("sixth message %d", 1)
+			Error("sixth message %d", 2)
+			Error("sixth message %d", 3)
+			assert.Len(t, tp.Lines(), 0)
+			assert.Eventually(t, func() bool { return len(tp.Lines()) == 1 }, time.Second, time.Millisecond)
+			assert.True(t, hasMsg("ERROR", "sixth message 1, 2 additional messages skipped", tp.Lines()), tp.Lines())
+		})
+
 		t.Run("instant", func(t *testing.T) {
 			tp.Reset()
 			defer func(old time.Duration) { errrate = old }(errrate)
@@ -0,0 +1,54 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+// Package namingschema allows integrations to follow the naming schema selected using
+// the DD_TRACE_SPAN_ATTRIBUTE_SCHEMA environment variable, which determines how their
+// spans and services are named.
+package namingschema
+
+import (
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+)
+
+// Version is a version of the naming schema.
+type Version int32
+
+const (
+	// VersionV0 is the original naming schema, used by default.
+	VersionV0 Version = iota
+	// VersionV1 is the naming schema in which client spans use the service of the
+	// application and tag the service they call in the "peer.service" tag.
+	VersionV1
+)
+
+var version = int32(parseVersion(os.Getenv("DD_TRACE_SPAN_ATTRIBUTE_SCHEMA")))
+
+// parseVersion returns the version named v, defaulting to VersionV0.
+func parseVersion(v string) Version {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "", "v0":
+		return VersionV0
+	case "v1":
+		return VersionV1
+	default:
+		log.Warn("DD_TRACE_SPAN_ATTRIBUTE_SCHEMA=%s is not a valid naming schema version, using v0.", v)
+		return VersionV0
+	}
+}
+
+// GetVersion returns the naming schema version in use.
+func GetVersion() Version {
+	return Version(atomic.LoadInt32(&version))
+}
+
+// SetVersion sets the naming schema version in use and returns the previous one.
+// It is meant to be used in tests.
+func SetVersion(v Version) Version {
+	return Version(atomic.SwapInt32(&version, int32(v)))
+}
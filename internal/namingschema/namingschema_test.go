@@ -0,0 +1,33 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package namingschema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseVersion(t *testing.T) {
+	for in, want := range map[string]Version{
+		"":      VersionV0,
+		"v0":    VersionV0,
+		"v1":    VersionV1,
+		" V1 ":  VersionV1,
+		"v2":    VersionV0,
+		"bogus": VersionV0,
+	} {
+		assert.Equal(t, want, parseVersion(in), in)
+	}
+}
+
+func TestSetVersion(t *testing.T) {
+	prev := SetVersion(VersionV1)
+	defer SetVersion(prev)
+	assert.Equal(t, VersionV1, GetVersion())
+	assert.Equal(t, VersionV1, SetVersion(VersionV0))
+	assert.Equal(t, VersionV0, GetVersion())
+}